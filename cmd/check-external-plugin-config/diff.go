@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// diffCommand is the name of the subcommand which reports the effective configuration changes.
+	diffCommand = "diff"
+	// markdownOutput specifies printing the changes as markdown tables, which can be posted as a PR comment.
+	markdownOutput = "markdown"
+)
+
+// diffOptions specifies command line parameters of the diff subcommand.
+type diffOptions struct {
+	oldConfigPath string
+	newConfigPath string
+	output        string
+}
+
+func (o *diffOptions) DefaultAndValidate() error {
+	if o.oldConfigPath == "" {
+		return errors.New("required flag --old-config-path was unset")
+	}
+	if o.newConfigPath == "" {
+		return errors.New("required flag --new-config-path was unset")
+	}
+	if o.output != markdownOutput && o.output != jsonOutput {
+		return fmt.Errorf("invalid output format %s, it must be %s or %s", o.output, markdownOutput, jsonOutput)
+	}
+	return nil
+}
+
+func (o *diffOptions) gatherOptions(flag *flag.FlagSet, args []string) error {
+	flag.StringVar(&o.oldConfigPath, "old-config-path", "", "Path to the old external_plugin_config.yaml.")
+	flag.StringVar(&o.newConfigPath, "new-config-path", "", "Path to the new external_plugin_config.yaml.")
+	flag.StringVar(&o.output, "output", markdownOutput, "Output format of the changes, markdown or json.")
+
+	if err := flag.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %v", err)
+	}
+	if err := o.DefaultAndValidate(); err != nil {
+		return fmt.Errorf("invalid options: %v", err)
+	}
+
+	return nil
+}
+
+// loadConfig loads the external plugin config without validating it.
+func loadConfig(path string) (*externalplugins.Configuration, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &externalplugins.Configuration{}
+	if err := yaml.Unmarshal(bytes, config); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// diff reports the effective configuration changes between the old and new config to w.
+func diff(o diffOptions, w io.Writer) error {
+	oldConfig, err := loadConfig(o.oldConfigPath)
+	if err != nil {
+		return err
+	}
+	newConfig, err := loadConfig(o.newConfigPath)
+	if err != nil {
+		return err
+	}
+
+	diffs := externalplugins.Diff(oldConfig, newConfig)
+	if o.output == jsonOutput {
+		if diffs == nil {
+			diffs = []externalplugins.ConfigDiff{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diffs)
+	}
+
+	writeMarkdown(diffs, w)
+	return nil
+}
+
+// writeMarkdown writes the changes as a markdown table for each org/repo and branch.
+func writeMarkdown(diffs []externalplugins.ConfigDiff, w io.Writer) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No effective configuration changes.")
+		return
+	}
+
+	var heading string
+	for _, d := range diffs {
+		current := fmt.Sprintf("### %s", d.OrgRepo)
+		if len(d.Branch) != 0 {
+			current += fmt.Sprintf(" (branch `%s`)", d.Branch)
+		}
+		if current != heading {
+			if len(heading) != 0 {
+				fmt.Fprintln(w)
+			}
+			heading = current
+			fmt.Fprintf(w, "%s\n\n| Plugin | Field | Old | New |\n| --- | --- | --- | --- |\n", heading)
+		}
+
+		switch d.Change {
+		case externalplugins.ConfigAdded:
+			fmt.Fprintf(w, "| %s | | not configured | configured |\n", d.Plugin)
+		case externalplugins.ConfigRemoved:
+			fmt.Fprintf(w, "| %s | | configured | not configured |\n", d.Plugin)
+		}
+		for _, field := range d.Fields {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", d.Plugin, field.Field, markdownValue(field.Old),
+				markdownValue(field.New))
+		}
+	}
+}
+
+// markdownValue formats the JSON value as inline code in a table cell.
+func markdownValue(value string) string {
+	if len(value) == 0 {
+		return "*unset*"
+	}
+	return "`" + strings.ReplaceAll(value, "|", "\\|") + "`"
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestDiffOptions(t *testing.T) {
+	testcases := []struct {
+		name string
+		args []string
+
+		expectedError  string
+		expectedOption *diffOptions
+	}{
+		{
+			name: "no new config path",
+			args: []string{
+				"--old-config-path=/tmp/old.yaml",
+			},
+
+			expectedError:  "invalid options: required flag --new-config-path was unset",
+			expectedOption: nil,
+		},
+		{
+			name: "has both config paths",
+			args: []string{
+				"--old-config-path=/tmp/old.yaml",
+				"--new-config-path=/tmp/new.yaml",
+			},
+
+			expectedError: "",
+			expectedOption: &diffOptions{
+				oldConfigPath: "/tmp/old.yaml",
+				newConfigPath: "/tmp/new.yaml",
+				output:        "markdown",
+			},
+		},
+		{
+			name: "invalid output",
+			args: []string{
+				"--old-config-path=/tmp/old.yaml",
+				"--new-config-path=/tmp/new.yaml",
+				"--output=text",
+			},
+
+			expectedError:  "invalid options: invalid output format text, it must be markdown or json",
+			expectedOption: nil,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			flags := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			var actualOptions diffOptions
+
+			err := actualOptions.gatherOptions(flags, tc.args)
+
+			if err != nil {
+				if err.Error() != tc.expectedError {
+					t.Errorf("expected error %#v but got %#v", tc.expectedError, err.Error())
+				}
+			} else {
+				if !reflect.DeepEqual(&actualOptions, tc.expectedOption) {
+					t.Errorf("expected options %#v but got %#v", tc.expectedOption, actualOptions)
+				}
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	testcases := []struct {
+		name          string
+		oldConfigPath string
+		newConfigPath string
+
+		expectedOutput string
+	}{
+		{
+			name:          "no changes",
+			oldConfigPath: "testdata/diff_old.yaml",
+			newConfigPath: "testdata/diff_old.yaml",
+
+			expectedOutput: "No effective configuration changes.\n",
+		},
+		{
+			name:          "changes",
+			oldConfigPath: "testdata/diff_old.yaml",
+			newConfigPath: "testdata/diff_new.yaml",
+
+			expectedOutput: "### ti-community-infra\n" +
+				"\n" +
+				"| Plugin | Field | Old | New |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| ti-community-blunderbuss | max_request_count | `2` | `3` |\n" +
+				"\n" +
+				"### ti-community-infra/test-dev\n" +
+				"\n" +
+				"| Plugin | Field | Old | New |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| ti-community-blunderbuss | max_request_count | `2` | `3` |\n" +
+				"\n" +
+				"### ti-community-infra/test-dev (branch `release-*`)\n" +
+				"\n" +
+				"| Plugin | Field | Old | New |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| ti-community-blunderbuss | max_request_count | `2` | `1` |\n" +
+				"\n" +
+				"### ti-community-infra/test-live\n" +
+				"\n" +
+				"| Plugin | Field | Old | New |\n" +
+				"| --- | --- | --- | --- |\n" +
+				"| ti-community-lgtm | | not configured | configured |\n" +
+				"| ti-community-lgtm | pull_owners_endpoint | *unset* | " +
+				"`\"https://prow-dev.tidb.io/ti-community-owners\"` |\n" +
+				"| ti-community-blunderbuss | max_request_count | `2` | `3` |\n" +
+				"| ti-community-label | | configured | not configured |\n" +
+				"| ti-community-label | prefixes | `[\"type\"]` | *unset* |\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			o := diffOptions{
+				oldConfigPath: tc.oldConfigPath,
+				newConfigPath: tc.newConfigPath,
+				output:        markdownOutput,
+			}
+			var out bytes.Buffer
+			if err := diff(o, &out); err != nil {
+				t.Fatalf("diff failed: %v", err)
+			}
+			if out.String() != tc.expectedOutput {
+				t.Errorf("Different output: Got \"%v\" expected \"%v\"", out.String(), tc.expectedOutput)
+			}
+		})
+	}
+}
@@ -1,26 +1,43 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/plugins"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// textOutput specifies printing the lint problems one per line.
+	textOutput = "text"
+	// jsonOutput specifies printing the lint problems as JSON.
+	jsonOutput = "json"
+)
+
 // options specifies command line parameters.
 type options struct {
 	externalPluginConfigPath string
+
+	lint             bool
+	pluginConfigPath string
+	output           string
 }
 
 func (o *options) DefaultAndValidate() error {
 	if o.externalPluginConfigPath == "" {
 		return errors.New("required flag --external-plugin-config-path was unset")
 	}
+	if o.output != textOutput && o.output != jsonOutput {
+		return fmt.Errorf("invalid output format %s, it must be %s or %s", o.output, textOutput, jsonOutput)
+	}
 	return nil
 }
 
@@ -38,6 +55,10 @@ func parseOptions() (options, error) {
 func (o *options) gatherOptions(flag *flag.FlagSet, args []string) error {
 	flag.StringVar(&o.externalPluginConfigPath, "external-plugin-config-path", "",
 		"Path to external_plugin_config.yaml.")
+	flag.BoolVar(&o.lint, "lint", false, "Report all the problems of the config instead of the first error.")
+	flag.StringVar(&o.pluginConfigPath, "plugin-config-path", "",
+		"Path to the prow plugins.yaml, lint warns about the repos not enabled or not configured if it is set.")
+	flag.StringVar(&o.output, "output", textOutput, "Output format of the lint problems, text or json.")
 
 	if err := flag.Parse(args); err != nil {
 		return fmt.Errorf("parse flags: %v", err)
@@ -50,11 +71,33 @@ func (o *options) gatherOptions(flag *flag.FlagSet, args []string) error {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == diffCommand {
+		o := diffOptions{}
+		if err := o.gatherOptions(flag.NewFlagSet(diffCommand, flag.ExitOnError), os.Args[2:]); err != nil {
+			logrus.Fatalf("Error parsing options - %v", err)
+		}
+		if err := diff(o, os.Stdout); err != nil {
+			logrus.WithError(err).Fatal("Diff failed.")
+		}
+		return
+	}
+
 	o, err := parseOptions()
 	if err != nil {
 		logrus.Fatalf("Error parsing options - %v", err)
 	}
 
+	if o.lint {
+		passed, err := lint(o, os.Stdout)
+		if err != nil {
+			logrus.WithError(err).Fatal("Lint failed.")
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := validate(o); err != nil {
 		logrus.WithError(err).Fatal("Validation failed.")
 	} else {
@@ -75,3 +118,56 @@ func validate(o options) error {
 
 	return config.Validate()
 }
+
+// lintResult is the JSON output of the lint.
+type lintResult struct {
+	File     string                    `json:"file"`
+	Problems []externalplugins.Problem `json:"problems"`
+}
+
+// lint reports all the problems of the config to w, it returns false if there is any error.
+func lint(o options, w io.Writer) (bool, error) {
+	bytes, err := ioutil.ReadFile(o.externalPluginConfigPath)
+	if err != nil {
+		return false, err
+	}
+
+	var pc *plugins.Configuration
+	if o.pluginConfigPath != "" {
+		pluginBytes, err := ioutil.ReadFile(o.pluginConfigPath)
+		if err != nil {
+			return false, err
+		}
+		pc = &plugins.Configuration{}
+		if err := yaml.Unmarshal(pluginBytes, pc); err != nil {
+			return false, fmt.Errorf("parse %s: %v", o.pluginConfigPath, err)
+		}
+	}
+
+	problems := externalplugins.Lint(bytes, pc)
+	passed := true
+	for _, problem := range problems {
+		if problem.Severity == externalplugins.ErrorSeverity {
+			passed = false
+		}
+	}
+
+	if o.output == jsonOutput {
+		if problems == nil {
+			problems = []externalplugins.Problem{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return passed, encoder.Encode(lintResult{File: o.externalPluginConfigPath, Problems: problems})
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(w, "%s:%d:%d: %s: %s", o.externalPluginConfigPath, problem.Line, problem.Column,
+			problem.Severity, problem.Message)
+		if problem.Path != "" {
+			fmt.Fprintf(w, " (%s)", problem.Path)
+		}
+		fmt.Fprintln(w)
+	}
+	return passed, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"reflect"
 	"testing"
@@ -30,8 +31,36 @@ func TestOptions(t *testing.T) {
 			expectedError: "",
 			expectedOption: &options{
 				externalPluginConfigPath: "/etc/external_plugin_config.yaml",
+				output:                   "text",
 			},
 		},
+		{
+			name: "lint with json output",
+			args: []string{
+				"--external-plugin-config-path=/etc/external_plugin_config.yaml",
+				"--lint",
+				"--plugin-config-path=/etc/plugins.yaml",
+				"--output=json",
+			},
+
+			expectedError: "",
+			expectedOption: &options{
+				externalPluginConfigPath: "/etc/external_plugin_config.yaml",
+				lint:                     true,
+				pluginConfigPath:         "/etc/plugins.yaml",
+				output:                   "json",
+			},
+		},
+		{
+			name: "invalid output",
+			args: []string{
+				"--external-plugin-config-path=/etc/external_plugin_config.yaml",
+				"--output=xml",
+			},
+
+			expectedError:  "invalid options: invalid output format xml, it must be text or json",
+			expectedOption: nil,
+		},
 	}
 
 	for _, testcase := range testcases {
@@ -77,3 +106,63 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestLint(t *testing.T) {
+	testcases := []struct {
+		name   string
+		output string
+
+		expectedPassed bool
+		expectedOutput string
+	}{
+		{
+			name:   "text output",
+			output: textOutput,
+
+			expectedPassed: false,
+			expectedOutput: "testdata/lint.yaml:9:5: error: unknown field \"exclude_labels\", " +
+				"did you mean \"excludeLabels\" (ti-community-cherrypicker[0].exclude_labels)\n",
+		},
+		{
+			name:   "json output",
+			output: jsonOutput,
+
+			expectedPassed: false,
+			expectedOutput: `{
+  "file": "testdata/lint.yaml",
+  "problems": [
+    {
+      "severity": "error",
+      "line": 9,
+      "column": 5,
+      "path": "ti-community-cherrypicker[0].exclude_labels",
+      "message": "unknown field \"exclude_labels\", did you mean \"excludeLabels\""
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			o := options{
+				externalPluginConfigPath: "testdata/lint.yaml",
+				lint:                     true,
+				output:                   tc.output,
+			}
+			var out bytes.Buffer
+			passed, err := lint(o, &out)
+			if err != nil {
+				t.Fatalf("lint failed: %v", err)
+			}
+			if passed != tc.expectedPassed {
+				t.Errorf("Different passed: Got \"%v\" expected \"%v\"", passed, tc.expectedPassed)
+			}
+			if out.String() != tc.expectedOutput {
+				t.Errorf("Different output: Got \"%v\" expected \"%v\"", out.String(), tc.expectedOutput)
+			}
+		})
+	}
+}
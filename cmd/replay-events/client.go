@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+// fakeGitHubClient serves the GitHub API reads from the state seeded by the event, and records the
+// mutations instead of performing them.
+type fakeGitHubClient struct {
+	*fakegithub.FakeClient
+
+	mut       sync.Mutex
+	mutations []string
+}
+
+// newFakeGitHubClient creates a fake GitHub client seeded with the issue or pull request in the payload.
+func newFakeGitHubClient(payload []byte, repoLabels []string) (*fakeGitHubClient, error) {
+	var event struct {
+		Repo        github.Repo         `json:"repository"`
+		Issue       *github.Issue       `json:"issue"`
+		PullRequest *github.PullRequest `json:"pull_request"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+
+	fc := fakegithub.NewFakeClient()
+	// The labels are not checked against the repo labels if they are not given.
+	if len(repoLabels) != 0 {
+		fc.RepoLabelsExisting = repoLabels
+	}
+	org, repo := event.Repo.Owner.Login, event.Repo.Name
+	seedLabels := func(number int, labels []github.Label) {
+		for _, label := range labels {
+			fc.IssueLabelsExisting = append(fc.IssueLabelsExisting,
+				fmt.Sprintf("%s/%s#%d:%s", org, repo, number, label.Name))
+		}
+	}
+
+	if pr := event.PullRequest; pr != nil {
+		fc.PullRequests[pr.Number] = pr
+		seedLabels(pr.Number, pr.Labels)
+	}
+	if issue := event.Issue; issue != nil {
+		fc.Issues[issue.Number] = issue
+		// The pull request in the payload is more detailed than the issue of it.
+		if _, ok := fc.PullRequests[issue.Number]; !ok {
+			if issue.IsPullRequest() {
+				fc.PullRequests[issue.Number] = &github.PullRequest{
+					Number:  issue.Number,
+					HTMLURL: issue.HTMLURL,
+					User:    issue.User,
+					Title:   issue.Title,
+					Body:    issue.Body,
+					State:   issue.State,
+					Labels:  issue.Labels,
+					Base:    github.PullRequestBranch{Repo: event.Repo},
+				}
+			}
+			seedLabels(issue.Number, issue.Labels)
+		}
+	}
+	return &fakeGitHubClient{FakeClient: fc}, nil
+}
+
+// record records a mutation, which is printed as it is.
+func (f *fakeGitHubClient) record(format string, args ...interface{}) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.mutations = append(f.mutations, fmt.Sprintf(format, args...))
+}
+
+// Mutations returns the mutations recorded in the order they were performed.
+func (f *fakeGitHubClient) Mutations() []string {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return append([]string(nil), f.mutations...)
+}
+
+func (f *fakeGitHubClient) AddLabel(org, repo string, number int, label string) error {
+	return f.AddLabels(org, repo, number, label)
+}
+
+func (f *fakeGitHubClient) AddLabels(org, repo string, number int, labels ...string) error {
+	if err := f.FakeClient.AddLabels(org, repo, number, labels...); err != nil {
+		return err
+	}
+	for _, label := range labels {
+		f.record("add label %s/%s#%d: %s", org, repo, number, label)
+	}
+	return nil
+}
+
+func (f *fakeGitHubClient) RemoveLabel(org, repo string, number int, label string) error {
+	if err := f.FakeClient.RemoveLabel(org, repo, number, label); err != nil {
+		return err
+	}
+	f.record("remove label %s/%s#%d: %s", org, repo, number, label)
+	return nil
+}
+
+func (f *fakeGitHubClient) CreateComment(org, repo string, number int, comment string) error {
+	if err := f.FakeClient.CreateComment(org, repo, number, comment); err != nil {
+		return err
+	}
+	f.record("create comment %s/%s#%d:\n%s", org, repo, number, indent(comment))
+	return nil
+}
+
+func (f *fakeGitHubClient) EditComment(org, repo string, id int, comment string) error {
+	if err := f.FakeClient.EditComment(org, repo, id, comment); err != nil {
+		return err
+	}
+	f.record("edit comment %s/%s comment %d:\n%s", org, repo, id, indent(comment))
+	return nil
+}
+
+func (f *fakeGitHubClient) DeleteComment(org, repo string, id int) error {
+	if err := f.FakeClient.DeleteComment(org, repo, id); err != nil {
+		return err
+	}
+	f.record("delete comment %s/%s comment %d", org, repo, id)
+	return nil
+}
+
+func (f *fakeGitHubClient) DeleteStaleComments(org, repo string, number int,
+	comments []github.IssueComment, isStale func(github.IssueComment) bool) error {
+	if comments == nil {
+		comments, _ = f.ListIssueComments(org, repo, number)
+	}
+	for _, comment := range comments {
+		if isStale(comment) {
+			if err := f.DeleteComment(org, repo, comment.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitHubClient) RequestReview(org, repo string, number int, logins []string) error {
+	f.record("request review %s/%s#%d: %s", org, repo, number, strings.Join(logins, ", "))
+	return nil
+}
+
+func (f *fakeGitHubClient) AssignIssue(org, repo string, number int, logins []string) error {
+	if err := f.FakeClient.AssignIssue(org, repo, number, logins); err != nil {
+		return err
+	}
+	f.record("assign %s/%s#%d: %s", org, repo, number, strings.Join(logins, ", "))
+	return nil
+}
+
+func (f *fakeGitHubClient) CreateStatus(org, repo, sha string, s github.Status) error {
+	if err := f.FakeClient.CreateStatus(org, repo, sha, s); err != nil {
+		return err
+	}
+	f.record("create status %s/%s@%s: %s is %s", org, repo, sha, s.Context, s.State)
+	return nil
+}
+
+func (f *fakeGitHubClient) UpdatePullRequestBranch(org, repo string, number int, _ *string) error {
+	f.record("update branch %s/%s#%d", org, repo, number)
+	return nil
+}
+
+func (f *fakeGitHubClient) UnrequestReview(org, repo string, number int, logins []string) error {
+	f.record("unrequest review %s/%s#%d: %s", org, repo, number, strings.Join(logins, ", "))
+	return nil
+}
+
+// indent indents the lines of the comment for printing.
+func indent(comment string) string {
+	return "    " + strings.ReplaceAll(comment, "\n", "\n    ")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/autoresponder"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/blunderbuss"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/contribution"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/label"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/labelblocker"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/lgtm"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/merge"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/tars"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+)
+
+// replayablePlugins specifies the plugins whose handlers can be replayed. The cherrypicker is not
+// included because it pushes to the git repos instead of calling the GitHub API only.
+var replayablePlugins = sets.NewString(
+	autoresponder.PluginName,
+	blunderbuss.PluginName,
+	contribution.PluginName,
+	label.PluginName,
+	labelblocker.PluginName,
+	lgtm.PluginName,
+	merge.PluginName,
+	tars.PluginName,
+)
+
+type options struct {
+	plugin                string
+	externalPluginsConfig string
+	events                string
+
+	ownersFile string
+	repoLabels prowflagutil.Strings
+
+	timeout time.Duration
+}
+
+// validate validates the plugin and the paths.
+func (o *options) validate() error {
+	if !replayablePlugins.Has(o.plugin) {
+		return fmt.Errorf("unknown plugin %q, the plugin must be one of %v", o.plugin, replayablePlugins.List())
+	}
+	if len(o.externalPluginsConfig) == 0 {
+		return errors.New("required flag --external-plugins-config was unset")
+	}
+	if len(o.events) == 0 {
+		return errors.New("required flag --events was unset")
+	}
+	if o.timeout <= 0 {
+		return fmt.Errorf("timeout must be greater than 0, got %v", o.timeout)
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{repoLabels: prowflagutil.NewStrings()}
+	fs.StringVar(&o.plugin, "plugin", "", "Name of the plugin whose handlers the events are replayed against.")
+	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
+		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
+	fs.StringVar(&o.events, "events", "",
+		"Path to a recorded event, or a directory of the recorded events which are replayed in order.")
+	fs.StringVar(&o.ownersFile, "owners-file", "",
+		"Path to a JSON file of the owners returned for all the PRs, the owners endpoint is requested if it is unset.")
+	fs.Var(&o.repoLabels, "repo-label",
+		"Name of a label existing in the repo, can be passed multiple times. All labels exist if it is unset.")
+	fs.DurationVar(&o.timeout, "timeout", time.Minute, "Time to wait for the handlers of each event.")
+	_ = fs.Parse(args)
+	return o
+}
+
+func main() {
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.validate(); err != nil {
+		logrus.Fatalf("Invalid options: %v", err)
+	}
+
+	log := logrus.StandardLogger().WithField("plugin", o.plugin)
+	if err := replay(o, os.Stdout, log); err != nil {
+		log.WithError(err).Fatal("Replay failed.")
+	}
+}
+
+// replay replays the recorded events against the handlers of the plugin, and prints the mutations
+// which the handlers would perform for each event.
+func replay(o options, out io.Writer, log *logrus.Entry) error {
+	epa := &tiexternalplugins.ConfigAgent{}
+	if err := epa.Load(o.externalPluginsConfig); err != nil {
+		return fmt.Errorf("load external plugin config from %q: %w", o.externalPluginsConfig, err)
+	}
+
+	ol, err := ownersLoader(o)
+	if err != nil {
+		return err
+	}
+
+	events, err := tiexternalplugins.ReadRecordedEvents(o.events)
+	if err != nil {
+		return fmt.Errorf("read recorded events from %q: %w", o.events, err)
+	}
+
+	for _, e := range events {
+		mutations, err := replayEvent(o, epa, ol, e, log)
+		if err != nil {
+			return fmt.Errorf("replay %s event %s: %w", e.EventType, e.GUID, err)
+		}
+
+		fmt.Fprintf(out, "%s event %s received at %s:\n", e.EventType, e.GUID, e.ReceivedAt.Format(time.RFC3339))
+		if len(mutations) == 0 {
+			fmt.Fprintln(out, "  no mutations")
+		}
+		for _, mutation := range mutations {
+			fmt.Fprintf(out, "  %s\n", mutation)
+		}
+	}
+	return nil
+}
+
+// replayEvent handles the event with a fake GitHub client, and returns the mutations recorded by it.
+func replayEvent(o options, epa *tiexternalplugins.ConfigAgent, ol ownersclient.OwnersLoader,
+	e tiexternalplugins.RecordedEvent, log *logrus.Entry) ([]string, error) {
+	gc, err := newFakeGitHubClient(e.Payload, o.repoLabels.Strings())
+	if err != nil {
+		return nil, err
+	}
+
+	// The events are handled one by one, and the failed ones are not retried.
+	host, err := tiexternalplugins.NewPluginHost(tiexternalplugins.HostOptions{
+		Workers:        1,
+		QueueSize:      1,
+		HandlerTimeout: o.timeout,
+		DrainTimeout:   o.timeout,
+		MaxAttempts:    1,
+		RetryBackoff:   o.timeout,
+	}, nil, epa, log)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.plugin {
+	case autoresponder.PluginName:
+		autoresponder.RegisterHandlers(host, gc)
+	case blunderbuss.PluginName:
+		blunderbuss.RegisterHandlers(host, gc, ol)
+	case contribution.PluginName:
+		contribution.RegisterHandlers(host, gc)
+	case label.PluginName:
+		label.RegisterHandlers(host, gc)
+	case labelblocker.PluginName:
+		labelblocker.RegisterHandlers(host, gc)
+	case lgtm.PluginName:
+		lgtm.RegisterHandlers(host, gc, ol, false)
+	case merge.PluginName:
+		merge.RegisterHandlers(host, gc, ol)
+	case tars.PluginName:
+		tars.RegisterHandlers(host, gc)
+	}
+
+	err = host.Dispatch(e.EventType, e.GUID, e.Payload)
+	host.Drain()
+	if err != nil {
+		return nil, err
+	}
+	return gc.Mutations(), nil
+}
+
+// ownersLoader returns the owners loader which loads the owners from the file if it is set,
+// otherwise from the owners endpoint.
+func ownersLoader(o options) (ownersclient.OwnersLoader, error) {
+	if len(o.ownersFile) == 0 {
+		return &ownersclient.OwnersClient{Client: &http.Client{Timeout: o.timeout}}, nil
+	}
+
+	content, err := ioutil.ReadFile(o.ownersFile)
+	if err != nil {
+		return nil, fmt.Errorf("read owners file %q: %w", o.ownersFile, err)
+	}
+	var owners ownersclient.Owners
+	if err := json.Unmarshal(content, &owners); err != nil {
+		return nil, fmt.Errorf("parse owners file %q: %w", o.ownersFile, err)
+	}
+	return &staticOwnersLoader{owners: &owners}, nil
+}
+
+// staticOwnersLoader returns the same owners for all the PRs.
+type staticOwnersLoader struct {
+	owners *ownersclient.Owners
+}
+
+func (l *staticOwnersLoader) LoadOwners(string, string, string, int) (*ownersclient.Owners, error) {
+	return l.owners, nil
+}
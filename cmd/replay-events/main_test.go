@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const approvedReviewEvent = `{
+  "event_type": "pull_request_review",
+  "guid": "review-guid",
+  "received_at": "2021-06-01T00:00:00Z",
+  "headers": {"X-Hub-Signature": "REDACTED"},
+  "payload": {
+    "action": "submitted",
+    "review": {"user": {"login": "spxtr"}, "state": "approved", "html_url": "https://github.com/review"},
+    "pull_request": {"number": 1, "user": {"login": "author"}, "labels": [{"name": "status/LGT2"}]},
+    "repository": {"name": "test-infra", "owner": {"login": "kubernetes"}}
+  }
+}`
+
+func TestOptions(t *testing.T) {
+	testcases := []struct {
+		name string
+		args []string
+
+		expectedErr string
+	}{
+		{
+			name: "valid options",
+			args: []string{"--plugin=ti-community-lgtm", "--events=/tmp/events"},
+		},
+		{
+			name:        "no events",
+			args:        []string{"--plugin=ti-community-lgtm"},
+			expectedErr: "required flag --events was unset",
+		},
+		{
+			name: "unknown plugin",
+			args: []string{"--plugin=ti-community-cherrypicker", "--events=/tmp/events"},
+			expectedErr: "unknown plugin \"ti-community-cherrypicker\", the plugin must be one of " +
+				"[ti-community-autoresponder ti-community-blunderbuss ti-community-contribution " +
+				"ti-community-label ti-community-label-blocker ti-community-lgtm ti-community-merge " +
+				"ti-community-tars]",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			o := gatherOptions(flag.NewFlagSet(tc.name, flag.ContinueOnError), tc.args...)
+
+			err := o.validate()
+			if err != nil || len(tc.expectedErr) != 0 {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("Different error: Got \"%v\" expected \"%v\"", err, tc.expectedErr)
+				}
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	lgtmComment, err := ioutil.ReadFile("../../test/testdata/lgtm_comment.json")
+	if err != nil {
+		t.Fatalf("read lgtm comment file failed: %v", err)
+	}
+	dir := t.TempDir()
+	commentEvent := `{"event_type": "issue_comment", "guid": "comment-guid", ` +
+		`"received_at": "2021-06-01T00:00:00Z", "payload": ` + string(lgtmComment) + `}`
+	for name, content := range map[string]string{
+		"20210601T000000-issue_comment.json":       commentEvent,
+		"20210601T000001-pull_request_review.json": approvedReviewEvent,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("write event failed: %v", err)
+		}
+	}
+
+	testcases := []struct {
+		name   string
+		plugin string
+
+		expectedOutputs []string
+	}{
+		{
+			name:   "Comment created",
+			plugin: "ti-community-autoresponder",
+			expectedOutputs: []string{
+				"issue_comment event comment-guid received at 2021-06-01T00:00:00Z:\n" +
+					"  create comment kubernetes/test-infra#947:\n" +
+					"    @spxtr: Thanks for your review.\n",
+				"pull_request_review event review-guid received at 2021-06-01T00:00:00Z:\n" +
+					"  no mutations\n",
+			},
+		},
+		{
+			name:   "Labels changed",
+			plugin: "ti-community-lgtm",
+			expectedOutputs: []string{
+				"issue_comment event comment-guid received at 2021-06-01T00:00:00Z:\n" +
+					"  no mutations\n",
+				"  create comment kubernetes/test-infra#1:\n",
+				"  remove label kubernetes/test-infra#1: status/LGT2\n" +
+					"  add label kubernetes/test-infra#1: status/LGT1\n",
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			o := options{
+				plugin:                tc.plugin,
+				externalPluginsConfig: "testdata/external_plugins_config.yaml",
+				events:                dir,
+				ownersFile:            "testdata/owners.json",
+				timeout:               time.Minute,
+			}
+
+			var out bytes.Buffer
+			if err := replay(o, &out, logrus.WithField("plugin", tc.plugin)); err != nil {
+				t.Fatalf("replay failed: %v", err)
+			}
+			for _, expected := range tc.expectedOutputs {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("Expected output contains \"%s\", got \"%s\"", expected, out.String())
+				}
+			}
+		})
+	}
+}
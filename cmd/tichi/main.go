@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/autoresponder"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/blunderbuss"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/cherrypicker"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/contribution"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/label"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/labelblocker"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/lgtm"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/merge"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/owners"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/tars"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/metrics"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// knownPlugins specifies the plugins which can be enabled.
+var knownPlugins = sets.NewString(
+	autoresponder.PluginName,
+	blunderbuss.PluginName,
+	cherrypicker.PluginName,
+	contribution.PluginName,
+	label.PluginName,
+	labelblocker.PluginName,
+	lgtm.PluginName,
+	merge.PluginName,
+	owners.PluginName,
+	tars.PluginName,
+)
+
+type options struct {
+	port int
+
+	plugins prowflagutil.Strings
+
+	pluginConfig          string
+	dryRun                bool
+	github                prowflagutil.GitHubOptions
+	externalPluginsConfig string
+
+	updatePeriod        time.Duration
+	lgtmReconcilePeriod time.Duration
+	lgtmReconcileDryRun bool
+	staleReviewPeriod   time.Duration
+
+	webhookSecretFile string
+
+	host            tiexternalplugins.HostOptions
+	owners          ownersclient.Options
+	ownersCache     owners.CacheOptions
+	instrumentation prowflagutil.InstrumentationOptions
+}
+
+// validate validates the enabled plugins and the option groups.
+func (o *options) validate() error {
+	if len(o.plugins.Strings()) == 0 {
+		return errors.New("at least one plugin must be enabled by --plugin")
+	}
+	if unknown := o.plugins.StringSet().Difference(knownPlugins); unknown.Len() != 0 {
+		return fmt.Errorf("unknown plugins %v, the plugin must be one of %v", unknown.List(), knownPlugins.List())
+	}
+
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.owners, &o.ownersCache, &o.instrumentation} {
+		if err := group.Validate(o.dryRun); err != nil {
+			return fmt.Errorf("%d: %w", idx, err)
+		}
+	}
+
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{plugins: prowflagutil.NewStrings()}
+	fs.IntVar(&o.port, "port", 80, "Port to listen on.")
+	fs.Var(&o.plugins, "plugin", "Name of a plugin to enable, can be passed multiple times.")
+	fs.StringVar(&o.pluginConfig, "plugin-config", "/etc/plugins/plugins.yaml",
+		"Path to plugin config file, it is only used by ti-community-tars and the reconciliations of ti-community-lgtm.")
+	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
+		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	fs.DurationVar(&o.updatePeriod, "update-period", time.Minute*20,
+		"Period duration for periodic scans of all PRs, it is only used by ti-community-tars.")
+	fs.DurationVar(&o.lgtmReconcilePeriod, "lgtm-reconcile-period", 0,
+		"Period duration for periodic reconciliations of the labels of all PRs by ti-community-lgtm, disabled if it is 0.")
+	fs.BoolVar(&o.lgtmReconcileDryRun, "lgtm-reconcile-dry-run", false,
+		"Report the changes of the reconciliations and the /refresh command of ti-community-lgtm without making them.")
+	fs.DurationVar(&o.staleReviewPeriod, "stale-review-period", time.Minute*30,
+		"Period duration for periodic checks of the stale reviewers of all PRs by ti-community-blunderbuss.")
+	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file", "/etc/webhook/hmac",
+		"Path to the file containing the GitHub HMAC secret.")
+
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.owners, &o.ownersCache, &o.instrumentation} {
+		group.AddFlags(fs)
+	}
+	_ = fs.Parse(args)
+	return o
+}
+
+func main() {
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.validate(); err != nil {
+		logrus.Fatalf("Invalid options: %v", err)
+	}
+
+	log := logrus.StandardLogger().WithField("component", "tichi")
+
+	epa := &tiexternalplugins.ConfigAgent{}
+	if err := epa.Start(interrupts.Context(), o.externalPluginsConfig); err != nil {
+		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
+	}
+
+	secretAgent := &secret.Agent{}
+	if err := secretAgent.Start([]string{o.github.TokenPath, o.webhookSecretFile}); err != nil {
+		logrus.WithError(err).Fatal("Error starting secrets agent.")
+	}
+
+	tiexternalplugins.InstrumentGitHubRequests()
+	// All the plugins share one GitHub client, so they share the token budget.
+	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting GitHub client.")
+	}
+	// NOTICE: This error is only possible when using the GitHub APP,
+	// but if we use the APP auth later we will have to handle the err.
+	_ = githubClient.Throttle(360, 360)
+
+	// Skip https verify.
+	//nolint:gosec
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+	ol := o.owners.NewClient(tr, log)
+
+	host, err := tiexternalplugins.NewPluginHost(o.host, secretAgent.GetTokenGenerator(o.webhookSecretFile), epa, log)
+	if err != nil {
+		log.WithError(err).Fatal("Error creating plugin host.")
+	}
+	// The cached owners of a PR are invalidated when its labels, base branch or commits change.
+	host.RegisterEventObserver(ol.ObserveEvent)
+	mux := http.NewServeMux()
+	// The webhooks sent to the root are handled by all enabled plugins.
+	mux.Handle("/", host)
+	mux.Handle(tiexternalplugins.ConfigStatusPath, epa.StatusHandler())
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	for _, name := range o.plugins.StringSet().List() {
+		pluginLog := log.WithField("plugin", name)
+		switch name {
+		case autoresponder.PluginName:
+			autoresponder.RegisterHandlers(host, githubClient)
+			host.ServePlugin(mux, name, autoresponder.HelpProvider(epa))
+		case blunderbuss.PluginName:
+			blunderbuss.RegisterHandlers(host, githubClient, ol)
+			blunderbuss.StartPeriodicStaleReviewCheck(pluginLog, githubClient, ol, epa, o.staleReviewPeriod)
+			host.ServePlugin(mux, name, blunderbuss.HelpProvider(epa))
+		case cherrypicker.PluginName:
+			server := newCherrypicker(o, secretAgent, githubClient, epa, pluginLog)
+			server.RegisterHandlers(host)
+			host.ServePlugin(mux, name, cherrypicker.HelpProvider(epa))
+		case contribution.PluginName:
+			contribution.RegisterHandlers(host, githubClient)
+			host.ServePlugin(mux, name, contribution.HelpProvider(epa))
+		case label.PluginName:
+			label.RegisterHandlers(host, githubClient)
+			host.ServePlugin(mux, name, label.HelpProvider(epa))
+		case labelblocker.PluginName:
+			labelblocker.RegisterHandlers(host, githubClient)
+			host.ServePlugin(mux, name, labelblocker.HelpProvider(epa))
+		case lgtm.PluginName:
+			lgtm.RegisterHandlers(host, githubClient, ol, o.lgtmReconcileDryRun)
+			if o.lgtmReconcilePeriod > 0 {
+				pa := &plugins.ConfigAgent{}
+				if err := pa.Start(o.pluginConfig, nil, "", false); err != nil {
+					pluginLog.WithError(err).Fatalf("Error loading plugin config from %q.", o.pluginConfig)
+				}
+				lgtm.StartPeriodicReconcile(pluginLog, githubClient, pa, epa, ol, o.lgtmReconcilePeriod,
+					o.lgtmReconcileDryRun)
+			}
+			host.ServePlugin(mux, name, lgtm.HelpProvider(epa))
+		case merge.PluginName:
+			merge.RegisterHandlers(host, githubClient, ol)
+			host.ServePlugin(mux, name, merge.HelpProvider(epa))
+		case owners.PluginName:
+			server := &owners.Server{
+				Client:         client,
+				TokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
+				Gc:             githubClient,
+				ConfigAgent:    epa,
+				Log:            pluginLog,
+				Cache:          owners.NewCache(o.ownersCache, pluginLog),
+			}
+			server.Cache.Start(interrupts.Context(), epa)
+			server.RegisterHandlers(host)
+			// The owners API is served under the prefix of the plugin besides its webhook and help.
+			prefix := "/" + owners.PluginName
+			router := gin.Default()
+			server.RegisterRoutes(router)
+			server.Cache.RegisterRoutes(router)
+			mux.Handle(prefix, host.PluginWebhookHandler(name))
+			mux.Handle(prefix+"/help", http.StripPrefix(prefix, host.PluginHandler(name, owners.HelpProvider(epa))))
+			mux.Handle(prefix+"/", router)
+		case tars.PluginName:
+			pa := &plugins.ConfigAgent{}
+			if err := pa.Start(o.pluginConfig, nil, "", false); err != nil {
+				pluginLog.WithError(err).Fatalf("Error loading plugin config from %q.", o.pluginConfig)
+			}
+
+			tars.RegisterHandlers(host, githubClient)
+			tars.StartPeriodicUpdate(pluginLog, githubClient, pa, epa, o.updatePeriod)
+			host.ServePlugin(mux, name, tars.HelpProvider(epa))
+		}
+	}
+
+	health := pjutil.NewHealthOnPort(o.instrumentation.HealthPort)
+	health.ServeReady()
+
+	metrics.ExposeMetrics("tichi", config.PushGateway{}, o.instrumentation.MetricsPort)
+
+	host.ListenAndServeMux(o.port, mux)
+}
+
+// newCherrypicker creates the cherrypicker server with its own git client.
+func newCherrypicker(o options, secretAgent *secret.Agent, githubClient github.Client,
+	epa *tiexternalplugins.ConfigAgent, log *logrus.Entry) *cherrypicker.Server {
+	gitClient, err := o.github.GitClient(secretAgent, o.dryRun)
+	if err != nil {
+		log.WithError(err).Fatal("Error getting Git client.")
+	}
+	interrupts.OnInterrupt(func() {
+		if err := gitClient.Clean(); err != nil {
+			log.WithError(err).Error("Could not clean up git client cache.")
+		}
+	})
+
+	email, err := githubClient.Email()
+	if err != nil {
+		log.WithError(err).Fatal("Error getting bot e-mail.")
+	}
+
+	botUser, err := githubClient.BotUser()
+	if err != nil {
+		log.WithError(err).Fatal("Error getting bot name.")
+	}
+
+	repos, err := githubClient.GetRepos(botUser.Login, true)
+	if err != nil {
+		log.WithError(err).Fatal("Error listing bot repositories.")
+	}
+
+	return &cherrypicker.Server{
+		BotUser:     botUser,
+		Email:       email,
+		ConfigAgent: epa,
+
+		GitClient:    git.ClientFactoryFrom(gitClient),
+		GitHubClient: githubClient,
+		Log:          log,
+
+		Bare:      &http.Client{},
+		PatchURL:  "https://patch-diff.githubusercontent.com",
+		GitHubURL: "https://github.com",
+
+		Repos: repos,
+	}
+}
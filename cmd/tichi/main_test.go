@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	testcases := []struct {
+		name string
+		args []string
+
+		expectedPlugins []string
+		expectedErr     string
+	}{
+		{
+			name:        "no plugins",
+			args:        []string{},
+			expectedErr: "at least one plugin must be enabled by --plugin",
+		},
+		{
+			name:            "several plugins",
+			args:            []string{"--plugin=ti-community-merge", "--plugin=ti-community-lgtm"},
+			expectedPlugins: []string{"ti-community-merge", "ti-community-lgtm"},
+		},
+		{
+			name: "unknown plugin",
+			args: []string{"--plugin=ti-community-lgtm", "--plugin=ti-community-unknown"},
+			expectedErr: "unknown plugins [ti-community-unknown], the plugin must be one of " +
+				"[ti-community-autoresponder ti-community-blunderbuss ti-community-cherrypicker " +
+				"ti-community-contribution ti-community-label ti-community-label-blocker ti-community-lgtm " +
+				"ti-community-merge ti-community-owners ti-community-tars]",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			o := gatherOptions(flag.NewFlagSet(tc.name, flag.ContinueOnError), tc.args...)
+
+			err := o.validate()
+			if err != nil || len(tc.expectedErr) != 0 {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Errorf("Different error: Got \"%v\" expected \"%v\"", err, tc.expectedErr)
+				}
+				return
+			}
+
+			plugins := o.plugins.Strings()
+			if len(plugins) != len(tc.expectedPlugins) {
+				t.Fatalf("Different plugins: Got \"%v\" expected \"%v\"", plugins, tc.expectedPlugins)
+			}
+			for i := range plugins {
+				if plugins[i] != tc.expectedPlugins[i] {
+					t.Errorf("Different plugins: Got \"%v\" expected \"%v\"", plugins, tc.expectedPlugins)
+				}
+			}
+		})
+	}
+}
@@ -1,25 +1,21 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
-	"strconv"
-	"time"
 
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/autoresponder"
 
 	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
-	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
-	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
 )
 
 type options struct {
@@ -31,11 +27,14 @@ type options struct {
 	externalPluginsConfig string
 
 	webhookSecretFile string
+
+	host            tiexternalplugins.HostOptions
+	instrumentation prowflagutil.InstrumentationOptions
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.instrumentation} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -54,7 +53,7 @@ func gatherOptions() options {
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.instrumentation} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -70,7 +69,7 @@ func main() {
 	log := logrus.StandardLogger().WithField("plugin", autoresponder.PluginName)
 
 	epa := &tiexternalplugins.ConfigAgent{}
-	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
+	if err := epa.Start(interrupts.Context(), o.externalPluginsConfig); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
@@ -79,6 +78,7 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	tiexternalplugins.InstrumentGitHubRequests()
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -87,111 +87,17 @@ func main() {
 	// but if we use the APP auth later we will have to handle the err.
 	_ = githubClient.Throttle(360, 360)
 
-	server := &server{
-		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
-		gc:             githubClient,
-		configAgent:    epa,
-		log:            log,
+	host, err := tiexternalplugins.NewPluginHost(o.host, secretAgent.GetTokenGenerator(o.webhookSecretFile), epa, log)
+	if err != nil {
+		log.WithError(err).Fatal("Error creating plugin host.")
 	}
+	autoresponder.RegisterHandlers(host, githubClient)
 
-	health := pjutil.NewHealth()
+	health := pjutil.NewHealthOnPort(o.instrumentation.HealthPort)
 	health.ServeReady()
 
-	mux := http.NewServeMux()
-	mux.Handle("/", server)
-
-	helpProvider := autoresponder.HelpProvider(epa)
-	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
-	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	metrics.ExposeMetrics(autoresponder.PluginName, config.PushGateway{}, o.instrumentation.MetricsPort)
 
 	defer interrupts.WaitForGracefulShutdown()
-	interrupts.ListenAndServe(httpServer, 5*time.Second)
-}
-
-// server implements http.Handler. It validates incoming GitHub webhooks and
-// then dispatches them to the appropriate plugins.
-type server struct {
-	tokenGenerator func() []byte
-	gc             github.Client
-
-	configAgent *tiexternalplugins.ConfigAgent
-	log         *logrus.Entry
-}
-
-func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, s.tokenGenerator)
-	if !ok {
-		return
-	}
-
-	if err := s.handleEvent(eventType, eventGUID, payload); err != nil {
-		logrus.WithError(err).Error("Error parsing event.")
-	}
-}
-
-// handleEvent distributed events and handles them.
-func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error {
-	l := s.log.WithFields(
-		logrus.Fields{
-			"event-type":     eventType,
-			github.EventGUID: eventGUID,
-		},
-	)
-	// Get external plugins config.
-	config := s.configAgent.Config()
-	switch eventType {
-	case tiexternalplugins.IssueCommentEvent:
-		var ice github.IssueCommentEvent
-		if err := json.Unmarshal(payload, &ice); err != nil {
-			return err
-		}
-		go func() {
-			if err := autoresponder.HandleIssueCommentEvent(s.gc, &ice, config, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	case tiexternalplugins.PullRequestReviewCommentEvent:
-		var pullReviewCommentEvent github.ReviewCommentEvent
-		if err := json.Unmarshal(payload, &pullReviewCommentEvent); err != nil {
-			return err
-		}
-		go func() {
-			if err := autoresponder.HandlePullReviewCommentEvent(s.gc, &pullReviewCommentEvent, config, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	case tiexternalplugins.PullRequestReviewEvent:
-		var pullReviewEvent github.ReviewEvent
-		if err := json.Unmarshal(payload, &pullReviewEvent); err != nil {
-			return err
-		}
-		go func() {
-			if err := autoresponder.HandlePullReviewEvent(s.gc, &pullReviewEvent, config, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	case tiexternalplugins.PullRequestEvent:
-		var pullRequestEvent github.PullRequestEvent
-		if err := json.Unmarshal(payload, &pullRequestEvent); err != nil {
-			return err
-		}
-		go func() {
-			if err := autoresponder.HandlePullRequestEvent(s.gc, &pullRequestEvent, config, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	case tiexternalplugins.IssuesEvent:
-		var issueEvent github.IssueEvent
-		if err := json.Unmarshal(payload, &issueEvent); err != nil {
-			return err
-		}
-		go func() {
-			if err := autoresponder.HandleIssueEvent(s.gc, &issueEvent, config, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	default:
-		s.log.Debugf("received an event of type %q but didn't ask for it", eventType)
-	}
-	return nil
+	host.ListenAndServe(o.port, autoresponder.HelpProvider(epa))
 }
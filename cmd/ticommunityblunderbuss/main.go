@@ -2,12 +2,10 @@ package main
 
 import (
 	"crypto/tls"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,12 +14,12 @@ import (
 
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
-	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
-	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
 )
 
 type options struct {
@@ -32,12 +30,18 @@ type options struct {
 
 	externalPluginsConfig string
 
+	staleReviewPeriod time.Duration
+
 	webhookSecretFile string
+
+	host            tiexternalplugins.HostOptions
+	owners          ownersclient.Options
+	instrumentation prowflagutil.InstrumentationOptions
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.owners, &o.instrumentation} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -55,8 +59,10 @@ func gatherOptions() options {
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
+	fs.DurationVar(&o.staleReviewPeriod, "stale-review-period", time.Minute*30,
+		"Period duration for periodic checks of the stale reviewers of all PRs.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.owners, &o.instrumentation} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -72,7 +78,7 @@ func main() {
 	log := logrus.StandardLogger().WithField("plugin", blunderbuss.PluginName)
 
 	epa := &tiexternalplugins.ConfigAgent{}
-	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
+	if err := epa.Start(interrupts.Context(), o.externalPluginsConfig); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
@@ -81,6 +87,7 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	tiexternalplugins.InstrumentGitHubRequests()
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -94,86 +101,23 @@ func main() {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
-	client := &http.Client{Transport: tr}
-	ol := &ownersclient.OwnersClient{Client: client}
-
-	server := &server{
-		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
-		gc:             githubClient,
-		ol:             ol,
-		configAgent:    epa,
-		log:            log,
+	ol := o.owners.NewClient(tr, log)
+
+	host, err := tiexternalplugins.NewPluginHost(o.host, secretAgent.GetTokenGenerator(o.webhookSecretFile), epa, log)
+	if err != nil {
+		log.WithError(err).Fatal("Error creating plugin host.")
 	}
+	// The cached owners of a PR are invalidated when its labels, base branch or commits change.
+	host.RegisterEventObserver(ol.ObserveEvent)
+	blunderbuss.RegisterHandlers(host, githubClient, ol)
 
-	health := pjutil.NewHealth()
+	health := pjutil.NewHealthOnPort(o.instrumentation.HealthPort)
 	health.ServeReady()
 
-	mux := http.NewServeMux()
-	mux.Handle("/", server)
-
-	helpProvider := blunderbuss.HelpProvider(epa)
-	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
-	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	metrics.ExposeMetrics(blunderbuss.PluginName, config.PushGateway{}, o.instrumentation.MetricsPort)
 
 	defer interrupts.WaitForGracefulShutdown()
-	interrupts.ListenAndServe(httpServer, 5*time.Second)
-}
-
-// server implements http.Handler. It validates incoming GitHub webhooks and
-// then dispatches them to the appropriate plugins.
-type server struct {
-	tokenGenerator func() []byte
-	gc             github.Client
-
-	ol          ownersclient.OwnersLoader
-	configAgent *tiexternalplugins.ConfigAgent
-	log         *logrus.Entry
-}
+	blunderbuss.StartPeriodicStaleReviewCheck(log, githubClient, ol, epa, o.staleReviewPeriod)
 
-func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, s.tokenGenerator)
-	if !ok {
-		return
-	}
-
-	if err := s.handleEvent(eventType, eventGUID, payload); err != nil {
-		logrus.WithError(err).Error("Error parsing event.")
-	}
-}
-
-// handleEvent distributed events and handles them.
-func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error {
-	l := s.log.WithFields(
-		logrus.Fields{
-			"event-type":     eventType,
-			github.EventGUID: eventGUID,
-		},
-	)
-	// Get external plugins config.
-	config := s.configAgent.Config()
-	switch eventType {
-	case tiexternalplugins.IssueCommentEvent:
-		var ice github.IssueCommentEvent
-		if err := json.Unmarshal(payload, &ice); err != nil {
-			return err
-		}
-		go func() {
-			if err := blunderbuss.HandleIssueCommentEvent(s.gc, &ice, config, s.ol, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	case tiexternalplugins.PullRequestEvent:
-		var pe github.PullRequestEvent
-		if err := json.Unmarshal(payload, &pe); err != nil {
-			return err
-		}
-		go func() {
-			if err := blunderbuss.HandlePullRequestEvent(s.gc, &pe, config, s.ol, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	default:
-		s.log.Debugf("received an event of type %q but didn't ask for it", eventType)
-	}
-	return nil
+	host.ListenAndServe(o.port, blunderbuss.HelpProvider(epa))
 }
@@ -5,19 +5,18 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
-	"time"
 
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/cherrypicker"
 	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
-	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
 )
 
 type options struct {
@@ -29,11 +28,14 @@ type options struct {
 	externalPluginsConfig string
 
 	webhookSecretFile string
+
+	host            tiexternalplugins.HostOptions
+	instrumentation prowflagutil.InstrumentationOptions
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.instrumentation} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -52,7 +54,7 @@ func gatherOptions() options {
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.instrumentation} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -68,7 +70,7 @@ func main() {
 	log := logrus.StandardLogger().WithField("plugin", cherrypicker.PluginName)
 
 	epa := &tiexternalplugins.ConfigAgent{}
-	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
+	if err := epa.Start(interrupts.Context(), o.externalPluginsConfig); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
@@ -77,6 +79,7 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	tiexternalplugins.InstrumentGitHubRequests()
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -111,10 +114,9 @@ func main() {
 	}
 
 	server := &cherrypicker.Server{
-		TokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
-		BotUser:        botUser,
-		Email:          email,
-		ConfigAgent:    epa,
+		BotUser:     botUser,
+		Email:       email,
+		ConfigAgent: epa,
 
 		GitClient:    git.ClientFactoryFrom(gitClient),
 		GitHubClient: githubClient,
@@ -127,16 +129,17 @@ func main() {
 		Repos: repos,
 	}
 
-	health := pjutil.NewHealth()
-	health.ServeReady()
+	host, err := tiexternalplugins.NewPluginHost(o.host, secretAgent.GetTokenGenerator(o.webhookSecretFile), epa, log)
+	if err != nil {
+		log.WithError(err).Fatal("Error creating plugin host.")
+	}
+	server.RegisterHandlers(host)
 
-	mux := http.NewServeMux()
-	mux.Handle("/", server)
+	health := pjutil.NewHealthOnPort(o.instrumentation.HealthPort)
+	health.ServeReady()
 
-	helpProvider := cherrypicker.HelpProvider(epa)
-	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
-	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	metrics.ExposeMetrics(cherrypicker.PluginName, config.PushGateway{}, o.instrumentation.MetricsPort)
 
 	defer interrupts.WaitForGracefulShutdown()
-	interrupts.ListenAndServe(httpServer, 5*time.Second)
+	host.ListenAndServe(o.port, cherrypicker.HelpProvider(epa))
 }
@@ -2,12 +2,10 @@ package main
 
 import (
 	"crypto/tls"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,28 +14,36 @@ import (
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/lgtm"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
-	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
-	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
+	"k8s.io/test-infra/prow/plugins"
 )
 
 type options struct {
 	port int
 
-	dryRun bool
-	github prowflagutil.GitHubOptions
-
+	pluginConfig          string
+	dryRun                bool
+	github                prowflagutil.GitHubOptions
 	externalPluginsConfig string
 
+	reconcilePeriod time.Duration
+	reconcileDryRun bool
+
 	webhookSecretFile string
+
+	host            tiexternalplugins.HostOptions
+	owners          ownersclient.Options
+	instrumentation prowflagutil.InstrumentationOptions
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.owners, &o.instrumentation} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -50,13 +56,18 @@ func gatherOptions() options {
 	o := options{}
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.IntVar(&o.port, "port", 80, "Port to listen on.")
+	fs.StringVar(&o.pluginConfig, "plugin-config", "/etc/plugins/plugins.yaml", "Path to plugin config file.")
 	fs.StringVar(&o.externalPluginsConfig, "external-plugins-config",
 		"/etc/external_plugins_config/external_plugins_config.yaml", "Path to external plugin config file.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	fs.DurationVar(&o.reconcilePeriod, "reconcile-period", 0,
+		"Period duration for periodic reconciliations of the labels of all PRs, disabled if it is 0.")
+	fs.BoolVar(&o.reconcileDryRun, "reconcile-dry-run", false,
+		"Report the changes of the reconciliations and the /refresh command without making them.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.owners, &o.instrumentation} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -72,7 +83,7 @@ func main() {
 	log := logrus.StandardLogger().WithField("plugin", lgtm.PluginName)
 
 	epa := &tiexternalplugins.ConfigAgent{}
-	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
+	if err := epa.Start(interrupts.Context(), o.externalPluginsConfig); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
@@ -81,6 +92,7 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	tiexternalplugins.InstrumentGitHubRequests()
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -94,86 +106,29 @@ func main() {
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 	}
-	client := &http.Client{Transport: tr}
-	ol := &ownersclient.OwnersClient{Client: client}
-
-	server := &server{
-		tokenGenerator: secretAgent.GetTokenGenerator(o.webhookSecretFile),
-		gc:             githubClient,
-		ol:             ol,
-		configAgent:    epa,
-		log:            log,
-	}
-
-	health := pjutil.NewHealth()
-	health.ServeReady()
+	ol := o.owners.NewClient(tr, log)
 
-	mux := http.NewServeMux()
-	mux.Handle("/", server)
-
-	helpProvider := lgtm.HelpProvider(epa)
-	externalplugins.ServeExternalPluginHelp(mux, log, helpProvider)
-	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	host, err := tiexternalplugins.NewPluginHost(o.host, secretAgent.GetTokenGenerator(o.webhookSecretFile), epa, log)
+	if err != nil {
+		log.WithError(err).Fatal("Error creating plugin host.")
+	}
+	// The cached owners of a PR are invalidated when its labels, base branch or commits change.
+	host.RegisterEventObserver(ol.ObserveEvent)
+	lgtm.RegisterHandlers(host, githubClient, ol, o.reconcileDryRun)
 
 	defer interrupts.WaitForGracefulShutdown()
-	interrupts.ListenAndServe(httpServer, 5*time.Second)
-}
-
-// server implements http.Handler. It validates incoming GitHub webhooks and
-// then dispatches them to the appropriate plugins.
-type server struct {
-	tokenGenerator func() []byte
-	gc             github.Client
-
-	ol          ownersclient.OwnersLoader
-	configAgent *tiexternalplugins.ConfigAgent
-	log         *logrus.Entry
-}
-
-func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, s.tokenGenerator)
-	if !ok {
-		return
+	if o.reconcilePeriod > 0 {
+		pa := &plugins.ConfigAgent{}
+		if err := pa.Start(o.pluginConfig, nil, "", false); err != nil {
+			log.WithError(err).Fatalf("Error loading plugin config from %q.", o.pluginConfig)
+		}
+		lgtm.StartPeriodicReconcile(log, githubClient, pa, epa, ol, o.reconcilePeriod, o.reconcileDryRun)
 	}
 
-	if err := s.handleEvent(eventType, eventGUID, payload); err != nil {
-		logrus.WithError(err).Error("Error parsing event.")
-	}
-}
+	health := pjutil.NewHealthOnPort(o.instrumentation.HealthPort)
+	health.ServeReady()
 
-// handleEvent distributed events and handles them.
-func (s *server) handleEvent(eventType, eventGUID string, payload []byte) error {
-	l := s.log.WithFields(
-		logrus.Fields{
-			"event-type":     eventType,
-			github.EventGUID: eventGUID,
-		},
-	)
-	// Get external plugins config.
-	config := s.configAgent.Config()
-	switch eventType {
-	case tiexternalplugins.PullRequestReviewEvent:
-		var prre github.ReviewEvent
-		if err := json.Unmarshal(payload, &prre); err != nil {
-			return err
-		}
-		go func() {
-			if err := lgtm.HandlePullReviewEvent(s.gc, &prre, config, s.ol, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	case tiexternalplugins.PullRequestEvent:
-		var pe github.PullRequestEvent
-		if err := json.Unmarshal(payload, &pe); err != nil {
-			return err
-		}
-		go func() {
-			if err := lgtm.HandlePullRequestEvent(s.gc, &pe, config, l); err != nil {
-				l.WithField("event-type", eventType).WithError(err).Info("Error handling event.")
-			}
-		}()
-	default:
-		s.log.Debugf("received an event of type %q but didn't ask for it", eventType)
-	}
-	return nil
+	metrics.ExposeMetrics(lgtm.PluginName, config.PushGateway{}, o.instrumentation.MetricsPort)
+
+	host.ListenAndServe(o.port, lgtm.HelpProvider(epa))
 }
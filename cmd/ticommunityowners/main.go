@@ -6,17 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins/owners"
 	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
 )
 
@@ -29,11 +29,15 @@ type options struct {
 	externalPluginsConfig string
 
 	webhookSecretFile string
+
+	host            tiexternalplugins.HostOptions
+	cache           owners.CacheOptions
+	instrumentation prowflagutil.InstrumentationOptions
 }
 
 // validate validates github options.
 func (o *options) validate() error {
-	for idx, group := range []flagutil.OptionGroup{&o.github} {
+	for idx, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.cache, &o.instrumentation} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
@@ -52,7 +56,7 @@ func gatherOptions() options {
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file",
 		"/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 
-	for _, group := range []flagutil.OptionGroup{&o.github} {
+	for _, group := range []flagutil.OptionGroup{&o.github, &o.host, &o.cache, &o.instrumentation} {
 		group.AddFlags(fs)
 	}
 	_ = fs.Parse(os.Args[1:])
@@ -68,7 +72,7 @@ func main() {
 	log := logrus.StandardLogger().WithField("plugin", owners.PluginName)
 
 	epa := &tiexternalplugins.ConfigAgent{}
-	if err := epa.Start(o.externalPluginsConfig, false); err != nil {
+	if err := epa.Start(interrupts.Context(), o.externalPluginsConfig); err != nil {
 		log.WithError(err).Fatalf("Error loading external plugin config from %q.", o.externalPluginsConfig)
 	}
 
@@ -77,6 +81,7 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
 
+	tiexternalplugins.InstrumentGitHubRequests()
 	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GitHub client.")
@@ -98,41 +103,28 @@ func main() {
 		Gc:             githubClient,
 		ConfigAgent:    epa,
 		Log:            log,
+		Cache:          owners.NewCache(o.cache, log),
 	}
+	server.Cache.Start(interrupts.Context(), epa)
 
-	health := pjutil.NewHealth()
-	health.ServeReady()
-
-	router := gin.Default()
-	router.GET("/", func(c *gin.Context) {
-		c.String(http.StatusOK, "ti-community-owners")
-	})
-	router.GET("/ti-community-owners/repos/:org/:repo/pulls/:number/owners", func(c *gin.Context) {
-		owner := c.Param("org")
-		repo := c.Param("repo")
-		number := c.Param("number")
-
-		pullNumber, err := strconv.Atoi(number)
-		if err != nil {
-			c.Status(http.StatusNotFound)
-			log.WithError(err).Error("Failed convert pull number.")
-			return
-		}
+	host, err := tiexternalplugins.NewPluginHost(o.host, secretAgent.GetTokenGenerator(o.webhookSecretFile), epa, log)
+	if err != nil {
+		log.WithError(err).Fatal("Error creating plugin host.")
+	}
+	server.RegisterHandlers(host)
 
-		// Get config everytime.
-		config := server.ConfigAgent.Config()
-		ownersData, err := server.ListOwners(owner, repo, pullNumber, config)
-		if err != nil {
-			c.Status(http.StatusInternalServerError)
-			log.WithError(err).Error("Failed list owners.")
-			return
-		}
+	health := pjutil.NewHealthOnPort(o.instrumentation.HealthPort)
+	health.ServeReady()
 
-		c.JSON(http.StatusOK, ownersData)
-	})
+	metrics.ExposeMetrics(owners.PluginName, config.PushGateway{}, o.instrumentation.MetricsPort)
 
-	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: router}
+	// The webhooks are served on the root, and the owners API is served under the prefix of the plugin.
+	router := gin.Default()
+	server.RegisterRoutes(router)
+	server.Cache.RegisterRoutes(router)
+	mux := host.ServeMux(owners.HelpProvider(epa))
+	mux.Handle("/"+owners.PluginName+"/", router)
 
 	defer interrupts.WaitForGracefulShutdown()
-	interrupts.ListenAndServe(httpServer, 5*time.Second)
+	host.ListenAndServeMux(o.port, mux)
 }
@@ -5,10 +5,16 @@ go 1.16
 replace k8s.io/client-go => k8s.io/client-go v0.20.2
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/gin-gonic/gin v1.6.3
+	github.com/google/go-cmp v0.5.2
 	github.com/mroth/weightedrand v0.4.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/shurcooL/githubv4 v0.0.0-20191102174205-af46314aec7b
 	github.com/sirupsen/logrus v1.7.0
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
 	gotest.tools v2.2.0+incompatible
 	k8s.io/apimachinery v0.20.2
 	k8s.io/test-infra v0.0.0-20210605052838-aa44f2be7bbc
@@ -88,6 +88,30 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient) {
+	host.RegisterIssueCommentEventHandler(PluginName,
+		func(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueCommentEvent(gc, ice, cfg, log)
+		})
+	host.RegisterReviewCommentEventHandler(PluginName,
+		func(rce *github.ReviewCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullReviewCommentEvent(gc, rce, cfg, log)
+		})
+	host.RegisterReviewEventHandler(PluginName,
+		func(re *github.ReviewEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullReviewEvent(gc, re, cfg, log)
+		})
+	host.RegisterPullRequestEventHandler(PluginName,
+		func(pe *github.PullRequestEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullRequestEvent(gc, pe, cfg, log)
+		})
+	host.RegisterIssueEventHandler(PluginName,
+		func(ie *github.IssueEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueEvent(gc, ie, cfg, log)
+		})
+}
+
 // HandleIssueCommentEvent handles a GitHub issue comment event and auto respond it.
 func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent,
 	cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
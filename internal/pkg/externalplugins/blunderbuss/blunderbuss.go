@@ -1,13 +1,13 @@
 package blunderbuss
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"regexp"
 	"strings"
 	"time"
 
-	wr "github.com/mroth/weightedrand"
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -25,9 +25,10 @@ const (
 	// PluginName defines this plugin's registered name.
 	PluginName = "ti-community-blunderbuss"
 	// defaultWeight specifies the default contribution weight.
-	defaultWeight = 1
-	// weightIncrement specifies the weight of the contribution added by each code change.
-	weightIncrement = 1
+	defaultWeight = 100
+	// weightIncrement specifies the weight of the contribution added by each code change,
+	// the weight of an old code change decays with its age.
+	weightIncrement = 100
 )
 
 var (
@@ -41,7 +42,11 @@ type githubClient interface {
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
 	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
-	ListFileCommits(org, repo, path string) ([]github.RepositoryCommit, error)
+	Query(context.Context, interface{}, map[string]interface{}) error
+	UnrequestReview(org, repo string, number int, logins []string) error
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	CreateComment(owner, repo string, number int, comment string) error
+	BotUserChecker() (func(candidate string) bool, error)
 }
 
 // HelpProvider constructs the PluginHelp for this plugin that takes into account enabled repositories.
@@ -73,6 +78,8 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 					IncludeReviewers:   []string{},
 					ExcludeReviewers:   []string{},
 					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					Strategy:           tiexternalplugins.BlunderbussStrategyFileHistory,
+					MaxReviewerLoad:    10,
 				},
 			},
 		})
@@ -97,6 +104,18 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient, ol ownersclient.OwnersLoader) {
+	host.RegisterIssueCommentEventHandler(PluginName,
+		func(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueCommentEvent(gc, ice, cfg, ol, log)
+		})
+	host.RegisterPullRequestEventHandler(PluginName,
+		func(pe *github.PullRequestEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullRequestEvent(gc, pe, cfg, ol, log)
+		})
+}
+
 func configString(maxReviewerCount int) string {
 	var pluralSuffix string
 	if maxReviewerCount > 1 {
@@ -116,7 +135,7 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 	}
 
 	repo := &pe.Repo
-	opts := cfg.BlunderbussFor(repo.Owner.Login, repo.Name)
+	opts := cfg.BlunderbussForBranch(repo.Owner.Login, repo.Name, pr.Base.Ref)
 	// If there is already /cc, the author has specified reviewers.
 	prBodyWithoutCcCommand := !assign.CCRegexp.MatchString(pr.Body)
 
@@ -188,7 +207,7 @@ func HandleIssueCommentEvent(gc githubClient, ce *github.IssueCommentEvent, cfg
 		return fmt.Errorf("error loading PullRequest: %v", err)
 	}
 
-	opts := cfg.BlunderbussFor(repo.Owner.Login, repo.Name)
+	opts := cfg.BlunderbussForBranch(repo.Owner.Login, repo.Name, pr.Base.Ref)
 
 	// Check if PR has sig label.
 	if opts.RequireSigLabel && !containSigLabel(pr.Labels) {
@@ -213,69 +232,83 @@ func handle(gc githubClient, opts *tiexternalplugins.TiCommunityBlunderbuss, rep
 		return fmt.Errorf("error loading repo owners: %v", err)
 	}
 
-	// List all available reviewers.
+	// List all available reviewers, the reviewers who are away are not requested.
 	availableReviewers := listAvailableReviewers(pr.User.Login, owners.Reviewers, opts.IncludeReviewers,
-		opts.ExcludeReviewers, pr.RequestedReviewers)
-
-	maxReviewerCount := opts.MaxReviewerCount
-	// If maxReviewerCount is not set or there are not enough reviewers, then all reviewers are assigned.
-	if maxReviewerCount == 0 || len(availableReviewers) <= maxReviewerCount {
-		log.Infof("Requesting all available reviewers %s.", availableReviewers.List())
-		return gc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, availableReviewers.List())
+		opts.ExcludeReviewers, pr.RequestedReviewers, owners.Unavailable)
+
+	var reviewers []string
+	if len(opts.ReviewerGroups) == 0 {
+		reviewers, err = selectReviewers(gc, opts, repo.Owner.Login, repo.Name, pr.Number, availableReviewers,
+			opts.MaxReviewerCount, log)
+	} else {
+		reviewers, err = selectGroupReviewers(gc, opts, repo.Owner.Login, repo.Name, pr.Number, availableReviewers,
+			log)
 	}
-
-	// Always seed random!
-	rand.Seed(time.Now().UTC().UnixNano())
-	// List the contributors of the changes.
-	contributors, err := listChangesContributors(gc, repo.Owner.Login, repo.Name, pr.Number, log)
 	if err != nil {
 		return err
 	}
 
-	// Filter out unavailable contributors.
-	for contributor := range contributors {
-		if !availableReviewers.Has(contributor) {
-			delete(contributors, contributor)
-		}
-	}
+	log.Infof("Requesting reviews from users %s.", reviewers)
+	return gc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, reviewers)
+}
 
-	// The default weight for other reviewers is 1.
-	for _, reviewer := range availableReviewers.List() {
-		_, ok := contributors[reviewer]
-		if !ok {
-			contributors[reviewer] = defaultWeight
-		}
+// selectReviewers chooses at most count reviewers from the available reviewers with the strategy of the repo,
+// all the available reviewers are chosen if count is 0.
+func selectReviewers(gc githubClient, opts *tiexternalplugins.TiCommunityBlunderbuss, org string, repo string,
+	num int, availableReviewers sets.String, count int, log *logrus.Entry) ([]string, error) {
+	loads := filterOverloadedReviewers(gc, opts, org, availableReviewers, log)
+	return pickReviewers(gc, opts, org, repo, num, availableReviewers, count, loads, log)
+}
+
+// filterOverloadedReviewers removes the reviewers who have too many pending review requests from the available
+// reviewers, and returns the loads of the reviewers if they are listed.
+func filterOverloadedReviewers(gc githubClient, opts *tiexternalplugins.TiCommunityBlunderbuss, org string,
+	availableReviewers sets.String, log *logrus.Entry) map[string]int {
+	if opts.MaxReviewerLoad <= 0 {
+		return nil
 	}
-	// Create weighted selectors chooser on the number of changes made to the code.
-	var choices []wr.Choice
-	for contributor, weight := range contributors {
-		choices = append(choices, wr.Choice{
-			Item:   contributor,
-			Weight: weight,
-		})
+	loads := listReviewerLoads(gc, org, availableReviewers.List(), log)
+	for reviewer, load := range loads {
+		if load >= opts.MaxReviewerLoad {
+			log.Infof("Skip reviewer %s with %d pending review requests.", reviewer, load)
+			availableReviewers.Delete(reviewer)
+		}
 	}
-	reviewers := sets.NewString()
+	return loads
+}
 
-	chooser, err := wr.NewChooser(
-		choices...,
-	)
-	if err != nil {
-		return err
-	}
-	for len(reviewers) < maxReviewerCount {
-		// Rand pick.
-		reviewers.Insert(chooser.Pick().(string))
+// pickReviewers picks at most count reviewers from the available reviewers with the strategy of the repo,
+// all the available reviewers are picked if count is 0. The loads are listed if they are nil but needed.
+func pickReviewers(gc githubClient, opts *tiexternalplugins.TiCommunityBlunderbuss, org string, repo string,
+	num int, availableReviewers sets.String, count int, loads map[string]int, log *logrus.Entry) ([]string, error) {
+	// If count is not set or there are not enough reviewers, then all reviewers are assigned.
+	if count == 0 || len(availableReviewers) <= count {
+		log.Infof("Choosing all available reviewers %s.", availableReviewers.List())
+		return availableReviewers.List(), nil
 	}
 
-	log.Infof("Requesting reviews from users %s.", reviewers.List())
-	return gc.RequestReview(repo.Owner.Login, repo.Name, pr.Number, reviewers.List())
+	// Always seed random!
+	rand.Seed(time.Now().UTC().UnixNano())
+	switch opts.Strategy {
+	case tiexternalplugins.BlunderbussStrategyRandom:
+		return pickRandomReviewers(availableReviewers, count), nil
+	case tiexternalplugins.BlunderbussStrategyLeastLoaded:
+		if loads == nil {
+			loads = listReviewerLoads(gc, org, availableReviewers.List(), log)
+		}
+		return pickLeastLoadedReviewers(availableReviewers, loads, count), nil
+	case tiexternalplugins.BlunderbussStrategyRoundRobin:
+		return pickRoundRobinReviewers(availableReviewers, num, count), nil
+	default:
+		return pickFileHistoryReviewers(gc, opts, org, repo, num, availableReviewers, count, log)
+	}
 }
 
 func listAvailableReviewers(author string, reviewers []string, includeReviewers []string, excludeReviewers []string,
-	requestedReviewers []github.User) sets.String {
+	requestedReviewers []github.User, unavailableReviewers []string) sets.String {
 	authorSet := sets.NewString(github.NormLogin(author))
 	includeReviewersSet := sets.NewString(includeReviewers...)
-	excludeReviewersSet := sets.NewString(excludeReviewers...)
+	excludeReviewersSet := sets.NewString(excludeReviewers...).Insert(unavailableReviewers...)
 	requestedReviewersSet := sets.NewString()
 	for _, reviewer := range requestedReviewers {
 		requestedReviewersSet.Insert(reviewer.Login)
@@ -287,40 +320,13 @@ func listAvailableReviewers(author string, reviewers []string, includeReviewers
 	if len(includeReviewers) != 0 {
 		nonReviewers := includeReviewersSet.Difference(reviewersSet)
 		includeReviewersSet = includeReviewersSet.Difference(nonReviewers)
-		return includeReviewersSet.Difference(authorSet).Difference(requestedReviewersSet)
+		return includeReviewersSet.Difference(authorSet).Difference(requestedReviewersSet).
+			Difference(sets.NewString(unavailableReviewers...))
 	}
 
 	return reviewersSet.Difference(authorSet).Difference(excludeReviewersSet).Difference(requestedReviewersSet)
 }
 
-func listChangesContributors(gc githubClient, org string, repo string, num int,
-	log *logrus.Entry) (map[string]uint, error) {
-	changes, err := gc.GetPullRequestChanges(org, repo, num)
-	if err != nil {
-		return nil, fmt.Errorf("error get pull request changes: %v", err)
-	}
-
-	contributors := make(map[string]uint)
-	for _, change := range changes {
-		commits, err := gc.ListFileCommits(org, repo, change.Filename)
-		if err != nil {
-			log.WithError(err).Warnf("Failed list file commits of %s.", change.Filename)
-		}
-
-		for _, commit := range commits {
-			contributor := commit.Author.Login
-			weight, ok := contributors[contributor]
-			if ok {
-				contributors[contributor] = weight + weightIncrement
-			} else {
-				contributors[contributor] = defaultWeight
-			}
-		}
-	}
-
-	return contributors, nil
-}
-
 func containSigLabel(labels []github.Label) bool {
 	for _, label := range labels {
 		if strings.HasPrefix(label.Name, tiexternalplugins.SigPrefix) {
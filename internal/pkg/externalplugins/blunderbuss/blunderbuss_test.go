@@ -1,13 +1,16 @@
 package blunderbuss
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
+	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
@@ -19,7 +22,17 @@ type fakeGitHubClient struct {
 	pr          *github.PullRequest
 	prChanges   []github.PullRequestChange
 	fileCommits map[string][]github.RepositoryCommit
+	// loads specifies the number of the pending review requests of the users.
+	loads          map[string]int
+	historyQueries int
+	// teams specifies the members of the teams of the org.
+	teams       map[string][]string
 	requested   []string
+	unrequested []string
+	comments    []github.IssueComment
+	// stalePRs specifies the PRs returned by the stale review searches, staleQueries records the searches.
+	stalePRs     []stalePullRequest
+	staleQueries []string
 }
 
 func newFakeGitHubClient(pr *github.PullRequest, prChanges []github.PullRequestChange,
@@ -41,6 +54,29 @@ func (c *fakeGitHubClient) RequestReview(org, repo string, number int, logins []
 	return nil
 }
 
+func (c *fakeGitHubClient) UnrequestReview(_, _ string, _ int, logins []string) error {
+	c.unrequested = append(c.unrequested, logins...)
+	return nil
+}
+
+func (c *fakeGitHubClient) ListIssueComments(_, _ string, _ int) ([]github.IssueComment, error) {
+	return c.comments, nil
+}
+
+func (c *fakeGitHubClient) CreateComment(_, _ string, _ int, comment string) error {
+	c.comments = append(c.comments, github.IssueComment{
+		Body: comment,
+		User: github.User{Login: "k8s-ci-robot"},
+	})
+	return nil
+}
+
+func (c *fakeGitHubClient) BotUserChecker() (func(candidate string) bool, error) {
+	return func(candidate string) bool {
+		return candidate == "k8s-ci-robot"
+	}, nil
+}
+
 func (c *fakeGitHubClient) GetPullRequest(_, _ string, _ int) (*github.PullRequest, error) {
 	return c.pr, nil
 }
@@ -60,12 +96,50 @@ func (c *fakeGitHubClient) GetPullRequestChanges(_, _ string, _ int) ([]github.P
 	return c.prChanges, nil
 }
 
-func (c *fakeGitHubClient) ListFileCommits(_, _, path string) ([]github.RepositoryCommit, error) {
-	commits, ok := c.fileCommits[path]
-	if ok {
-		return commits, nil
+func (c *fakeGitHubClient) Query(_ context.Context, q interface{}, vars map[string]interface{}) error {
+	switch query := q.(type) {
+	case *workloadQuery:
+		search := string(vars["query"].(githubql.String))
+		for login, load := range c.loads {
+			if search == fmt.Sprintf(workloadQueryFormat, login, "org") {
+				query.Search.IssueCount = githubql.Int(load)
+			}
+		}
+	case *historyQuery:
+		c.historyQueries++
+		commit := &query.Repository.DefaultBranchRef.Target.Commit
+		histories := []*history{&commit.History0, &commit.History1, &commit.History2, &commit.History3,
+			&commit.History4}
+		for i, h := range histories {
+			p := string(vars[fmt.Sprintf("path%d", i)].(githubql.String))
+			// The history of a directory contains the commits of the files in it.
+			for filename, commits := range c.fileCommits {
+				if filename != p && !strings.HasPrefix(filename, p+"/") {
+					continue
+				}
+				for _, commit := range commits {
+					node := historyCommit{CommittedDate: githubql.DateTime{Time: commit.Commit.Author.Date}}
+					node.Author.User.Login = githubql.String(commit.Author.Login)
+					h.Nodes = append(h.Nodes, node)
+				}
+			}
+		}
+	case *staleSearchQuery:
+		c.staleQueries = append(c.staleQueries, string(vars["query"].(githubql.String)))
+		for _, pr := range c.stalePRs {
+			query.Search.Nodes = append(query.Search.Nodes, struct {
+				PullRequest stalePullRequest `graphql:"... on PullRequest"`
+			}{PullRequest: pr})
+		}
+	case *teamMembersQuery:
+		for _, login := range c.teams[string(vars["slug"].(githubql.String))] {
+			query.Organization.Team.Members.Nodes = append(query.Organization.Team.Members.Nodes,
+				struct{ Login githubql.String }{Login: githubql.String(login)})
+		}
+	default:
+		return errors.New("unexpected query type")
 	}
-	return nil, errors.New("can not get commits of the file path")
+	return nil
 }
 
 type fakeOwnersClient struct {
@@ -523,6 +597,7 @@ func TestGetReviewers(t *testing.T) {
 		includeReviewers   []string
 		excludeReviewers   []string
 		requestedReviewers []github.User
+		unavailable        []string
 
 		expectReviewers []string
 	}{
@@ -590,11 +665,40 @@ func TestGetReviewers(t *testing.T) {
 				"reviewers1", "reviewers3",
 			},
 		},
+		{
+			name:   "unavailable reviewers",
+			author: "author",
+			reviewers: []string{
+				"author", "reviewers1", "reviewers2", "reviewers3",
+			},
+			unavailable: []string{
+				"reviewers1",
+			},
+			expectReviewers: []string{
+				"reviewers2", "reviewers3",
+			},
+		},
+		{
+			name:   "include unavailable reviewers",
+			author: "author",
+			reviewers: []string{
+				"author", "reviewers1", "reviewers2", "reviewers3",
+			},
+			includeReviewers: []string{
+				"reviewers2", "reviewers3",
+			},
+			unavailable: []string{
+				"reviewers3",
+			},
+			expectReviewers: []string{
+				"reviewers2",
+			},
+		},
 	}
 
 	for _, tc := range testcases {
 		reviewers := listAvailableReviewers(tc.author, tc.reviewers, tc.includeReviewers, tc.excludeReviewers,
-			tc.requestedReviewers).List()
+			tc.requestedReviewers, tc.unavailable).List()
 		sort.Strings(reviewers)
 		sort.Strings(tc.expectReviewers)
 		if !reflect.DeepEqual(reviewers, tc.expectReviewers) {
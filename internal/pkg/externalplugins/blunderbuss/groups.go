@@ -0,0 +1,155 @@
+package blunderbuss
+
+import (
+	"context"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+)
+
+// See: https://docs.github.com/en/graphql/reference/objects#team.
+type teamMembersQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Organization struct {
+		Team struct {
+			Members struct {
+				PageInfo struct {
+					HasNextPage githubql.Boolean
+					EndCursor   githubql.String
+				}
+				Nodes []struct {
+					Login githubql.String
+				}
+			} `graphql:"members(first: 100, after: $membersCursor)"`
+		} `graphql:"team(slug: $slug)"`
+	} `graphql:"organization(login: $org)"`
+}
+
+// selectGroupReviewers chooses the reviewers satisfying the minimum count of every reviewer group first,
+// and then fills up to the max reviewer count without exceeding the maximum count of any reviewer group.
+// The teams of the groups requesting team reviews are returned in the form of org/team.
+func selectGroupReviewers(gc githubClient, opts *tiexternalplugins.TiCommunityBlunderbuss, org string, repo string,
+	num int, availableReviewers sets.String, log *logrus.Entry) ([]string, error) {
+	loads := filterOverloadedReviewers(gc, opts, org, availableReviewers, log)
+
+	// The members of the groups are matched with the available reviewers case-insensitively.
+	groupMembers := make([]sets.String, len(opts.ReviewerGroups))
+	for i, group := range opts.ReviewerGroups {
+		members := listGroupMembers(gc, org, group, log)
+		groupMembers[i] = sets.NewString()
+		for _, reviewer := range availableReviewers.List() {
+			if members.Has(github.NormLogin(reviewer)) {
+				groupMembers[i].Insert(reviewer)
+			}
+		}
+	}
+
+	chosen := sets.NewString()
+	// candidates returns the reviewers who can be chosen without exceeding the maximum count of any group.
+	candidates := func(reviewers sets.String) sets.String {
+		result := reviewers.Difference(chosen)
+		for i, group := range opts.ReviewerGroups {
+			if group.MaxCount > 0 && chosen.Intersection(groupMembers[i]).Len() >= group.MaxCount {
+				result = result.Difference(groupMembers[i])
+			}
+		}
+		return result
+	}
+	// pickOne picks a reviewer from the candidates, it returns false if there is no candidate.
+	pickOne := func(reviewers sets.String) (bool, error) {
+		if reviewers.Len() == 0 {
+			return false, nil
+		}
+		picked, err := pickReviewers(gc, opts, org, repo, num, reviewers, 1, loads, log)
+		if err != nil {
+			return false, err
+		}
+		chosen.Insert(picked...)
+		return true, nil
+	}
+
+	var teams []string
+	for i, group := range opts.ReviewerGroups {
+		if group.MinCount == 0 {
+			continue
+		}
+		if group.RequestTeams {
+			for _, team := range group.Teams {
+				teams = append(teams, org+"/"+team)
+			}
+			continue
+		}
+		for chosen.Intersection(groupMembers[i]).Len() < group.MinCount {
+			ok, err := pickOne(candidates(groupMembers[i]))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				log.Warnf("Not enough reviewers are available in the reviewer group %s.", group.Name)
+				break
+			}
+		}
+	}
+
+	for opts.MaxReviewerCount == 0 || chosen.Len() < opts.MaxReviewerCount {
+		ok, err := pickOne(candidates(availableReviewers))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	return append(chosen.List(), teams...), nil
+}
+
+// listGroupMembers returns the normalized logins of the members of the reviewer group and its teams.
+// The members of a team are ignored if they cannot be queried.
+func listGroupMembers(gc githubClient, org string, group tiexternalplugins.BlunderbussReviewerGroup,
+	log *logrus.Entry) sets.String {
+	members := sets.NewString()
+	for _, member := range group.Members {
+		members.Insert(github.NormLogin(member))
+	}
+
+	var totalCost int
+	var remaining int
+	for _, team := range group.Teams {
+		vars := map[string]interface{}{
+			"org":           githubql.String(org),
+			"slug":          githubql.String(team),
+			"membersCursor": (*githubql.String)(nil),
+		}
+		for {
+			tq := teamMembersQuery{}
+			if err := gc.Query(context.Background(), &tq, vars); err != nil {
+				log.WithError(err).Warnf("Failed query the members of team %s.", team)
+				break
+			}
+			totalCost += int(tq.RateLimit.Cost)
+			remaining = int(tq.RateLimit.Remaining)
+			teamMembers := tq.Organization.Team.Members
+			for _, node := range teamMembers.Nodes {
+				members.Insert(github.NormLogin(string(node.Login)))
+			}
+			if !teamMembers.PageInfo.HasNextPage {
+				break
+			}
+			vars["membersCursor"] = githubql.NewString(teamMembers.PageInfo.EndCursor)
+		}
+	}
+	if len(group.Teams) != 0 {
+		log.Infof("Query for the members of the teams %v cost %d point(s). %d remaining.", group.Teams,
+			totalCost, remaining)
+		tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
+	}
+	return members
+}
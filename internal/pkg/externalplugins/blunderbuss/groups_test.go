@@ -0,0 +1,102 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandleWithReviewerGroups(t *testing.T) {
+	loads := map[string]int{
+		"lead1": 9, "lead2": 8, "committer1": 7, "committer2": 6,
+		"company-a1": 1, "company-a2": 2, "company-b1": 3, "other": 0,
+	}
+	teams := map[string][]string{"committers": {"Committer1", "committer2"}}
+
+	testcases := []struct {
+		name             string
+		reviewers        []string
+		groups           []tiexternalplugins.BlunderbussReviewerGroup
+		maxReviewerCount int
+
+		expectReviewers []string
+	}{
+		{
+			name:      "at least one from each group",
+			reviewers: []string{"lead1", "lead2", "committer1", "committer2", "other"},
+			groups: []tiexternalplugins.BlunderbussReviewerGroup{
+				{Name: "leads", Members: []string{"lead1", "lead2"}, MinCount: 1},
+				{Name: "committers", Teams: []string{"committers"}, MinCount: 1},
+			},
+			maxReviewerCount: 2,
+			expectReviewers:  []string{"committer2", "lead2"},
+		},
+		{
+			name:      "fill up to the max reviewer count",
+			reviewers: []string{"lead1", "lead2", "committer1", "committer2", "other"},
+			groups: []tiexternalplugins.BlunderbussReviewerGroup{
+				{Name: "leads", Members: []string{"lead1", "lead2"}, MinCount: 1},
+			},
+			maxReviewerCount: 3,
+			expectReviewers:  []string{"committer2", "lead2", "other"},
+		},
+		{
+			name:      "never two reviewers from the same company",
+			reviewers: []string{"company-a1", "company-a2", "company-b1"},
+			groups: []tiexternalplugins.BlunderbussReviewerGroup{
+				{Name: "company-a", Members: []string{"company-a1", "company-a2"}, MaxCount: 1},
+				{Name: "company-b", Members: []string{"company-b1"}, MaxCount: 1},
+			},
+			maxReviewerCount: 3,
+			expectReviewers:  []string{"company-a1", "company-b1"},
+		},
+		{
+			name:      "request team reviews",
+			reviewers: []string{"lead1", "other"},
+			groups: []tiexternalplugins.BlunderbussReviewerGroup{
+				{Name: "committers", Teams: []string{"committers"}, MinCount: 1, RequestTeams: true},
+			},
+			maxReviewerCount: 1,
+			expectReviewers:  []string{"org/committers", "other"},
+		},
+		{
+			name:      "not enough reviewers in the group",
+			reviewers: []string{"lead1", "committer1", "other"},
+			groups: []tiexternalplugins.BlunderbussReviewerGroup{
+				{Name: "leads", Members: []string{"lead1", "lead2"}, MinCount: 2},
+			},
+			maxReviewerCount: 2,
+			expectReviewers:  []string{"lead1", "other"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{Number: 5, User: github.User{Login: "author"}}
+			fc := newFakeGitHubClient(pr, nil, nil)
+			fc.loads = loads
+			fc.teams = teams
+			foc := &fakeOwnersClient{reviewers: tc.reviewers, needsLgtm: 2}
+			opts := &tiexternalplugins.TiCommunityBlunderbuss{
+				MaxReviewerCount: tc.maxReviewerCount,
+				Strategy:         tiexternalplugins.BlunderbussStrategyLeastLoaded,
+				ReviewerGroups:   tc.groups,
+			}
+			repo := &github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+
+			if err := handle(fc, opts, repo, pr, logrus.WithField("plugin", PluginName), foc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			requested := fc.requested
+			sort.Strings(requested)
+			if !reflect.DeepEqual(requested, tc.expectReviewers) {
+				t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", requested, tc.expectReviewers)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package blunderbuss
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+)
+
+const (
+	// historyBatchSize specifies the number of the paths whose history is queried by one GraphQL query.
+	historyBatchSize = 5
+	// historyCommitsPerPath specifies the number of the latest commits of a path in its history.
+	historyCommitsPerPath = 100
+	// historyCacheTTL specifies the time to cache the history of a path.
+	historyCacheTTL = time.Hour
+	// historyCacheSize specifies the number of the cached paths after which the expired ones are evicted.
+	historyCacheSize = 10000
+)
+
+// history is the commit history of a path in the default branch.
+type history struct {
+	Nodes []historyCommit
+}
+
+type historyCommit struct {
+	CommittedDate githubql.DateTime
+	Author        struct {
+		User struct {
+			Login githubql.String
+		}
+	}
+}
+
+// See: https://docs.github.com/en/graphql/reference/objects#commit.
+type historyQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Repository struct {
+		DefaultBranchRef struct {
+			Target struct {
+				Commit struct {
+					History0 history `graphql:"history0: history(first: $first, path: $path0)"`
+					History1 history `graphql:"history1: history(first: $first, path: $path1)"`
+					History2 history `graphql:"history2: history(first: $first, path: $path2)"`
+					History3 history `graphql:"history3: history(first: $first, path: $path3)"`
+					History4 history `graphql:"history4: history(first: $first, path: $path4)"`
+				} `graphql:"... on Commit"`
+			}
+		}
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// histories returns the histories of the paths in the order of the query variables.
+func (q *historyQuery) histories() []history {
+	commit := &q.Repository.DefaultBranchRef.Target.Commit
+	return []history{commit.History0, commit.History1, commit.History2, commit.History3, commit.History4}
+}
+
+// contribution is a commit of the contributor to a path.
+type contribution struct {
+	login         string
+	committedDate time.Time
+}
+
+type historyCacheEntry struct {
+	contributions []contribution
+	expiry        time.Time
+}
+
+// historyCache caches the history of the paths between the PRs.
+type historyCache struct {
+	lock    sync.Mutex
+	entries map[string]historyCacheEntry
+	now     func() time.Time
+}
+
+func newHistoryCache() *historyCache {
+	return &historyCache{entries: make(map[string]historyCacheEntry), now: time.Now}
+}
+
+func (c *historyCache) get(key string) ([]contribution, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.now().After(entry.expiry) {
+		return nil, false
+	}
+	return entry.contributions, true
+}
+
+func (c *historyCache) put(key string, contributions []contribution) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	if len(c.entries) >= historyCacheSize {
+		for k, entry := range c.entries {
+			if now.After(entry.expiry) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = historyCacheEntry{contributions: contributions, expiry: now.Add(historyCacheTTL)}
+}
+
+var pathHistoryCache = newHistoryCache()
+
+// listChangesContributors returns the weight of the recent contributions of the contributors to the changes.
+// At most maxPaths paths are sampled, the weight of a commit is halved every halfLife days.
+func listChangesContributors(gc githubClient, org string, repo string, num int, maxPaths int, halfLife int,
+	log *logrus.Entry) (map[string]uint, error) {
+	changes, err := gc.GetPullRequestChanges(org, repo, num)
+	if err != nil {
+		return nil, fmt.Errorf("error get pull request changes: %v", err)
+	}
+
+	var filenames []string
+	for _, change := range changes {
+		filenames = append(filenames, change.Filename)
+	}
+	paths := samplePaths(filenames, maxPaths)
+	contributions := loadHistories(gc, org, repo, paths, log)
+
+	now := time.Now()
+	weights := make(map[string]float64)
+	for _, p := range paths {
+		for _, c := range contributions[p] {
+			weights[c.login] += weightIncrement * decay(now.Sub(c.committedDate), halfLife)
+		}
+	}
+
+	contributors := make(map[string]uint)
+	for contributor, weight := range weights {
+		contributors[contributor] = uint(math.Round(weight))
+	}
+	return contributors, nil
+}
+
+// samplePaths returns the directories of the changed files, the directories with more changed files are
+// preferred if there are more than maxPaths directories. The files in the root directory are used as is.
+func samplePaths(filenames []string, maxPaths int) []string {
+	counts := make(map[string]int)
+	for _, filename := range filenames {
+		dir := path.Dir(filename)
+		if dir == "." {
+			dir = filename
+		}
+		counts[dir]++
+	}
+
+	paths := make([]string, 0, len(counts))
+	for p := range counts {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if counts[paths[i]] != counts[paths[j]] {
+			return counts[paths[i]] > counts[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	if maxPaths > 0 && len(paths) > maxPaths {
+		paths = paths[:maxPaths]
+	}
+	return paths
+}
+
+// loadHistories returns the contributions to the paths, the histories of the uncached paths are queried in
+// batches. The paths whose history cannot be queried have no contributions.
+func loadHistories(gc githubClient, org string, repo string, paths []string,
+	log *logrus.Entry) map[string][]contribution {
+	contributions := make(map[string][]contribution)
+	var uncached []string
+	for _, p := range paths {
+		if cached, ok := pathHistoryCache.get(historyCacheKey(org, repo, p)); ok {
+			contributions[p] = cached
+		} else {
+			uncached = append(uncached, p)
+		}
+	}
+
+	var totalCost int
+	var remaining int
+	for start := 0; start < len(uncached); start += historyBatchSize {
+		batch := uncached[start:]
+		if len(batch) > historyBatchSize {
+			batch = batch[:historyBatchSize]
+		}
+		vars := map[string]interface{}{
+			"owner": githubql.String(org),
+			"name":  githubql.String(repo),
+			"first": githubql.Int(historyCommitsPerPath),
+		}
+		// The query always has the same number of the paths, the last path fills the rest of a short batch.
+		for i := 0; i < historyBatchSize; i++ {
+			p := batch[len(batch)-1]
+			if i < len(batch) {
+				p = batch[i]
+			}
+			vars[fmt.Sprintf("path%d", i)] = githubql.String(p)
+		}
+
+		hq := historyQuery{}
+		if err := gc.Query(context.Background(), &hq, vars); err != nil {
+			log.WithError(err).Warnf("Failed query the history of %v.", batch)
+			continue
+		}
+		totalCost += int(hq.RateLimit.Cost)
+		remaining = int(hq.RateLimit.Remaining)
+
+		histories := hq.histories()
+		for i, p := range batch {
+			var pathContributions []contribution
+			for _, node := range histories[i].Nodes {
+				// The commits of the authors without a GitHub account are ignored.
+				if len(node.Author.User.Login) == 0 {
+					continue
+				}
+				pathContributions = append(pathContributions, contribution{
+					login:         string(node.Author.User.Login),
+					committedDate: node.CommittedDate.Time,
+				})
+			}
+			contributions[p] = pathContributions
+			pathHistoryCache.put(historyCacheKey(org, repo, p), pathContributions)
+		}
+	}
+	if len(uncached) != 0 {
+		log.Infof("Query for the history of %d path(s) cost %d point(s). %d remaining.", len(uncached),
+			totalCost, remaining)
+		tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
+	}
+	return contributions
+}
+
+func historyCacheKey(org, repo, path string) string {
+	return org + "/" + repo + "/" + path
+}
+
+// decay returns the factor of the weight of a commit with the age, which is halved every halfLife days.
+// The weight does not decay if halfLife is not positive.
+func decay(age time.Duration, halfLife int) float64 {
+	if halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, age.Hours()/24/float64(halfLife))
+}
@@ -0,0 +1,78 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestSamplePaths(t *testing.T) {
+	testcases := []struct {
+		name      string
+		filenames []string
+		maxPaths  int
+
+		expectPaths []string
+	}{
+		{
+			name:        "directories of the files",
+			filenames:   []string{"executor/join.go", "executor/sort.go", "planner/plan.go", "main.go"},
+			expectPaths: []string{"executor", "main.go", "planner"},
+		},
+		{
+			name: "directories with more files are preferred",
+			filenames: []string{"planner/plan.go", "executor/join.go", "executor/sort.go",
+				"util/codec/codec.go", "util/codec/bytes.go", "util/codec/number.go"},
+			maxPaths:    2,
+			expectPaths: []string{"util/codec", "executor"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			paths := samplePaths(tc.filenames, tc.maxPaths)
+			if !reflect.DeepEqual(paths, tc.expectPaths) {
+				t.Errorf("Different paths: Got \"%v\" expected \"%v\"", paths, tc.expectPaths)
+			}
+		})
+	}
+}
+
+func TestListChangesContributors(t *testing.T) {
+	pathHistoryCache = newHistoryCache()
+	now := time.Now()
+	commit := func(login string, age time.Duration) github.RepositoryCommit {
+		c := github.RepositoryCommit{Author: github.User{Login: login}}
+		c.Commit.Author.Date = now.Add(-age)
+		return c
+	}
+
+	var changes []github.PullRequestChange
+	fileCommits := make(map[string][]github.RepositoryCommit)
+	// The 7 directories of the changes need 2 queries.
+	for _, dir := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		changes = append(changes, github.PullRequestChange{Filename: dir + "/file.go"})
+	}
+	fileCommits["a/file.go"] = []github.RepositoryCommit{commit("recent", time.Hour), commit("old", 360*24*time.Hour)}
+	fileCommits["g/file.go"] = []github.RepositoryCommit{commit("recent", 180*24*time.Hour)}
+	fc := newFakeGitHubClient(&github.PullRequest{Number: 5}, changes, fileCommits)
+
+	expectContributors := map[string]uint{"recent": 150, "old": 25}
+	for i := 0; i < 2; i++ {
+		contributors, err := listChangesContributors(fc, "org", "repo", 5, 20, 180, logrus.WithField("plugin", PluginName))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(contributors, expectContributors) {
+			t.Errorf("Different contributors: Got \"%v\" expected \"%v\"", contributors, expectContributors)
+		}
+	}
+	// The histories are cached for the second time.
+	if fc.historyQueries != 2 {
+		t.Errorf("Different queries: Got \"%v\" expected \"%v\"", fc.historyQueries, 2)
+	}
+}
@@ -0,0 +1,349 @@
+package blunderbuss
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/interrupts"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+)
+
+const (
+	// staleReviewNotificationIdentifier defines the identifier for the stale review notifications.
+	staleReviewNotificationIdentifier = "Stale Review Notification Identifier"
+	// staleReviewSearchQueryPrefix is the search query of the open PRs requesting reviews.
+	staleReviewSearchQueryPrefix = "archived:false is:pr is:open draft:false"
+)
+
+// See: https://docs.github.com/en/graphql/reference/objects#pullrequest.
+type stalePullRequest struct {
+	Number     githubql.Int
+	Repository struct {
+		Name  githubql.String
+		Owner struct {
+			Login githubql.String
+		}
+	}
+	Author struct {
+		Login githubql.String
+	}
+	BaseRef struct {
+		Name githubql.String
+	}
+	Labels struct {
+		Nodes []struct {
+			Name githubql.String
+		}
+	} `graphql:"labels(first: 100)"`
+	ReviewRequests struct {
+		Nodes []struct {
+			RequestedReviewer struct {
+				User struct {
+					Login githubql.String
+				} `graphql:"... on User"`
+			}
+		}
+	} `graphql:"reviewRequests(first: 100)"`
+	TimelineItems struct {
+		Nodes []struct {
+			ReviewRequestedEvent struct {
+				CreatedAt         githubql.DateTime
+				RequestedReviewer struct {
+					User struct {
+						Login githubql.String
+					} `graphql:"... on User"`
+				}
+			} `graphql:"... on ReviewRequestedEvent"`
+		}
+	} `graphql:"timelineItems(last: 100, itemTypes: [REVIEW_REQUESTED_EVENT])"`
+}
+
+type staleSearchQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Search struct {
+		PageInfo struct {
+			HasNextPage githubql.Boolean
+			EndCursor   githubql.String
+		}
+		Nodes []struct {
+			PullRequest stalePullRequest `graphql:"... on PullRequest"`
+		}
+	} `graphql:"search(type: ISSUE, first: 100, after: $searchCursor, query: $query)"`
+}
+
+// staleReviewers returns the requested reviewers whose latest review request is older than the deadline.
+func (pr *stalePullRequest) staleReviewers(deadline time.Time) []string {
+	requested := sets.NewString()
+	for _, node := range pr.ReviewRequests.Nodes {
+		if login := string(node.RequestedReviewer.User.Login); len(login) != 0 {
+			requested.Insert(login)
+		}
+	}
+
+	requestedAt := make(map[string]time.Time)
+	for _, node := range pr.TimelineItems.Nodes {
+		event := node.ReviewRequestedEvent
+		login := string(event.RequestedReviewer.User.Login)
+		if event.CreatedAt.Time.After(requestedAt[login]) {
+			requestedAt[login] = event.CreatedAt.Time
+		}
+	}
+
+	var stale []string
+	for _, login := range requested.List() {
+		// The reviewers whose review requests are not in the latest timeline items are stale for a long time.
+		if requestedAt[login].Before(deadline) {
+			stale = append(stale, login)
+		}
+	}
+	return stale
+}
+
+// HandleStaleReviews requests reviews from other reviewers for the open PRs whose requested reviewers
+// have not reviewed them in time. The repos with the stale review check enabled at the repo level or
+// for any branch are searched, and each PR is checked with the config of its base branch.
+func HandleStaleReviews(log *logrus.Entry, gc githubClient, ol ownersclient.OwnersLoader,
+	cfg *tiexternalplugins.Configuration) {
+	log.Info("Checking the stale reviewers of all PRs.")
+	// Do _not_ parallelize this. It will trigger GitHub's abuse detection.
+	checked := sets.NewString()
+	for _, blunderbuss := range cfg.TiCommunityBlunderbuss {
+		for _, orgRepo := range blunderbuss.Repos {
+			if checked.Has(orgRepo) {
+				continue
+			}
+			checked.Insert(orgRepo)
+			org, repo := orgRepo, ""
+			if parts := strings.SplitN(orgRepo, "/", 2); len(parts) == 2 {
+				org, repo = parts[0], parts[1]
+			}
+			opts := cfg.BlunderbussFor(org, repo)
+			branches := cfg.BlunderbussBranchesFor(org, repo)
+			if !staleReviewEnabled(cfg, org, repo, opts, branches) {
+				continue
+			}
+
+			var query bytes.Buffer
+			fmt.Fprint(&query, staleReviewSearchQueryPrefix)
+			if len(repo) == 0 {
+				fmt.Fprintf(&query, " org:\"%s\"", org)
+			} else {
+				fmt.Fprintf(&query, " repo:\"%s\"", orgRepo)
+			}
+			// The exclude labels can be overridden by the branch level configs, then the labels of each PR
+			// are checked against the config of its base branch instead.
+			if len(branches) == 0 {
+				for _, label := range opts.StaleReviewExcludeLabels {
+					fmt.Fprintf(&query, " -label:\"%s\"", label)
+				}
+			}
+
+			prs, err := searchStalePullRequests(context.Background(), log, gc, query.String())
+			if err != nil {
+				log.WithError(err).Error("Error was encountered when querying GitHub, " +
+					"but the remaining repositories will be processed anyway.")
+				continue
+			}
+
+			log.Infof("Considering %d PRs of %s.", len(prs), orgRepo)
+			for i := range prs {
+				pr := &prs[i]
+				l := log.WithFields(logrus.Fields{
+					"org":  string(pr.Repository.Owner.Login),
+					"repo": string(pr.Repository.Name),
+					"pr":   int(pr.Number),
+				})
+				if err := handleStaleReview(gc, cfg, pr, ol, time.Now(), l); err != nil {
+					l.WithError(err).Error("Failed to handle the stale reviewers, " +
+						"but the remaining PRs will be processed anyway.")
+				}
+			}
+		}
+	}
+}
+
+// staleReviewEnabled checks if the stale review check is enabled at the repo level or for any of the branches.
+func staleReviewEnabled(cfg *tiexternalplugins.Configuration, org, repo string,
+	opts *tiexternalplugins.TiCommunityBlunderbuss, branches []string) bool {
+	if opts.StaleReviewHours != 0 {
+		return true
+	}
+	for _, branch := range branches {
+		if cfg.BlunderbussForBranch(org, repo, branch).StaleReviewHours != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// handleStaleReview requests reviews from other reviewers if the requested reviewers of the PR are stale,
+// the PR is handled at most once in the stale review hours.
+func handleStaleReview(gc githubClient, cfg *tiexternalplugins.Configuration, pr *stalePullRequest,
+	ol ownersclient.OwnersLoader, now time.Time, log *logrus.Entry) error {
+	org := string(pr.Repository.Owner.Login)
+	repo := string(pr.Repository.Name)
+	num := int(pr.Number)
+	opts := cfg.BlunderbussForBranch(org, repo, string(pr.BaseRef.Name))
+	if opts.StaleReviewHours == 0 {
+		return nil
+	}
+	excludeLabels := sets.NewString(opts.StaleReviewExcludeLabels...)
+	for _, label := range pr.Labels.Nodes {
+		if excludeLabels.Has(string(label.Name)) {
+			log.Infof("Skip the PR with the exclude label %s.", label.Name)
+			return nil
+		}
+	}
+	deadline := now.Add(-time.Duration(opts.StaleReviewHours) * time.Hour)
+
+	stale := pr.staleReviewers(deadline)
+	if len(stale) == 0 {
+		return nil
+	}
+
+	comments, err := gc.ListIssueComments(org, repo, num)
+	if err != nil {
+		return fmt.Errorf("error listing issue comments: %v", err)
+	}
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return fmt.Errorf("error getting bot user checker: %v", err)
+	}
+	for _, comment := range comments {
+		if botUserChecker(comment.User.Login) && strings.Contains(comment.Body, staleReviewNotificationIdentifier) &&
+			comment.CreatedAt.After(deadline) {
+			log.Infof("Skip the PR notified about the stale reviewers at %v.", comment.CreatedAt)
+			return nil
+		}
+	}
+
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, num)
+	if err != nil {
+		return fmt.Errorf("error loading repo owners: %v", err)
+	}
+	var requestedReviewers []github.User
+	for _, node := range pr.ReviewRequests.Nodes {
+		requestedReviewers = append(requestedReviewers, github.User{Login: string(node.RequestedReviewer.User.Login)})
+	}
+	availableReviewers := listAvailableReviewers(string(pr.Author.Login), owners.Reviewers, opts.IncludeReviewers,
+		opts.ExcludeReviewers, requestedReviewers, owners.Unavailable)
+
+	count := 1
+	if opts.ReassignStaleReviewers {
+		count = len(stale)
+	}
+	reviewers, err := selectReviewers(gc, opts, org, repo, num, availableReviewers, count, log)
+	if err != nil {
+		return err
+	}
+	if len(reviewers) == 0 {
+		log.Infof("No other reviewers are available to replace the stale reviewers %s.", stale)
+		return nil
+	}
+
+	if opts.ReassignStaleReviewers {
+		log.Infof("Replacing the stale reviewers %s with %s.", stale, reviewers)
+		if err := gc.UnrequestReview(org, repo, num, stale); err != nil {
+			return fmt.Errorf("error unrequesting the stale reviewers: %v", err)
+		}
+	} else {
+		log.Infof("Requesting reviews from %s besides the stale reviewers %s.", reviewers, stale)
+	}
+	if err := gc.RequestReview(org, repo, num, reviewers); err != nil {
+		return fmt.Errorf("error requesting reviews: %v", err)
+	}
+
+	return gc.CreateComment(org, repo, num, staleReviewMessage(stale, reviewers, opts))
+}
+
+func staleReviewMessage(stale []string, reviewers []string, opts *tiexternalplugins.TiCommunityBlunderbuss) string {
+	action := "so the review is also requested from"
+	if opts.ReassignStaleReviewers {
+		action = "so the review is requested from"
+	}
+	return fmt.Sprintf("%s did not review this pull request within %d hours, %s %s.\n\n<!--%s-->",
+		mentions(stale), opts.StaleReviewHours, action, mentions(reviewers), staleReviewNotificationIdentifier)
+}
+
+func mentions(logins []string) string {
+	var ats []string
+	for _, login := range logins {
+		ats = append(ats, "@"+login)
+	}
+	return strings.Join(ats, ", ")
+}
+
+func searchStalePullRequests(ctx context.Context, log *logrus.Entry, gc githubClient,
+	q string) ([]stalePullRequest, error) {
+	var ret []stalePullRequest
+	vars := map[string]interface{}{
+		"query":        githubql.String(q),
+		"searchCursor": (*githubql.String)(nil),
+	}
+	var totalCost int
+	var remaining int
+	for {
+		sq := staleSearchQuery{}
+		if err := gc.Query(ctx, &sq, vars); err != nil {
+			return nil, err
+		}
+		totalCost += int(sq.RateLimit.Cost)
+		remaining = int(sq.RateLimit.Remaining)
+		for _, n := range sq.Search.Nodes {
+			ret = append(ret, n.PullRequest)
+		}
+		if !sq.Search.PageInfo.HasNextPage {
+			break
+		}
+		vars["searchCursor"] = githubql.NewString(sq.Search.PageInfo.EndCursor)
+	}
+	log.Infof("Search for query \"%s\" cost %d point(s). %d remaining.", q, totalCost, remaining)
+	tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
+	return ret, nil
+}
+
+// StartPeriodicStaleReviewCheck checks the stale reviewers of all PRs periodically.
+func StartPeriodicStaleReviewCheck(log *logrus.Entry, gc githubClient, ol ownersclient.OwnersLoader,
+	epa *tiexternalplugins.ConfigAgent, period time.Duration) {
+	// Avoid the periodic check and the check triggered by config changes running at the same time.
+	var handleAllMut sync.Mutex
+	handleAll := func() {
+		handleAllMut.Lock()
+		defer handleAllMut.Unlock()
+		start := time.Now()
+		HandleStaleReviews(log, gc, ol, epa.Config())
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Periodic stale review check complete.")
+	}
+
+	interrupts.TickLiteral(handleAll, period)
+
+	deltas := make(chan tiexternalplugins.Delta)
+	interrupts.Run(func(ctx context.Context) {
+		epa.Subscribe(ctx, deltas)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case delta := <-deltas:
+				if !reflect.DeepEqual(delta.Before.TiCommunityBlunderbuss, delta.After.TiCommunityBlunderbuss) {
+					log.Info("The blunderbuss configuration changed, checking the stale reviewers of all PRs now.")
+					handleAll()
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package blunderbuss
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+// newStalePullRequest returns the PR 5 of org/repo requesting reviews from the reviewers at the times.
+func newStalePullRequest(t *testing.T, requestedAt map[string]time.Time) *stalePullRequest {
+	var requests, events []string
+	for login, at := range requestedAt {
+		requests = append(requests, fmt.Sprintf(`{"requestedReviewer": {"user": {"login": %q}}}`, login))
+		events = append(events, fmt.Sprintf(`{"reviewRequestedEvent": {"createdAt": %q, `+
+			`"requestedReviewer": {"user": {"login": %q}}}}`, at.Format(time.RFC3339), login))
+	}
+	data := fmt.Sprintf(`{"number": 5, "repository": {"name": "repo", "owner": {"login": "org"}}, `+
+		`"author": {"login": "author"}, "baseRef": {"name": "master"}, "reviewRequests": {"nodes": [%s]}, `+
+		`"timelineItems": {"nodes": [%s]}}`, strings.Join(requests, ", "), strings.Join(events, ", "))
+
+	pr := &stalePullRequest{}
+	if err := json.Unmarshal([]byte(data), pr); err != nil {
+		t.Fatalf("unmarshal the PR failed: %v", err)
+	}
+	return pr
+}
+
+func TestHandleStaleReview(t *testing.T) {
+	now := time.Now()
+	staleNotification := github.IssueComment{
+		Body:      "<!--" + staleReviewNotificationIdentifier + "-->",
+		User:      github.User{Login: "k8s-ci-robot"},
+		CreatedAt: now.Add(-2 * time.Hour),
+	}
+
+	testcases := []struct {
+		name        string
+		requestedAt map[string]time.Time
+		reviewers   []string
+		reassign    bool
+		comments    []github.IssueComment
+
+		expectRequested   []string
+		expectUnrequested []string
+		expectComment     string
+	}{
+		{
+			name:        "no stale reviewers",
+			requestedAt: map[string]time.Time{"reviewer1": now.Add(-time.Hour)},
+			reviewers:   []string{"reviewer1", "reviewer2"},
+		},
+		{
+			name:            "additional reviewer",
+			requestedAt:     map[string]time.Time{"reviewer1": now.Add(-48 * time.Hour)},
+			reviewers:       []string{"author", "reviewer1", "reviewer2"},
+			expectRequested: []string{"reviewer2"},
+			expectComment: "@reviewer1 did not review this pull request within 24 hours, " +
+				"so the review is also requested from @reviewer2.\n\n<!--" + staleReviewNotificationIdentifier + "-->",
+		},
+		{
+			name: "reassign stale reviewers",
+			requestedAt: map[string]time.Time{
+				"reviewer1": now.Add(-48 * time.Hour),
+				"reviewer3": now.Add(-time.Hour),
+			},
+			reviewers:         []string{"reviewer1", "reviewer2", "reviewer3"},
+			reassign:          true,
+			expectRequested:   []string{"reviewer2"},
+			expectUnrequested: []string{"reviewer1"},
+			expectComment: "@reviewer1 did not review this pull request within 24 hours, " +
+				"so the review is requested from @reviewer2.\n\n<!--" + staleReviewNotificationIdentifier + "-->",
+		},
+		{
+			name:        "notified recently",
+			requestedAt: map[string]time.Time{"reviewer1": now.Add(-48 * time.Hour)},
+			reviewers:   []string{"reviewer1", "reviewer2"},
+			comments:    []github.IssueComment{staleNotification},
+		},
+		{
+			name:        "no other reviewers",
+			requestedAt: map[string]time.Time{"reviewer1": now.Add(-48 * time.Hour)},
+			reviewers:   []string{"reviewer1"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := newFakeGitHubClient(&github.PullRequest{Number: 5}, nil, nil)
+			fc.comments = tc.comments
+			foc := &fakeOwnersClient{reviewers: tc.reviewers, needsLgtm: 2}
+			cfg := &tiexternalplugins.Configuration{}
+			cfg.TiCommunityBlunderbuss = []tiexternalplugins.TiCommunityBlunderbuss{
+				{
+					Repos:                  []string{"org/repo"},
+					MaxReviewerCount:       2,
+					StaleReviewHours:       24,
+					ReassignStaleReviewers: tc.reassign,
+				},
+			}
+
+			pr := newStalePullRequest(t, tc.requestedAt)
+			err := handleStaleReview(fc, cfg, pr, foc, now, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(fc.requested, tc.expectRequested) {
+				t.Errorf("Different requested: Got \"%v\" expected \"%v\"", fc.requested, tc.expectRequested)
+			}
+			if !reflect.DeepEqual(fc.unrequested, tc.expectUnrequested) {
+				t.Errorf("Different unrequested: Got \"%v\" expected \"%v\"", fc.unrequested, tc.expectUnrequested)
+			}
+			var comment string
+			if len(fc.comments) > len(tc.comments) {
+				comment = fc.comments[len(fc.comments)-1].Body
+			}
+			if comment != tc.expectComment {
+				t.Errorf("Different comment: Got \"%v\" expected \"%v\"", comment, tc.expectComment)
+			}
+		})
+	}
+}
+
+func TestHandleStaleReviews(t *testing.T) {
+	now := time.Now()
+	requestedAt := map[string]time.Time{"reviewer1": now.Add(-48 * time.Hour)}
+
+	testcases := []struct {
+		name     string
+		base     string
+		labels   []string
+		branches map[string]tiexternalplugins.TiCommunityBlunderbuss
+
+		expectQueries   []string
+		expectRequested []string
+	}{
+		{
+			name: "stale review check disabled",
+			base: "release-5.0",
+		},
+		{
+			name: "stale review check enabled for the branch",
+			base: "release-5.0",
+			branches: map[string]tiexternalplugins.TiCommunityBlunderbuss{
+				"release-*": {
+					StaleReviewHours: 24,
+				},
+			},
+			expectQueries:   []string{staleReviewSearchQueryPrefix + " repo:\"org/repo\""},
+			expectRequested: []string{"reviewer2"},
+		},
+		{
+			name: "stale review check enabled for the other branch",
+			base: "master",
+			branches: map[string]tiexternalplugins.TiCommunityBlunderbuss{
+				"release-*": {
+					StaleReviewHours: 24,
+				},
+			},
+			expectQueries: []string{staleReviewSearchQueryPrefix + " repo:\"org/repo\""},
+		},
+		{
+			name:   "exclude label of the branch",
+			base:   "release-5.0",
+			labels: []string{"status/pending"},
+			branches: map[string]tiexternalplugins.TiCommunityBlunderbuss{
+				"release-*": {
+					StaleReviewHours:         24,
+					StaleReviewExcludeLabels: []string{"status/pending"},
+				},
+			},
+			expectQueries: []string{staleReviewSearchQueryPrefix + " repo:\"org/repo\""},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := newStalePullRequest(t, requestedAt)
+			pr.BaseRef.Name = githubql.String(tc.base)
+			for _, label := range tc.labels {
+				pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+			}
+			fc := newFakeGitHubClient(&github.PullRequest{Number: 5}, nil, nil)
+			fc.stalePRs = []stalePullRequest{*pr}
+			foc := &fakeOwnersClient{reviewers: []string{"reviewer1", "reviewer2"}, needsLgtm: 2}
+			cfg := &tiexternalplugins.Configuration{}
+			cfg.TiCommunityBlunderbuss = []tiexternalplugins.TiCommunityBlunderbuss{
+				{
+					Repos:            []string{"org/repo"},
+					MaxReviewerCount: 2,
+					Branches:         tc.branches,
+				},
+			}
+
+			HandleStaleReviews(logrus.WithField("plugin", PluginName), fc, foc, cfg)
+			if !reflect.DeepEqual(fc.staleQueries, tc.expectQueries) {
+				t.Errorf("Different queries: Got \"%v\" expected \"%v\"", fc.staleQueries, tc.expectQueries)
+			}
+			if !reflect.DeepEqual(fc.requested, tc.expectRequested) {
+				t.Errorf("Different requested: Got \"%v\" expected \"%v\"", fc.requested, tc.expectRequested)
+			}
+		})
+	}
+}
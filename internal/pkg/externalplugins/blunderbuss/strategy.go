@@ -0,0 +1,133 @@
+package blunderbuss
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	wr "github.com/mroth/weightedrand"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+)
+
+// workloadQueryFormat is the search query of the open PRs requesting reviews from the user in the org.
+const workloadQueryFormat = "archived:false is:pr is:open review-requested:%s org:%s"
+
+// See: https://docs.github.com/en/graphql/reference/queries#search.
+type workloadQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Search struct {
+		IssueCount githubql.Int
+	} `graphql:"search(type: ISSUE, first: 1, query: $query)"`
+}
+
+// listReviewerLoads returns the number of the pending review requests of the reviewers in the org.
+// The load of a reviewer is 0 if it cannot be searched.
+func listReviewerLoads(gc githubClient, org string, reviewers []string, log *logrus.Entry) map[string]int {
+	loads := make(map[string]int)
+	var totalCost int
+	var remaining int
+	for _, reviewer := range reviewers {
+		wq := workloadQuery{}
+		vars := map[string]interface{}{
+			"query": githubql.String(fmt.Sprintf(workloadQueryFormat, reviewer, org)),
+		}
+		if err := gc.Query(context.Background(), &wq, vars); err != nil {
+			log.WithError(err).Warnf("Failed search the pending review requests of %s.", reviewer)
+			loads[reviewer] = 0
+			continue
+		}
+		totalCost += int(wq.RateLimit.Cost)
+		remaining = int(wq.RateLimit.Remaining)
+		loads[reviewer] = int(wq.Search.IssueCount)
+	}
+	log.Infof("Search for the pending review requests cost %d point(s). %d remaining.", totalCost, remaining)
+	tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
+	return loads
+}
+
+// pickRandomReviewers picks the reviewers randomly.
+func pickRandomReviewers(availableReviewers sets.String, maxReviewerCount int) []string {
+	candidates := availableReviewers.List()
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return sets.NewString(candidates[:maxReviewerCount]...).List()
+}
+
+// pickLeastLoadedReviewers picks the reviewers with the fewest pending review requests,
+// the reviewers with the same load are picked randomly.
+func pickLeastLoadedReviewers(availableReviewers sets.String, loads map[string]int, maxReviewerCount int) []string {
+	candidates := availableReviewers.List()
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return loads[candidates[i]] < loads[candidates[j]]
+	})
+	return sets.NewString(candidates[:maxReviewerCount]...).List()
+}
+
+// pickRoundRobinReviewers picks the reviewers in turn, the consecutive PRs start from the
+// consecutive positions of the sorted reviewers, so that no state needs to be stored.
+func pickRoundRobinReviewers(availableReviewers sets.String, number int, maxReviewerCount int) []string {
+	candidates := availableReviewers.List()
+	start := (number * maxReviewerCount) % len(candidates)
+	reviewers := sets.NewString()
+	for i := 0; i < maxReviewerCount; i++ {
+		reviewers.Insert(candidates[(start+i)%len(candidates)])
+	}
+	return reviewers.List()
+}
+
+// pickFileHistoryReviewers picks the reviewers randomly, weighted by their recent commits to the
+// changed files.
+func pickFileHistoryReviewers(gc githubClient, opts *tiexternalplugins.TiCommunityBlunderbuss, org string,
+	repo string, num int, availableReviewers sets.String, count int, log *logrus.Entry) ([]string, error) {
+	// List the contributors of the changes.
+	contributors, err := listChangesContributors(gc, org, repo, num, opts.MaxHistoryPaths, opts.HistoryHalfLife, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter out unavailable contributors.
+	for contributor := range contributors {
+		if !availableReviewers.Has(contributor) {
+			delete(contributors, contributor)
+		}
+	}
+
+	// Every reviewer has the default weight besides the weight of the contributions.
+	for _, reviewer := range availableReviewers.List() {
+		contributors[reviewer] += defaultWeight
+	}
+	// Create weighted selectors chooser on the number of changes made to the code.
+	var choices []wr.Choice
+	for contributor, weight := range contributors {
+		choices = append(choices, wr.Choice{
+			Item:   contributor,
+			Weight: weight,
+		})
+	}
+	reviewers := sets.NewString()
+
+	chooser, err := wr.NewChooser(
+		choices...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for len(reviewers) < count {
+		// Rand pick.
+		reviewers.Insert(chooser.Pick().(string))
+	}
+
+	return reviewers.List(), nil
+}
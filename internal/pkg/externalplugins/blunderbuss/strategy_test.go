@@ -0,0 +1,93 @@
+package blunderbuss
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandleWithStrategy(t *testing.T) {
+	reviewers := []string{"reviewer1", "reviewer2", "reviewer3", "reviewer4"}
+	loads := map[string]int{"reviewer1": 8, "reviewer2": 1, "reviewer3": 3, "reviewer4": 5}
+
+	testcases := []struct {
+		name             string
+		strategy         string
+		maxReviewerCount int
+		maxReviewerLoad  int
+
+		expectReviewers []string
+	}{
+		{
+			name:             "least loaded",
+			strategy:         tiexternalplugins.BlunderbussStrategyLeastLoaded,
+			maxReviewerCount: 2,
+			expectReviewers:  []string{"reviewer2", "reviewer3"},
+		},
+		{
+			name:             "round robin",
+			strategy:         tiexternalplugins.BlunderbussStrategyRoundRobin,
+			maxReviewerCount: 2,
+			expectReviewers:  []string{"reviewer3", "reviewer4"},
+		},
+		{
+			name:             "round robin wraps around",
+			strategy:         tiexternalplugins.BlunderbussStrategyRoundRobin,
+			maxReviewerCount: 3,
+			expectReviewers:  []string{"reviewer1", "reviewer2", "reviewer4"},
+		},
+		{
+			name:             "overloaded reviewers are skipped",
+			strategy:         tiexternalplugins.BlunderbussStrategyRoundRobin,
+			maxReviewerCount: 2,
+			maxReviewerLoad:  5,
+			expectReviewers:  []string{"reviewer2", "reviewer3"},
+		},
+		{
+			name:             "random with overloaded reviewers",
+			strategy:         tiexternalplugins.BlunderbussStrategyRandom,
+			maxReviewerCount: 3,
+			maxReviewerLoad:  8,
+			expectReviewers:  []string{"reviewer2", "reviewer3", "reviewer4"},
+		},
+		{
+			name:             "file history with overloaded reviewers",
+			strategy:         tiexternalplugins.BlunderbussStrategyFileHistory,
+			maxReviewerCount: 1,
+			maxReviewerLoad:  2,
+			expectReviewers:  []string{"reviewer2"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{Number: 5, User: github.User{Login: "author"}}
+			fileCommits := map[string][]github.RepositoryCommit{
+				"test/file1": {{Author: github.User{Login: "reviewer1"}}},
+			}
+			fc := newFakeGitHubClient(pr, []github.PullRequestChange{{Filename: "test/file1"}}, fileCommits)
+			fc.loads = loads
+			foc := &fakeOwnersClient{reviewers: reviewers, needsLgtm: 2}
+			opts := &tiexternalplugins.TiCommunityBlunderbuss{
+				MaxReviewerCount: tc.maxReviewerCount,
+				MaxReviewerLoad:  tc.maxReviewerLoad,
+				Strategy:         tc.strategy,
+			}
+			repo := &github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+
+			if err := handle(fc, opts, repo, pr, logrus.WithField("plugin", PluginName), foc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			requested := fc.requested
+			sort.Strings(requested)
+			if !reflect.DeepEqual(requested, tc.expectReviewers) {
+				t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", requested, tc.expectReviewers)
+			}
+		})
+	}
+}
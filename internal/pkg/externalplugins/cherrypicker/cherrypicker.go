@@ -23,7 +23,6 @@ package cherrypicker
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -33,6 +32,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -56,6 +56,22 @@ var (
 
 const upstreamRemoteName = "upstream"
 
+// The results of the cherry-picks.
+const (
+	cherryPickSuccess  = "success"
+	cherryPickConflict = "conflict"
+	cherryPickFailure  = "failure"
+)
+
+var cherryPickResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tichi_cherrypicker_results_total",
+	Help: "The number of cherry-picks by the result, which is success, conflict or failure.",
+}, []string{"org", "repo", "result"})
+
+func init() {
+	prometheus.MustRegister(cherryPickResults)
+}
+
 type githubClient interface {
 	AddLabels(org, repo string, number int, labels ...string) error
 	AssignIssue(org, repo string, number int, logins []string) error
@@ -154,12 +170,10 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
-// Server implements http.Handler. It validates incoming GitHub webhooks and
-// then dispatches them to the appropriate plugins.
+// Server handles the GitHub events and cherry-picks the PRs.
 type Server struct {
-	TokenGenerator func() []byte
-	BotUser        *github.UserData
-	Email          string
+	BotUser *github.UserData
+	Email   string
 
 	GitClient git.ClientFactory
 	// Used for unit testing
@@ -186,49 +200,24 @@ type cherryPickRequest struct {
 	targetBranch string
 }
 
-// ServeHTTP validates an incoming webhook and puts it into the event channel.
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, s.TokenGenerator)
-	if !ok {
-		return
-	}
-	fmt.Fprint(w, "Event received. Have a nice day.")
+// RegisterHandlers registers the handlers of the server for the events it handles to the host.
+func (s *Server) RegisterHandlers(host *tiexternalplugins.PluginHost) {
+	host.RegisterIssueCommentEventHandler(PluginName, s.HandleIssueCommentEvent)
+	host.RegisterPullRequestEventHandler(PluginName, s.HandlePullRequestEvent)
+}
 
-	if err := s.handleEvent(eventType, eventGUID, payload); err != nil {
-		logrus.WithError(err).Error("Error parsing event.")
-	}
+// HandleIssueCommentEvent handles a GitHub issue comment event and cherry-picks the PR if requested.
+// The cherrypicker reads the latest configuration from the config agent, so the config is not used.
+func (s *Server) HandleIssueCommentEvent(ice *github.IssueCommentEvent, _ *tiexternalplugins.Configuration,
+	log *logrus.Entry) error {
+	return s.handleIssueComment(log, *ice)
 }
 
-func (s *Server) handleEvent(eventType, eventGUID string, payload []byte) error {
-	l := logrus.WithFields(logrus.Fields{
-		"event-type":     eventType,
-		github.EventGUID: eventGUID,
-	})
-	switch eventType {
-	case "issue_comment":
-		var ic github.IssueCommentEvent
-		if err := json.Unmarshal(payload, &ic); err != nil {
-			return err
-		}
-		go func() {
-			if err := s.handleIssueComment(l, ic); err != nil {
-				s.Log.WithError(err).WithFields(l.Data).Info("Cherry-pick failed.")
-			}
-		}()
-	case "pull_request":
-		var pr github.PullRequestEvent
-		if err := json.Unmarshal(payload, &pr); err != nil {
-			return err
-		}
-		go func() {
-			if err := s.handlePullRequest(l, pr); err != nil {
-				s.Log.WithError(err).WithFields(l.Data).Info("Cherry-pick failed.")
-			}
-		}()
-	default:
-		logrus.Debugf("Skipping event of type %q.", eventType)
-	}
-	return nil
+// HandlePullRequestEvent handles a GitHub pull request event and cherry-picks the merged PR if requested.
+// The cherrypicker reads the latest configuration from the config agent, so the config is not used.
+func (s *Server) HandlePullRequestEvent(pre *github.PullRequestEvent, _ *tiexternalplugins.Configuration,
+	log *logrus.Entry) error {
+	return s.handlePullRequest(log, *pre)
 }
 
 func (s *Server) handleIssueComment(l *logrus.Entry, ic github.IssueCommentEvent) error {
@@ -490,6 +479,14 @@ func (s *Server) handle(logger *logrus.Entry, requestor string,
 	lock.Lock()
 	defer lock.Unlock()
 
+	// The result is not recorded if the PR has already been cherry picked.
+	result := cherryPickFailure
+	defer func() {
+		if len(result) != 0 {
+			cherryPickResults.WithLabelValues(org, repo, result).Inc()
+		}
+	}()
+
 	opts := s.ConfigAgent.Config().CherrypickerFor(org, repo)
 
 	forkName, err := s.ensureForkExists(org, repo)
@@ -548,6 +545,7 @@ func (s *Server) handle(logger *logrus.Entry, requestor string,
 		for _, pr := range prs {
 			if pr.Head.Ref == fmt.Sprintf("%s:%s", s.BotUser.Login, newBranch) {
 				logger.WithField("preexisting_cherrypick", pr.HTMLURL).Info("PR already has cherrypick.")
+				result = ""
 				resp := fmt.Sprintf("looks like #%d has already been cherry picked in %s.", num, pr.HTMLURL)
 				return s.createComment(logger, org, repo, num, comment, resp)
 			}
@@ -563,7 +561,9 @@ func (s *Server) handle(logger *logrus.Entry, requestor string,
 	title = fmt.Sprintf("%s (#%d)", title, num)
 
 	// Try git am --3way localPath.
+	conflicted := false
 	if err := r.Am(localPath); err != nil {
+		conflicted = true
 		var errs []error
 		logger.WithError(err).Warnf("Failed to apply #%d on top of target branch %q.", num, targetBranch)
 		if opts.IssueOnConflict {
@@ -573,6 +573,7 @@ func (s *Server) handle(logger *logrus.Entry, requestor string,
 				errs = append(errs, fmt.Errorf("failed to create issue: %w", err))
 			} else {
 				// Return after issue created.
+				result = cherryPickConflict
 				return nil
 			}
 		} else {
@@ -656,6 +657,11 @@ func (s *Server) handle(logger *logrus.Entry, requestor string,
 		resp := fmt.Sprintf("new pull request could not be created: %v", err)
 		return utilerrors.NewAggregate([]error{err, s.createComment(logger, org, repo, num, comment, resp)})
 	}
+	if conflicted {
+		result = cherryPickConflict
+	} else {
+		result = cherryPickSuccess
+	}
 	*logger = *logger.WithField("new_pull_request_number", createdNum)
 	resp := fmt.Sprintf("new pull request created: #%d.", createdNum)
 	logger.Info("new pull request created")
@@ -24,9 +24,6 @@ package cherrypicker
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/http/httptest"
 	"reflect"
 	"strings"
 	"sync"
@@ -37,7 +34,6 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/git/localgit"
 	"k8s.io/test-infra/prow/github"
-	"k8s.io/test-infra/prow/plugins"
 )
 
 var commentFormat = "%s/%s#%d %s"
@@ -343,10 +339,6 @@ func testCherryPickIC(clients localgit.Clients, t *testing.T) {
 			branch, expectedLabels, expectedAssignees)
 	}
 
-	getSecret := func() []byte {
-		return []byte("sha=abcdefg")
-	}
-
 	cfg := &externalplugins.Configuration{}
 	cfg.TiCommunityCherrypicker = []externalplugins.TiCommunityCherrypicker{
 		{
@@ -359,14 +351,13 @@ func testCherryPickIC(clients localgit.Clients, t *testing.T) {
 	ca.Set(cfg)
 
 	s := &Server{
-		BotUser:        botUser,
-		GitClient:      c,
-		ConfigAgent:    ca,
-		Push:           func(forkName, newBranch string, force bool) error { return nil },
-		GitHubClient:   ghc,
-		TokenGenerator: getSecret,
-		Log:            logrus.StandardLogger().WithField("client", "cherrypicker"),
-		Repos:          []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
+		BotUser:      botUser,
+		GitClient:    c,
+		ConfigAgent:  ca,
+		Push:         func(forkName, newBranch string, force bool) error { return nil },
+		GitHubClient: ghc,
+		Log:          logrus.StandardLogger().WithField("client", "cherrypicker"),
+		Repos:        []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
 	}
 
 	if err := s.handleIssueComment(logrus.NewEntry(logrus.StandardLogger()), ic); err != nil {
@@ -460,10 +451,6 @@ func testCherryPickPRWithLabels(clients localgit.Clients, t *testing.T) {
 
 	botUser := &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"}
 
-	getSecret := func() []byte {
-		return []byte("sha=abcdefg")
-	}
-
 	testCases := []struct {
 		name        string
 		labelPrefix string
@@ -536,14 +523,13 @@ func testCherryPickPRWithLabels(clients localgit.Clients, t *testing.T) {
 			ca.Set(cfg)
 
 			s := &Server{
-				BotUser:        botUser,
-				GitClient:      c,
-				ConfigAgent:    ca,
-				Push:           func(forkName, newBranch string, force bool) error { return nil },
-				GitHubClient:   ghc,
-				TokenGenerator: getSecret,
-				Log:            logrus.StandardLogger().WithField("client", "cherrypicker"),
-				Repos:          []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
+				BotUser:      botUser,
+				GitClient:    c,
+				ConfigAgent:  ca,
+				Push:         func(forkName, newBranch string, force bool) error { return nil },
+				GitHubClient: ghc,
+				Log:          logrus.StandardLogger().WithField("client", "cherrypicker"),
+				Repos:        []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
 			}
 
 			if err := s.handlePullRequest(logrus.NewEntry(logrus.StandardLogger()), pr); err != nil {
@@ -726,10 +712,6 @@ func testCherryPickPRWithComment(clients localgit.Clients, t *testing.T) {
 
 	botUser := &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"}
 
-	getSecret := func() []byte {
-		return []byte("sha=abcdefg")
-	}
-
 	cfg := &externalplugins.Configuration{}
 	cfg.TiCommunityCherrypicker = []externalplugins.TiCommunityCherrypicker{
 		{
@@ -741,14 +723,13 @@ func testCherryPickPRWithComment(clients localgit.Clients, t *testing.T) {
 	ca.Set(cfg)
 
 	s := &Server{
-		BotUser:        botUser,
-		GitClient:      c,
-		ConfigAgent:    ca,
-		Push:           func(forkName, newBranch string, force bool) error { return nil },
-		GitHubClient:   ghc,
-		TokenGenerator: getSecret,
-		Log:            logrus.StandardLogger().WithField("client", "cherrypicker"),
-		Repos:          []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
+		BotUser:      botUser,
+		GitClient:    c,
+		ConfigAgent:  ca,
+		Push:         func(forkName, newBranch string, force bool) error { return nil },
+		GitHubClient: ghc,
+		Log:          logrus.StandardLogger().WithField("client", "cherrypicker"),
+		Repos:        []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
 	}
 
 	if err := s.handlePullRequest(logrus.NewEntry(logrus.StandardLogger()), pr); err != nil {
@@ -856,10 +837,6 @@ func testCherryPickPRLabeled(clients localgit.Clients, t *testing.T) {
 
 	botUser := &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"}
 
-	getSecret := func() []byte {
-		return []byte("sha=abcdefg")
-	}
-
 	testCases := []struct {
 		name         string
 		labelPrefix  string
@@ -963,14 +940,13 @@ func testCherryPickPRLabeled(clients localgit.Clients, t *testing.T) {
 					ca.Set(cfg)
 
 					s := &Server{
-						BotUser:        botUser,
-						GitClient:      c,
-						ConfigAgent:    ca,
-						Push:           func(forkName, newBranch string, force bool) error { return nil },
-						GitHubClient:   ghc,
-						TokenGenerator: getSecret,
-						Log:            logrus.StandardLogger().WithField("client", "cherrypicker"),
-						Repos:          []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
+						BotUser:      botUser,
+						GitClient:    c,
+						ConfigAgent:  ca,
+						Push:         func(forkName, newBranch string, force bool) error { return nil },
+						GitHubClient: ghc,
+						Log:          logrus.StandardLogger().WithField("client", "cherrypicker"),
+						Repos:        []github.Repo{{Fork: true, FullName: "ci-robot/bar"}},
 					}
 
 					if err := s.handlePullRequest(logrus.NewEntry(logrus.StandardLogger()), pr(lb)); err != nil {
@@ -1245,261 +1221,6 @@ func (tuf *threadUnsafeFGHC) EnsureFork(login, org, repo string) (string, error)
 	return "", errors.New("that is enough")
 }
 
-func TestServeHTTPErrors(t *testing.T) {
-	pa := &plugins.ConfigAgent{}
-	pa.Set(&plugins.Configuration{})
-
-	getSecret := func() []byte {
-		var repoLevelSec = `
-'*':
-  - value: abc
-    created_at: 2019-10-02T15:00:00Z
-  - value: key2
-    created_at: 2020-10-02T15:00:00Z
-foo/bar:
-  - value: 123abc
-    created_at: 2019-10-02T15:00:00Z
-  - value: key6
-    created_at: 2020-10-02T15:00:00Z
-`
-		return []byte(repoLevelSec)
-	}
-
-	// This is the SHA1 signature for payload "{}" and signature "abc"
-	// echo -n '{}' | openssl dgst -sha1 -hmac abc
-	const hmac string = "sha1=db5c76f4264d0ad96cf21baec394964b4b8ce580"
-	const body string = "{}"
-	var testcases = []struct {
-		name string
-
-		Method string
-		Header map[string]string
-		Body   string
-		Code   int
-	}{
-		{
-			name: "Delete",
-
-			Method: http.MethodDelete,
-			Header: map[string]string{
-				"X-GitHub-Event":    "ping",
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   hmac,
-				"content-type":      "application/json",
-			},
-			Body: body,
-			Code: http.StatusMethodNotAllowed,
-		},
-		{
-			name: "No event",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   hmac,
-				"content-type":      "application/json",
-			},
-			Body: body,
-			Code: http.StatusBadRequest,
-		},
-		{
-			name: "No content type",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":    "ping",
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   hmac,
-			},
-			Body: body,
-			Code: http.StatusBadRequest,
-		},
-		{
-			name: "No event guid",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":  "ping",
-				"X-Hub-Signature": hmac,
-				"content-type":    "application/json",
-			},
-			Body: body,
-			Code: http.StatusBadRequest,
-		},
-		{
-			name: "No signature",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":    "ping",
-				"X-GitHub-Delivery": "I am unique",
-				"content-type":      "application/json",
-			},
-			Body: body,
-			Code: http.StatusForbidden,
-		},
-		{
-			name: "Bad signature",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":    "ping",
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   "this doesn't work",
-				"content-type":      "application/json",
-			},
-			Body: body,
-			Code: http.StatusForbidden,
-		},
-		{
-			name: "Good",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":    "ping",
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   hmac,
-				"content-type":      "application/json",
-			},
-			Body: body,
-			Code: http.StatusOK,
-		},
-		{
-			name: "Good, again",
-
-			Method: http.MethodGet,
-			Header: map[string]string{
-				"content-type": "application/json",
-			},
-			Body: body,
-			Code: http.StatusMethodNotAllowed,
-		},
-	}
-
-	for _, tc := range testcases {
-		t.Logf("Running scenario %q", tc.name)
-
-		w := httptest.NewRecorder()
-		r, err := http.NewRequest(tc.Method, "", strings.NewReader(tc.Body))
-		if err != nil {
-			t.Fatal(err)
-		}
-		for k, v := range tc.Header {
-			r.Header.Set(k, v)
-		}
-
-		s := Server{
-			TokenGenerator: getSecret,
-		}
-
-		s.ServeHTTP(w, r)
-		if w.Code != tc.Code {
-			t.Errorf("For test case: %+v\nExpected code %v, got code %v", tc, tc.Code, w.Code)
-		}
-	}
-}
-
-func TestServeHTTP(t *testing.T) {
-	pa := &plugins.ConfigAgent{}
-	pa.Set(&plugins.Configuration{})
-
-	getSecret := func() []byte {
-		var repoLevelSec = `
-'*':
-  - value: abc
-    created_at: 2019-10-02T15:00:00Z
-  - value: key2
-    created_at: 2020-10-02T15:00:00Z
-foo/bar:
-  - value: 123abc
-    created_at: 2019-10-02T15:00:00Z
-  - value: key6
-    created_at: 2020-10-02T15:00:00Z
-`
-		return []byte(repoLevelSec)
-	}
-
-	lgtmComment, err := ioutil.ReadFile("../../../../test/testdata/lgtm_comment.json")
-	if err != nil {
-		t.Fatalf("read lgtm comment file failed: %v", err)
-	}
-
-	openedPR, err := ioutil.ReadFile("../../../../test/testdata/opened_pr.json")
-	if err != nil {
-		t.Fatalf("read opened PR file failed: %v", err)
-	}
-
-	// This is the SHA1 signature for payload "{}" and signature "abc"
-	// echo -n '{}' | openssl dgst -sha1 -hmac abc
-	var testcases = []struct {
-		name string
-
-		Method string
-		Header map[string]string
-		Body   string
-		Code   int
-	}{
-		{
-			name: "Issue comment event",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":    "issue_comment",
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   "sha1=f3fee26b22d3748f393f7e37f71baa467495971a",
-				"content-type":      "application/json",
-			},
-			Body: string(lgtmComment),
-			Code: http.StatusOK,
-		},
-		{
-			name: "Pull request event",
-
-			Method: http.MethodPost,
-			Header: map[string]string{
-				"X-GitHub-Event":    "pull_request",
-				"X-GitHub-Delivery": "I am unique",
-				"X-Hub-Signature":   "sha1=9a62c443a5ab561e023e64610dc467523188defc",
-				"content-type":      "application/json",
-			},
-			Body: string(openedPR),
-			Code: http.StatusOK,
-		},
-	}
-
-	for _, tc := range testcases {
-		t.Logf("Running scenario %q", tc.name)
-
-		w := httptest.NewRecorder()
-		r, err := http.NewRequest(tc.Method, "", strings.NewReader(tc.Body))
-		if err != nil {
-			t.Fatal(err)
-		}
-		for k, v := range tc.Header {
-			r.Header.Set(k, v)
-		}
-
-		cfg := &externalplugins.Configuration{}
-		cfg.TiCommunityCherrypicker = []externalplugins.TiCommunityCherrypicker{
-			{
-				Repos: []string{"foo/bar"},
-			},
-		}
-		ca := &externalplugins.ConfigAgent{}
-		ca.Set(cfg)
-
-		s := Server{
-			TokenGenerator: getSecret,
-			ConfigAgent:    ca,
-		}
-
-		s.ServeHTTP(w, r)
-		if w.Code != tc.Code {
-			t.Errorf("For test case: %+v\nExpected code %v, got code %v", tc, tc.Code, w.Code)
-		}
-	}
-}
-
 func TestHelpProvider(t *testing.T) {
 	enabledRepos := []config.OrgRepo{
 		{Org: "org1", Repo: "repo"},
@@ -1524,9 +1245,9 @@ func TestHelpProvider(t *testing.T) {
 			},
 			enabledRepos: enabledRepos,
 			configInfoIncludes: []string{"For this repository, only organization members are allowed to do cherry-pick.",
-				"When a cherry-pick PR conflicts, cherrypicker will create the PR with conflicts."},
-			configInfoExcludes: []string{"The current label prefix for cherrypicker is: ",
-				"The current picked label prefix for cherrypicker is: ",
+				"When a cherry-pick PR conflicts, cherrypicker will create the PR with conflicts.",
+				"The current label prefix for cherrypicker is: cherrypick/"},
+			configInfoExcludes: []string{"The current picked label prefix for cherrypicker is: ",
 				"For this repository, cherry-pick is available to all.",
 				"When a cherry-pick PR conflicts, an issue will be created to track it."},
 		},
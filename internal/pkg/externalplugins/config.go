@@ -4,18 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/labels"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 )
 
 const (
 	// defaultGracePeriodDuration define the time for blunderbuss plugin to wait
 	// before requesting a review (default five seconds).
 	defaultGracePeriodDuration = 5
+	// defaultMaxHistoryPaths defines the maximum number of the paths whose commit history is used by
+	// the blunderbuss plugin.
+	defaultMaxHistoryPaths = 20
+	// defaultHistoryHalfLife defines the days after which the weight of a commit is halved in the blunderbuss plugin.
+	defaultHistoryHalfLife = 180
 	// defaultLogLevel defines the default log level of all ti community plugins.
 	defaultLogLevel = logrus.InfoLevel
 )
@@ -61,8 +70,47 @@ type TiCommunityLgtm struct {
 	Repos []string `json:"repos,omitempty"`
 	// PullOwnersEndpoint specifies the URL of the reviewer of pull request.
 	PullOwnersEndpoint string `json:"pull_owners_endpoint,omitempty"`
+	// ApprovalsOnPush specifies what happens to the approvals when new commits are pushed to the PR, it is "keep"
+	// to keep them, "reset" to invalidate them, or "reset-on-change" to invalidate them only if the changes of
+	// the PR are changed, i.e. not for a rebase or a merge of the base branch. The approvals are kept if it is empty.
+	ApprovalsOnPush string `json:"approvals_on_push,omitempty"`
+	// AllowLgtmCommands specifies whether the reviewers can approve the PR with the /lgtm comment command and
+	// withdraw their approvals with the /lgtm cancel comment command besides the GitHub reviews.
+	AllowLgtmCommands bool `json:"allow_lgtm_commands,omitempty"`
+	// RoleWeights specifies how many approvals an approval from a user of the role counts as, the role is
+	// "leader", "committer" or "reviewer". The weight of the highest role of the user specified is used,
+	// and an approval counts as 1 if none of the roles of the user are specified.
+	RoleWeights map[string]int `json:"role_weights,omitempty"`
+	// ApprovalRules specifies the rules which the approvals of the PR must satisfy besides the needed LGTM number.
+	ApprovalRules []LgtmApprovalRule `json:"approval_rules,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityLgtm `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
+}
+
+// LgtmApprovalRule requires a number of the approvals from the users of a role.
+type LgtmApprovalRule struct {
+	// Name specifies the name of the rule shown in the review notifications.
+	Name string `json:"name,omitempty"`
+	// Role specifies the role of the users whose approvals count, the users of the higher roles also count.
+	Role string `json:"role,omitempty"`
+	// MinApprovals specifies the minimum number of the approvals from the users of the role.
+	MinApprovals int `json:"min_approvals,omitempty"`
 }
 
+// The policies of the lgtm plugin for the approvals when new commits are pushed to the PR.
+const (
+	// LgtmApprovalsOnPushKeep means the approvals are kept.
+	LgtmApprovalsOnPushKeep = "keep"
+	// LgtmApprovalsOnPushReset means the approvals are invalidated.
+	LgtmApprovalsOnPushReset = "reset"
+	// LgtmApprovalsOnPushResetOnChange means the approvals are invalidated if the changes of the PR are changed.
+	LgtmApprovalsOnPushResetOnChange = "reset-on-change"
+)
+
 // TiCommunityMerge specifies a configuration for a single merge.
 //
 // The configuration for the merge plugin is defined as a list of these structures.
@@ -74,6 +122,12 @@ type TiCommunityMerge struct {
 	StoreTreeHash bool `json:"store_tree_hash,omitempty"`
 	// PullOwnersEndpoint specifies the URL of the reviewer of pull request.
 	PullOwnersEndpoint string `json:"pull_owners_endpoint,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityMerge `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // TiCommunityOwners specifies a configuration for a single ti community owners plugin.
@@ -84,6 +138,20 @@ type TiCommunityOwners struct {
 	Repos []string `json:"repos,omitempty"`
 	// SigEndpoint specifies the URL of the sig info.
 	SigEndpoint string `json:"sig_endpoint,omitempty"`
+	// SigBackend specifies where the sigs and their members come from, it is "http" for the sig endpoint,
+	// "file" for the sig file, or "github_teams" for the GitHub teams of the sig teams. The sig endpoint
+	// is used if it is empty.
+	SigBackend string `json:"sig_backend,omitempty"`
+	// SigFile specifies the path of the YAML or JSON file describing the sigs, it is a local file
+	// of the owners server unless the sig file repo is set.
+	SigFile string `json:"sig_file,omitempty"`
+	// SigFileRepo specifies the repo of the sig file in the form of org/repo.
+	SigFileRepo string `json:"sig_file_repo,omitempty"`
+	// SigFileBranch specifies the branch of the sig file in the sig file repo,
+	// the default branch is used if it is empty.
+	SigFileBranch string `json:"sig_file_branch,omitempty"`
+	// SigTeams specifies the GitHub teams of the org mapped to the sigs, the key of the map is the name of the sig.
+	SigTeams map[string]TiCommunityOwnerSigTeams `json:"sig_teams,omitempty"`
 	// DefaultSigName specifies the default sig name of this repo's PR.
 	DefaultSigName string `json:"default_sig_name,omitempty"`
 	// DefaultRequireLgtm specifies the default require lgtm number.
@@ -98,9 +166,20 @@ type TiCommunityOwners struct {
 	// UseGitHubPermission specifies the permissions to use GitHub.
 	// People with write and admin permissions have reviewer and committer permissions.
 	UseGitHubPermission bool `json:"use_github_permission,omitempty"`
+	// OwnersFileMode specifies the files in the repo which the owners of the changed files come from,
+	// it is "owners" for the OWNERS files, "codeowners" for the CODEOWNERS file, or empty to not use them.
+	OwnersFileMode string `json:"owners_file_mode,omitempty"`
+	// RequireApprovalCoverage specifies whether every area of the PR, i.e. every sig label or the changed files
+	// of every OWNERS file or CODEOWNERS rule, requires an approval from the reviewers of the area.
+	RequireApprovalCoverage bool `json:"require_approval_coverage,omitempty"`
+	// Availability specifies where the periods when the reviewers are unavailable come from.
+	Availability TiCommunityOwnerAvailability `json:"availability,omitempty"`
 	// Branches specifies the branch level configuration that will override the repository
 	// level configuration.
 	Branches map[string]TiCommunityOwnerBranchConfig `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // TiCommunityOwnerBranchConfig is the branch level configuration of the owners plugin.
@@ -112,8 +191,56 @@ type TiCommunityOwnerBranchConfig struct {
 	// UseGitHubPermission specifies the permissions to use GitHub.
 	// People with write and admin permissions have reviewer and committer permissions.
 	UseGitHubPermission bool `json:"use_github_permission,omitempty"`
+	// OwnersFileMode specifies the files in the repo which the owners of the changed files come from,
+	// it overrides the repository level mode if it is not empty.
+	OwnersFileMode string `json:"owners_file_mode,omitempty"`
+	// RequireApprovalCoverage specifies whether every area of the PR requires an approval from the reviewers
+	// of the area, it overrides the repository level setting if it is set.
+	RequireApprovalCoverage *bool `json:"require_approval_coverage,omitempty"`
 }
 
+// TiCommunityOwnerAvailability is the configuration of the periods when the reviewers are unavailable,
+// which come from the availability file and the commands in the comments of the availability issue.
+type TiCommunityOwnerAvailability struct {
+	// Repo specifies the repo of the availability file and the availability issue in the form of org/repo.
+	Repo string `json:"repo,omitempty"`
+	// File specifies the path of the YAML or JSON file in the repo describing the unavailable periods.
+	File string `json:"file,omitempty"`
+	// Branch specifies the branch of the availability file, the default branch is used if it is empty.
+	Branch string `json:"branch,omitempty"`
+	// Issue specifies the number of the issue in the repo where the reviewers comment the away commands.
+	Issue int `json:"issue,omitempty"`
+}
+
+// TiCommunityOwnerSigTeams is the GitHub teams whose members are the members of a sig.
+type TiCommunityOwnerSigTeams struct {
+	// CommitterTeams specifies the GitHub teams whose members are the committers of the sig.
+	CommitterTeams []string `json:"committer_teams,omitempty"`
+	// ReviewerTeams specifies the GitHub teams whose members are the reviewers of the sig.
+	ReviewerTeams []string `json:"reviewer_teams,omitempty"`
+	// NeedsLgtm specifies the required lgtm number of the sig.
+	NeedsLgtm int `json:"needs_lgtm,omitempty"`
+}
+
+// The backends which the sigs and their members come from.
+const (
+	// SigBackendHTTP means the sigs come from the sig endpoint.
+	SigBackendHTTP = "http"
+	// SigBackendFile means the sigs come from the sig file.
+	SigBackendFile = "file"
+	// SigBackendGitHubTeams means the sigs come from the sig teams.
+	SigBackendGitHubTeams = "github_teams"
+)
+
+// The files in the repo which the owners of the changed files come from.
+const (
+	// OwnersFileModeOwners means the owners come from the OWNERS files in the directories of the changed files
+	// and their parent directories.
+	OwnersFileModeOwners = "owners"
+	// OwnersFileModeCodeowners means the owners come from the CODEOWNERS file of the repo.
+	OwnersFileModeCodeowners = "codeowners"
+)
+
 // TiCommunityLabel is the config for the label plugin.
 type TiCommunityLabel struct {
 	// Repos is either of the form org/repos or just org.
@@ -131,6 +258,12 @@ type TiCommunityLabel struct {
 	Prefixes []string `json:"prefixes,omitempty"`
 	// ExcludeLabels specifies labels that cannot be added by TiCommunityLabel.
 	ExcludeLabels []string `json:"exclude_labels,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityLabel `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // TiCommunityAutoresponder is the config for the blunderbuss plugin.
@@ -139,6 +272,12 @@ type TiCommunityAutoresponder struct {
 	Repos []string `json:"repos,omitempty"`
 	// AutoResponds is a set of responds.
 	AutoResponds []AutoRespond `json:"auto_responds,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityAutoresponder `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // AutoRespond is the config for auto respond.
@@ -167,6 +306,54 @@ type TiCommunityBlunderbuss struct {
 	GracePeriodDuration int `json:"grace_period_duration,omitempty"`
 	// RequireSigLabel specifies whether the PR is required to have a sig label before requesting reviewers.
 	RequireSigLabel bool `json:"require_sig_label,omitempty"`
+	// Strategy specifies how to choose the reviewers when there are more than MaxReviewerCount reviewers,
+	// defaults to file-history.
+	Strategy string `json:"strategy,omitempty"`
+	// MaxReviewerLoad specifies the maximum number of the pending review requests of a reviewer in the org,
+	// the reviewers who have reached it are not requested. Defaults to 0 meaning no limit.
+	MaxReviewerLoad int `json:"max_reviewer_load,omitempty"`
+	// MaxHistoryPaths specifies the maximum number of the paths whose commit history is used to weight
+	// the reviewers by the file-history strategy, defaults to 20.
+	MaxHistoryPaths int `json:"max_history_paths,omitempty"`
+	// HistoryHalfLife specifies the number of days after which the weight of a commit is halved,
+	// defaults to 180.
+	HistoryHalfLife int `json:"history_half_life,omitempty"`
+	// StaleReviewHours specifies the number of hours after which the requested reviewers who have not
+	// reviewed the PR are stale. Defaults to 0 meaning the stale reviewers are not checked.
+	StaleReviewHours int `json:"stale_review_hours,omitempty"`
+	// ReassignStaleReviewers specifies whether the stale reviewers are replaced by other reviewers,
+	// otherwise an additional reviewer is requested.
+	ReassignStaleReviewers bool `json:"reassign_stale_reviewers,omitempty"`
+	// StaleReviewExcludeLabels specifies that the stale reviewers of the PRs with these labels are not checked.
+	StaleReviewExcludeLabels []string `json:"stale_review_exclude_labels,omitempty"`
+	// ReviewerGroups specifies the groups of the reviewers whose constraints are satisfied before the reviewers
+	// are chosen up to MaxReviewerCount.
+	ReviewerGroups []BlunderbussReviewerGroup `json:"reviewer_groups,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityBlunderbuss `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
+}
+
+// BlunderbussReviewerGroup is a group of the reviewers with the constraints on the number of the reviewers
+// requested from it.
+type BlunderbussReviewerGroup struct {
+	// Name specifies the name of the group.
+	Name string `json:"name,omitempty"`
+	// Members specifies the GitHub logins of the members of the group.
+	Members []string `json:"members,omitempty"`
+	// Teams specifies the GitHub teams of the org whose members are the members of the group.
+	Teams []string `json:"teams,omitempty"`
+	// MinCount specifies the minimum number of the reviewers requested from the group.
+	MinCount int `json:"min_count,omitempty"`
+	// MaxCount specifies the maximum number of the reviewers requested from the group.
+	// Defaults to 0 meaning no limit.
+	MaxCount int `json:"max_count,omitempty"`
+	// RequestTeams specifies whether the reviews are requested from the teams of the group instead of
+	// its members to satisfy MinCount.
+	RequestTeams bool `json:"request_teams,omitempty"`
 }
 
 // setDefaults will set the default value for the config of blunderbuss plugin.
@@ -174,8 +361,36 @@ func (c *TiCommunityBlunderbuss) setDefaults() {
 	if c.GracePeriodDuration == 0 {
 		c.GracePeriodDuration = defaultGracePeriodDuration
 	}
+	if len(c.Strategy) == 0 {
+		c.Strategy = BlunderbussStrategyFileHistory
+	}
+	if c.MaxHistoryPaths == 0 {
+		c.MaxHistoryPaths = defaultMaxHistoryPaths
+	}
+	if c.HistoryHalfLife == 0 {
+		c.HistoryHalfLife = defaultHistoryHalfLife
+	}
+	if len(c.StaleReviewExcludeLabels) == 0 {
+		// Label: do-not-merge/hold.
+		c.StaleReviewExcludeLabels = append(c.StaleReviewExcludeLabels, labels.Hold)
+		// Label: do-not-merge/work-in-progress.
+		c.StaleReviewExcludeLabels = append(c.StaleReviewExcludeLabels, labels.WorkInProgress)
+	}
 }
 
+// The strategies of the blunderbuss plugin to choose the reviewers.
+const (
+	// BlunderbussStrategyRandom means the reviewers are chosen randomly.
+	BlunderbussStrategyRandom = "random"
+	// BlunderbussStrategyFileHistory means the reviewers are chosen randomly, weighted by the number of
+	// their commits to the changed files.
+	BlunderbussStrategyFileHistory = "file-history"
+	// BlunderbussStrategyLeastLoaded means the reviewers with the fewest pending review requests are chosen.
+	BlunderbussStrategyLeastLoaded = "least-loaded"
+	// BlunderbussStrategyRoundRobin means the reviewers are chosen in turn by the number of the PR.
+	BlunderbussStrategyRoundRobin = "round-robin"
+)
+
 // TiCommunityTars is the config for the tars plugin.
 type TiCommunityTars struct {
 	// Repos is either of the form org/repos or just org.
@@ -186,6 +401,12 @@ type TiCommunityTars struct {
 	OnlyWhenLabel string `json:"only_when_label,omitempty"`
 	// ExcludeLabels specifies that the automatic update are not triggered when the PR has these labels.
 	ExcludeLabels []string `json:"exclude_labels,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityTars `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // setDefaults will set the default label for the config of tars plugin.
@@ -210,6 +431,12 @@ type TiCommunityLabelBlocker struct {
 	Repos []string `json:"repos,omitempty"`
 	// BlockLabels is a set of label block rules.
 	BlockLabels []BlockLabel `json:"block_labels,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityLabelBlocker `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // BlockLabel is the config for label blocking.
@@ -232,6 +459,12 @@ type TiCommunityContribution struct {
 	Repos []string `json:"repos,omitempty"`
 	// Message specifies the tips for the contributor's PR.
 	Message string `json:"message,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityContribution `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // TiCommunityCherrypicker is the config for the cherrypicker plugin.
@@ -248,6 +481,12 @@ type TiCommunityCherrypicker struct {
 	PickedLabelPrefix string `json:"picked_label_prefix,omitempty"`
 	// ExcludeLabels specifies the labels that need to be excluded when copying the labels of the original PR.
 	ExcludeLabels []string `json:"excludeLabels,omitempty"`
+	// Branches specifies the branch level configurations which override the repository level
+	// configuration field by field, the key is a branch name or a glob pattern such as "release-*".
+	Branches map[string]TiCommunityCherrypicker `json:"branches,omitempty"`
+
+	// setFields records the fields set explicitly in the configuration file.
+	setFields fieldSet
 }
 
 // setDefaults will set the default value for the config of blunderbuss plugin.
@@ -257,284 +496,385 @@ func (c *TiCommunityCherrypicker) setDefaults() {
 	}
 }
 
-// LgtmFor finds the Lgtm for a repo, if one exists
-// a trigger can be listed for the repo itself or for the
-// owning organization
+// LgtmFor finds the TiCommunityLgtm for a repo, if one exists.
+// TiCommunityLgtm configuration can be listed for a repository
+// or an organization.
 func (c *Configuration) LgtmFor(org, repo string) *TiCommunityLgtm {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, lgtm := range c.TiCommunityLgtm {
-		if !sets.NewString(lgtm.Repos...).Has(fullName) {
-			continue
-		}
-		return &lgtm
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, lgtm := range c.TiCommunityLgtm {
-		if !sets.NewString(lgtm.Repos...).Has(org) {
-			continue
-		}
-		return &lgtm
-	}
-	return &TiCommunityLgtm{}
+	return c.LgtmForBranch(org, repo, "")
+}
+
+// LgtmForBranch finds the TiCommunityLgtm for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) LgtmForBranch(org, repo, branch string) *TiCommunityLgtm {
+	lgtm := &TiCommunityLgtm{}
+	resolve(c.TiCommunityLgtm, org, repo, branch, lgtm)
+	return lgtm
 }
 
 // MergeFor finds the TiCommunityMerge for a repo, if one exists.
 // TiCommunityMerge configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) MergeFor(org, repo string) *TiCommunityMerge {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, merge := range c.TiCommunityMerge {
-		if !sets.NewString(merge.Repos...).Has(fullName) {
-			continue
-		}
-		return &merge
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, merge := range c.TiCommunityMerge {
-		if !sets.NewString(merge.Repos...).Has(org) {
-			continue
-		}
-		return &merge
-	}
-	return &TiCommunityMerge{}
+	return c.MergeForBranch(org, repo, "")
+}
+
+// MergeForBranch finds the TiCommunityMerge for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) MergeForBranch(org, repo, branch string) *TiCommunityMerge {
+	merge := &TiCommunityMerge{}
+	resolve(c.TiCommunityMerge, org, repo, branch, merge)
+	return merge
 }
 
 // OwnersFor finds the TiCommunityOwners for a repo, if one exists.
 // TiCommunityOwners configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) OwnersFor(org, repo string) *TiCommunityOwners {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, owners := range c.TiCommunityOwners {
-		if !sets.NewString(owners.Repos...).Has(fullName) {
-			continue
-		}
-		return &owners
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, owners := range c.TiCommunityOwners {
-		if !sets.NewString(owners.Repos...).Has(org) {
-			continue
-		}
-		return &owners
-	}
-	return &TiCommunityOwners{}
+	return c.OwnersForBranch(org, repo, "")
+}
+
+// OwnersForBranch finds the TiCommunityOwners for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) OwnersForBranch(org, repo, branch string) *TiCommunityOwners {
+	owners := &TiCommunityOwners{}
+	resolve(c.TiCommunityOwners, org, repo, branch, owners)
+	return owners
 }
 
 // LabelFor finds the TiCommunityLabel for a repo, if one exists.
 // TiCommunityLabel configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) LabelFor(org, repo string) *TiCommunityLabel {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, label := range c.TiCommunityLabel {
-		if !sets.NewString(label.Repos...).Has(fullName) {
-			continue
-		}
-		return &label
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, label := range c.TiCommunityLabel {
-		if !sets.NewString(label.Repos...).Has(org) {
-			continue
-		}
-		return &label
-	}
-	return &TiCommunityLabel{}
+	return c.LabelForBranch(org, repo, "")
+}
+
+// LabelForBranch finds the TiCommunityLabel for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) LabelForBranch(org, repo, branch string) *TiCommunityLabel {
+	label := &TiCommunityLabel{}
+	resolve(c.TiCommunityLabel, org, repo, branch, label)
+	return label
 }
 
 // AutoresponderFor finds the TiCommunityAutoresponder for a repo, if one exists.
 // TiCommunityAutoresponder configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) AutoresponderFor(org, repo string) *TiCommunityAutoresponder {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, autoresponder := range c.TiCommunityAutoresponder {
-		if !sets.NewString(autoresponder.Repos...).Has(fullName) {
-			continue
-		}
-		return &autoresponder
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, autoresponder := range c.TiCommunityAutoresponder {
-		if !sets.NewString(autoresponder.Repos...).Has(org) {
-			continue
-		}
-		return &autoresponder
-	}
-	return &TiCommunityAutoresponder{}
+	return c.AutoresponderForBranch(org, repo, "")
+}
+
+// AutoresponderForBranch finds the TiCommunityAutoresponder for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) AutoresponderForBranch(org, repo, branch string) *TiCommunityAutoresponder {
+	autoresponder := &TiCommunityAutoresponder{}
+	resolve(c.TiCommunityAutoresponder, org, repo, branch, autoresponder)
+	return autoresponder
 }
 
 // BlunderbussFor finds the TiCommunityBlunderbuss for a repo, if one exists.
 // TiCommunityBlunderbuss configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) BlunderbussFor(org, repo string) *TiCommunityBlunderbuss {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, blunderbuss := range c.TiCommunityBlunderbuss {
-		if !sets.NewString(blunderbuss.Repos...).Has(fullName) {
-			continue
-		}
-		return &blunderbuss
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, blunderbuss := range c.TiCommunityBlunderbuss {
-		if !sets.NewString(blunderbuss.Repos...).Has(org) {
-			continue
-		}
-		return &blunderbuss
-	}
-	return &TiCommunityBlunderbuss{}
+	return c.BlunderbussForBranch(org, repo, "")
+}
+
+// BlunderbussForBranch finds the TiCommunityBlunderbuss for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) BlunderbussForBranch(org, repo, branch string) *TiCommunityBlunderbuss {
+	blunderbuss := &TiCommunityBlunderbuss{}
+	resolve(c.TiCommunityBlunderbuss, org, repo, branch, blunderbuss)
+	return blunderbuss
+}
+
+// BlunderbussBranchesFor returns the branch patterns of the branch level TiCommunityBlunderbuss of the repo.
+func (c *Configuration) BlunderbussBranchesFor(org, repo string) []string {
+	return branchPatterns(c.TiCommunityBlunderbuss, org, repo)
 }
 
 // TarsFor finds the TiCommunityTars for a repo, if one exists.
 // TiCommunityTars configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) TarsFor(org, repo string) *TiCommunityTars {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, tars := range c.TiCommunityTars {
-		if !sets.NewString(tars.Repos...).Has(fullName) {
-			continue
-		}
-		return &tars
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, tars := range c.TiCommunityTars {
-		if !sets.NewString(tars.Repos...).Has(org) {
-			continue
-		}
-		return &tars
-	}
-	return &TiCommunityTars{}
+	return c.TarsForBranch(org, repo, "")
+}
+
+// TarsForBranch finds the TiCommunityTars for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) TarsForBranch(org, repo, branch string) *TiCommunityTars {
+	tars := &TiCommunityTars{}
+	resolve(c.TiCommunityTars, org, repo, branch, tars)
+	return tars
+}
+
+// TarsBranchesFor returns the branch patterns of the branch level TiCommunityTars of the repo.
+func (c *Configuration) TarsBranchesFor(org, repo string) []string {
+	return branchPatterns(c.TiCommunityTars, org, repo)
 }
 
 // ContributionFor finds the TiCommunityContribution for a repo, if one exists.
 // TiCommunityContribution configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) ContributionFor(org, repo string) *TiCommunityContribution {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, contribution := range c.TiCommunityContribution {
-		if !sets.NewString(contribution.Repos...).Has(fullName) {
-			continue
-		}
-		return &contribution
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, contribution := range c.TiCommunityContribution {
-		if !sets.NewString(contribution.Repos...).Has(org) {
-			continue
-		}
-		return &contribution
-	}
-	return &TiCommunityContribution{}
+	return c.ContributionForBranch(org, repo, "")
+}
+
+// ContributionForBranch finds the TiCommunityContribution for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) ContributionForBranch(org, repo, branch string) *TiCommunityContribution {
+	contribution := &TiCommunityContribution{}
+	resolve(c.TiCommunityContribution, org, repo, branch, contribution)
+	return contribution
 }
 
 // LabelBlockerFor finds the TiCommunityLabelBlocker for a repo, if one exists.
 // TiCommunityLabelBlocker configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) LabelBlockerFor(org, repo string) *TiCommunityLabelBlocker {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, labelBlocker := range c.TiCommunityLabelBlocker {
-		if !sets.NewString(labelBlocker.Repos...).Has(fullName) {
-			continue
-		}
-		return &labelBlocker
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, labelBlocker := range c.TiCommunityLabelBlocker {
-		if !sets.NewString(labelBlocker.Repos...).Has(org) {
-			continue
-		}
-		return &labelBlocker
-	}
-	return &TiCommunityLabelBlocker{}
+	return c.LabelBlockerForBranch(org, repo, "")
+}
+
+// LabelBlockerForBranch finds the TiCommunityLabelBlocker for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) LabelBlockerForBranch(org, repo, branch string) *TiCommunityLabelBlocker {
+	labelBlocker := &TiCommunityLabelBlocker{}
+	resolve(c.TiCommunityLabelBlocker, org, repo, branch, labelBlocker)
+	return labelBlocker
 }
 
 // CherrypickerFor finds the TiCommunityCherrypicker for a repo, if one exists.
 // TiCommunityCherrypicker configuration can be listed for a repository
 // or an organization.
 func (c *Configuration) CherrypickerFor(org, repo string) *TiCommunityCherrypicker {
-	fullName := fmt.Sprintf("%s/%s", org, repo)
-	for _, cherrypicker := range c.TiCommunityCherrypicker {
-		if !sets.NewString(cherrypicker.Repos...).Has(fullName) {
-			continue
-		}
-		return &cherrypicker
-	}
-	// If you don't find anything, loop again looking for an org config
-	for _, cherrypicker := range c.TiCommunityCherrypicker {
-		if !sets.NewString(cherrypicker.Repos...).Has(org) {
-			continue
-		}
-		return &cherrypicker
-	}
-	return &TiCommunityCherrypicker{}
+	return c.CherrypickerForBranch(org, repo, "")
 }
 
-// setDefaults will set the default value for the configuration of all plugins.
-func (c *Configuration) setDefaults() {
-	for i := range c.TiCommunityBlunderbuss {
-		c.TiCommunityBlunderbuss[i].setDefaults()
-	}
+// CherrypickerForBranch finds the TiCommunityCherrypicker for a branch of the repo, if one exists.
+// The org level, repo level and branch level configurations are merged field by field.
+func (c *Configuration) CherrypickerForBranch(org, repo, branch string) *TiCommunityCherrypicker {
+	cherrypicker := &TiCommunityCherrypicker{}
+	resolve(c.TiCommunityCherrypicker, org, repo, branch, cherrypicker)
+	return cherrypicker
+}
 
-	for i := range c.TiCommunityCherrypicker {
-		c.TiCommunityCherrypicker[i].setDefaults()
-	}
+// UnmarshalJSON unmarshals the TiCommunityLgtm and records the fields set explicitly.
+func (l *TiCommunityLgtm) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityLgtm
+	setFields, err := unmarshalFieldSet(b, (*plain)(l))
+	l.setFields = setFields
+	return err
+}
 
-	for i := range c.TiCommunityTars {
-		c.TiCommunityTars[i].setDefaults()
-	}
+// UnmarshalJSON unmarshals the TiCommunityMerge and records the fields set explicitly.
+func (m *TiCommunityMerge) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityMerge
+	setFields, err := unmarshalFieldSet(b, (*plain)(m))
+	m.setFields = setFields
+	return err
+}
 
+// UnmarshalJSON unmarshals the TiCommunityOwners and records the fields set explicitly.
+func (o *TiCommunityOwners) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityOwners
+	setFields, err := unmarshalFieldSet(b, (*plain)(o))
+	o.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityLabel and records the fields set explicitly.
+func (l *TiCommunityLabel) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityLabel
+	setFields, err := unmarshalFieldSet(b, (*plain)(l))
+	l.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityAutoresponder and records the fields set explicitly.
+func (a *TiCommunityAutoresponder) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityAutoresponder
+	setFields, err := unmarshalFieldSet(b, (*plain)(a))
+	a.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityBlunderbuss and records the fields set explicitly.
+func (b *TiCommunityBlunderbuss) UnmarshalJSON(data []byte) error {
+	type plain TiCommunityBlunderbuss
+	setFields, err := unmarshalFieldSet(data, (*plain)(b))
+	b.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityTars and records the fields set explicitly.
+func (t *TiCommunityTars) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityTars
+	setFields, err := unmarshalFieldSet(b, (*plain)(t))
+	t.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityLabelBlocker and records the fields set explicitly.
+func (l *TiCommunityLabelBlocker) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityLabelBlocker
+	setFields, err := unmarshalFieldSet(b, (*plain)(l))
+	l.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityContribution and records the fields set explicitly.
+func (c *TiCommunityContribution) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityContribution
+	setFields, err := unmarshalFieldSet(b, (*plain)(c))
+	c.setFields = setFields
+	return err
+}
+
+// UnmarshalJSON unmarshals the TiCommunityCherrypicker and records the fields set explicitly.
+func (c *TiCommunityCherrypicker) UnmarshalJSON(b []byte) error {
+	type plain TiCommunityCherrypicker
+	setFields, err := unmarshalFieldSet(b, (*plain)(c))
+	c.setFields = setFields
+	return err
+}
+
+// setDefaults will set the default value for the global configuration.
+// NOTICE: The default values of the plugins are set after the plugin configurations are resolved,
+// otherwise the default values of a repo level configuration would override the org level configuration.
+func (c *Configuration) setDefaults() {
 	if len(c.LogLevel) == 0 {
 		c.LogLevel = defaultLogLevel.String()
 	}
 }
 
+// fieldPath is the path of a field in the configuration file, the elements are the JSON names of the fields
+// or the keys of the maps, which are strings, and the indexes of the lists, which are ints.
+type fieldPath []interface{}
+
+// with returns a new path with the elements appended.
+func (p fieldPath) with(elements ...interface{}) fieldPath {
+	path := make(fieldPath, 0, len(p)+len(elements))
+	path = append(path, p...)
+	return append(path, elements...)
+}
+
+// String returns the path in the form like "ti-community-lgtm[0].repos[1]".
+func (p fieldPath) String() string {
+	var b strings.Builder
+	for _, element := range p {
+		switch e := element.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", e)
+		default:
+			if b.Len() != 0 {
+				b.WriteString(".")
+			}
+			fmt.Fprintf(&b, "%v", e)
+		}
+	}
+	return b.String()
+}
+
+// configError is an error of the field located by path.
+type configError struct {
+	path fieldPath
+	err  error
+}
+
 // Validate will return an error if there are any invalid external plugin config.
 func (c *Configuration) Validate() error {
 	// Defaulting should run before validation.
 	c.setDefaults()
 
-	// Validate tichi web URL.
-	if _, err := url.ParseRequestURI(c.TichiWebURL); err != nil {
-		return err
-	}
-
-	// Validate pr process link.
-	if _, err := url.ParseRequestURI(c.PRProcessLink); err != nil {
-		return err
+	if errs := c.validate(); len(errs) != 0 {
+		return errs[0].err
 	}
+	return nil
+}
 
-	// Validate command help link.
-	if _, err := url.ParseRequestURI(c.CommandHelpLink); err != nil {
-		return err
+// validate returns all the errors of the configuration.
+func (c *Configuration) validate() []configError {
+	var errs []configError
+
+	// Validate tichi web URL, pr process link and command help link.
+	for _, link := range []struct {
+		field string
+		value string
+	}{
+		{field: "tichi_web_url", value: c.TichiWebURL},
+		{field: "pr_process_link", value: c.PRProcessLink},
+		{field: "command_help_link", value: c.CommandHelpLink},
+	} {
+		if _, err := url.ParseRequestURI(link.value); err != nil {
+			errs = append(errs, configError{path: fieldPath{link.field}, err: err})
+		}
 	}
 
 	if err := validateLogLevel(c.LogLevel); err != nil {
-		return err
+		errs = append(errs, configError{path: fieldPath{"log_level"}, err: err})
 	}
 
-	if err := validateLgtm(c.TiCommunityLgtm); err != nil {
-		return err
+	for _, plugin := range c.plugins() {
+		errs = append(errs, repoErrors(plugin.name, reposOf(plugin.configs))...)
 	}
 
-	if err := validateMerge(c.TiCommunityMerge); err != nil {
-		return err
+	for _, plugin := range c.plugins() {
+		configs := reflect.ValueOf(plugin.configs)
+		for i := 0; i < configs.Len(); i++ {
+			errs = append(errs, validateConfig(configs.Index(i), fieldPath{plugin.name, i})...)
+		}
 	}
 
-	if err := validateOwners(c.TiCommunityOwners); err != nil {
-		return err
-	}
+	return errs
+}
 
-	if err := validateAutoresponder(c.TiCommunityAutoresponder); err != nil {
-		return err
-	}
+// pluginConfigs is the configurations of a plugin.
+type pluginConfigs struct {
+	// name specifies the name of the plugin, it is also the key of the configurations.
+	name string
+	// configs specifies the slice of the plugin configurations.
+	configs interface{}
+}
 
-	if err := validateBlunderbuss(c.TiCommunityBlunderbuss); err != nil {
-		return err
+// plugins returns the configurations of all plugins in the order they are validated.
+func (c *Configuration) plugins() []pluginConfigs {
+	return []pluginConfigs{
+		{name: "ti-community-lgtm", configs: c.TiCommunityLgtm},
+		{name: "ti-community-merge", configs: c.TiCommunityMerge},
+		{name: "ti-community-owners", configs: c.TiCommunityOwners},
+		{name: "ti-community-autoresponder", configs: c.TiCommunityAutoresponder},
+		{name: "ti-community-blunderbuss", configs: c.TiCommunityBlunderbuss},
+		{name: "ti-community-label-blocker", configs: c.TiCommunityLabelBlocker},
+		{name: "ti-community-tars", configs: c.TiCommunityTars},
+		{name: "ti-community-label", configs: c.TiCommunityLabel},
+		{name: "ti-community-contribution", configs: c.TiCommunityContribution},
+		{name: "ti-community-cherrypicker", configs: c.TiCommunityCherrypicker},
 	}
+}
 
-	if err := validateLabelBlocker(c.TiCommunityLabelBlocker); err != nil {
-		return err
+// validator is implemented by the plugin configs.
+type validator interface {
+	// validate returns the errors of the plugin config located by path.
+	validate(path fieldPath) []configError
+}
+
+// validateConfig validates the plugin config and its branch level overrides.
+func validateConfig(config reflect.Value, path fieldPath) []configError {
+	// The validators have pointer receivers, the values of the branches map are not addressable.
+	ptr := reflect.New(config.Type())
+	ptr.Elem().Set(config)
+
+	var errs []configError
+	if v, ok := ptr.Interface().(validator); ok {
+		errs = append(errs, v.validate(path)...)
 	}
 
-	return validateTars(c.TiCommunityTars)
+	branches := branchOverrides(config)
+	if !branches.IsValid() {
+		return errs
+	}
+	for _, key := range sortedKeys(branches) {
+		errs = append(errs, validateConfig(branches.MapIndex(key), path.with(branchesJSONName, key.String()))...)
+	}
+	return errs
 }
 
 // validateLogLevel will return an error if the value of the log level is invalid.
@@ -547,94 +887,272 @@ func validateLogLevel(logLevel string) error {
 	return nil
 }
 
-// validateLgtm will return an error if the URL configured by lgtm is invalid.
-func validateLgtm(lgtms []TiCommunityLgtm) error {
-	for _, lgtm := range lgtms {
-		_, err := url.ParseRequestURI(lgtm.PullOwnersEndpoint)
-		if err != nil {
-			return err
-		}
+// validateEndpoint will return an error if the endpoint is set but invalid.
+// The endpoint can be omitted by the configuration which only overrides other fields.
+func validateEndpoint(endpoint string, path fieldPath) []configError {
+	if len(endpoint) == 0 {
+		return nil
+	}
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return []configError{{path: path, err: err}}
 	}
-
 	return nil
 }
 
-// validateMerge will return an error if the URL configured by merge is invalid.
-func validateMerge(merges []TiCommunityMerge) error {
-	for _, merge := range merges {
-		_, err := url.ParseRequestURI(merge.PullOwnersEndpoint)
-		if err != nil {
-			return err
+// validate will return errors if the URL, the approvals on push policy, the role weights or the approval rules
+// configured by lgtm are invalid.
+func (l *TiCommunityLgtm) validate(path fieldPath) []configError {
+	errs := validateEndpoint(l.PullOwnersEndpoint, path.with("pull_owners_endpoint"))
+	switch l.ApprovalsOnPush {
+	case "", LgtmApprovalsOnPushKeep, LgtmApprovalsOnPushReset, LgtmApprovalsOnPushResetOnChange:
+	default:
+		errs = append(errs, configError{
+			path: path.with("approvals_on_push"),
+			err: fmt.Errorf("unknown approvals on push policy %q, the policy must be %q, %q or %q", l.ApprovalsOnPush,
+				LgtmApprovalsOnPushKeep, LgtmApprovalsOnPushReset, LgtmApprovalsOnPushResetOnChange),
+		})
+	}
+
+	roles := make([]string, 0, len(l.RoleWeights))
+	for role := range l.RoleWeights {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		errs = append(errs, validateRole(role, path.with("role_weights", role))...)
+		if l.RoleWeights[role] < 1 {
+			errs = append(errs, configError{
+				path: path.with("role_weights", role),
+				err:  errors.New("role weight must not less than 1"),
+			})
 		}
 	}
+	for i := range l.ApprovalRules {
+		errs = append(errs, l.ApprovalRules[i].validate(path.with("approval_rules", i))...)
+	}
+	return errs
+}
 
-	return nil
+// validate will return errors if the name, the role or the minimum approvals of the approval rule is invalid.
+func (r *LgtmApprovalRule) validate(path fieldPath) []configError {
+	var errs []configError
+	if len(r.Name) == 0 {
+		errs = append(errs, configError{path: path.with("name"), err: errors.New("name must be set")})
+	}
+	errs = append(errs, validateRole(r.Role, path.with("role"))...)
+	if r.MinApprovals < 1 {
+		errs = append(errs, configError{
+			path: path.with("min_approvals"),
+			err:  errors.New("min approvals must not less than 1"),
+		})
+	}
+	return errs
 }
 
-// validateOwners will return an error if the endpoint configured by merge is invalid.
-func validateOwners(owners []TiCommunityOwners) error {
-	for _, merge := range owners {
-		_, err := url.ParseRequestURI(merge.SigEndpoint)
-		if err != nil {
-			return err
-		}
+// validateRole will return an error if the role of the owners is unknown.
+func validateRole(role string, path fieldPath) []configError {
+	switch role {
+	case ownersclient.RoleLeader, ownersclient.RoleCommitter, ownersclient.RoleReviewer:
+		return nil
+	default:
+		return []configError{{
+			path: path,
+			err: fmt.Errorf("unknown role %q, the role must be %q, %q or %q", role,
+				ownersclient.RoleLeader, ownersclient.RoleCommitter, ownersclient.RoleReviewer),
+		}}
 	}
+}
 
-	return nil
+// validate will return errors if the URL configured by merge is invalid.
+func (m *TiCommunityMerge) validate(path fieldPath) []configError {
+	return validateEndpoint(m.PullOwnersEndpoint, path.with("pull_owners_endpoint"))
 }
 
-// validateAutoresponder will return an error if the regex cannot compile.
-func validateAutoresponder(autoresponders []TiCommunityAutoresponder) error {
-	for _, autoresponder := range autoresponders {
-		for _, respond := range autoresponder.AutoResponds {
-			_, err := regexp.Compile(respond.Regex)
-			if err != nil {
-				return err
-			}
-		}
+// validate will return errors if the endpoint or the owners file mode configured by owners is invalid.
+func (o *TiCommunityOwners) validate(path fieldPath) []configError {
+	errs := validateEndpoint(o.SigEndpoint, path.with("sig_endpoint"))
+	errs = append(errs, o.validateSigBackend(path)...)
+	errs = append(errs, o.Availability.validate(path.with("availability"))...)
+	errs = append(errs, validateOwnersFileMode(o.OwnersFileMode, path.with("owners_file_mode"))...)
+
+	// The branch configs of owners have their own type, so they are not validated as the branch overrides.
+	branches := make([]string, 0, len(o.Branches))
+	for branch := range o.Branches {
+		branches = append(branches, branch)
 	}
-
-	return nil
+	sort.Strings(branches)
+	for _, branch := range branches {
+		errs = append(errs, validateOwnersFileMode(o.Branches[branch].OwnersFileMode,
+			path.with(branchesJSONName, branch, "owners_file_mode"))...)
+	}
+	return errs
 }
 
-// validateBlunderbuss will return an error if the endpoint configured by blunderbuss is invalid.
-func validateBlunderbuss(blunderbusses []TiCommunityBlunderbuss) error {
-	for _, blunderbuss := range blunderbusses {
-		_, err := url.ParseRequestURI(blunderbuss.PullOwnersEndpoint)
-		if err != nil {
-			return err
+// validateSigBackend will return an error if the sig backend is unknown or its options are missing.
+func (o *TiCommunityOwners) validateSigBackend(path fieldPath) []configError {
+	switch o.SigBackend {
+	case "", SigBackendHTTP:
+		return nil
+	case SigBackendFile:
+		if len(o.SigFile) == 0 {
+			return []configError{{path: path.with("sig_file"), err: errors.New("sig file must be set for the file backend")}}
 		}
-		if blunderbuss.MaxReviewerCount <= 0 {
-			return errors.New("max reviewer count must more than 0")
+		if len(o.SigFileRepo) != 0 && len(strings.Split(o.SigFileRepo, "/")) != 2 {
+			return []configError{{path: path.with("sig_file_repo"),
+				err: fmt.Errorf("invalid sig file repo %q, the repo must be in the form of org/repo", o.SigFileRepo)}}
 		}
-		if blunderbuss.GracePeriodDuration < 0 {
-			return errors.New("grace period duration must not less than 0")
-		}
-		if len(blunderbuss.IncludeReviewers) != 0 && len(blunderbuss.ExcludeReviewers) != 0 {
-			return errors.New("cannot set both include_reviewers and exclude_reviewers configurations")
+		return nil
+	case SigBackendGitHubTeams:
+		if len(o.SigTeams) == 0 {
+			return []configError{{path: path.with("sig_teams"),
+				err: errors.New("sig teams must be set for the github_teams backend")}}
 		}
+		return nil
 	}
+	return []configError{{path: path.with("sig_backend"),
+		err: fmt.Errorf("unknown sig backend %q, the backend must be %q, %q or %q",
+			o.SigBackend, SigBackendHTTP, SigBackendFile, SigBackendGitHubTeams)}}
+}
 
+// validate will return an error if the availability file or issue is set without the repo.
+func (a *TiCommunityOwnerAvailability) validate(path fieldPath) []configError {
+	if len(a.File) == 0 && a.Issue == 0 {
+		return nil
+	}
+	if len(strings.Split(a.Repo, "/")) != 2 {
+		return []configError{{path: path.with("repo"),
+			err: fmt.Errorf("invalid availability repo %q, the repo must be in the form of org/repo", a.Repo)}}
+	}
 	return nil
 }
 
-// validateLabelBlocker will return an error if the regex cannot compile or actions is illegal.
-func validateLabelBlocker(labelBlockers []TiCommunityLabelBlocker) error {
-	for _, labelBlocker := range labelBlockers {
-		for _, blockLabel := range labelBlocker.BlockLabels {
-			_, err := regexp.Compile(blockLabel.Regex)
-			if err != nil {
-				return err
-			}
+// validateOwnersFileMode will return an error if the owners file mode is set but unknown.
+func validateOwnersFileMode(mode string, path fieldPath) []configError {
+	switch mode {
+	case "", OwnersFileModeOwners, OwnersFileModeCodeowners:
+		return nil
+	}
+	return []configError{{path: path, err: fmt.Errorf("unknown owners file mode %q, the mode must be %q or %q",
+		mode, OwnersFileModeOwners, OwnersFileModeCodeowners)}}
+}
 
-			err = validateLabelBlockerAction(blockLabel.Actions)
-			if err != nil {
-				return err
-			}
+// validate will return errors if the regex cannot compile.
+func (a *TiCommunityAutoresponder) validate(path fieldPath) []configError {
+	var errs []configError
+	for i, respond := range a.AutoResponds {
+		if _, err := regexp.Compile(respond.Regex); err != nil {
+			errs = append(errs, configError{path: path.with("auto_responds", i, "regex"), err: err})
 		}
 	}
 
-	return nil
+	return errs
+}
+
+// validate will return errors if the config of blunderbuss is invalid.
+func (b *TiCommunityBlunderbuss) validate(path fieldPath) []configError {
+	errs := validateEndpoint(b.PullOwnersEndpoint, path.with("pull_owners_endpoint"))
+	if b.MaxReviewerCount < 0 {
+		errs = append(errs, configError{
+			path: path.with("max_request_count"),
+			err:  errors.New("max reviewer count must not less than 0"),
+		})
+	}
+	if b.GracePeriodDuration < 0 {
+		errs = append(errs, configError{
+			path: path.with("grace_period_duration"),
+			err:  errors.New("grace period duration must not less than 0"),
+		})
+	}
+	if b.MaxHistoryPaths < 0 {
+		errs = append(errs, configError{
+			path: path.with("max_history_paths"),
+			err:  errors.New("max history paths must not less than 0"),
+		})
+	}
+	if b.HistoryHalfLife < 0 {
+		errs = append(errs, configError{
+			path: path.with("history_half_life"),
+			err:  errors.New("history half life must not less than 0"),
+		})
+	}
+	if b.StaleReviewHours < 0 {
+		errs = append(errs, configError{
+			path: path.with("stale_review_hours"),
+			err:  errors.New("stale review hours must not less than 0"),
+		})
+	}
+	if b.MaxReviewerLoad < 0 {
+		errs = append(errs, configError{
+			path: path.with("max_reviewer_load"),
+			err:  errors.New("max reviewer load must not less than 0"),
+		})
+	}
+	switch b.Strategy {
+	case "", BlunderbussStrategyRandom, BlunderbussStrategyFileHistory, BlunderbussStrategyLeastLoaded,
+		BlunderbussStrategyRoundRobin:
+	default:
+		errs = append(errs, configError{
+			path: path.with("strategy"),
+			err: fmt.Errorf("unknown strategy %q, the strategy must be %q, %q, %q or %q", b.Strategy,
+				BlunderbussStrategyRandom, BlunderbussStrategyFileHistory, BlunderbussStrategyLeastLoaded,
+				BlunderbussStrategyRoundRobin),
+		})
+	}
+	for i := range b.ReviewerGroups {
+		errs = append(errs, b.ReviewerGroups[i].validate(path.with("reviewer_groups", i))...)
+	}
+	if len(b.IncludeReviewers) != 0 && len(b.ExcludeReviewers) != 0 {
+		errs = append(errs, configError{
+			path: path.with("include_reviewers"),
+			err:  errors.New("cannot set both include_reviewers and exclude_reviewers configurations"),
+		})
+	}
+
+	return errs
+}
+
+// validate will return errors if the config of the reviewer group is invalid.
+func (g *BlunderbussReviewerGroup) validate(path fieldPath) []configError {
+	var errs []configError
+	if len(g.Name) == 0 {
+		errs = append(errs, configError{path: path.with("name"), err: errors.New("name must be set")})
+	}
+	if g.MinCount < 0 {
+		errs = append(errs, configError{
+			path: path.with("min_count"),
+			err:  errors.New("min count must not less than 0"),
+		})
+	}
+	if g.MaxCount < 0 || (g.MaxCount > 0 && g.MaxCount < g.MinCount) {
+		errs = append(errs, configError{
+			path: path.with("max_count"),
+			err:  errors.New("max count must not less than 0 or min count"),
+		})
+	}
+	if g.RequestTeams && len(g.Teams) == 0 {
+		errs = append(errs, configError{
+			path: path.with("request_teams"),
+			err:  errors.New("teams must be set to request reviews from the teams"),
+		})
+	}
+
+	return errs
+}
+
+// validate will return errors if the regex cannot compile or actions is illegal.
+func (l *TiCommunityLabelBlocker) validate(path fieldPath) []configError {
+	var errs []configError
+	for i, blockLabel := range l.BlockLabels {
+		if _, err := regexp.Compile(blockLabel.Regex); err != nil {
+			errs = append(errs, configError{path: path.with("block_labels", i, "regex"), err: err})
+		}
+
+		if err := validateLabelBlockerAction(blockLabel.Actions); err != nil {
+			errs = append(errs, configError{path: path.with("block_labels", i, "actions"), err: err})
+		}
+	}
+
+	return errs
 }
 
 // validateLabelBlockerAction used to check whether all actions filled in are allowed values.
@@ -656,18 +1174,75 @@ func validateLabelBlockerAction(actions []string) error {
 	return nil
 }
 
-// validateTars will return an error if tars is set for org.
+// validate will return errors if tars is set for org.
 // If set directly to org will query the query to a large number of pull requests,
 // which will create a dos attack to the CI system.
-func validateTars(tars []TiCommunityTars) error {
-	for _, tar := range tars {
-		for _, repo := range tar.Repos {
-			slashSplit := strings.Split(repo, "/")
-			if n := len(slashSplit); n != 2 {
-				return fmt.Errorf("found repo %s that was not in org/repo format", repo)
-			}
+func (t *TiCommunityTars) validate(path fieldPath) []configError {
+	var errs []configError
+	for i, repo := range t.Repos {
+		slashSplit := strings.Split(repo, "/")
+		if n := len(slashSplit); n != 2 {
+			errs = append(errs, configError{
+				path: path.with(reposJSONName, i),
+				err:  fmt.Errorf("found repo %s that was not in org/repo format", repo),
+			})
 		}
 	}
 
+	return errs
+}
+
+// validate will return errors if the labels configured by label are empty or conflicting.
+func (l *TiCommunityLabel) validate(path fieldPath) []configError {
+	errs := validateLabelNames(l.Prefixes, path.with("prefixes"))
+	errs = append(errs, validateLabelNames(l.AdditionalLabels, path.with("additional_labels"))...)
+	errs = append(errs, validateLabelNames(l.ExcludeLabels, path.with("exclude_labels"))...)
+
+	excludeLabels := sets.NewString(l.ExcludeLabels...)
+	for i, label := range l.AdditionalLabels {
+		if excludeLabels.Has(label) {
+			errs = append(errs, configError{
+				path: path.with("additional_labels", i),
+				err:  fmt.Errorf("label %s cannot be both additional and excluded", label),
+			})
+		}
+	}
+
+	return errs
+}
+
+// validate will return errors if the config of contribution is invalid.
+// There is nothing to check except the repos, which are checked for all plugins.
+func (c *TiCommunityContribution) validate(fieldPath) []configError {
 	return nil
 }
+
+// validate will return errors if the labels configured by cherrypicker are empty or conflicting.
+func (c *TiCommunityCherrypicker) validate(path fieldPath) []configError {
+	errs := validateLabelNames(c.ExcludeLabels, path.with("excludeLabels"))
+
+	labelPrefix := c.LabelPrefix
+	if len(labelPrefix) == 0 {
+		labelPrefix = DefaultCherryPickLabelPrefix
+	}
+	if labelPrefix == c.PickedLabelPrefix {
+		errs = append(errs, configError{
+			path: path.with("picked_label_prefix"),
+			err:  fmt.Errorf("picked label prefix must be different from the label prefix %s", labelPrefix),
+		})
+	}
+
+	return errs
+}
+
+// validateLabelNames will return errors if any of the label names is empty.
+func validateLabelNames(labels []string, path fieldPath) []configError {
+	var errs []configError
+	for i, label := range labels {
+		if len(strings.TrimSpace(label)) == 0 {
+			errs = append(errs, configError{path: path.with(i), err: errors.New("label must not be empty")})
+		}
+	}
+
+	return errs
+}
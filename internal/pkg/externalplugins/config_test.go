@@ -2,10 +2,12 @@
 package externalplugins
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
 
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"gotest.tools/assert"
 )
 
@@ -266,6 +268,206 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expected: fmt.Errorf("parse \"https/bots.tidb.io/ti-community-bot\": invalid URI for request"),
 		},
+		{
+			name:            "invalid owners file mode of branch",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:          []string{"ti-community-infra/test-dev"},
+				SigEndpoint:    "https://bots.tidb.io/ti-community-bot",
+				OwnersFileMode: OwnersFileModeOwners,
+				Branches: map[string]TiCommunityOwnerBranchConfig{
+					"release": {OwnersFileMode: "OWNERS"},
+				},
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("unknown owners file mode \"OWNERS\", the mode must be \"owners\" or \"codeowners\""),
+		},
+		{
+			name:            "file sig backend without sig file",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:      []string{"ti-community-infra/test-dev"},
+				SigBackend: SigBackendFile,
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: errors.New("sig file must be set for the file backend"),
+		},
+		{
+			name:            "unknown sig backend",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:      []string{"ti-community-infra/test-dev"},
+				SigBackend: "ldap",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("unknown sig backend \"ldap\", the backend must be \"http\", \"file\" or \"github_teams\""),
+		},
+		{
+			name:            "invalid availability repo",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"ti-community-infra/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Availability: TiCommunityOwnerAvailability{
+					Repo:  "community",
+					Issue: 42,
+				},
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"ti-community-infra/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"ti-community-infra/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: errors.New("invalid availability repo \"community\", the repo must be in the form of org/repo"),
+		},
 		{
 			name:            "invalid blunderbuss regex",
 			tichiWebURL:     "https://tichiWebURL",
@@ -408,7 +610,7 @@ func TestValidateConfig(t *testing.T) {
 			tars: &TiCommunityTars{
 				Repos: []string{"ti-community-infra/test-dev"},
 			},
-			expected: fmt.Errorf("max reviewer count must more than 0"),
+			expected: fmt.Errorf("max reviewer count must not less than 0"),
 		},
 		{
 			name:            "invalid blunderbuss grace period duration",
@@ -459,6 +661,266 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expected: fmt.Errorf("grace period duration must not less than 0"),
 		},
+		{
+			name:            "unknown blunderbuss strategy",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				Strategy:           "fastest",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("unknown strategy \"fastest\", the strategy must be \"random\", " +
+				"\"file-history\", \"least-loaded\" or \"round-robin\""),
+		},
+		{
+			name:            "unknown lgtm approvals on push policy",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ApprovalsOnPush:    "dismiss",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("unknown approvals on push policy \"dismiss\", the policy must be \"keep\", " +
+				"\"reset\" or \"reset-on-change\""),
+		},
+		{
+			name:            "unknown lgtm role weight role",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				RoleWeights:        map[string]int{"maintainer": 2},
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("unknown role \"maintainer\", the role must be \"leader\", " +
+				"\"committer\" or \"reviewer\""),
+		},
+		{
+			name:            "invalid lgtm approval rule min approvals",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ApprovalRules: []LgtmApprovalRule{
+					{
+						Name: "committers",
+						Role: "committer",
+					},
+				},
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("min approvals must not less than 1"),
+		},
+		{
+			name:            "invalid blunderbuss reviewer group max count",
+			tichiWebURL:     "https://tichiWebURL",
+			commandHelpLink: "https://commandHelpLink",
+			prProcessLink:   "https://prProcessLink",
+			lgtm: &TiCommunityLgtm{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			merge: &TiCommunityMerge{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			owners: &TiCommunityOwners{
+				Repos:       []string{"tidb-community-bots/test-dev"},
+				SigEndpoint: "https://bots.tidb.io/ti-community-bot",
+			},
+			autoresponders: &TiCommunityAutoresponder{
+				Repos: []string{"tidb-community-bots/test-dev"},
+				AutoResponds: []AutoRespond{
+					{
+						Regex:   `(?mi)^/merge\s*$`,
+						Message: "/run-all-test",
+					},
+				},
+			},
+			blunderbuss: &TiCommunityBlunderbuss{
+				Repos:              []string{"tidb-community-bots/test-dev"},
+				MaxReviewerCount:   2,
+				ExcludeReviewers:   []string{},
+				PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				ReviewerGroups: []BlunderbussReviewerGroup{
+					{
+						Name:     "committers",
+						Members:  []string{"committer1", "committer2"},
+						MinCount: 2,
+						MaxCount: 1,
+					},
+				},
+			},
+			labelBlocker: &TiCommunityLabelBlocker{
+				Repos: []string{"ti-community-infra/test-dev"},
+				BlockLabels: []BlockLabel{
+					{
+						Regex:        `^status/can-merge$`,
+						Actions:      []string{"labeled", "unlabeled"},
+						TrustedTeams: []string{"release-team"},
+						TrustedUsers: []string{"ti-chi-bot"},
+					},
+				},
+			},
+			tars: &TiCommunityTars{
+				Repos: []string{"ti-community-infra/test-dev"},
+			},
+			expected: fmt.Errorf("max count must not less than 0 or min count"),
+		},
 		{
 			name:            "invalid blunderbuss include_reviewers and exclude_reviewers",
 			tichiWebURL:     "https://tichiWebURL",
@@ -978,7 +1440,7 @@ func TestLgtmFor(t *testing.T) {
 			lgtm := config.LgtmFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, lgtm, &TiCommunityLgtm{})
+				assert.DeepEqual(t, lgtm, &TiCommunityLgtm{}, cmpopts.IgnoreUnexported(TiCommunityLgtm{}))
 			} else {
 				assert.DeepEqual(t, lgtm.Repos, tc.lgtm.Repos)
 			}
@@ -1031,7 +1493,7 @@ func TestMergeFor(t *testing.T) {
 			merge := config.MergeFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, merge, &TiCommunityMerge{})
+				assert.DeepEqual(t, merge, &TiCommunityMerge{}, cmpopts.IgnoreUnexported(TiCommunityMerge{}))
 			} else {
 				assert.DeepEqual(t, merge.Repos, tc.merge.Repos)
 			}
@@ -1084,7 +1546,7 @@ func TestOwnersFor(t *testing.T) {
 			owners := config.OwnersFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, owners, &TiCommunityOwners{})
+				assert.DeepEqual(t, owners, &TiCommunityOwners{}, cmpopts.IgnoreUnexported(TiCommunityOwners{}))
 			} else {
 				assert.DeepEqual(t, owners.Repos, tc.owners.Repos)
 			}
@@ -1137,7 +1599,7 @@ func TestLabelFor(t *testing.T) {
 			label := config.LabelFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, label, &TiCommunityLabel{})
+				assert.DeepEqual(t, label, &TiCommunityLabel{}, cmpopts.IgnoreUnexported(TiCommunityLabel{}))
 			} else {
 				assert.DeepEqual(t, label.Repos, tc.label.Repos)
 			}
@@ -1200,7 +1662,7 @@ func TestAutoresponderFor(t *testing.T) {
 			autoresponder := config.AutoresponderFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, autoresponder, &TiCommunityAutoresponder{})
+				assert.DeepEqual(t, autoresponder, &TiCommunityAutoresponder{}, cmpopts.IgnoreUnexported(TiCommunityAutoresponder{}))
 			} else {
 				assert.DeepEqual(t, autoresponder.Repos, tc.autoresponder.Repos)
 			}
@@ -1257,7 +1719,7 @@ func TestBlunderbussFor(t *testing.T) {
 			blunderbuss := config.BlunderbussFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, blunderbuss, &TiCommunityBlunderbuss{})
+				assert.DeepEqual(t, blunderbuss, &TiCommunityBlunderbuss{}, cmpopts.IgnoreUnexported(TiCommunityBlunderbuss{}))
 			} else {
 				assert.DeepEqual(t, blunderbuss.Repos, tc.blunderbuss.Repos)
 			}
@@ -1312,7 +1774,7 @@ func TestTarsFor(t *testing.T) {
 			tars := config.TarsFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, tars, &TiCommunityTars{})
+				assert.DeepEqual(t, tars, &TiCommunityTars{}, cmpopts.IgnoreUnexported(TiCommunityTars{}))
 			} else {
 				assert.DeepEqual(t, tars.Repos, tc.tars.Repos)
 			}
@@ -1324,17 +1786,22 @@ func TestSetBlunderbussDefaults(t *testing.T) {
 	testcases := []struct {
 		name                      string
 		gracePeriodDuration       int
+		strategy                  string
 		expectGracePeriodDuration int
+		expectStrategy            string
 	}{
 		{
 			name:                      "default",
 			gracePeriodDuration:       0,
 			expectGracePeriodDuration: 5,
+			expectStrategy:            BlunderbussStrategyFileHistory,
 		},
 		{
 			name:                      "overwrite",
 			gracePeriodDuration:       3,
+			strategy:                  BlunderbussStrategyLeastLoaded,
 			expectGracePeriodDuration: 3,
+			expectStrategy:            BlunderbussStrategyLeastLoaded,
 		},
 	}
 
@@ -1344,18 +1811,20 @@ func TestSetBlunderbussDefaults(t *testing.T) {
 			c := &Configuration{
 				TiCommunityBlunderbuss: []TiCommunityBlunderbuss{
 					{
+						Repos:               []string{"ti-community-infra/test-dev"},
 						GracePeriodDuration: tc.gracePeriodDuration,
+						Strategy:            tc.strategy,
 					},
 				},
 			}
 
-			c.setDefaults()
-
-			for _, blunderbuss := range c.TiCommunityBlunderbuss {
-				if blunderbuss.GracePeriodDuration != tc.expectGracePeriodDuration {
-					t.Errorf("unexpected grace_period_duration: %v, expected: %v",
-						blunderbuss.GracePeriodDuration, tc.expectGracePeriodDuration)
-				}
+			blunderbuss := c.BlunderbussFor("ti-community-infra", "test-dev")
+			if blunderbuss.GracePeriodDuration != tc.expectGracePeriodDuration {
+				t.Errorf("unexpected grace_period_duration: %v, expected: %v",
+					blunderbuss.GracePeriodDuration, tc.expectGracePeriodDuration)
+			}
+			if blunderbuss.Strategy != tc.expectStrategy {
+				t.Errorf("unexpected strategy: %v, expected: %v", blunderbuss.Strategy, tc.expectStrategy)
 			}
 		})
 	}
@@ -1421,7 +1890,7 @@ func TestLabelBlockerFor(t *testing.T) {
 			labelBlocker := config.LabelBlockerFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, labelBlocker, &TiCommunityLabelBlocker{})
+				assert.DeepEqual(t, labelBlocker, &TiCommunityLabelBlocker{}, cmpopts.IgnoreUnexported(TiCommunityLabelBlocker{}))
 			} else {
 				assert.DeepEqual(t, labelBlocker.Repos, tc.labelBlocker.Repos)
 			}
@@ -1477,7 +1946,7 @@ func TestContributionFor(t *testing.T) {
 			contribution := config.ContributionFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, contribution, &TiCommunityContribution{})
+				assert.DeepEqual(t, contribution, &TiCommunityContribution{}, cmpopts.IgnoreUnexported(TiCommunityContribution{}))
 			} else {
 				assert.DeepEqual(t, contribution.Repos, tc.contribution.Repos)
 			}
@@ -1533,7 +2002,7 @@ func TestCherrypickerFor(t *testing.T) {
 			cherrypicker := config.CherrypickerFor(tc.org, tc.repo)
 
 			if tc.expectEmpty != nil {
-				assert.DeepEqual(t, cherrypicker, &TiCommunityCherrypicker{})
+				assert.DeepEqual(t, cherrypicker, &TiCommunityCherrypicker{}, cmpopts.IgnoreUnexported(TiCommunityCherrypicker{}))
 			} else {
 				assert.DeepEqual(t, cherrypicker.Repos, tc.cherrypicker.Repos)
 			}
@@ -1565,18 +2034,16 @@ func TestSetCherrypickerDefaults(t *testing.T) {
 			c := &Configuration{
 				TiCommunityCherrypicker: []TiCommunityCherrypicker{
 					{
+						Repos:       []string{"ti-community-infra/test-dev"},
 						LabelPrefix: tc.labelPrefix,
 					},
 				},
 			}
 
-			c.setDefaults()
-
-			for _, cherrypicker := range c.TiCommunityCherrypicker {
-				if cherrypicker.LabelPrefix != tc.expectLabelPrefix {
-					t.Errorf("unexpected labelPrefix: %v, expected: %v",
-						cherrypicker.LabelPrefix, tc.expectLabelPrefix)
-				}
+			cherrypicker := c.CherrypickerFor("ti-community-infra", "test-dev")
+			if cherrypicker.LabelPrefix != tc.expectLabelPrefix {
+				t.Errorf("unexpected labelPrefix: %v, expected: %v",
+					cherrypicker.LabelPrefix, tc.expectLabelPrefix)
 			}
 		})
 	}
@@ -1616,24 +2083,22 @@ func TestSetTarsDefaults(t *testing.T) {
 			c := &Configuration{
 				TiCommunityTars: []TiCommunityTars{
 					{
+						Repos:         []string{"ti-community-infra/test-dev"},
 						OnlyWhenLabel: tc.onlyWhenLabel,
 						ExcludeLabels: tc.excludeLabels,
 					},
 				},
 			}
 
-			c.setDefaults()
-
-			for _, tars := range c.TiCommunityTars {
-				if tars.OnlyWhenLabel != tc.expectOnlyWhenLabel {
-					t.Errorf("unexpected onlyWhenLabel: %v, expected: %v",
-						tars.OnlyWhenLabel, tc.expectOnlyWhenLabel)
-				}
+			tars := c.TarsFor("ti-community-infra", "test-dev")
+			if tars.OnlyWhenLabel != tc.expectOnlyWhenLabel {
+				t.Errorf("unexpected onlyWhenLabel: %v, expected: %v",
+					tars.OnlyWhenLabel, tc.expectOnlyWhenLabel)
+			}
 
-				if !reflect.DeepEqual(tars.ExcludeLabels, tc.expectExcludeLabels) {
-					t.Errorf("unexpected excludeLabels: %v, expected: %v",
-						tars.ExcludeLabels, tc.expectExcludeLabels)
-				}
+			if !reflect.DeepEqual(tars.ExcludeLabels, tc.expectExcludeLabels) {
+				t.Errorf("unexpected excludeLabels: %v, expected: %v",
+					tars.ExcludeLabels, tc.expectExcludeLabels)
 			}
 		})
 	}
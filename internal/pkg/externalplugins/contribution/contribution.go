@@ -82,6 +82,14 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient) {
+	host.RegisterPullRequestEventHandler(PluginName,
+		func(pe *github.PullRequestEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullRequestEvent(gc, pe, cfg, log)
+		})
+}
+
 func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 	config *tiexternalplugins.Configuration, log *logrus.Entry) error {
 	if pe.Action != github.PullRequestActionOpened {
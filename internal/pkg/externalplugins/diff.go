@@ -0,0 +1,221 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The kinds of the effective configuration changes.
+const (
+	// ConfigAdded means the plugin is configured for the repo only in the new configuration.
+	ConfigAdded = "added"
+	// ConfigRemoved means the plugin is configured for the repo only in the old configuration.
+	ConfigRemoved = "removed"
+	// ConfigModified means the effective configuration of the plugin for the repo is changed.
+	ConfigModified = "modified"
+)
+
+// FieldDiff is the difference of a field of the effective plugin configuration.
+type FieldDiff struct {
+	// Field specifies the JSON name of the field.
+	Field string `json:"field"`
+	// Old specifies the JSON value of the field in the old configuration.
+	Old string `json:"old"`
+	// New specifies the JSON value of the field in the new configuration.
+	New string `json:"new"`
+}
+
+// ConfigDiff is the difference of the effective configuration of a plugin for a repo or a branch.
+type ConfigDiff struct {
+	// OrgRepo specifies the org/repo or org whose effective configuration is changed.
+	OrgRepo string `json:"org_repo"`
+	// Branch specifies the branch whose effective configuration is changed, it is empty for the whole repo.
+	Branch string `json:"branch,omitempty"`
+	// Plugin specifies the name of the plugin.
+	Plugin string `json:"plugin"`
+	// Change specifies the kind of the change, it is "added", "removed" or "modified".
+	Change string `json:"change"`
+	// Fields specifies the changed fields.
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// diffTarget is an org/repo or org whose effective configuration is compared.
+type diffTarget struct {
+	org  string
+	repo string
+}
+
+func (t diffTarget) String() string {
+	if len(t.repo) == 0 {
+		return t.org
+	}
+	return t.org + "/" + t.repo
+}
+
+// Diff compares the effective configurations of the old and new configuration for every org/repo, org and
+// branch that appears in either of them, and returns the differences ordered by org/repo, branch and plugin.
+//
+// The effective configurations are resolved in the same way as the plugins do, including the defaults,
+// so a change of an org level config is reported for each repo configured in the files that inherits it.
+// NOTICE: The repos which only match glob patterns cannot be listed, so a glob pattern is reported as
+// a repo named by the pattern itself.
+func Diff(oldConfig, newConfig *Configuration) []ConfigDiff {
+	oldPlugins, newPlugins := oldConfig.plugins(), newConfig.plugins()
+
+	var diffs []ConfigDiff
+	for _, target := range diffTargets(oldConfig, newConfig) {
+		for _, branch := range diffBranches(oldConfig, newConfig) {
+			for i := range oldPlugins {
+				diff := diffPlugin(oldPlugins[i].configs, newPlugins[i].configs, target, branch)
+				if diff == nil {
+					continue
+				}
+				diff.OrgRepo = target.String()
+				diff.Plugin = oldPlugins[i].name
+				diffs = append(diffs, *diff)
+			}
+		}
+	}
+	return diffs
+}
+
+// diffTargets returns the org/repos and orgs configured in either of the configurations in order.
+func diffTargets(configs ...*Configuration) []diffTarget {
+	names := make(map[string]bool)
+	for _, c := range configs {
+		for _, plugin := range c.plugins() {
+			for _, repos := range reposOf(plugin.configs) {
+				for _, repo := range repos {
+					names[repo] = true
+				}
+			}
+		}
+	}
+
+	targets := make([]diffTarget, 0, len(names))
+	for name := range names {
+		org, repo := splitOrgRepo(name)
+		targets = append(targets, diffTarget{org: org, repo: repo})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].String() < targets[j].String()
+	})
+	return targets
+}
+
+// diffBranches returns the branch patterns of the branch level overrides in either of the configurations
+// in order, the first one is the empty branch which stands for the whole repo.
+func diffBranches(configs ...*Configuration) []string {
+	names := make(map[string]bool)
+	for _, c := range configs {
+		for _, plugin := range c.plugins() {
+			pluginConfigs := reflect.ValueOf(plugin.configs)
+			for i := 0; i < pluginConfigs.Len(); i++ {
+				branches := branchOverrides(pluginConfigs.Index(i))
+				if !branches.IsValid() {
+					continue
+				}
+				for _, key := range branches.MapKeys() {
+					names[key.String()] = true
+				}
+			}
+		}
+	}
+
+	branches := make([]string, 0, len(names))
+	for name := range names {
+		branches = append(branches, name)
+	}
+	sort.Strings(branches)
+	return append([]string{""}, branches...)
+}
+
+// diffPlugin compares the effective plugin configurations for the repo or branch, it returns nil if the
+// configuration is not changed. For a branch, it returns nil if the branch does not override the effective
+// configuration of the repo in both configurations, since the change is already reported for the repo.
+func diffPlugin(oldConfigs, newConfigs interface{}, target diffTarget, branch string) *ConfigDiff {
+	oldConfigured := matchReposList(reposOf(oldConfigs), target) != noMatch
+	newConfigured := matchReposList(reposOf(newConfigs), target) != noMatch
+	if !oldConfigured && !newConfigured {
+		return nil
+	}
+
+	oldResolved := resolveValue(oldConfigs, target, branch)
+	newResolved := resolveValue(newConfigs, target, branch)
+	if len(branch) != 0 && len(diffFields(oldResolved, resolveValue(oldConfigs, target, ""))) == 0 &&
+		len(diffFields(newResolved, resolveValue(newConfigs, target, ""))) == 0 {
+		return nil
+	}
+
+	diff := &ConfigDiff{Branch: branch, Fields: diffFields(oldResolved, newResolved)}
+	switch {
+	case !oldConfigured:
+		diff.Change = ConfigAdded
+	case !newConfigured:
+		diff.Change = ConfigRemoved
+	case len(diff.Fields) != 0:
+		diff.Change = ConfigModified
+	default:
+		return nil
+	}
+	return diff
+}
+
+// matchReposList returns the highest priority of the repos patterns matching the target.
+func matchReposList(reposList [][]string, target diffTarget) int {
+	priority := noMatch
+	for _, repos := range reposList {
+		if p := matchRepos(repos, target.org, target.repo); p > priority {
+			priority = p
+		}
+	}
+	return priority
+}
+
+// resolveValue returns the effective plugin config of the configs for the target and branch.
+func resolveValue(configs interface{}, target diffTarget, branch string) reflect.Value {
+	result := reflect.New(reflect.TypeOf(configs).Elem())
+	resolve(configs, target.org, target.repo, branch, result.Interface())
+	return result.Elem()
+}
+
+// diffFields returns the differences of the fields of the effective plugin configs, the repos and
+// the branch level overrides are not compared since they are resolved already.
+func diffFields(oldValue, newValue reflect.Value) []FieldDiff {
+	var diffs []FieldDiff
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+		if field.Name == reposFieldName || (field.Name == branchesFieldName && branchOverrides(oldValue).IsValid()) {
+			continue
+		}
+
+		oldField, newField := jsonValue(oldValue.Field(i)), jsonValue(newValue.Field(i))
+		if oldField == newField {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field: strings.Split(field.Tag.Get("json"), ",")[0],
+			Old:   oldField,
+			New:   newField,
+		})
+	}
+	return diffs
+}
+
+// jsonValue returns the JSON of the value, it is empty if the value is zero or an empty slice or map,
+// since they are the same as omitting the field.
+func jsonValue(v reflect.Value) string {
+	if v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0) {
+		return ""
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
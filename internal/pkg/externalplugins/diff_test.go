@@ -0,0 +1,203 @@
+package externalplugins
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestDiff(t *testing.T) {
+	testcases := []struct {
+		name      string
+		oldConfig *Configuration
+		newConfig *Configuration
+
+		expectedDiffs []ConfigDiff
+	}{
+		{
+			name: "no changes",
+			oldConfig: &Configuration{
+				TiCommunityLgtm: []TiCommunityLgtm{
+					{
+						Repos:              []string{"ti-community-infra/test-dev"},
+						PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					},
+				},
+			},
+			newConfig: &Configuration{
+				TiCommunityLgtm: []TiCommunityLgtm{
+					{
+						Repos:              []string{"ti-community-infra/test-dev"},
+						PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					},
+				},
+			},
+			expectedDiffs: nil,
+		},
+		{
+			name: "org level change is inherited by repos",
+			oldConfig: &Configuration{
+				TiCommunityLgtm: []TiCommunityLgtm{
+					{
+						Repos:              []string{"ti-community-infra"},
+						PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					},
+					{
+						Repos: []string{"ti-community-infra/test-dev"},
+					},
+				},
+			},
+			newConfig: &Configuration{
+				TiCommunityLgtm: []TiCommunityLgtm{
+					{
+						Repos:              []string{"ti-community-infra"},
+						PullOwnersEndpoint: "https://prow.tidb.io/ti-community-owners",
+					},
+					{
+						Repos: []string{"ti-community-infra/test-dev"},
+					},
+				},
+			},
+			expectedDiffs: []ConfigDiff{
+				{
+					OrgRepo: "ti-community-infra",
+					Plugin:  "ti-community-lgtm",
+					Change:  ConfigModified,
+					Fields: []FieldDiff{
+						{
+							Field: "pull_owners_endpoint",
+							Old:   `"https://bots.tidb.io/ti-community-bot"`,
+							New:   `"https://prow.tidb.io/ti-community-owners"`,
+						},
+					},
+				},
+				{
+					OrgRepo: "ti-community-infra/test-dev",
+					Plugin:  "ti-community-lgtm",
+					Change:  ConfigModified,
+					Fields: []FieldDiff{
+						{
+							Field: "pull_owners_endpoint",
+							Old:   `"https://bots.tidb.io/ti-community-bot"`,
+							New:   `"https://prow.tidb.io/ti-community-owners"`,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "defaults are compared",
+			oldConfig: &Configuration{
+				TiCommunityCherrypicker: []TiCommunityCherrypicker{
+					{
+						Repos: []string{"ti-community-infra/test-dev"},
+					},
+				},
+			},
+			newConfig: &Configuration{
+				TiCommunityCherrypicker: []TiCommunityCherrypicker{
+					{
+						Repos:       []string{"ti-community-infra/test-dev"},
+						LabelPrefix: DefaultCherryPickLabelPrefix,
+						AllowAll:    true,
+					},
+				},
+			},
+			expectedDiffs: []ConfigDiff{
+				{
+					OrgRepo: "ti-community-infra/test-dev",
+					Plugin:  "ti-community-cherrypicker",
+					Change:  ConfigModified,
+					Fields: []FieldDiff{
+						{
+							Field: "allow_all",
+							Old:   "",
+							New:   "true",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "branch overrides",
+			oldConfig: &Configuration{
+				TiCommunityMerge: []TiCommunityMerge{
+					{
+						Repos: []string{"ti-community-infra/test-dev"},
+						Branches: map[string]TiCommunityMerge{
+							"master": {
+								StoreTreeHash: true,
+							},
+						},
+					},
+				},
+			},
+			newConfig: &Configuration{
+				TiCommunityMerge: []TiCommunityMerge{
+					{
+						Repos: []string{"ti-community-infra/test-dev"},
+						Branches: map[string]TiCommunityMerge{
+							"release-*": {
+								StoreTreeHash: true,
+							},
+						},
+					},
+				},
+			},
+			expectedDiffs: []ConfigDiff{
+				{
+					OrgRepo: "ti-community-infra/test-dev",
+					Branch:  "master",
+					Plugin:  "ti-community-merge",
+					Change:  ConfigModified,
+					Fields: []FieldDiff{
+						{
+							Field: "store_tree_hash",
+							Old:   "true",
+							New:   "",
+						},
+					},
+				},
+				{
+					OrgRepo: "ti-community-infra/test-dev",
+					Branch:  "release-*",
+					Plugin:  "ti-community-merge",
+					Change:  ConfigModified,
+					Fields: []FieldDiff{
+						{
+							Field: "store_tree_hash",
+							Old:   "",
+							New:   "true",
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "plugin added",
+			oldConfig: &Configuration{},
+			newConfig: &Configuration{
+				TiCommunityContribution: []TiCommunityContribution{
+					{
+						Repos: []string{"ti-community-infra/test-dev"},
+					},
+				},
+			},
+			expectedDiffs: []ConfigDiff{
+				{
+					OrgRepo: "ti-community-infra/test-dev",
+					Plugin:  "ti-community-contribution",
+					Change:  ConfigAdded,
+				},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			diffs := Diff(tc.oldConfig, tc.newConfig)
+			assert.DeepEqual(t, diffs, tc.expectedDiffs)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package externalplugins
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+// The paths of the admin endpoint of the event store.
+const (
+	// PendingEventsPath lists the events waiting to be handled or retried.
+	PendingEventsPath = "/events/pending"
+	// DeadEventsPath lists the events which failed to be handled and will not be retried.
+	DeadEventsPath = "/events/dead"
+	// ReplayEventsPath replays the dead event specified by the guid and plugin query parameters,
+	// or all the dead events if they are not specified.
+	ReplayEventsPath = "/events/replay"
+)
+
+// bearerPrefix is the prefix of the Authorization header carrying a bearer token.
+const bearerPrefix = "Bearer "
+
+// adminMux returns the handler of the admin endpoint, which inspects and replays the persisted events.
+// The requests must carry the admin token as the bearer token.
+func (h *PluginHost) adminMux() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PendingEventsPath, h.serveEvents(pendingBucket))
+	mux.HandleFunc(DeadEventsPath, h.serveEvents(deadBucket))
+	mux.HandleFunc(ReplayEventsPath, h.serveReplay)
+	return h.authorizeAdmin(mux)
+}
+
+// authorizeAdmin rejects the requests without the admin token, all requests are rejected if the admin
+// token is empty.
+func (h *PluginHost) authorizeAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), bearerPrefix)
+		if len(h.adminToken) == 0 || subtle.ConstantTimeCompare([]byte(token), h.adminToken) != 1 {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveEvents serves the events in the bucket as JSON.
+func (h *PluginHost) serveEvents(bucket []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		events, err := h.store.list(bucket)
+		if err != nil {
+			h.log.WithError(err).Errorf("Error listing the %s events.", bucket)
+			http.Error(w, "500 Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, events)
+	}
+}
+
+// serveReplay moves the dead events back to the pending events and puts them into the queue.
+func (h *PluginHost) serveReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	guid, plugin := r.URL.Query().Get("guid"), r.URL.Query().Get("plugin")
+	var targets []StoredEvent
+	if len(guid) == 0 && len(plugin) == 0 {
+		events, err := h.store.list(deadBucket)
+		if err != nil {
+			h.log.WithError(err).Error("Error listing the dead events.")
+			http.Error(w, "500 Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		targets = events
+	} else {
+		if len(guid) == 0 || len(plugin) == 0 {
+			http.Error(w, "400 Bad Request: both guid and plugin must be specified", http.StatusBadRequest)
+			return
+		}
+		targets = []StoredEvent{{GUID: guid, Plugin: plugin}}
+	}
+
+	replayed := []StoredEvent{}
+	for _, target := range targets {
+		e, err := h.replay(target.GUID, target.Plugin)
+		if errors.Is(err, errEventNotFound) {
+			http.Error(w, fmt.Sprintf("404 Not Found: dead event %s of %s", target.GUID, target.Plugin),
+				http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.log.WithError(err).Error("Error replaying the dead event.")
+			http.Error(w, "500 Internal server error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		replayed = append(replayed, *e)
+	}
+	writeJSON(w, replayed)
+}
+
+// replay moves the dead event back to the pending events and puts it into the queue.
+func (h *PluginHost) replay(guid, plugin string) (*StoredEvent, error) {
+	e, err := h.store.revive(guid, plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	log := h.log.WithFields(logrus.Fields{
+		"plugin":         e.Plugin,
+		"event-type":     e.EventType,
+		github.EventGUID: e.GUID,
+	})
+	handler, ok := h.handlerFor(e.EventType, e.Plugin)
+	if !ok {
+		log.Warn("No handler for the replayed event, it is kept in the event store.")
+		return e, nil
+	}
+	log.Info("Replaying the dead event.")
+	h.schedule(handler, e, 0)
+	return e, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
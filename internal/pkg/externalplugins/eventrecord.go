@@ -0,0 +1,179 @@
+package externalplugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces the secret headers and payload fields of the recorded events.
+const redactedValue = "REDACTED"
+
+// redactedHeaders specifies the headers which carry secrets, they are redacted when recorded.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"X-Hub-Signature":     true,
+	"X-Hub-Signature-256": true,
+}
+
+// secretFieldKeywords specifies the keywords of the payload fields which carry secrets, e.g. the secret
+// of the hook in the ping events. The fields whose name contains any of them are redacted when recorded.
+var secretFieldKeywords = []string{"secret", "token", "password"}
+
+// RecordedEvent is a webhook recorded by the plugin host, which can be replayed offline.
+type RecordedEvent struct {
+	// EventType specifies the type of the event.
+	EventType string `json:"event_type"`
+	// GUID specifies the GUID of the webhook delivery.
+	GUID string `json:"guid"`
+	// Plugin specifies the plugin whose endpoint received the webhook, it is empty for the root endpoint.
+	Plugin string `json:"plugin,omitempty"`
+	// ReceivedAt specifies the time when the webhook was received.
+	ReceivedAt time.Time `json:"received_at"`
+	// Headers specifies the headers of the webhook with the secrets redacted.
+	Headers map[string]string `json:"headers"`
+	// Payload specifies the payload of the webhook with the secrets redacted.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// recordEvent writes the webhook to a file in the directory with the secrets redacted.
+func recordEvent(dir string, r *http.Request, eventType, eventGUID string, payload []byte, plugin string,
+	now time.Time) error {
+	redacted, err := redactPayload(payload)
+	if err != nil {
+		return fmt.Errorf("redact %s event: %w", eventType, err)
+	}
+
+	e := RecordedEvent{
+		EventType:  eventType,
+		GUID:       eventGUID,
+		Plugin:     plugin,
+		ReceivedAt: now,
+		Headers:    redactHeaders(r.Header),
+		Payload:    redacted,
+	}
+	content, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The files are named by the time first, so that they are sorted in the order of receiving.
+	name := fmt.Sprintf("%s-%s-%s", now.UTC().Format("20060102T150405.000000000Z"), eventType, eventGUID)
+	if len(plugin) != 0 {
+		name += "-" + plugin
+	}
+	return ioutil.WriteFile(filepath.Join(dir, sanitizeFileName(name)+".json"), content, 0600)
+}
+
+// ReadRecordedEvents reads the recorded events from the file, or from all the JSON files in the directory
+// in the order of receiving.
+func ReadRecordedEvents(path string) ([]RecordedEvent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	events := make([]RecordedEvent, 0, len(files))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var e RecordedEvent
+		if err := json.Unmarshal(content, &e); err != nil {
+			return nil, fmt.Errorf("parse recorded event %s: %w", file, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// redactHeaders returns the first value of each header, with the secret headers redacted.
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key := range header {
+		if redactedHeaders[http.CanonicalHeaderKey(key)] {
+			headers[key] = redactedValue
+		} else {
+			headers[key] = header.Get(key)
+		}
+	}
+	return headers
+}
+
+// redactPayload returns the payload with the secret fields redacted.
+func redactPayload(payload []byte) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	// Keep the numbers as they are, e.g. the large IDs are not rounded.
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redactValue(value)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSecretField(key) && field != nil {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+func isSecretField(key string) bool {
+	key = strings.ToLower(key)
+	for _, keyword := range secretFieldKeywords {
+		if strings.Contains(key, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFileName replaces the characters which are not safe in file names.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
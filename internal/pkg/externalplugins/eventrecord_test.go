@@ -0,0 +1,115 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestRecordEvent(t *testing.T) {
+	lgtmComment, err := ioutil.ReadFile("../../../test/testdata/lgtm_comment.json")
+	if err != nil {
+		t.Fatalf("read lgtm comment file failed: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "events")
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second, RecordDir: dir})
+	host.RegisterIssueCommentEventHandler("a", func(*github.IssueCommentEvent, *Configuration, *logrus.Entry) error {
+		return nil
+	})
+	mux := http.NewServeMux()
+	host.ServePlugin(mux, "a", nil)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodPost, "/a", strings.NewReader(string(lgtmComment)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("X-GitHub-Event", "issue_comment")
+	r.Header.Set("X-GitHub-Delivery", "I am unique")
+	r.Header.Set("X-Hub-Signature", "sha1=f3fee26b22d3748f393f7e37f71baa467495971a")
+	r.Header.Set("content-type", "application/json")
+
+	mux.ServeHTTP(w, r)
+	host.Drain()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected code %v, got code %v", http.StatusOK, w.Code)
+	}
+
+	events, err := ReadRecordedEvents(dir)
+	if err != nil {
+		t.Fatalf("read recorded events failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Different events: Got \"%d\" expected \"%d\"", len(events), 1)
+	}
+	e := events[0]
+	if e.EventType != IssueCommentEvent || e.GUID != "I am unique" || e.Plugin != "a" {
+		t.Errorf("Different event: Got \"%s %s %s\" expected \"%s %s %s\"",
+			e.EventType, e.GUID, e.Plugin, IssueCommentEvent, "I am unique", "a")
+	}
+	if signature := e.Headers["X-Hub-Signature"]; signature != redactedValue {
+		t.Errorf("Different signature: Got \"%v\" expected \"%v\"", signature, redactedValue)
+	}
+
+	var expected, recorded github.IssueCommentEvent
+	_ = json.Unmarshal(lgtmComment, &expected)
+	if err := json.Unmarshal(e.Payload, &recorded); err != nil {
+		t.Fatalf("unmarshal recorded payload failed: %v", err)
+	}
+	if recorded.Comment.Body != expected.Comment.Body || recorded.Issue.Number != expected.Issue.Number ||
+		recorded.Comment.ID != expected.Comment.ID {
+		t.Errorf("Different payload: Got \"%+v\" expected \"%+v\"", recorded.Comment, expected.Comment)
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	testcases := []struct {
+		name    string
+		payload string
+
+		expectedPayload string
+	}{
+		{
+			name:            "No secrets",
+			payload:         `{"action": "opened", "number": 1234567890123456789}`,
+			expectedPayload: `{"action":"opened","number":1234567890123456789}`,
+		},
+		{
+			name:            "Secret of the hook",
+			payload:         `{"hook": {"config": {"secret": "abc", "url": "https://example.com"}}}`,
+			expectedPayload: `{"hook":{"config":{"secret":"REDACTED","url":"https://example.com"}}}`,
+		},
+		{
+			name:            "Tokens in arrays",
+			payload:         `{"items": [{"access_token": "abc"}, {"Password": "123", "body": "<b>"}]}`,
+			expectedPayload: `{"items":[{"access_token":"REDACTED"},{"Password":"REDACTED","body":"<b>"}]}`,
+		},
+		{
+			name:            "Empty secret",
+			payload:         `{"secret": null}`,
+			expectedPayload: `{"secret":null}`,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := redactPayload([]byte(tc.payload))
+			if err != nil {
+				t.Fatalf("redact payload failed: %v", err)
+			}
+			if string(payload) != tc.expectedPayload {
+				t.Errorf("Different payload: Got \"%s\" expected \"%s\"", payload, tc.expectedPayload)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The buckets of the event store.
+var (
+	// pendingBucket contains the events waiting to be handled or retried.
+	pendingBucket = []byte("pending")
+	// deadBucket contains the events which failed to be handled and will not be retried.
+	deadBucket = []byte("dead")
+	// doneBucket contains the keys of the events handled, which are used to skip the redelivered events.
+	doneBucket = []byte("done")
+)
+
+// errEventNotFound means the event is not in the event store.
+var errEventNotFound = errors.New("event not found")
+
+// StoredEvent is an event of a plugin persisted in the event store.
+type StoredEvent struct {
+	// GUID specifies the GUID of the webhook delivery.
+	GUID string `json:"guid"`
+	// EventType specifies the type of the event.
+	EventType string `json:"event_type"`
+	// Plugin specifies the plugin which handles the event.
+	Plugin string `json:"plugin"`
+	// Payload specifies the payload of the webhook.
+	Payload json.RawMessage `json:"payload"`
+	// ReceivedAt specifies the time when the event was received.
+	ReceivedAt time.Time `json:"received_at"`
+	// Attempts specifies the number of the failed attempts of handling the event.
+	Attempts int `json:"attempts"`
+	// LastError specifies the error of the last failed attempt.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// key returns the key of the event in the event store, the event of each plugin is stored separately.
+func (e *StoredEvent) key() []byte {
+	return eventKey(e.GUID, e.Plugin)
+}
+
+func eventKey(guid, plugin string) []byte {
+	return []byte(plugin + "/" + guid)
+}
+
+// eventStore persists the events on disk, so that they can be retried after restarts.
+type eventStore struct {
+	db *bolt.DB
+}
+
+// openEventStore opens the event store at the path, it is created if it does not exist.
+func openEventStore(path string) (*eventStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open event store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{pendingBucket, deadBucket, doneBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create buckets of event store %s: %w", path, err)
+	}
+	return &eventStore{db: db}, nil
+}
+
+// add adds the event to the pending events, it returns false if the event has been received before.
+func (s *eventStore) add(e *StoredEvent) (bool, error) {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		key := e.key()
+		for _, bucket := range [][]byte{pendingBucket, deadBucket, doneBucket} {
+			if tx.Bucket(bucket).Get(key) != nil {
+				return nil
+			}
+		}
+		added = true
+		return putEvent(tx, pendingBucket, e)
+	})
+	return added, err
+}
+
+// update updates the pending event.
+func (s *eventStore) update(e *StoredEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putEvent(tx, pendingBucket, e)
+	})
+}
+
+// complete removes the event from the pending events, and marks it as handled.
+func (s *eventStore) complete(e *StoredEvent, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := e.key()
+		if err := tx.Bucket(pendingBucket).Delete(key); err != nil {
+			return err
+		}
+		return tx.Bucket(doneBucket).Put(key, []byte(now.UTC().Format(time.RFC3339)))
+	})
+}
+
+// kill moves the event from the pending events to the dead events.
+func (s *eventStore) kill(e *StoredEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pendingBucket).Delete(e.key()); err != nil {
+			return err
+		}
+		return putEvent(tx, deadBucket, e)
+	})
+}
+
+// revive moves the dead event back to the pending events with its attempts reset.
+func (s *eventStore) revive(guid, plugin string) (*StoredEvent, error) {
+	var e StoredEvent
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		key := eventKey(guid, plugin)
+		value := tx.Bucket(deadBucket).Get(key)
+		if value == nil {
+			return errEventNotFound
+		}
+		if err := json.Unmarshal(value, &e); err != nil {
+			return err
+		}
+		if err := tx.Bucket(deadBucket).Delete(key); err != nil {
+			return err
+		}
+		e.Attempts = 0
+		return putEvent(tx, pendingBucket, &e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// list returns the events in the bucket.
+func (s *eventStore) list(bucket []byte) ([]StoredEvent, error) {
+	events := []StoredEvent{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, value []byte) error {
+			var e StoredEvent
+			if err := json.Unmarshal(value, &e); err != nil {
+				return err
+			}
+			events = append(events, e)
+			return nil
+		})
+	})
+	return events, err
+}
+
+// pruneDone forgets the handled events before the time, the redelivered ones will be handled again.
+func (s *eventStore) pruneDone(before time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		done := tx.Bucket(doneBucket)
+		var expired [][]byte
+		err := done.ForEach(func(key, value []byte) error {
+			handledAt, err := time.Parse(time.RFC3339, string(value))
+			if err != nil || handledAt.Before(before) {
+				expired = append(expired, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := done.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *eventStore) close() error {
+	return s.db.Close()
+}
+
+func putEvent(tx *bolt.Tx, bucket []byte, e *StoredEvent) error {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put(e.key(), value)
+}
@@ -0,0 +1,304 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	storeTestPayload = `{"ref": "refs/heads/master"}`
+	adminTestToken   = "admin-token"
+)
+
+// newStoreTestHost creates a plugin host with the event store in the directory.
+func newStoreTestHost(t *testing.T, dir string, maxAttempts int) *PluginHost {
+	return newTestHost(t, HostOptions{
+		Workers:        1,
+		QueueSize:      1,
+		HandlerTimeout: time.Second,
+		EventStorePath: filepath.Join(dir, "events.db"),
+		MaxAttempts:    maxAttempts,
+		RetryBackoff:   time.Millisecond,
+	})
+}
+
+// newAdminRequest returns a request to the admin endpoint carrying the token.
+func newAdminRequest(method, target, token string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	if len(token) != 0 {
+		r.Header.Set("Authorization", bearerPrefix+token)
+	}
+	return r
+}
+
+// waitFor waits for the value from the channel.
+func waitFor(t *testing.T, ch <-chan int) int {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the handler")
+		return 0
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	testcases := []struct {
+		name     string
+		attempts int
+
+		expectedBackoff time.Duration
+	}{
+		{
+			name:            "first retry",
+			attempts:        1,
+			expectedBackoff: 30 * time.Second,
+		},
+		{
+			name:            "third retry",
+			attempts:        3,
+			expectedBackoff: 2 * time.Minute,
+		},
+		{
+			name:            "max backoff",
+			attempts:        10,
+			expectedBackoff: maxHandlerRetryBackoff,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			backoff := retryBackoff(30*time.Second, tc.attempts)
+			if backoff != tc.expectedBackoff {
+				t.Errorf("Different backoff: Got \"%v\" expected \"%v\"", backoff, tc.expectedBackoff)
+			}
+		})
+	}
+}
+
+func TestPersistedEventRetriedAndDeduplicated(t *testing.T) {
+	host := newStoreTestHost(t, t.TempDir(), 3)
+
+	var mut sync.Mutex
+	attempts := 0
+	succeeded := make(chan int, 1)
+	host.RegisterPushEventHandler("test", func(*github.PushEvent, *Configuration, *logrus.Entry) error {
+		mut.Lock()
+		defer mut.Unlock()
+		attempts++
+		if attempts < 3 {
+			return errors.New("transient error")
+		}
+		succeeded <- attempts
+		return nil
+	})
+
+	if err := host.Dispatch(PushEvent, "guid", []byte(storeTestPayload)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	if n := waitFor(t, succeeded); n != 3 {
+		t.Errorf("Different attempts: Got \"%v\" expected \"%v\"", n, 3)
+	}
+
+	// The event redelivered by GitHub is skipped.
+	if err := host.Dispatch(PushEvent, "guid", []byte(storeTestPayload)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	host.Drain()
+
+	if attempts != 3 {
+		t.Errorf("Different attempts: Got \"%v\" expected \"%v\"", attempts, 3)
+	}
+}
+
+func TestDeadEventReplayed(t *testing.T) {
+	host := newStoreTestHost(t, t.TempDir(), 2)
+	defer host.Drain()
+
+	var mut sync.Mutex
+	failing := true
+	attempted := make(chan int, 10)
+	host.RegisterPushEventHandler("test", func(*github.PushEvent, *Configuration, *logrus.Entry) error {
+		mut.Lock()
+		defer mut.Unlock()
+		if failing {
+			attempted <- 0
+			return errors.New("owners endpoint is down")
+		}
+		attempted <- 1
+		return nil
+	})
+
+	if err := host.Dispatch(PushEvent, "guid", []byte(storeTestPayload)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	waitFor(t, attempted)
+	waitFor(t, attempted)
+
+	host.adminToken = []byte(adminTestToken)
+	admin := host.adminMux()
+	var dead []StoredEvent
+	// The event is moved to the dead events after the handler returns.
+	for i := 0; i < 100 && len(dead) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, newAdminRequest(http.MethodGet, DeadEventsPath, adminTestToken))
+		if err := json.Unmarshal(w.Body.Bytes(), &dead); err != nil {
+			t.Fatalf("unmarshal dead events failed: %v", err)
+		}
+	}
+	if len(dead) != 1 || dead[0].GUID != "guid" || dead[0].Attempts != 2 ||
+		dead[0].LastError != "owners endpoint is down" {
+		t.Fatalf("Different dead events: Got \"%+v\"", dead)
+	}
+
+	mut.Lock()
+	failing = false
+	mut.Unlock()
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, newAdminRequest(http.MethodPost, ReplayEventsPath+"?guid=guid&plugin=test", adminTestToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected code %v, got code %v", http.StatusOK, w.Code)
+	}
+	if result := waitFor(t, attempted); result != 1 {
+		t.Errorf("Expected the replayed event handled successfully")
+	}
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, newAdminRequest(http.MethodPost, ReplayEventsPath+"?guid=guid&plugin=test", adminTestToken))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected code %v, got code %v", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestAdminUnauthorized(t *testing.T) {
+	testcases := []struct {
+		name       string
+		adminToken string
+		token      string
+
+		expectedCode int
+	}{
+		{
+			name:         "Valid token",
+			adminToken:   adminTestToken,
+			token:        adminTestToken,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "No token",
+			adminToken:   adminTestToken,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Wrong token",
+			adminToken:   adminTestToken,
+			token:        "wrong",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "No admin token configured",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			host := newStoreTestHost(t, t.TempDir(), 1)
+			defer host.Drain()
+			host.adminToken = []byte(tc.adminToken)
+
+			w := httptest.NewRecorder()
+			host.adminMux().ServeHTTP(w, newAdminRequest(http.MethodGet, PendingEventsPath, tc.token))
+			if w.Code != tc.expectedCode {
+				t.Errorf("Expected code %v, got code %v", tc.expectedCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminTokenLoaded(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "admin-token")
+	if err := ioutil.WriteFile(tokenPath, []byte(adminTestToken+"\n"), 0600); err != nil {
+		t.Fatalf("write admin token failed: %v", err)
+	}
+	host := newTestHost(t, HostOptions{
+		Workers:        1,
+		QueueSize:      1,
+		HandlerTimeout: time.Second,
+		EventStorePath: filepath.Join(dir, "events.db"),
+		AdminTokenPath: tokenPath,
+	})
+	defer host.Drain()
+
+	w := httptest.NewRecorder()
+	host.adminMux().ServeHTTP(w, newAdminRequest(http.MethodGet, PendingEventsPath, adminTestToken))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected code %v, got code %v", http.StatusOK, w.Code)
+	}
+}
+
+func TestPermanentErrorNotRetried(t *testing.T) {
+	host := newStoreTestHost(t, t.TempDir(), 5)
+
+	attempted := make(chan int, 10)
+	host.RegisterPushEventHandler("test", func(*github.PushEvent, *Configuration, *logrus.Entry) error {
+		attempted <- 1
+		return Permanent(errors.New("invalid command"))
+	})
+
+	if err := host.Dispatch(PushEvent, "guid", []byte(storeTestPayload)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	waitFor(t, attempted)
+	host.Drain()
+
+	if len(attempted) != 0 {
+		t.Errorf("Expected the event not retried, got %d more attempts", len(attempted))
+	}
+}
+
+func TestPendingEventsResumed(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openEventStore(filepath.Join(dir, "events.db"))
+	if err != nil {
+		t.Fatalf("open event store failed: %v", err)
+	}
+	pending := &StoredEvent{GUID: "guid", EventType: PushEvent, Plugin: "test", Payload: []byte(storeTestPayload)}
+	if _, err := store.add(pending); err != nil {
+		t.Fatalf("add event failed: %v", err)
+	}
+	if err := store.close(); err != nil {
+		t.Fatalf("close event store failed: %v", err)
+	}
+
+	host := newStoreTestHost(t, dir, 1)
+	defer host.Drain()
+
+	handled := make(chan int, 1)
+	host.RegisterPushEventHandler("test", func(pe *github.PushEvent, _ *Configuration, _ *logrus.Entry) error {
+		if pe.Ref != "refs/heads/master" {
+			t.Errorf("Different ref: Got \"%v\" expected \"%v\"", pe.Ref, "refs/heads/master")
+		}
+		handled <- 1
+		return nil
+	})
+	if err := host.resume(); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	waitFor(t, handled)
+}
@@ -0,0 +1,845 @@
+package externalplugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
+)
+
+const (
+	// defaultHandlerWorkers specifies the default number of the events handled at the same time.
+	defaultHandlerWorkers = 16
+	// defaultHandlerQueueSize specifies the default number of the events waiting for a worker.
+	defaultHandlerQueueSize = 256
+	// defaultHandlerTimeout specifies the default timeout of handling an event.
+	defaultHandlerTimeout = 10 * time.Minute
+	// defaultDrainTimeout specifies the default time to wait for the handlers when the host is drained.
+	defaultDrainTimeout = time.Minute
+	// defaultHandlerMaxAttempts specifies the default number of attempts of handling a persisted event.
+	defaultHandlerMaxAttempts = 5
+	// defaultHandlerRetryBackoff specifies the default delay before retrying a persisted event.
+	defaultHandlerRetryBackoff = 30 * time.Second
+	// maxHandlerRetryBackoff specifies the max delay before retrying a persisted event.
+	maxHandlerRetryBackoff = 30 * time.Minute
+	// doneEventRetention specifies how long the handled events are remembered to skip the redelivered ones.
+	doneEventRetention = 24 * time.Hour
+	// pruneDoneEventsInterval specifies the interval of forgetting the expired handled events.
+	pruneDoneEventsInterval = time.Hour
+	// serverShutdownGracePeriod specifies the grace period of shutting down the HTTP server.
+	serverShutdownGracePeriod = 5 * time.Second
+)
+
+var (
+	// ErrHandlerQueueFull means the event is rejected because too many events are waiting for a worker.
+	ErrHandlerQueueFull = errors.New("too many events are waiting to be handled")
+	// ErrHostDraining means the event is rejected because the host is shutting down.
+	ErrHostDraining = errors.New("the plugin host is shutting down")
+)
+
+// HostOptions specifies the options of the plugin host, it implements the flagutil.OptionGroup.
+type HostOptions struct {
+	// Workers specifies the number of the events handled at the same time.
+	Workers int
+	// QueueSize specifies the number of the events waiting for a worker, the events beyond it are rejected.
+	QueueSize int
+	// HandlerTimeout specifies the time after which a running handler is reported as timed out. The handlers
+	// can not be cancelled, so a timed out handler keeps occupying its worker until it returns.
+	HandlerTimeout time.Duration
+	// DrainTimeout specifies the time to wait for the queued and running handlers when the host is drained.
+	DrainTimeout time.Duration
+
+	// EventStorePath specifies the path of the on-disk event store. The events are persisted, deduplicated
+	// by the GUID and retried on errors only if it is set.
+	EventStorePath string
+	// MaxAttempts specifies the number of attempts of handling a persisted event before it is dead.
+	MaxAttempts int
+	// RetryBackoff specifies the delay before the first retry, it is doubled for each of the later retries.
+	RetryBackoff time.Duration
+	// AdminPort specifies the port of the admin endpoint, which inspects and replays the persisted events.
+	// The admin endpoint is served only if it is set.
+	AdminPort int
+	// AdminTokenPath specifies the path of the file containing the token, which the requests to the admin
+	// endpoint must carry as the bearer token.
+	AdminTokenPath string
+
+	// RecordDir specifies the directory which the incoming webhooks are recorded to with the secrets
+	// redacted, so that they can be replayed offline. The webhooks are recorded only if it is set.
+	RecordDir string
+}
+
+// AddFlags adds the flags of the plugin host to the flag set.
+func (o *HostOptions) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.Workers, "handler-workers", defaultHandlerWorkers,
+		"Number of the webhook events handled at the same time.")
+	fs.IntVar(&o.QueueSize, "handler-queue-size", defaultHandlerQueueSize,
+		"Number of the webhook events waiting to be handled, the events beyond it are rejected.")
+	fs.DurationVar(&o.HandlerTimeout, "handler-timeout", defaultHandlerTimeout,
+		"Time after which a running webhook event handler is reported as timed out.")
+	fs.DurationVar(&o.DrainTimeout, "drain-timeout", defaultDrainTimeout,
+		"Time to wait for the queued and running webhook event handlers when shutting down.")
+	fs.StringVar(&o.EventStorePath, "event-store-path", "",
+		"Path of the on-disk store of the webhook events, the events are persisted and retried only if it is set.")
+	fs.IntVar(&o.MaxAttempts, "handler-max-attempts", defaultHandlerMaxAttempts,
+		"Number of attempts of handling a persisted webhook event before it is moved to the dead events.")
+	fs.DurationVar(&o.RetryBackoff, "handler-retry-backoff", defaultHandlerRetryBackoff,
+		"Delay before retrying a persisted webhook event, it is doubled for each of the later retries.")
+	fs.IntVar(&o.AdminPort, "event-admin-port", 0,
+		"Port of the admin endpoint which inspects and replays the persisted webhook events, it is served only if set.")
+	fs.StringVar(&o.AdminTokenPath, "event-admin-token-path", "",
+		"Path to the file containing the bearer token required by the admin endpoint of the webhook events.")
+	fs.StringVar(&o.RecordDir, "event-record-dir", "",
+		"Directory to record the incoming webhook events to with the secrets redacted, for replaying them offline.")
+}
+
+// Validate validates the options of the plugin host.
+func (o *HostOptions) Validate(bool) error {
+	if o.Workers <= 0 {
+		return fmt.Errorf("handler workers must be greater than 0, got %d", o.Workers)
+	}
+	if o.QueueSize < 0 {
+		return fmt.Errorf("handler queue size must not less than 0, got %d", o.QueueSize)
+	}
+	if o.HandlerTimeout <= 0 {
+		return fmt.Errorf("handler timeout must be greater than 0, got %v", o.HandlerTimeout)
+	}
+	if o.MaxAttempts <= 0 {
+		return fmt.Errorf("handler max attempts must be greater than 0, got %d", o.MaxAttempts)
+	}
+	if o.RetryBackoff <= 0 {
+		return fmt.Errorf("handler retry backoff must be greater than 0, got %v", o.RetryBackoff)
+	}
+	if o.DrainTimeout <= 0 {
+		return fmt.Errorf("drain timeout must be greater than 0, got %v", o.DrainTimeout)
+	}
+	if o.AdminPort < 0 {
+		return fmt.Errorf("event admin port must not less than 0, got %d", o.AdminPort)
+	}
+	if o.AdminPort != 0 && len(o.AdminTokenPath) == 0 {
+		return errors.New("event admin token path must be set if the event admin port is set")
+	}
+	return nil
+}
+
+// IssueEventHandler handles the issues events.
+type IssueEventHandler func(event *github.IssueEvent, config *Configuration, log *logrus.Entry) error
+
+// IssueCommentEventHandler handles the issue comment events.
+type IssueCommentEventHandler func(event *github.IssueCommentEvent, config *Configuration, log *logrus.Entry) error
+
+// PullRequestEventHandler handles the pull request events.
+type PullRequestEventHandler func(event *github.PullRequestEvent, config *Configuration, log *logrus.Entry) error
+
+// ReviewEventHandler handles the pull request review events.
+type ReviewEventHandler func(event *github.ReviewEvent, config *Configuration, log *logrus.Entry) error
+
+// ReviewCommentEventHandler handles the pull request review comment events.
+type ReviewCommentEventHandler func(event *github.ReviewCommentEvent, config *Configuration, log *logrus.Entry) error
+
+// PushEventHandler handles the push events.
+type PushEventHandler func(event *github.PushEvent, config *Configuration, log *logrus.Entry) error
+
+// StatusEventHandler handles the status events.
+type StatusEventHandler func(event *github.StatusEvent, config *Configuration, log *logrus.Entry) error
+
+// EventObserver observes the webhook events before they are dispatched to the handlers, e.g. to invalidate
+// the caches affected by the events. It is called synchronously, so it should not block.
+type EventObserver func(eventType string, payload []byte)
+
+// eventHandler is a handler registered for an event type.
+type eventHandler struct {
+	plugin string
+	// newEvent returns a pointer to the event struct which the payload is decoded into.
+	newEvent func() interface{}
+	handle   func(event interface{}, config *Configuration, log *logrus.Entry) error
+}
+
+// job is an event waiting to be handled by a handler.
+type job struct {
+	handler   eventHandler
+	eventType string
+	eventGUID string
+	action    string
+	event     interface{}
+	config    *Configuration
+	log       *logrus.Entry
+	// stored is the persisted event, it is nil if the event store is disabled.
+	stored *StoredEvent
+}
+
+// permanentError is an error which should not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the error returned by a handler as permanent, so that the event is not retried.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// PluginHost implements http.Handler. It validates incoming GitHub webhooks, decodes them and
+// dispatches them to the handlers registered for the event type.
+//
+// The handlers run in a bounded pool of workers, the events are rejected when too many events
+// are waiting. A panic in a handler is recovered and logged, and a handler running longer than
+// the handler timeout is reported, it still occupies its worker so that the number of the running
+// handlers never exceeds the pool size. When the host is drained, it stops accepting events and
+// waits for the queued and running handlers to finish until the drain timeout.
+//
+// If the event store is enabled, the events are persisted before they are queued. The events
+// redelivered by GitHub are skipped, the failed events are retried with exponential backoff
+// and moved to the dead events after the max attempts, the pending events are handled again
+// after restarts.
+type PluginHost struct {
+	options        HostOptions
+	tokenGenerator func() []byte
+	configAgent    *ConfigAgent
+	log            *logrus.Entry
+
+	handlers  map[EventType][]eventHandler
+	observers []EventObserver
+
+	// store persists the events, it is nil if the event store is disabled.
+	store *eventStore
+	// adminToken is the bearer token required by the admin endpoint.
+	adminToken []byte
+
+	// mut guards the draining flag, sending to the jobs channel and the retry timers.
+	mut      sync.RWMutex
+	draining bool
+	jobs     chan job
+	retries  map[*time.Timer]struct{}
+	// workers tracks the workers, background tracks the other goroutines.
+	workers    sync.WaitGroup
+	background sync.WaitGroup
+	stop       chan struct{}
+}
+
+// NewPluginHost creates a plugin host and starts its workers, the handlers should be registered
+// before the host serves any event.
+func NewPluginHost(options HostOptions, tokenGenerator func() []byte, configAgent *ConfigAgent,
+	log *logrus.Entry) (*PluginHost, error) {
+	h := &PluginHost{
+		options:        options,
+		tokenGenerator: tokenGenerator,
+		configAgent:    configAgent,
+		log:            log,
+		handlers:       make(map[EventType][]eventHandler),
+		jobs:           make(chan job, options.QueueSize),
+		retries:        make(map[*time.Timer]struct{}),
+		stop:           make(chan struct{}),
+	}
+
+	if len(options.RecordDir) != 0 {
+		if err := os.MkdirAll(options.RecordDir, 0700); err != nil {
+			return nil, fmt.Errorf("create event record dir %s: %w", options.RecordDir, err)
+		}
+	}
+
+	if len(options.AdminTokenPath) != 0 {
+		token, err := ioutil.ReadFile(options.AdminTokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("read event admin token %s: %w", options.AdminTokenPath, err)
+		}
+		h.adminToken = bytes.TrimSpace(token)
+	}
+
+	if len(options.EventStorePath) != 0 {
+		store, err := openEventStore(options.EventStorePath)
+		if err != nil {
+			return nil, err
+		}
+		h.store = store
+		h.background.Add(1)
+		go h.pruneDoneEvents()
+	}
+
+	h.workers.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go h.work()
+	}
+	return h, nil
+}
+
+// register registers the handler of the plugin for the event type.
+func (h *PluginHost) register(eventType EventType, handler eventHandler) {
+	h.handlers[eventType] = append(h.handlers[eventType], handler)
+}
+
+// RegisterEventObserver registers the observer for the events of all types, including the events
+// sent to the endpoints of the plugins.
+func (h *PluginHost) RegisterEventObserver(observer EventObserver) {
+	h.observers = append(h.observers, observer)
+}
+
+// RegisterIssueEventHandler registers the handler of the plugin for the issues events.
+func (h *PluginHost) RegisterIssueEventHandler(plugin string, handler IssueEventHandler) {
+	h.register(IssuesEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.IssueEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.IssueEvent), config, log)
+		},
+	})
+}
+
+// RegisterIssueCommentEventHandler registers the handler of the plugin for the issue comment events.
+func (h *PluginHost) RegisterIssueCommentEventHandler(plugin string, handler IssueCommentEventHandler) {
+	h.register(IssueCommentEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.IssueCommentEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.IssueCommentEvent), config, log)
+		},
+	})
+}
+
+// RegisterPullRequestEventHandler registers the handler of the plugin for the pull request events.
+func (h *PluginHost) RegisterPullRequestEventHandler(plugin string, handler PullRequestEventHandler) {
+	h.register(PullRequestEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.PullRequestEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.PullRequestEvent), config, log)
+		},
+	})
+}
+
+// RegisterReviewEventHandler registers the handler of the plugin for the pull request review events.
+func (h *PluginHost) RegisterReviewEventHandler(plugin string, handler ReviewEventHandler) {
+	h.register(PullRequestReviewEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.ReviewEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.ReviewEvent), config, log)
+		},
+	})
+}
+
+// RegisterReviewCommentEventHandler registers the handler of the plugin for the pull request review comment events.
+func (h *PluginHost) RegisterReviewCommentEventHandler(plugin string, handler ReviewCommentEventHandler) {
+	h.register(PullRequestReviewCommentEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.ReviewCommentEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.ReviewCommentEvent), config, log)
+		},
+	})
+}
+
+// RegisterPushEventHandler registers the handler of the plugin for the push events.
+func (h *PluginHost) RegisterPushEventHandler(plugin string, handler PushEventHandler) {
+	h.register(PushEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.PushEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.PushEvent), config, log)
+		},
+	})
+}
+
+// RegisterStatusEventHandler registers the handler of the plugin for the status events.
+func (h *PluginHost) RegisterStatusEventHandler(plugin string, handler StatusEventHandler) {
+	h.register(StatusEvent, eventHandler{
+		plugin:   plugin,
+		newEvent: func() interface{} { return &github.StatusEvent{} },
+		handle: func(event interface{}, config *Configuration, log *logrus.Entry) error {
+			return handler(event.(*github.StatusEvent), config, log)
+		},
+	})
+}
+
+// ServeHTTP validates an incoming webhook and puts it into the queue of the handlers of all plugins.
+func (h *PluginHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serveWebhook(w, r, "")
+}
+
+// serveWebhook validates an incoming webhook and puts it into the queue of the handlers of the plugin,
+// the handlers of all plugins are used if the plugin is empty.
+func (h *PluginHost) serveWebhook(w http.ResponseWriter, r *http.Request, plugin string) {
+	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, h.tokenGenerator)
+	if !ok {
+		return
+	}
+	log := h.log.WithFields(logrus.Fields{
+		"event-type":     eventType,
+		github.EventGUID: eventGUID,
+	})
+
+	if len(h.options.RecordDir) != 0 {
+		if err := recordEvent(h.options.RecordDir, r, eventType, eventGUID, payload, plugin, time.Now()); err != nil {
+			log.WithError(err).Warn("Error recording event.")
+		}
+	}
+
+	if err := h.dispatch(eventType, eventGUID, payload, plugin); err != nil {
+		log.WithError(err).Error("Error dispatching event.")
+
+		if errors.Is(err, ErrHandlerQueueFull) || errors.Is(err, ErrHostDraining) {
+			http.Error(w, "503 Service Unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		} else {
+			http.Error(w, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	fmt.Fprint(w, "Event received. Have a nice day.")
+}
+
+// Dispatch decodes the payload and puts the event into the queue of the handlers registered for
+// the event type. The external plugins config is the one when the event is received.
+func (h *PluginHost) Dispatch(eventType, eventGUID string, payload []byte) error {
+	return h.dispatch(eventType, eventGUID, payload, "")
+}
+
+// dispatch puts the event into the queue of the handlers of the plugin registered for the event type,
+// the handlers of all plugins are used if the plugin is empty.
+func (h *PluginHost) dispatch(eventType, eventGUID string, payload []byte, plugin string) error {
+	for _, observe := range h.observers {
+		observe(eventType, payload)
+	}
+
+	var handlers []eventHandler
+	for _, handler := range h.handlers[eventType] {
+		if len(plugin) == 0 || handler.plugin == plugin {
+			handlers = append(handlers, handler)
+		}
+	}
+	if len(handlers) == 0 {
+		h.log.Debugf("received an event of type %q but didn't ask for it", eventType)
+		return nil
+	}
+
+	config := h.configAgent.Config()
+	jobs := make([]job, 0, len(handlers))
+	for _, handler := range handlers {
+		// Decode the payload for each handler, so that the handlers do not share the event.
+		j, err := h.newJob(handler, eventType, eventGUID, payload, config)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, j)
+	}
+
+	if h.store != nil {
+		return h.persist(jobs, payload)
+	}
+
+	for _, j := range jobs {
+		eventsReceived.WithLabelValues(j.handler.plugin, j.eventType, j.action).Inc()
+	}
+
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+	if h.draining {
+		rejectJobs(jobs)
+		return ErrHostDraining
+	}
+	for i, j := range jobs {
+		select {
+		case h.jobs <- j:
+		default:
+			rejectJobs(jobs[i:])
+			return ErrHandlerQueueFull
+		}
+	}
+	return nil
+}
+
+// newJob decodes the payload into the event of the handler.
+func (h *PluginHost) newJob(handler eventHandler, eventType, eventGUID string, payload []byte,
+	config *Configuration) (job, error) {
+	event := handler.newEvent()
+	if err := json.Unmarshal(payload, event); err != nil {
+		return job{}, fmt.Errorf("decode %s event: %w", eventType, err)
+	}
+	return job{
+		handler:   handler,
+		eventType: eventType,
+		eventGUID: eventGUID,
+		action:    eventAction(payload),
+		event:     event,
+		config:    config,
+		log: h.log.WithFields(logrus.Fields{
+			"plugin":         handler.plugin,
+			"event-type":     eventType,
+			github.EventGUID: eventGUID,
+		}),
+	}, nil
+}
+
+// rejectJobs records the jobs which are not put into the queue as failed.
+func rejectJobs(jobs []job) {
+	for _, j := range jobs {
+		eventsFailed.WithLabelValues(j.handler.plugin, j.eventType, j.action).Inc()
+	}
+}
+
+// persist adds the events to the event store and puts them into the queue, the events received before
+// are skipped. The events are retried later instead of being rejected when the queue is full.
+func (h *PluginHost) persist(jobs []job, payload []byte) error {
+	h.mut.RLock()
+	draining := h.draining
+	h.mut.RUnlock()
+	if draining {
+		rejectJobs(jobs)
+		return ErrHostDraining
+	}
+
+	for _, j := range jobs {
+		j.stored = &StoredEvent{
+			GUID:       j.eventGUID,
+			EventType:  j.eventType,
+			Plugin:     j.handler.plugin,
+			Payload:    payload,
+			ReceivedAt: time.Now(),
+		}
+		added, err := h.store.add(j.stored)
+		if err != nil {
+			return fmt.Errorf("persist %s event: %w", j.eventType, err)
+		}
+		if !added {
+			j.log.Info("Skipping the event received before.")
+			continue
+		}
+
+		eventsReceived.WithLabelValues(j.handler.plugin, j.eventType, j.action).Inc()
+		if !h.enqueue(j) {
+			h.schedule(j.handler, j.stored, h.options.RetryBackoff)
+		}
+	}
+	return nil
+}
+
+// enqueue puts the job into the queue, it returns false if the queue is full or the host is draining.
+func (h *PluginHost) enqueue(j job) bool {
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+	if h.draining {
+		return false
+	}
+	select {
+	case h.jobs <- j:
+		return true
+	default:
+		return false
+	}
+}
+
+// schedule puts the persisted event into the queue after the delay. If the host is draining, the event
+// is kept in the event store and handled after restarts.
+func (h *PluginHost) schedule(handler eventHandler, e *StoredEvent, delay time.Duration) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	if h.draining {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		h.mut.Lock()
+		delete(h.retries, timer)
+		h.mut.Unlock()
+
+		j, err := h.newJob(handler, e.EventType, e.GUID, e.Payload, h.configAgent.Config())
+		if err != nil {
+			h.kill(j, e, err)
+			return
+		}
+		j.stored = e
+		if !h.enqueue(j) {
+			h.schedule(handler, e, h.options.RetryBackoff)
+		}
+	})
+	h.retries[timer] = struct{}{}
+}
+
+// handlerFor returns the handler of the plugin registered for the event type.
+func (h *PluginHost) handlerFor(eventType, plugin string) (eventHandler, bool) {
+	for _, handler := range h.handlers[eventType] {
+		if handler.plugin == plugin {
+			return handler, true
+		}
+	}
+	return eventHandler{}, false
+}
+
+// resume puts the pending events in the event store into the queue, it should be called after
+// the handlers are registered.
+func (h *PluginHost) resume() error {
+	events, err := h.store.list(pendingBucket)
+	if err != nil {
+		return fmt.Errorf("list pending events: %w", err)
+	}
+
+	for i := range events {
+		e := &events[i]
+		handler, ok := h.handlerFor(e.EventType, e.Plugin)
+		if !ok {
+			h.log.WithFields(logrus.Fields{
+				"plugin":         e.Plugin,
+				"event-type":     e.EventType,
+				github.EventGUID: e.GUID,
+			}).Warn("No handler for the pending event, it is kept in the event store.")
+			continue
+		}
+		h.schedule(handler, e, 0)
+	}
+	h.log.Infof("Resumed %d pending events.", len(events))
+	return nil
+}
+
+// finish records the result of handling the persisted event. The failed event is retried with
+// exponential backoff, unless the error is permanent or the event has run out of attempts.
+func (h *PluginHost) finish(j job, err error) {
+	if j.stored == nil {
+		return
+	}
+	if err == nil {
+		if err := h.store.complete(j.stored, time.Now()); err != nil {
+			j.log.WithError(err).Error("Error marking the event as handled.")
+		}
+		return
+	}
+
+	j.stored.Attempts++
+	j.stored.LastError = err.Error()
+	var permanent *permanentError
+	if errors.As(err, &permanent) || j.stored.Attempts >= h.options.MaxAttempts {
+		h.kill(j, j.stored, err)
+		return
+	}
+
+	if err := h.store.update(j.stored); err != nil {
+		j.log.WithError(err).Error("Error updating the event.")
+	}
+	delay := retryBackoff(h.options.RetryBackoff, j.stored.Attempts)
+	eventsRetried.WithLabelValues(j.handler.plugin, j.eventType, j.action).Inc()
+	j.log.WithField("attempts", j.stored.Attempts).Infof("Retrying the event in %v.", delay)
+	h.schedule(j.handler, j.stored, delay)
+}
+
+// kill moves the persisted event to the dead events.
+func (h *PluginHost) kill(j job, e *StoredEvent, err error) {
+	e.LastError = err.Error()
+	if err := h.store.kill(e); err != nil {
+		h.log.WithError(err).Error("Error moving the event to the dead events.")
+	}
+	eventsDead.WithLabelValues(e.Plugin, e.EventType, j.action).Inc()
+	h.log.WithFields(logrus.Fields{
+		"plugin":         e.Plugin,
+		"event-type":     e.EventType,
+		github.EventGUID: e.GUID,
+		"attempts":       e.Attempts,
+	}).WithError(err).Warn("Moved the event to the dead events.")
+}
+
+// retryBackoff returns the delay before the next attempt, which is doubled for each failed attempt.
+func retryBackoff(backoff time.Duration, attempts int) time.Duration {
+	for i := 1; i < attempts && backoff < maxHandlerRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxHandlerRetryBackoff {
+		return maxHandlerRetryBackoff
+	}
+	return backoff
+}
+
+// pruneDoneEvents forgets the expired handled events periodically until the host is drained.
+func (h *PluginHost) pruneDoneEvents() {
+	defer h.background.Done()
+	ticker := time.NewTicker(pruneDoneEventsInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.store.pruneDone(time.Now().Add(-doneEventRetention)); err != nil {
+			h.log.WithError(err).Error("Error pruning the handled events.")
+		}
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// work handles the queued events until the host is drained.
+func (h *PluginHost) work() {
+	defer h.workers.Done()
+	for j := range h.jobs {
+		h.handle(j)
+	}
+}
+
+// handle runs the handler of the job and waits for it, the handler is reported if it runs longer than
+// the handler timeout.
+func (h *PluginHost) handle(j job) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		labels := []string{j.handler.plugin, j.eventType, j.action}
+		start := time.Now()
+		var err error
+		defer func() {
+			handleDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+			if r := recover(); r != nil {
+				j.log.WithField("panic", r).Errorf("Panic handling event.\n%s", debug.Stack())
+				// The handler may panic again, so the event is not retried.
+				err = Permanent(fmt.Errorf("panic: %v", r))
+			}
+			if err != nil {
+				eventsFailed.WithLabelValues(labels...).Inc()
+			} else {
+				eventsHandled.WithLabelValues(labels...).Inc()
+			}
+			h.finish(j, err)
+		}()
+
+		if err = j.handler.handle(j.event, j.config, j.log); err != nil {
+			j.log.WithError(err).Info("Error handling event.")
+		}
+		j.log.WithField("duration", time.Since(start).String()).Debug("Completed handling event.")
+	}()
+
+	timer := time.NewTimer(h.options.HandlerTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	// The handler can not be cancelled, so the worker keeps waiting for it, otherwise the timed out
+	// handlers pile up beyond the pool size.
+	eventsTimedOut.WithLabelValues(j.handler.plugin, j.eventType, j.action).Inc()
+	j.log.WithField("timeout", h.options.HandlerTimeout.String()).
+		Warn("The handler is running longer than the timeout.")
+	<-done
+}
+
+// Drain stops accepting events, and waits for the queued and running handlers to finish until the drain
+// timeout. The persisted events not handled before the timeout are handled again after restarts.
+func (h *PluginHost) Drain() {
+	h.mut.Lock()
+	if h.draining {
+		h.mut.Unlock()
+		return
+	}
+	h.draining = true
+	close(h.jobs)
+	h.mut.Unlock()
+
+	h.log.Info("Draining the event handlers...")
+	workersDone := make(chan struct{})
+	go func() {
+		h.workers.Wait()
+		close(workersDone)
+	}()
+	timer := time.NewTimer(h.options.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-workersDone:
+	case <-timer.C:
+		h.log.WithField("timeout", h.options.DrainTimeout.String()).
+			Warn("Timed out waiting for the event handlers, stop waiting for the remaining ones.")
+	}
+
+	// The events waiting to be retried are kept in the event store.
+	h.mut.Lock()
+	for timer := range h.retries {
+		timer.Stop()
+	}
+	h.retries = make(map[*time.Timer]struct{})
+	h.mut.Unlock()
+	close(h.stop)
+	h.background.Wait()
+	if h.store != nil {
+		if err := h.store.close(); err != nil {
+			h.log.WithError(err).Error("Error closing the event store.")
+		}
+	}
+	h.log.Info("All the event handlers are finished.")
+}
+
+// ServeMux returns the mux which serves the webhooks, the status of the external plugins config
+// and the help of the plugin.
+func (h *PluginHost) ServeMux(helpProvider externalplugins.ExternalPluginHelpProvider) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+	mux.Handle(ConfigStatusPath, h.configAgent.StatusHandler())
+	externalplugins.ServeExternalPluginHelp(mux, h.log, helpProvider)
+	return mux
+}
+
+// ServePlugin serves the webhooks only for the handlers of the plugin on "/<plugin>", and the help of
+// the plugin on "/<plugin>/help". So that several plugins served by the same host can be configured
+// with different endpoints in the prow plugins config, and the events are not handled twice.
+func (h *PluginHost) ServePlugin(mux *http.ServeMux, plugin string,
+	helpProvider externalplugins.ExternalPluginHelpProvider) {
+	prefix := "/" + plugin
+	mux.Handle(prefix, h.PluginWebhookHandler(plugin))
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, h.PluginHandler(plugin, helpProvider)))
+}
+
+// PluginWebhookHandler returns the handler which serves the webhooks only for the handlers of the plugin.
+func (h *PluginHost) PluginWebhookHandler(plugin string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serveWebhook(w, r, plugin)
+	})
+}
+
+// PluginHandler returns the handler which serves the webhooks only for the handlers of the plugin on "/",
+// and the help of the plugin on "/help".
+func (h *PluginHost) PluginHandler(plugin string,
+	helpProvider externalplugins.ExternalPluginHelpProvider) http.Handler {
+	pluginMux := http.NewServeMux()
+	pluginMux.Handle("/", h.PluginWebhookHandler(plugin))
+	externalplugins.ServeExternalPluginHelp(pluginMux, h.log.WithField("plugin", plugin), helpProvider)
+	return pluginMux
+}
+
+// ListenAndServe serves the plugin on the port until an interrupt is received, then the host
+// is drained. This function is not blocking, callers are expected to exit only after
+// interrupts.WaitForGracefulShutdown returns.
+func (h *PluginHost) ListenAndServe(port int, helpProvider externalplugins.ExternalPluginHelpProvider) {
+	h.ListenAndServeMux(port, h.ServeMux(helpProvider))
+}
+
+// ListenAndServeMux serves the mux on the port until an interrupt is received, then the host is drained.
+// If the event store is enabled, the pending events are resumed, and the admin endpoint is served too
+// if the admin port is set.
+func (h *PluginHost) ListenAndServeMux(port int, mux *http.ServeMux) {
+	if h.store != nil {
+		if err := h.resume(); err != nil {
+			h.log.WithError(err).Error("Error resuming the pending events.")
+		}
+		if h.options.AdminPort != 0 {
+			adminServer := &http.Server{Addr: ":" + strconv.Itoa(h.options.AdminPort), Handler: h.adminMux()}
+			interrupts.ListenAndServe(adminServer, serverShutdownGracePeriod)
+		}
+	}
+
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(port), Handler: mux}
+	interrupts.ListenAndServe(httpServer, serverShutdownGracePeriod)
+	interrupts.OnInterrupt(h.Drain)
+}
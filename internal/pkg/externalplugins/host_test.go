@@ -0,0 +1,631 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+)
+
+const hostTestSecret = `
+'*':
+  - value: abc
+    created_at: 2019-10-02T15:00:00Z
+  - value: key2
+    created_at: 2020-10-02T15:00:00Z
+foo/bar:
+  - value: 123abc
+    created_at: 2019-10-02T15:00:00Z
+  - value: key6
+    created_at: 2020-10-02T15:00:00Z
+`
+
+func newTestHost(t *testing.T, options HostOptions) *PluginHost {
+	ca := &ConfigAgent{}
+	ca.Set(&Configuration{})
+	getSecret := func() []byte {
+		return []byte(hostTestSecret)
+	}
+	if options.MaxAttempts == 0 {
+		options.MaxAttempts = defaultHandlerMaxAttempts
+	}
+	if options.RetryBackoff == 0 {
+		options.RetryBackoff = defaultHandlerRetryBackoff
+	}
+	if options.DrainTimeout == 0 {
+		options.DrainTimeout = defaultDrainTimeout
+	}
+	host, err := NewPluginHost(options, getSecret, ca, logrus.WithField("plugin", "test"))
+	if err != nil {
+		t.Fatalf("create plugin host failed: %v", err)
+	}
+	return host
+}
+
+func TestHostOptionsValidate(t *testing.T) {
+	testcases := []struct {
+		name    string
+		options HostOptions
+
+		expectedError string
+	}{
+		{
+			name: "valid options",
+			options: HostOptions{Workers: 1, QueueSize: 0, HandlerTimeout: time.Second,
+				DrainTimeout: time.Second, MaxAttempts: 1, RetryBackoff: time.Second},
+		},
+		{
+			name:          "no workers",
+			options:       HostOptions{Workers: 0, QueueSize: 1, HandlerTimeout: time.Second},
+			expectedError: "handler workers must be greater than 0, got 0",
+		},
+		{
+			name:          "negative queue size",
+			options:       HostOptions{Workers: 1, QueueSize: -1, HandlerTimeout: time.Second},
+			expectedError: "handler queue size must not less than 0, got -1",
+		},
+		{
+			name:          "no timeout",
+			options:       HostOptions{Workers: 1, QueueSize: 1},
+			expectedError: "handler timeout must be greater than 0, got 0s",
+		},
+		{
+			name:          "no attempts",
+			options:       HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second, RetryBackoff: time.Second},
+			expectedError: "handler max attempts must be greater than 0, got 0",
+		},
+		{
+			name:          "no retry backoff",
+			options:       HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second, MaxAttempts: 1},
+			expectedError: "handler retry backoff must be greater than 0, got 0s",
+		},
+		{
+			name: "no drain timeout",
+			options: HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second,
+				MaxAttempts: 1, RetryBackoff: time.Second},
+			expectedError: "drain timeout must be greater than 0, got 0s",
+		},
+		{
+			name: "admin port without token",
+			options: HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second, DrainTimeout: time.Second,
+				MaxAttempts: 1, RetryBackoff: time.Second, AdminPort: 8082},
+			expectedError: "event admin token path must be set if the event admin port is set",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate(false)
+			if tc.expectedError == "" && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			if tc.expectedError != "" && (err == nil || err.Error() != tc.expectedError) {
+				t.Errorf("expected error %q but got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPErrors(t *testing.T) {
+	// This is the SHA1 signature for payload "{}" and signature "abc"
+	// echo -n '{}' | openssl dgst -sha1 -hmac abc
+	const hmac string = "sha1=db5c76f4264d0ad96cf21baec394964b4b8ce580"
+	const body string = "{}"
+	var testcases = []struct {
+		name string
+
+		Method string
+		Header map[string]string
+		Body   string
+		Code   int
+	}{
+		{
+			name: "Delete",
+
+			Method: http.MethodDelete,
+			Header: map[string]string{
+				"X-GitHub-Event":    "ping",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   hmac,
+				"content-type":      "application/json",
+			},
+			Body: body,
+			Code: http.StatusMethodNotAllowed,
+		},
+		{
+			name: "No event",
+
+			Method: http.MethodPost,
+			Header: map[string]string{
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   hmac,
+				"content-type":      "application/json",
+			},
+			Body: body,
+			Code: http.StatusBadRequest,
+		},
+		{
+			name: "No content type",
+
+			Method: http.MethodPost,
+			Header: map[string]string{
+				"X-GitHub-Event":    "ping",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   hmac,
+			},
+			Body: body,
+			Code: http.StatusBadRequest,
+		},
+		{
+			name: "No event guid",
+
+			Method: http.MethodPost,
+			Header: map[string]string{
+				"X-GitHub-Event":  "ping",
+				"X-Hub-Signature": hmac,
+				"content-type":    "application/json",
+			},
+			Body: body,
+			Code: http.StatusBadRequest,
+		},
+		{
+			name: "No signature",
+
+			Method: http.MethodPost,
+			Header: map[string]string{
+				"X-GitHub-Event":    "ping",
+				"X-GitHub-Delivery": "I am unique",
+				"content-type":      "application/json",
+			},
+			Body: body,
+			Code: http.StatusForbidden,
+		},
+		{
+			name: "Bad signature",
+
+			Method: http.MethodPost,
+			Header: map[string]string{
+				"X-GitHub-Event":    "ping",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   "this doesn't work",
+				"content-type":      "application/json",
+			},
+			Body: body,
+			Code: http.StatusForbidden,
+		},
+		{
+			name: "Good",
+
+			Method: http.MethodPost,
+			Header: map[string]string{
+				"X-GitHub-Event":    "ping",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   hmac,
+				"content-type":      "application/json",
+			},
+			Body: body,
+			Code: http.StatusOK,
+		},
+		{
+			name: "Good, again",
+
+			Method: http.MethodGet,
+			Header: map[string]string{
+				"content-type": "application/json",
+			},
+			Body: body,
+			Code: http.StatusMethodNotAllowed,
+		},
+	}
+
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second})
+	defer host.Drain()
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest(tc.Method, "", strings.NewReader(tc.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.Header {
+				r.Header.Set(k, v)
+			}
+
+			host.ServeHTTP(w, r)
+			if w.Code != tc.Code {
+				t.Errorf("Expected code %v, got code %v", tc.Code, w.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	lgtmComment, err := ioutil.ReadFile("../../../test/testdata/lgtm_comment.json")
+	if err != nil {
+		t.Fatalf("read lgtm comment file failed: %v", err)
+	}
+
+	openedPR, err := ioutil.ReadFile("../../../test/testdata/opened_pr.json")
+	if err != nil {
+		t.Fatalf("read opened PR file failed: %v", err)
+	}
+
+	var testcases = []struct {
+		name string
+
+		Header map[string]string
+		Body   string
+		Code   int
+
+		expectedHandled string
+	}{
+		{
+			name: "Issue comment event",
+
+			Header: map[string]string{
+				"X-GitHub-Event":    "issue_comment",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   "sha1=f3fee26b22d3748f393f7e37f71baa467495971a",
+				"content-type":      "application/json",
+			},
+			Body: string(lgtmComment),
+			Code: http.StatusOK,
+
+			expectedHandled: IssueCommentEvent,
+		},
+		{
+			name: "Pull request event",
+
+			Header: map[string]string{
+				"X-GitHub-Event":    "pull_request",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   "sha1=9a62c443a5ab561e023e64610dc467523188defc",
+				"content-type":      "application/json",
+			},
+			Body: string(openedPR),
+			Code: http.StatusOK,
+
+			expectedHandled: PullRequestEvent,
+		},
+		{
+			name: "Invalid payload",
+
+			Header: map[string]string{
+				"X-GitHub-Event":    "pull_request",
+				"X-GitHub-Delivery": "I am unique",
+				"X-Hub-Signature":   "sha1=bb61dd954d81eaf09271a2bb20f7cb4ddc3f5a74",
+				"content-type":      "application/json",
+			},
+			Body: `{"number": "1"}`,
+			Code: http.StatusBadRequest,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var mut sync.Mutex
+			var handled []string
+
+			host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second})
+			host.RegisterIssueCommentEventHandler("test",
+				func(*github.IssueCommentEvent, *Configuration, *logrus.Entry) error {
+					mut.Lock()
+					defer mut.Unlock()
+					handled = append(handled, IssueCommentEvent)
+					return nil
+				})
+			host.RegisterPullRequestEventHandler("test",
+				func(*github.PullRequestEvent, *Configuration, *logrus.Entry) error {
+					mut.Lock()
+					defer mut.Unlock()
+					handled = append(handled, PullRequestEvent)
+					return nil
+				})
+
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest(http.MethodPost, "", strings.NewReader(tc.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.Header {
+				r.Header.Set(k, v)
+			}
+
+			host.ServeHTTP(w, r)
+			host.Drain()
+
+			if w.Code != tc.Code {
+				t.Errorf("Expected code %v, got code %v", tc.Code, w.Code)
+			}
+			if len(tc.expectedHandled) == 0 && len(handled) != 0 {
+				t.Errorf("Expected no event handled, got %v", handled)
+			}
+			if len(tc.expectedHandled) != 0 && (len(handled) != 1 || handled[0] != tc.expectedHandled) {
+				t.Errorf("Expected %s event handled, got %v", tc.expectedHandled, handled)
+			}
+		})
+	}
+}
+
+func TestServePlugin(t *testing.T) {
+	lgtmComment, err := ioutil.ReadFile("../../../test/testdata/lgtm_comment.json")
+	if err != nil {
+		t.Fatalf("read lgtm comment file failed: %v", err)
+	}
+
+	var testcases = []struct {
+		name string
+		path string
+
+		expectedHandledBy map[string]int
+	}{
+		{
+			name:              "Webhook for all plugins",
+			path:              "/",
+			expectedHandledBy: map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:              "Webhook for one plugin",
+			path:              "/a",
+			expectedHandledBy: map[string]int{"a": 1},
+		},
+		{
+			name:              "Webhook for one plugin with trailing slash",
+			path:              "/b/",
+			expectedHandledBy: map[string]int{"b": 1},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var mut sync.Mutex
+			handledBy := make(map[string]int)
+
+			host := newTestHost(t, HostOptions{Workers: 2, QueueSize: 2, HandlerTimeout: time.Second})
+			mux := http.NewServeMux()
+			mux.Handle("/", host)
+			for _, plugin := range []string{"a", "b"} {
+				name := plugin
+				host.RegisterIssueCommentEventHandler(name,
+					func(*github.IssueCommentEvent, *Configuration, *logrus.Entry) error {
+						mut.Lock()
+						defer mut.Unlock()
+						handledBy[name]++
+						return nil
+					})
+				host.ServePlugin(mux, name, nil)
+			}
+
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest(http.MethodPost, tc.path, strings.NewReader(string(lgtmComment)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("X-GitHub-Event", "issue_comment")
+			r.Header.Set("X-GitHub-Delivery", "I am unique")
+			r.Header.Set("X-Hub-Signature", "sha1=f3fee26b22d3748f393f7e37f71baa467495971a")
+			r.Header.Set("content-type", "application/json")
+
+			mux.ServeHTTP(w, r)
+			host.Drain()
+
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected code %v, got code %v", http.StatusOK, w.Code)
+			}
+			if len(handledBy) != len(tc.expectedHandledBy) {
+				t.Errorf("Expected the event handled by %v, got %v", tc.expectedHandledBy, handledBy)
+			}
+			for plugin, count := range tc.expectedHandledBy {
+				if handledBy[plugin] != count {
+					t.Errorf("Expected the event handled by %v, got %v", tc.expectedHandledBy, handledBy)
+				}
+			}
+		})
+	}
+}
+
+func TestServePluginHelp(t *testing.T) {
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second})
+	defer host.Drain()
+
+	mux := http.NewServeMux()
+	for _, plugin := range []string{"a", "b"} {
+		description := "The " + plugin + " plugin."
+		host.ServePlugin(mux, plugin, func([]config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+			return &pluginhelp.PluginHelp{Description: description}, nil
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodPost, "/b/help", strings.NewReader("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected code %v, got code %v", http.StatusOK, w.Code)
+	}
+	var help pluginhelp.PluginHelp
+	if err := json.Unmarshal(w.Body.Bytes(), &help); err != nil {
+		t.Fatalf("unmarshal help failed: %v", err)
+	}
+	if help.Description != "The b plugin." {
+		t.Errorf("Different description: Got \"%s\" expected \"%s\"", help.Description, "The b plugin.")
+	}
+}
+
+func TestDispatchToAllHandlers(t *testing.T) {
+	var mut sync.Mutex
+	handledBy := make(map[string]int)
+
+	host := newTestHost(t, HostOptions{Workers: 2, QueueSize: 2, HandlerTimeout: time.Second})
+	for _, plugin := range []string{"a", "b"} {
+		name := plugin
+		host.RegisterPushEventHandler(name, func(pe *github.PushEvent, _ *Configuration, _ *logrus.Entry) error {
+			mut.Lock()
+			defer mut.Unlock()
+			// Each handler has its own event.
+			pe.Ref = name
+			handledBy[name]++
+			return nil
+		})
+	}
+
+	if err := host.Dispatch(PushEvent, "guid", []byte(`{"ref": "refs/heads/master"}`)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	host.Drain()
+
+	if handledBy["a"] != 1 || handledBy["b"] != 1 {
+		t.Errorf("Expected the event handled by both plugins once, got %v", handledBy)
+	}
+}
+
+func TestDispatchQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Minute})
+	host.RegisterPushEventHandler("test", func(*github.PushEvent, *Configuration, *logrus.Entry) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	// The first event occupies the worker, and the second one waits in the queue.
+	if err := host.Dispatch(PushEvent, "1", []byte(`{}`)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	<-started
+	if err := host.Dispatch(PushEvent, "2", []byte(`{}`)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	if err := host.Dispatch(PushEvent, "3", []byte(`{}`)); !errors.Is(err, ErrHandlerQueueFull) {
+		t.Errorf("Expected error %v, got %v", ErrHandlerQueueFull, err)
+	}
+
+	// Draining waits for both of the accepted events.
+	close(release)
+	host.Drain()
+	if len(started) != 1 {
+		t.Errorf("Expected the queued event handled after draining")
+	}
+
+	if err := host.Dispatch(PushEvent, "4", []byte(`{}`)); !errors.Is(err, ErrHostDraining) {
+		t.Errorf("Expected error %v, got %v", ErrHostDraining, err)
+	}
+}
+
+func TestHandlerPanicAndTimeout(t *testing.T) {
+	release := make(chan struct{})
+	var mut sync.Mutex
+	var handled []string
+
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 3, HandlerTimeout: 10 * time.Millisecond})
+	host.RegisterPushEventHandler("timeout", func(pe *github.PushEvent, _ *Configuration, _ *logrus.Entry) error {
+		switch pe.Ref {
+		case "panic":
+			panic("something went wrong")
+		case "slow":
+			<-release
+		}
+		mut.Lock()
+		defer mut.Unlock()
+		handled = append(handled, pe.Ref)
+		return nil
+	})
+
+	for _, ref := range []string{"panic", "slow", "fast"} {
+		if err := host.Dispatch(PushEvent, ref, []byte(`{"ref": "`+ref+`"}`)); err != nil {
+			t.Fatalf("dispatch failed: %v", err)
+		}
+	}
+
+	// The timed out handler still occupies the worker, so the fast one is not handled until the slow one returns.
+	timedOut := eventsTimedOut.WithLabelValues("timeout", PushEvent, "")
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(timedOut) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	mut.Lock()
+	if len(handled) != 0 {
+		t.Errorf("Expected no event handled while the slow handler is running, got %v", handled)
+	}
+	mut.Unlock()
+	close(release)
+	host.Drain()
+
+	if len(handled) != 2 || handled[0] != "slow" || handled[1] != "fast" {
+		t.Errorf("Expected the slow event handled before the fast one, got %v", handled)
+	}
+	if n := testutil.ToFloat64(timedOut); n != 1 {
+		t.Errorf("Different timed out: Got \"%v\" expected \"%v\"", n, 1)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Millisecond,
+		DrainTimeout: 10 * time.Millisecond})
+	host.RegisterPushEventHandler("test", func(*github.PushEvent, *Configuration, *logrus.Entry) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	if err := host.Dispatch(PushEvent, "1", []byte(`{}`)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	<-started
+
+	// Draining returns after the drain timeout even if the handler never returns.
+	drained := make(chan struct{})
+	go func() {
+		host.Drain()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected draining to return after the drain timeout")
+	}
+}
+
+func TestEventObserver(t *testing.T) {
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 1, HandlerTimeout: time.Second})
+	defer host.Drain()
+
+	var observed []string
+	host.RegisterEventObserver(func(eventType string, payload []byte) {
+		observed = append(observed, eventType+":"+string(payload))
+	})
+
+	// The events without handlers are observed too.
+	if err := host.dispatch(PullRequestEvent, "guid", []byte(`{"action": "labeled"}`), "a"); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	expected := PullRequestEvent + `:{"action": "labeled"}`
+	if len(observed) != 1 || observed[0] != expected {
+		t.Errorf("Different observed events: Got \"%v\" expected \"%v\"", observed, []string{expected})
+	}
+}
@@ -94,6 +94,14 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient) {
+	host.RegisterIssueCommentEventHandler(PluginName,
+		func(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueCommentEvent(gc, ice, cfg, log)
+		})
+}
+
 func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent,
 	cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
 	opts := cfg.LabelFor(ice.Repo.Owner.Login, ice.Repo.Name)
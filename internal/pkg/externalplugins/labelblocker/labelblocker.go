@@ -108,6 +108,18 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient) {
+	host.RegisterPullRequestEventHandler(PluginName,
+		func(pe *github.PullRequestEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullRequestEvent(gc, pe, cfg, log)
+		})
+	host.RegisterIssueEventHandler(PluginName,
+		func(ie *github.IssueEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueEvent(gc, ie, cfg, log)
+		})
+}
+
 // HandlePullRequestEvent handles a GitHub pull request event.
 func HandlePullRequestEvent(gc githubClient, pullRequestEvent *github.PullRequestEvent,
 	cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
@@ -3,9 +3,12 @@ package lgtm
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -28,15 +31,11 @@ const (
 	// ReviewNotificationName defines the name used in the title for the review notifications.
 	ReviewNotificationName = "Review Notification"
 	// ReviewNotificationIdentifier defines the identifier for the review notifications.
-	ReviewNotificationIdentifier = "Review Notification Identifier"
+	ReviewNotificationIdentifier = tiexternalplugins.ReviewNotificationIdentifier
 )
 
-var (
-	// notificationRegex is the regex that matches the notifications.
-	notificationRegex = regexp.MustCompile("<!--" + ReviewNotificationIdentifier + "-->$")
-	// reviewersRegex is the regex that matches the reviewers, such as: - hi-rustin.
-	reviewersRegex = regexp.MustCompile(`(?i)- [@]*([a-z0-9](?:-?[a-z0-9]){0,38})`)
-)
+// patchIDRegex is the regex that matches the ID of the changes recorded in the review notifications.
+var patchIDRegex = regexp.MustCompile(`<!--Patch ID: ([0-9a-f]+)-->`)
 
 // HelpProvider constructs the PluginHelp for this plugin that takes into account enabled repositories.
 // HelpProvider defines the type for function that construct the PluginHelp for plugins.
@@ -56,7 +55,11 @@ func HelpProvider(_ *tiexternalplugins.ConfigAgent) externalplugins.ExternalPlug
 		pluginHelp := &pluginhelp.PluginHelp{
 			Description: "The ti-community-lgtm plugin manages the 'status/LGT{number}' (Looks Good To Me) label.",
 			Snippet:     yamlSnippet,
-			Events:      []string{tiexternalplugins.PullRequestReviewEvent, tiexternalplugins.PullRequestEvent},
+			Events: []string{
+				tiexternalplugins.PullRequestReviewEvent,
+				tiexternalplugins.PullRequestEvent,
+				tiexternalplugins.IssueCommentEvent,
+			},
 		}
 
 		pluginHelp.AddCommand(pluginhelp.Command{
@@ -67,10 +70,42 @@ func HelpProvider(_ *tiexternalplugins.ConfigAgent) externalplugins.ExternalPlug
 			Examples: []string{
 				"<a href=\"https://help.github.com/articles/about-pull-request-reviews/\">'Approve' or 'Request Changes'</a>"},
 		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/lgtm [cancel]",
+			Description: "Approve the pull request or withdraw the approval like the GitHub reviews, " +
+				"only available in the repositories which allow the lgtm commands.",
+			WhoCanUse: "Reviewers of this pull request.",
+			Examples:  []string{"/lgtm", "/lgtm cancel"},
+		})
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/refresh",
+			Description: "Reconcile the 'status/LGT{number}' label and the '" + tiexternalplugins.CanMergeLabel +
+				"' label with the reviews and the current owners of the pull request.",
+			WhoCanUse: "Anyone can trigger this command on a PR.",
+			Examples:  []string{"/refresh"},
+		})
 		return pluginHelp, nil
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host,
+// the /refresh command only reports the changes without making them in dry-run mode.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient, ol ownersclient.OwnersLoader,
+	refreshDryRun bool) {
+	host.RegisterIssueCommentEventHandler(PluginName,
+		func(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueCommentEvent(gc, ice, cfg, ol, refreshDryRun, log)
+		})
+	host.RegisterReviewEventHandler(PluginName,
+		func(re *github.ReviewEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullReviewEvent(gc, re, cfg, ol, log)
+		})
+	host.RegisterPullRequestEventHandler(PluginName,
+		func(pe *github.PullRequestEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullRequestEvent(gc, pe, cfg, ol, log)
+		})
+}
+
 type githubClient interface {
 	AddLabel(owner, repo string, number int, label string) error
 	CreateComment(owner, repo string, number int, comment string) error
@@ -79,13 +114,22 @@ type githubClient interface {
 	BotUserChecker() (func(candidate string) bool, error)
 	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
 	DeleteComment(org, repo string, ID int) error
+	ListReviews(org, repo string, number int) ([]github.Review, error)
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	Query(context.Context, interface{}, map[string]interface{}) error
 }
 
 // reviewCtx contains information about each review event.
 type reviewCtx struct {
 	author, issueAuthor, body, htmlURL string
+	branch                             string
 	repo                               github.Repo
 	number                             int
+	review                             *github.Review
+	// withdrawn specifies whether the approval is withdrawn without requesting changes, i.e. the review
+	// is dismissed or the /lgtm cancel command is used.
+	withdrawn bool
 }
 
 func HandlePullReviewEvent(gc githubClient, pullReviewEvent *github.ReviewEvent,
@@ -93,13 +137,21 @@ func HandlePullReviewEvent(gc githubClient, pullReviewEvent *github.ReviewEvent,
 	rc := reviewCtx{
 		author:      pullReviewEvent.Review.User.Login,
 		issueAuthor: pullReviewEvent.PullRequest.User.Login,
+		branch:      pullReviewEvent.PullRequest.Base.Ref,
 		repo:        pullReviewEvent.Repo,
 		number:      pullReviewEvent.PullRequest.Number,
 		body:        pullReviewEvent.Review.Body,
 		htmlURL:     pullReviewEvent.Review.HTMLURL,
+		review:      &pullReviewEvent.Review,
+	}
+
+	// The approvals are updated when a review is dismissed.
+	if pullReviewEvent.Action == github.ReviewActionDismissed {
+		rc.withdrawn = true
+		return handle(false, cfg, rc, gc, ol, log)
 	}
 
-	// Only react to reviews that are being submitted (not edited or dismissed).
+	// Only react to reviews that are being submitted (not edited).
 	if pullReviewEvent.Action != github.ReviewActionSubmitted {
 		return nil
 	}
@@ -124,19 +176,99 @@ func HandlePullReviewEvent(gc githubClient, pullReviewEvent *github.ReviewEvent,
 	return handle(wantLGTM, cfg, rc, gc, ol, log)
 }
 
+// HandleIssueCommentEvent handles a GitHub issue comment event, it reconciles the labels of the PR for
+// the /refresh command and updates the approvals of the PR for the lgtm commands.
+func HandleIssueCommentEvent(gc githubClient, ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration,
+	ol ownersclient.OwnersLoader, refreshDryRun bool, log *logrus.Entry) error {
+	// Only consider open PRs and new comments.
+	if !ice.Issue.IsPullRequest() || ice.Issue.State != "open" || ice.Action != github.IssueCommentActionCreated {
+		return nil
+	}
+	if refreshRe.MatchString(ice.Comment.Body) {
+		return handleRefresh(gc, ice, cfg, ol, refreshDryRun, log)
+	}
+
+	review := tiexternalplugins.ReviewFromLgtmCommand(ice.Comment)
+	if review == nil {
+		return nil
+	}
+
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get pull request %s/%s#%d: %v", org, repo, number, err)
+	}
+	// The lgtm commands are only handled if they are allowed.
+	opts := cfg.LgtmForBranch(org, repo, pr.Base.Ref)
+	if !opts.AllowLgtmCommands {
+		return nil
+	}
+
+	rc := reviewCtx{
+		author:      ice.Comment.User.Login,
+		issueAuthor: pr.User.Login,
+		branch:      pr.Base.Ref,
+		repo:        ice.Repo,
+		number:      number,
+		body:        ice.Comment.Body,
+		htmlURL:     ice.Comment.HTMLURL,
+		review:      review,
+	}
+
+	// The PR author cannot approve their own PR, just like the GitHub reviews.
+	if rc.author == rc.issueAuthor {
+		if review.State != github.ReviewStateApproved {
+			return nil
+		}
+		resp := "you cannot LGTM your own PR."
+		log.Infof("Reply /lgtm request in comment: \"%s\"", resp)
+		return gc.CreateComment(org, repo, number,
+			tiexternalplugins.FormatResponseRaw(rc.body, rc.htmlURL, rc.author, resp))
+	}
+
+	if review.State == github.ReviewStateDismissed {
+		rc.withdrawn = true
+		return handle(false, cfg, rc, gc, ol, log)
+	}
+	return handle(true, cfg, rc, gc, ol, log)
+}
+
 func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
-	config *tiexternalplugins.Configuration, log *logrus.Entry) error {
-	if pe.Action != github.PullRequestActionOpened {
-		log.Debug("Not a pull request opened action, skipping...")
+	config *tiexternalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	switch pe.Action {
+	case github.PullRequestActionOpened:
+		return handlePullRequestOpened(gc, pe, config, log)
+	case github.PullRequestActionSynchronize:
+		return handlePullRequestSynchronize(gc, pe, config, ol, log)
+	default:
+		log.Debug("Not a pull request opened or synchronize action, skipping...")
 		return nil
 	}
+}
 
+func handlePullRequestOpened(gc githubClient, pe *github.PullRequestEvent,
+	config *tiexternalplugins.Configuration, log *logrus.Entry) error {
 	org := pe.PullRequest.Base.Repo.Owner.Login
 	repo := pe.PullRequest.Base.Repo.Name
 	number := pe.PullRequest.Number
 	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
 
-	reviewMsg, err := getMessage(nil, config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
+	// Record the changes of the PR to find out whether they are changed by the following commits.
+	var patchID string
+	opts := config.LgtmForBranch(org, repo, pe.PullRequest.Base.Ref)
+	if opts.ApprovalsOnPush == tiexternalplugins.LgtmApprovalsOnPushResetOnChange {
+		changes, err := gc.GetPullRequestChanges(org, repo, number)
+		if err != nil {
+			log.WithError(err).Warn("Failed to get the changes of the pull request.")
+		} else {
+			patchID = getPatchID(changes)
+		}
+	}
+
+	reviewMsg, err := getMessage(nil, nil, nil, nil, patchID, config.CommandHelpLink, config.PRProcessLink, tichiURL,
+		org, repo)
 	if err != nil {
 		return err
 	}
@@ -144,6 +276,52 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 	return gc.CreateComment(org, repo, number, *reviewMsg)
 }
 
+// handlePullRequestSynchronize invalidates the approvals of the PR when new commits are pushed to it
+// according to the approvals on push policy.
+func handlePullRequestSynchronize(gc githubClient, pe *github.PullRequestEvent,
+	config *tiexternalplugins.Configuration, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
+	org := pe.PullRequest.Base.Repo.Owner.Login
+	repo := pe.PullRequest.Base.Repo.Name
+	number := pe.PullRequest.Number
+	opts := config.LgtmForBranch(org, repo, pe.PullRequest.Base.Ref)
+	if opts.ApprovalsOnPush != tiexternalplugins.LgtmApprovalsOnPushReset &&
+		opts.ApprovalsOnPush != tiexternalplugins.LgtmApprovalsOnPushResetOnChange {
+		return nil
+	}
+
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get owners info for %s/%s#%d: %v", org, repo, number, err)
+	}
+	state, err := loadApprovalState(gc, opts, org, repo, number, pe.PullRequest.User.Login, owners, nil)
+	if err != nil {
+		return err
+	}
+
+	changed := true
+	if opts.ApprovalsOnPush == tiexternalplugins.LgtmApprovalsOnPushResetOnChange {
+		changes, err := gc.GetPullRequestChanges(org, repo, number)
+		if err != nil {
+			return fmt.Errorf("failed to get changes for %s/%s#%d: %v", org, repo, number, err)
+		}
+		patchID := getPatchID(changes)
+		// The changes are regarded as changed if they were not recorded.
+		changed = patchID != state.patchID
+		state.patchID = patchID
+	}
+
+	if changed && state.approvers.Len() != 0 {
+		log.Infof("Invalidating the approvals of %s by commit %s.", state.approvers.List(), pe.PullRequest.Head.SHA)
+		state.setReset(&tiexternalplugins.ApprovalsReset{
+			Commit:    pe.PullRequest.Head.SHA,
+			Time:      time.Now(),
+			Reviewers: state.approvers.List(),
+		})
+	}
+
+	return reconcileApprovals(gc, config, opts, org, repo, number, owners, state, log)
+}
+
 func handle(wantLGTM bool, config *tiexternalplugins.Configuration, rc reviewCtx,
 	gc githubClient, ol ownersclient.OwnersLoader, log *logrus.Entry) error {
 	funcStart := time.Now()
@@ -162,17 +340,14 @@ func handle(wantLGTM bool, config *tiexternalplugins.Configuration, rc reviewCtx
 	}
 
 	// Get ti-community-lgtm config.
-	opts := config.LgtmFor(rc.repo.Owner.Login, rc.repo.Name)
+	opts := config.LgtmForBranch(rc.repo.Owner.Login, rc.repo.Name, rc.branch)
 	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
 	reviewersAndNeedsLGTM, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
 	if err != nil {
 		return fetchErr("owners info", err)
 	}
 
-	reviewers := sets.String{}
-	for _, reviewer := range reviewersAndNeedsLGTM.Reviewers {
-		reviewers.Insert(reviewer)
-	}
+	reviewers := sets.NewString(reviewersAndNeedsLGTM.Reviewers...)
 
 	// Not reviewers but want to add LGTM.
 	if !reviewers.Has(author) && wantLGTM {
@@ -184,131 +359,201 @@ func handle(wantLGTM bool, config *tiexternalplugins.Configuration, rc reviewCtx
 	}
 
 	// Not reviewers but want to remove LGTM.
-	if !reviewers.Has(author) && !wantLGTM {
+	if !reviewers.Has(author) && !wantLGTM && !rc.withdrawn {
 		resp := "Request changes is only allowed for the reviewers in [list](" + tichiURL + ")."
 		log.Infof("Reply request changes pull request in comment: \"%s\"", resp)
 		return gc.CreateComment(org, repo, number, tiexternalplugins.FormatResponseRaw(body, htmlURL, author, resp))
 	}
 
+	state, err := loadApprovalState(gc, opts, org, repo, number, rc.issueAuthor, reviewersAndNeedsLGTM, rc.review)
+	if err != nil {
+		return err
+	}
+	return reconcileApprovals(gc, config, opts, org, repo, number, reviewersAndNeedsLGTM, state, log)
+}
+
+// approvalState contains the approvals of the PR computed from its reviews, and the states recorded in
+// its review notifications.
+type approvalState struct {
+	reviews       []github.Review
+	reviewers     sets.String
+	approvers     sets.String
+	labels        []github.Label
+	notifications []*github.IssueComment
+	reset         *tiexternalplugins.ApprovalsReset
+	patchID       string
+}
+
+// setReset sets the approvals reset and recomputes the approvals.
+func (s *approvalState) setReset(reset *tiexternalplugins.ApprovalsReset) {
+	s.reset = reset
+	s.approvers = tiexternalplugins.ApproversFromReviews(reset.ValidReviews(s.reviews)).Intersection(s.reviewers)
+}
+
+// loadApprovalState loads the approval state of the PR, the review is used in case it is not listed yet.
+// The lgtm commands in the comments of the PR count as reviews if they are allowed.
+func loadApprovalState(gc githubClient, opts *tiexternalplugins.TiCommunityLgtm, org, repo string, number int,
+	author string, owners *ownersclient.Owners, review *github.Review) (*approvalState, error) {
+	fetchErr := func(context string, err error) error {
+		return fmt.Errorf("failed to get %s for %s/%s#%d: %v", context, org, repo, number, err)
+	}
+
+	reviews, err := gc.ListReviews(org, repo, number)
+	if err != nil {
+		return nil, fetchErr("reviews", err)
+	}
 	labels, err := gc.GetIssueLabels(org, repo, number)
 	if err != nil {
-		return fetchErr("issue labels", err)
+		return nil, fetchErr("issue labels", err)
 	}
 	botUserChecker, err := gc.BotUserChecker()
 	if err != nil {
-		return fetchErr("bot name", err)
+		return nil, fetchErr("bot name", err)
 	}
 	issueComments, err := gc.ListIssueComments(org, repo, number)
 	if err != nil {
-		return fetchErr("issue comments", err)
-	}
-	notifications := filterComments(issueComments, notificationMatcher(botUserChecker))
-	cleanupOldNotifications := func() {
-		for _, notification := range notifications {
-			notif := notification
-			if err := gc.DeleteComment(org, repo, notif.ID); err != nil {
-				log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID: %d.", org, repo, number, notif.ID)
-			}
-		}
+		return nil, fetchErr("issue comments", err)
 	}
 
-	// Now we update the LGTM labels, having checked all cases where changing.
-	// Only add the label if it doesn't have it, and vice versa.
-	currentLabel, nextLabel := getCurrentAndNextLabel(tiexternalplugins.LgtmLabelPrefix, labels,
-		reviewersAndNeedsLGTM.NeedsLgtm)
-	// Remove the label if necessary, we're done after this.
-	if currentLabel != "" && !wantLGTM {
-		newMsg, err := getMessage(nil, config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
-		if err != nil {
-			return err
-		}
-		err = gc.CreateComment(org, repo, number, *newMsg)
-		if err != nil {
-			return err
-		}
-		log.Info("Removing LGTM label.")
-		if err := gc.RemoveLabel(org, repo, number, currentLabel); err != nil {
-			return err
-		}
+	reviews = upsertReview(reviews, review)
+	if opts.AllowLgtmCommands {
+		reviews = append(reviews, tiexternalplugins.ReviewsFromLgtmCommands(issueComments, author)...)
+	}
 
-		// Clean up old notifications after we added the new notification.
-		cleanupOldNotifications()
-	} else if nextLabel != "" && wantLGTM {
-		latestNotification := getLastComment(notifications)
-		reviewedReviewers := getReviewersFromNotification(latestNotification)
-		// Ignore already reviewed reviewer.
-		if reviewedReviewers.Has(author) {
-			log.Infof("Ignore %s's multiple reviews.", author)
-			return nil
+	state := &approvalState{
+		reviews:       reviews,
+		reviewers:     sets.NewString(owners.Reviewers...),
+		labels:        labels,
+		notifications: filterComments(issueComments, notificationMatcher(botUserChecker)),
+	}
+	latestNotification := getLastComment(state.notifications)
+	if latestNotification != nil {
+		if m := patchIDRegex.FindStringSubmatch(latestNotification.Body); m != nil {
+			state.patchID = m[1]
 		}
+	}
+	state.setReset(tiexternalplugins.ApprovalsResetFromNotification(latestNotification))
+	return state, nil
+}
 
-		// Add author as reviewers and create new notification.
-		reviewedReviewers.Insert(author)
-		newMsg, err := getMessage(reviewedReviewers.List(), config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
-		if err != nil {
-			return err
-		}
+// reconcileApprovals reconciles the LGTM label and the review notification of the PR with its approvals.
+// The approvals are weighted by the roles of the approvers, and the notification shows the progress of
+// the approvals against the needed LGTM number and the approval rules if either of them is configured.
+func reconcileApprovals(gc githubClient, config *tiexternalplugins.Configuration,
+	opts *tiexternalplugins.TiCommunityLgtm, org, repo string, number int, owners *ownersclient.Owners,
+	state *approvalState, log *logrus.Entry) error {
+	tichiURL := fmt.Sprintf(ownersclient.OwnersURLFmt, config.TichiWebURL, org, repo, number)
+	approvals := opts.WeightedApprovals(owners, state.approvers)
+	var progress []tiexternalplugins.ApprovalProgress
+	if len(opts.RoleWeights) != 0 || len(opts.ApprovalRules) != 0 {
+		progress = append(progress, tiexternalplugins.ApprovalProgress{
+			Name:      "LGTM",
+			Approvals: approvals,
+			Required:  owners.NeedsLgtm,
+		})
+		progress = append(progress, opts.ApprovalRulesProgress(owners, state.approvers)...)
+	}
 
-		err = gc.CreateComment(org, repo, number, *newMsg)
+	// The notification is only a rendered view of the approvals, so it is recreated if it is outdated.
+	latestNotification := getLastComment(state.notifications)
+	var outdatedNotifications []*github.IssueComment
+	if latestNotification != nil || state.approvers.Len() != 0 || state.reset != nil || state.patchID != "" {
+		missingAreas := owners.UncoveredGroups(state.approvers)
+		newMsg, err := getMessage(state.approvers.List(), missingAreas, progress, state.reset, state.patchID,
+			config.CommandHelpLink, config.PRProcessLink, tichiURL, org, repo)
 		if err != nil {
 			return err
 		}
-
-		log.Info("Adding LGTM label.")
-		// Remove current label.
-		if currentLabel != "" {
-			if err := gc.RemoveLabel(org, repo, number, currentLabel); err != nil {
+		if latestNotification == nil || latestNotification.Body != *newMsg {
+			if err := gc.CreateComment(org, repo, number, *newMsg); err != nil {
 				return err
 			}
+			outdatedNotifications = state.notifications
+		}
+	}
+
+	// Reconcile the LGTM label with the approvals.
+	expectedLabel := getLgtmLabel(tiexternalplugins.LgtmLabelPrefix, approvals, owners.NeedsLgtm)
+	hasExpectedLabel := false
+	for _, label := range state.labels {
+		if !strings.HasPrefix(label.Name, tiexternalplugins.LgtmLabelPrefix) {
+			continue
+		}
+		if label.Name == expectedLabel {
+			hasExpectedLabel = true
+			continue
+		}
+		log.Infof("Removing LGTM label %s.", label.Name)
+		if err := gc.RemoveLabel(org, repo, number, label.Name); err != nil {
+			return err
 		}
-		if err := gc.AddLabel(org, repo, number, nextLabel); err != nil {
+	}
+	if expectedLabel != "" && !hasExpectedLabel {
+		log.Infof("Adding LGTM label %s.", expectedLabel)
+		if err := gc.AddLabel(org, repo, number, expectedLabel); err != nil {
 			return err
 		}
+	}
 
-		// Clean up old notifications after we added the new notification.
-		cleanupOldNotifications()
+	// Clean up old notifications after we added the new notification.
+	for _, notification := range outdatedNotifications {
+		if err := gc.DeleteComment(org, repo, notification.ID); err != nil {
+			log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID: %d.", org, repo, number,
+				notification.ID)
+		}
 	}
 
 	return nil
 }
 
-// getCurrentAndNextLabel returns pull request current label and next required label.
-func getCurrentAndNextLabel(prefix string, labels []github.Label, needsLgtm int) (string, string) {
-	currentLabel := ""
-	nextLabel := ""
-	for _, label := range labels {
-		if strings.Contains(label.Name, prefix) {
-			currentLabel = label.Name
-			currentLgtmNumber, _ := strconv.Atoi(strings.Trim(label.Name, prefix))
-			if currentLgtmNumber < needsLgtm {
-				nextLabel = fmt.Sprintf("%s%d", prefix, currentLgtmNumber+1)
+// getPatchID returns the ID of the changes of the PR like git patch-id, it ignores the line numbers and the
+// whitespace of the patches, so it is not changed by a rebase or a merge of the base branch without conflicts.
+// The changes of the binary files are only identified by their file names and status.
+func getPatchID(changes []github.PullRequestChange) string {
+	sorted := append([]github.PullRequestChange(nil), changes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Filename < sorted[j].Filename
+	})
+
+	h := sha256.New()
+	for _, change := range sorted {
+		fmt.Fprintf(h, "%s %s %s %d %d\n", change.Filename, change.PreviousFilename, change.Status,
+			change.Additions, change.Deletions)
+		for _, line := range strings.Split(change.Patch, "\n") {
+			// The hunk headers contain the line numbers.
+			if strings.HasPrefix(line, "@@") {
+				continue
 			}
+			fmt.Fprintln(h, strings.Join(strings.Fields(line), ""))
 		}
 	}
-	if currentLabel == "" {
-		nextLabel = fmt.Sprintf("%s%d", prefix, 1)
-	}
-
-	return currentLabel, nextLabel
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-// getReviewersFromNotification get the reviewers from latest notification.
-func getReviewersFromNotification(latestNotification *github.IssueComment) sets.String {
-	result := sets.String{}
-	if latestNotification == nil {
-		return result
+// upsertReview returns the reviews with the review replaced or added by its ID.
+func upsertReview(reviews []github.Review, review *github.Review) []github.Review {
+	if review == nil {
+		return reviews
 	}
-
-	reviewers := reviewersRegex.FindAllStringSubmatch(latestNotification.Body, -1)
-
-	reviewerNameIndex := 1
-	for _, reviewer := range reviewers {
-		// Example: - a => [[- a a]]
-		if len(reviewer) == reviewerNameIndex+1 {
-			result.Insert(reviewer[reviewerNameIndex])
+	for i := range reviews {
+		if reviews[i].ID == review.ID {
+			reviews[i] = *review
+			return reviews
 		}
 	}
-	return result
+	return append(reviews, *review)
+}
+
+// getLgtmLabel returns the LGTM label of the number of the approvals, which is at most the needed LGTM number.
+// No LGTM label is needed if there are no approvals.
+func getLgtmLabel(prefix string, approvals int, needsLgtm int) string {
+	if needsLgtm > 0 && approvals > needsLgtm {
+		approvals = needsLgtm
+	}
+	if approvals <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s%d", prefix, approvals)
 }
 
 // filterComments will filtering the issue comments by filter.
@@ -335,10 +580,22 @@ func getLastComment(issueComments []*github.IssueComment) *github.IssueComment {
 // getMessage returns the comment body that we want the approve plugin to display on PRs
 // The comment shows:
 // 	- a list of reviewed reviewers
+// 	- the progress of the approvals against the requirements
+// 	- a list of the reviewers whose approvals were invalidated by new commits
+// 	- a list of the areas which still require an approval from their reviewers
 // 	- how an approver can indicate their lgtm
 // 	- how an approver can cancel their lgtm
-func getMessage(reviewedReviewers []string, commandHelpLink,
-	prProcessLink, ownersLink, org, repo string) (*string, error) {
+func getMessage(reviewedReviewers []string, missingAreas []string, progress []tiexternalplugins.ApprovalProgress,
+	reset *tiexternalplugins.ApprovalsReset, patchID, commandHelpLink, prProcessLink, ownersLink, org,
+	repo string) (*string, error) {
+	var invalidatedReviewers []string
+	var resetCommit, resetRecord string
+	if reset != nil {
+		invalidatedReviewers = sets.NewString(reset.Reviewers...).Difference(sets.NewString(reviewedReviewers...)).List()
+		resetCommit = reset.Commit
+		resetRecord = reset.String()
+	}
+
 	// nolint:lll
 	message, err := generateTemplate(`
 {{if .reviewers}}
@@ -348,6 +605,18 @@ This pull request has been approved by:
 
 {{else}}
 This pull request has not been approved.
+{{end}}{{if .progress}}
+The approvals of this pull request against the requirements:
+
+{{range $index, $p := .progress}}* {{$p.Name}}: {{$p.Approvals}}/{{$p.Required}}{{if $p.Satisfied}} (satisfied){{end}}`+"\n"+`{{end}}
+{{end}}{{if .invalidatedReviewers}}
+The approvals of the following reviewers were invalidated by commit {{ .resetCommit }}:
+
+{{range $index, $reviewer := .invalidatedReviewers}}* {{$reviewer}}`+"\n"+`{{end}}
+{{end}}{{if .missingAreas}}
+These areas of this pull request still require an approval from their reviewers:
+
+{{range $index, $area := .missingAreas}}* `+"`{{$area}}`"+"\n"+`{{end}}
 {{end}}
 
 To complete the [pull request process]({{ .prProcessLink }}), please ask the reviewers in the [list]({{ .ownersLink }}) to review by filling `+"`/cc @reviewer`"+` in the comment.
@@ -361,9 +630,17 @@ Reviewer can indicate their review by submitting an approval review.
 Reviewer can cancel approval by submitting a request changes review.
 </details>
 
-<!--{{ .reviewNotificationIdentifier }}-->
+{{if .resetRecord}}{{ .resetRecord }}
+{{end}}{{if .patchID}}<!--Patch ID: {{ .patchID }}-->
+{{end}}<!--{{ .reviewNotificationIdentifier }}-->
 `, "message", map[string]interface{}{
 		"reviewers":                    reviewedReviewers,
+		"missingAreas":                 missingAreas,
+		"progress":                     progress,
+		"invalidatedReviewers":         invalidatedReviewers,
+		"resetCommit":                  resetCommit,
+		"resetRecord":                  resetRecord,
+		"patchID":                      patchID,
 		"commandHelpLink":              commandHelpLink,
 		"prProcessLink":                prProcessLink,
 		"ownersLink":                   ownersLink,
@@ -411,10 +688,6 @@ func notification(name, arguments, context string) *string {
 func notificationMatcher(isBot func(string) bool) func(comment *github.IssueComment) bool {
 	return func(c *github.IssueComment) bool {
 		// Only match robot's comment.
-		if !isBot(c.User.Login) {
-			return false
-		}
-		match := notificationRegex.FindStringSubmatch(c.Body)
-		return len(match) > 0
+		return tiexternalplugins.IsReviewNotification(c, isBot)
 	}
 }
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/github/fakegithub"
@@ -20,103 +22,135 @@ var (
 )
 
 type fakeOwnersClient struct {
-	reviewers []string
-	needsLgtm int
+	leaders        []string
+	reviewers      []string
+	needsLgtm      int
+	reviewerGroups []ownersclient.ReviewerGroup
 }
 
 func (f *fakeOwnersClient) LoadOwners(_ string,
 	_, _ string, _ int) (*ownersclient.Owners, error) {
 	return &ownersclient.Owners{
-		Reviewers: f.reviewers,
-		NeedsLgtm: f.needsLgtm,
+		Leaders:        f.leaders,
+		Reviewers:      f.reviewers,
+		NeedsLgtm:      f.needsLgtm,
+		ReviewerGroups: f.reviewerGroups,
 	}, nil
 }
 
 func TestLGTMFromApproveReview(t *testing.T) {
+	approvedByCollab3 := github.Review{ID: 1, State: github.ReviewStateApproved, User: github.User{Login: "collab3"}}
+	approvedByCollab1 := github.Review{ID: 2, State: github.ReviewStateApproved, User: github.User{Login: "collab1"}}
+
 	var testcases = []struct {
 		name         string
 		state        github.ReviewState
 		action       github.ReviewEventAction
 		body         string
 		reviewer     string
+		reviews      []github.Review
 		currentLabel string
-		isCancel     bool
-		shouldToggle bool
+
+		expectLabel   string
+		expectComment bool
 	}{
 		{
-			name:         "Edit approve review by reviewer, no lgtm on pr",
-			state:        github.ReviewStateApproved,
-			action:       github.ReviewActionEdited,
-			reviewer:     "collab1",
-			shouldToggle: false,
+			name:     "Edit approve review by reviewer, no lgtm on pr",
+			state:    github.ReviewStateApproved,
+			action:   github.ReviewActionEdited,
+			reviewer: "collab1",
 		},
 		{
-			name:         "Dismiss approve review by reviewer, no lgtm on pr",
-			state:        github.ReviewStateApproved,
+			name:         "Dismiss approve review by reviewer, lgtm on pr",
+			state:        github.ReviewStateDismissed,
 			action:       github.ReviewActionDismissed,
 			reviewer:     "collab1",
-			shouldToggle: false,
+			reviews:      []github.Review{approvedByCollab1},
+			currentLabel: lgtmOne,
 		},
 		{
-			name:         "Request changes review by reviewer, no lgtm on pr",
-			state:        github.ReviewStateChangesRequested,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab1",
-			shouldToggle: false,
+			name:     "Request changes review by reviewer, no lgtm on pr",
+			state:    github.ReviewStateChangesRequested,
+			action:   github.ReviewActionSubmitted,
+			reviewer: "collab1",
 		},
 		{
-			name:         "Request changes review by reviewer, lgtm on pr",
-			state:        github.ReviewStateChangesRequested,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab1",
-			currentLabel: lgtmOne,
-			isCancel:     true,
-			shouldToggle: true,
+			name:          "Request changes review by reviewer, lgtm on pr",
+			state:         github.ReviewStateChangesRequested,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab1",
+			reviews:       []github.Review{approvedByCollab3, approvedByCollab1},
+			currentLabel:  lgtmTwo,
+			expectLabel:   lgtmOne,
+			expectComment: true,
 		},
 		{
-			name:         "Approve review by reviewer, no lgtm on pr",
-			state:        github.ReviewStateApproved,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab1",
-			currentLabel: lgtmOne,
-			shouldToggle: true,
+			name:          "Approve review by reviewer, no lgtm on pr",
+			state:         github.ReviewStateApproved,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab1",
+			expectLabel:   lgtmOne,
+			expectComment: true,
 		},
 		{
-			name:         "Approve review by reviewer, LGTM is enough",
-			state:        github.ReviewStateApproved,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab1",
-			currentLabel: lgtmTwo,
-			shouldToggle: false,
+			name:          "Approve review by reviewer, lgtm on pr",
+			state:         github.ReviewStateApproved,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab1",
+			reviews:       []github.Review{approvedByCollab3},
+			currentLabel:  lgtmOne,
+			expectLabel:   lgtmTwo,
+			expectComment: true,
 		},
 		{
-			name:         "Approve review by non-reviewer, no lgtm on pr",
-			state:        github.ReviewStateApproved,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab2",
-			shouldToggle: false,
+			name:     "Approve review by reviewer, LGTM is enough",
+			state:    github.ReviewStateApproved,
+			action:   github.ReviewActionSubmitted,
+			reviewer: "collab1",
+			reviews: []github.Review{
+				approvedByCollab3,
+				{ID: 3, State: github.ReviewStateApproved, User: github.User{Login: "collab4"}},
+			},
+			currentLabel:  lgtmTwo,
+			expectLabel:   lgtmTwo,
+			expectComment: true,
 		},
 		{
-			name:         "Request changes review by non-reviewer, no lgtm on pr",
-			state:        github.ReviewStateChangesRequested,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab2",
-			shouldToggle: false,
+			name:          "Approve review by reviewer, drifted label is repaired",
+			state:         github.ReviewStateApproved,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab1",
+			currentLabel:  lgtmTwo,
+			expectLabel:   lgtmOne,
+			expectComment: true,
 		},
 		{
-			name:         "Approve review by random",
-			state:        github.ReviewStateApproved,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "not-in-the-org",
-			shouldToggle: false,
+			name:          "Approve review by non-reviewer, no lgtm on pr",
+			state:         github.ReviewStateApproved,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab2",
+			expectComment: true,
 		},
 		{
-			name:         "Comment Review by issue author, no lgtm on pr",
-			state:        github.ReviewStateCommented,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "author",
-			body:         "/lgtm",
-			shouldToggle: false,
+			name:          "Request changes review by non-reviewer, no lgtm on pr",
+			state:         github.ReviewStateChangesRequested,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab2",
+			expectComment: true,
+		},
+		{
+			name:          "Approve review by random",
+			state:         github.ReviewStateApproved,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "not-in-the-org",
+			expectComment: true,
+		},
+		{
+			name:     "Comment Review by issue author, no lgtm on pr",
+			state:    github.ReviewStateCommented,
+			action:   github.ReviewActionSubmitted,
+			reviewer: "author",
+			body:     "/lgtm",
 		},
 		{
 			name:         "(Deprecated) Comment Review with /lgtm comment",
@@ -125,7 +159,7 @@ func TestLGTMFromApproveReview(t *testing.T) {
 			reviewer:     "collab1",
 			body:         "/lgtm",
 			currentLabel: lgtmOne,
-			shouldToggle: false,
+			expectLabel:  lgtmOne,
 		},
 		{
 			name:         "(Deprecated) Comment Review with /lgtm cancel comment",
@@ -134,7 +168,7 @@ func TestLGTMFromApproveReview(t *testing.T) {
 			reviewer:     "collab1",
 			body:         "/lgtm cancel",
 			currentLabel: lgtmOne,
-			shouldToggle: false,
+			expectLabel:  lgtmOne,
 		},
 		{
 			name:         "Comment Review with random comment",
@@ -143,82 +177,332 @@ func TestLGTMFromApproveReview(t *testing.T) {
 			reviewer:     "collab1",
 			body:         "/random content",
 			currentLabel: lgtmOne,
-			shouldToggle: false,
+			expectLabel:  lgtmOne,
 		},
 		{
-			name:         "(Deprecated) Comment body has /lgtm cancel on Approve Review",
-			state:        github.ReviewStateApproved,
-			action:       github.ReviewActionSubmitted,
-			reviewer:     "collab1",
-			body:         "/lgtm cancel",
-			currentLabel: lgtmOne,
-			shouldToggle: true,
+			name:          "(Deprecated) Comment body has /lgtm cancel on Approve Review",
+			state:         github.ReviewStateApproved,
+			action:        github.ReviewActionSubmitted,
+			reviewer:      "collab1",
+			body:          "/lgtm cancel",
+			reviews:       []github.Review{approvedByCollab3},
+			currentLabel:  lgtmOne,
+			expectLabel:   lgtmTwo,
+			expectComment: true,
 		},
 	}
 	SHA := "0bd3ed50c88cd53a09316bf7a298f900e9371652"
-	for _, tc := range testcases {
-		fc := &fakegithub.FakeClient{
-			IssueComments:    make(map[int][]github.IssueComment),
-			IssueLabelsAdded: []string{},
-			PullRequests: map[int]*github.PullRequest{
-				5: {
-					Head: github.PullRequestBranch{
-						SHA: SHA,
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegithub.FakeClient{
+				IssueComments:    make(map[int][]github.IssueComment),
+				IssueLabelsAdded: []string{},
+				PullRequests: map[int]*github.PullRequest{
+					5: {
+						Head: github.PullRequestBranch{
+							SHA: SHA,
+						},
 					},
 				},
-			},
-			Collaborators: []string{"collab1", "collab2"},
-		}
+				Reviews:       map[int][]github.Review{5: tc.reviews},
+				Collaborators: []string{"collab1", "collab2"},
+			}
+			e := &github.ReviewEvent{
+				Action: tc.action,
+				Review: github.Review{ID: 2, Body: tc.body, State: tc.state, HTMLURL: "<url>",
+					User: github.User{Login: tc.reviewer}},
+				PullRequest: github.PullRequest{
+					User: github.User{Login: "author"},
+					Assignees: []github.User{
+						{Login: "collab1"},
+						{Login: "assignee1"}},
+					Number: 5},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			if tc.currentLabel != "" {
+				fc.IssueLabelsExisting = append(fc.IssueLabelsExisting, "org/repo#5:"+tc.currentLabel)
+			}
+
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+				{
+					Repos:              []string{"org/repo"},
+					PullOwnersEndpoint: "https://fake/ti-community-bot",
+				},
+			}
+
+			foc := &fakeOwnersClient{
+				reviewers: []string{"collab1", "collab3", "collab4"},
+				needsLgtm: 2,
+			}
+
+			if err := HandlePullReviewEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error from pull request review: %v", err)
+			}
+
+			var lgtmLabels []string
+			labels, _ := fc.GetIssueLabels("org", "repo", 5)
+			for _, label := range labels {
+				if strings.HasPrefix(label.Name, externalplugins.LgtmLabelPrefix) {
+					lgtmLabels = append(lgtmLabels, label.Name)
+				}
+			}
+			if strings.Join(lgtmLabels, ",") != tc.expectLabel {
+				t.Errorf("Different labels: Got \"%v\" expected \"%v\"", lgtmLabels, tc.expectLabel)
+			}
+			if commented := len(fc.IssueCommentsAdded) != 0; commented != tc.expectComment {
+				t.Errorf("Different comment: Got \"%v\" expected \"%v\"", fc.IssueCommentsAdded, tc.expectComment)
+			}
+		})
+	}
+}
+
+func TestLGTMMissingAreas(t *testing.T) {
+	fc := &fakegithub.FakeClient{
+		IssueComments:    make(map[int][]github.IssueComment),
+		IssueLabelsAdded: []string{},
+		Reviews:          make(map[int][]github.Review),
+	}
+	cfg := &externalplugins.Configuration{}
+	foc := &fakeOwnersClient{
+		reviewers: []string{"collab1", "collab2"},
+		needsLgtm: 2,
+		reviewerGroups: []ownersclient.ReviewerGroup{
+			{Name: "sig/planner", Reviewers: []string{"collab1"}},
+			{Name: "sig/execution", Reviewers: []string{"collab2"}},
+		},
+	}
+
+	testcases := []struct {
+		reviewer string
+
+		expectReviewers []string
+		expectMissing   []string
+	}{
+		{reviewer: "collab1", expectReviewers: []string{"collab1"}, expectMissing: []string{"sig/execution"}},
+		{reviewer: "collab2", expectReviewers: []string{"collab1", "collab2"}},
+	}
+
+	for i, tc := range testcases {
 		e := &github.ReviewEvent{
-			Action: tc.action,
-			Review: github.Review{Body: tc.body, State: tc.state, HTMLURL: "<url>", User: github.User{Login: tc.reviewer}},
-			PullRequest: github.PullRequest{
-				User: github.User{Login: "author"},
-				Assignees: []github.User{
-					{Login: "collab1"},
-					{Login: "assignee1"}},
-				Number: 5},
-			Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			Action: github.ReviewActionSubmitted,
+			Review: github.Review{ID: i + 1, State: github.ReviewStateApproved,
+				User: github.User{Login: tc.reviewer}},
+			PullRequest: github.PullRequest{User: github.User{Login: "author"}, Number: 5},
+			Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		}
+		if err := HandlePullReviewEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+			t.Fatalf("For reviewer %s, didn't expect error from pull request review: %v", tc.reviewer, err)
+		}
+
+		// GitHub lists the review after it is submitted.
+		fc.Reviews[5] = append(fc.Reviews[5], e.Review)
+
+		notification := fc.IssueComments[5][len(fc.IssueComments[5])-1]
+		for _, reviewer := range foc.reviewers {
+			listed := strings.Contains(notification.Body, "- "+reviewer+"\n")
+			approved := sets.NewString(tc.expectReviewers...).Has(reviewer)
+			if listed != approved {
+				t.Errorf("Different reviewer %s: Got \"%v\" expected \"%v\"", reviewer, listed, approved)
+			}
 		}
-		if tc.currentLabel != "" {
-			fc.IssueLabelsAdded = append(fc.IssueLabelsAdded, "org/repo#5:"+tc.currentLabel)
+		for _, group := range foc.reviewerGroups {
+			listed := strings.Contains(notification.Body, "* `"+group.Name+"`")
+			missing := sets.NewString(tc.expectMissing...).Has(group.Name)
+			if listed != missing {
+				t.Errorf("Different missing area %s: Got \"%v\" expected \"%v\"", group.Name, listed, missing)
+			}
 		}
+	}
+}
 
-		cfg := &externalplugins.Configuration{}
-		cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
-			{
-				Repos:              []string{"org/repo"},
-				PullOwnersEndpoint: "https://fake/ti-community-bot",
+func TestLGTMWeightedApprovals(t *testing.T) {
+	fc := &fakegithub.FakeClient{
+		IssueComments:    make(map[int][]github.IssueComment),
+		IssueLabelsAdded: []string{},
+		Reviews:          make(map[int][]github.Review),
+	}
+	cfg := &externalplugins.Configuration{}
+	cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+		{
+			Repos:       []string{"org/repo"},
+			RoleWeights: map[string]int{ownersclient.RoleLeader: 2},
+			ApprovalRules: []externalplugins.LgtmApprovalRule{
+				{Name: "leaders", Role: ownersclient.RoleLeader, MinApprovals: 1},
 			},
+		},
+	}
+	foc := &fakeOwnersClient{
+		leaders:   []string{"leader1"},
+		reviewers: []string{"collab1", "leader1"},
+		needsLgtm: 3,
+	}
+
+	testcases := []struct {
+		reviewer string
+
+		expectLabel    string
+		expectProgress []string
+	}{
+		{
+			reviewer:       "collab1",
+			expectLabel:    lgtmOne,
+			expectProgress: []string{"* LGTM: 1/3\n", "* leaders: 0/1\n"},
+		},
+		{
+			reviewer:       "leader1",
+			expectLabel:    externalplugins.LgtmLabelPrefix + "3",
+			expectProgress: []string{"* LGTM: 3/3 (satisfied)\n", "* leaders: 1/1 (satisfied)\n"},
+		},
+	}
+
+	for i, tc := range testcases {
+		e := &github.ReviewEvent{
+			Action: github.ReviewActionSubmitted,
+			Review: github.Review{ID: i + 1, State: github.ReviewStateApproved,
+				User: github.User{Login: tc.reviewer}},
+			PullRequest: github.PullRequest{User: github.User{Login: "author"}, Number: 5},
+			Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+		}
+		fc.Reviews[5] = append(fc.Reviews[5], e.Review)
+		if err := HandlePullReviewEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+			t.Fatalf("For reviewer %s, didn't expect error from pull request review: %v", tc.reviewer, err)
 		}
 
-		foc := &fakeOwnersClient{
-			reviewers: []string{"collab1"},
-			needsLgtm: 2,
+		var lgtmLabels []string
+		labels, _ := fc.GetIssueLabels("org", "repo", 5)
+		for _, label := range labels {
+			if strings.HasPrefix(label.Name, externalplugins.LgtmLabelPrefix) {
+				lgtmLabels = append(lgtmLabels, label.Name)
+			}
+		}
+		if strings.Join(lgtmLabels, ",") != tc.expectLabel {
+			t.Errorf("For reviewer %s, different labels: Got \"%v\" expected \"%v\"",
+				tc.reviewer, lgtmLabels, tc.expectLabel)
 		}
 
-		if err := HandlePullReviewEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
-			t.Errorf("For case %s, didn't expect error from pull request review: %v", tc.name, err)
-			continue
+		notification := fc.IssueComments[5][len(fc.IssueComments[5])-1]
+		for _, progress := range tc.expectProgress {
+			if !strings.Contains(notification.Body, progress) {
+				t.Errorf("For reviewer %s, expected progress %q in the notification %q",
+					tc.reviewer, progress, notification.Body)
+			}
 		}
+	}
+}
 
-		if tc.shouldToggle {
-			if tc.currentLabel != "" {
-				if len(fc.IssueLabelsRemoved) != 1 {
-					t.Error("should have removed " + lgtmOne + ".")
-				} else if len(fc.IssueLabelsAdded) != 2 && !tc.isCancel {
-					t.Error("should have added " + lgtmTwo + ".")
-				}
-			} else {
-				if len(fc.IssueLabelsAdded) == 0 {
-					t.Error("should have added " + lgtmOne + ".")
-				} else if len(fc.IssueLabelsRemoved) > 0 {
-					t.Error("should not have removed " + lgtmOne)
+func TestHandleLgtmCommand(t *testing.T) {
+	now := time.Now()
+	lgtmByCollab1 := github.IssueComment{ID: 1, Body: "/lgtm", User: github.User{Login: "collab1"}, CreatedAt: now}
+
+	testcases := []struct {
+		name      string
+		body      string
+		commenter string
+		allowed   bool
+		comments  []github.IssueComment
+
+		expectLabel   string
+		expectComment string
+	}{
+		{
+			name:      "lgtm commands are not allowed",
+			body:      "/lgtm",
+			commenter: "collab1",
+		},
+		{
+			name:        "lgtm by a reviewer",
+			body:        "/lgtm",
+			commenter:   "collab1",
+			allowed:     true,
+			expectLabel: lgtmOne,
+		},
+		{
+			name:          "lgtm by a non-reviewer",
+			body:          "/lgtm",
+			commenter:     "collab3",
+			allowed:       true,
+			expectComment: "The bot only counts approvals from reviewers",
+		},
+		{
+			name:          "lgtm by the author",
+			body:          "/lgtm",
+			commenter:     "author",
+			allowed:       true,
+			expectComment: "you cannot LGTM your own PR.",
+		},
+		{
+			name:        "lgtm by another reviewer",
+			body:        "/lgtm",
+			commenter:   "collab2",
+			allowed:     true,
+			comments:    []github.IssueComment{lgtmByCollab1},
+			expectLabel: lgtmTwo,
+		},
+		{
+			name:      "lgtm cancel",
+			body:      "/lgtm cancel",
+			commenter: "collab1",
+			allowed:   true,
+			comments:  []github.IssueComment{lgtmByCollab1},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{5: tc.comments},
+				PullRequests: map[int]*github.PullRequest{
+					5: {Number: 5, User: github.User{Login: "author"}, Base: github.PullRequestBranch{Ref: "master"}},
+				},
+				Reviews: make(map[int][]github.Review),
+			}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					Number:      5,
+					State:       "open",
+					User:        github.User{Login: "author"},
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					ID:        2,
+					Body:      tc.body,
+					User:      github.User{Login: tc.commenter},
+					CreatedAt: now.Add(time.Minute),
+				},
+				Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+				{
+					Repos:             []string{"org/repo"},
+					AllowLgtmCommands: tc.allowed,
+				},
+			}
+			foc := &fakeOwnersClient{reviewers: []string{"collab1", "collab2"}, needsLgtm: 2}
+
+			err := HandleIssueCommentEvent(fc, e, cfg, foc, false, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error from lgtm command: %v", err)
+			}
+
+			var lgtmLabels []string
+			labels, _ := fc.GetIssueLabels("org", "repo", 5)
+			for _, label := range labels {
+				if strings.HasPrefix(label.Name, externalplugins.LgtmLabelPrefix) {
+					lgtmLabels = append(lgtmLabels, label.Name)
 				}
 			}
-		} else if len(fc.IssueLabelsRemoved) > 0 {
-			t.Error("should not have removed " + lgtmOne + ".")
-		}
+			if strings.Join(lgtmLabels, ",") != tc.expectLabel {
+				t.Errorf("Different labels: Got \"%v\" expected \"%v\"", lgtmLabels, tc.expectLabel)
+			}
+			if len(tc.expectComment) != 0 && !strings.Contains(strings.Join(fc.IssueCommentsAdded, "\n"), tc.expectComment) {
+				t.Errorf("Expected comment %q, got %v", tc.expectComment, fc.IssueCommentsAdded)
+			}
+		})
 	}
 }
 
@@ -292,7 +576,7 @@ func TestHandlePullRequest(t *testing.T) {
 			PRProcessLink:   "https://prProcessLink",
 		}
 
-		err := HandlePullRequestEvent(fc, &tc.event, cfg, logrus.WithField("plugin", PluginName))
+		err := HandlePullRequestEvent(fc, &tc.event, cfg, &fakeOwnersClient{}, logrus.WithField("plugin", PluginName))
 		if err != nil {
 			t.Errorf("For case %s, didn't expect error: %v", tc.name, err)
 		}
@@ -307,74 +591,220 @@ func TestHandlePullRequest(t *testing.T) {
 	}
 }
 
-func TestGetCurrentAndNextLabel(t *testing.T) {
-	var testcases = []struct {
-		name               string
-		labels             []github.Label
-		needsLgtm          int
-		expectCurrentLabel string
-		expectNextLabel    string
+func TestHandlePullRequestSynchronize(t *testing.T) {
+	const headSHA = "0bd3ed50c88cd53a09316bf7a298f900e9371652"
+	now := time.Now()
+	changes := []github.PullRequestChange{{Filename: "a.go", Status: "modified", Additions: 1, Patch: "@@ -1 +1,2 @@\n+a"}}
+	approvedByCollab1 := github.Review{ID: 1, State: github.ReviewStateApproved, User: github.User{Login: "collab1"},
+		SubmittedAt: now.Add(-time.Hour)}
+	approvedByCollab2 := github.Review{ID: 2, State: github.ReviewStateApproved, User: github.User{Login: "collab2"},
+		SubmittedAt: now.Add(-time.Minute)}
+	notification := func(reviewers []string, reset *externalplugins.ApprovalsReset, patchID string) github.IssueComment {
+		msg, err := getMessage(reviewers, nil, nil, reset, patchID, "", "", "", "org", "repo")
+		if err != nil {
+			t.Fatalf("failed to get message: %v", err)
+		}
+		return github.IssueComment{ID: 1, User: github.User{Login: fakegithub.Bot}, Body: *msg}
+	}
+
+	testcases := []struct {
+		name            string
+		approvalsOnPush string
+		reviews         []github.Review
+		notification    *github.IssueComment
+		currentLabel    string
+
+		expectLabel       string
+		expectInvalidated []string
 	}{
 		{
-			name:               "Current no LGTM, needs 1 LGTM",
-			labels:             []github.Label{},
-			needsLgtm:          1,
-			expectCurrentLabel: "",
-			expectNextLabel:    lgtmOne,
+			name:            "keep approvals",
+			approvalsOnPush: externalplugins.LgtmApprovalsOnPushKeep,
+			reviews:         []github.Review{approvedByCollab1},
+			currentLabel:    lgtmOne,
+			expectLabel:     lgtmOne,
 		},
 		{
-			name:               "Current no LGTM, needs 2 LGTM",
-			labels:             []github.Label{},
-			needsLgtm:          2,
-			expectCurrentLabel: "",
-			expectNextLabel:    lgtmOne,
+			name:              "reset approvals",
+			approvalsOnPush:   externalplugins.LgtmApprovalsOnPushReset,
+			reviews:           []github.Review{approvedByCollab1, approvedByCollab2},
+			currentLabel:      lgtmTwo,
+			expectInvalidated: []string{"collab1", "collab2"},
 		},
 		{
-			name: "Current LGT1, needs 1 LGTM",
-			labels: []github.Label{
-				{
-					Name: lgtmOne,
-				},
-			},
-			needsLgtm:          1,
-			expectCurrentLabel: lgtmOne,
-			expectNextLabel:    "",
+			name:            "approvals after the last reset",
+			approvalsOnPush: externalplugins.LgtmApprovalsOnPushReset,
+			reviews:         []github.Review{approvedByCollab1, approvedByCollab2},
+			notification: func() *github.IssueComment {
+				reset := &externalplugins.ApprovalsReset{Commit: "abc", Time: now.Add(-30 * time.Minute),
+					Reviewers: []string{"collab1"}}
+				n := notification([]string{"collab2"}, reset, "")
+				return &n
+			}(),
+			currentLabel:      lgtmOne,
+			expectInvalidated: []string{"collab2"},
 		},
 		{
-			name: "Current LGT1, needs 2 LGTM",
-			labels: []github.Label{
-				{
-					Name: lgtmOne,
-				},
-			},
-			needsLgtm:          2,
-			expectCurrentLabel: lgtmOne,
-			expectNextLabel:    lgtmTwo,
+			name:            "rebase without changes",
+			approvalsOnPush: externalplugins.LgtmApprovalsOnPushResetOnChange,
+			reviews:         []github.Review{approvedByCollab1},
+			notification: func() *github.IssueComment {
+				n := notification([]string{"collab1"}, nil, getPatchID([]github.PullRequestChange{
+					{Filename: "a.go", Status: "modified", Additions: 1, Patch: "@@ -10 +10,2 @@\n+a"},
+				}))
+				return &n
+			}(),
+			currentLabel: lgtmOne,
+			expectLabel:  lgtmOne,
 		},
 		{
-			name: "Current LGT2, needs 2 LGTM",
-			labels: []github.Label{
-				{
-					Name: lgtmTwo,
-				},
-			},
-			needsLgtm:          2,
-			expectCurrentLabel: lgtmTwo,
-			expectNextLabel:    "",
+			name:            "changes changed",
+			approvalsOnPush: externalplugins.LgtmApprovalsOnPushResetOnChange,
+			reviews:         []github.Review{approvedByCollab1},
+			notification: func() *github.IssueComment {
+				n := notification([]string{"collab1"}, nil, getPatchID([]github.PullRequestChange{
+					{Filename: "a.go", Status: "modified", Additions: 1, Patch: "@@ -1 +1,2 @@\n+b"},
+				}))
+				return &n
+			}(),
+			currentLabel:      lgtmOne,
+			expectInvalidated: []string{"collab1"},
+		},
+		{
+			name:              "changes not recorded",
+			approvalsOnPush:   externalplugins.LgtmApprovalsOnPushResetOnChange,
+			reviews:           []github.Review{approvedByCollab1},
+			currentLabel:      lgtmOne,
+			expectInvalidated: []string{"collab1"},
 		},
 	}
 
 	for _, testcase := range testcases {
 		tc := testcase
 		t.Run(tc.name, func(t *testing.T) {
-			currentLabel, nextLabel := getCurrentAndNextLabel(externalplugins.LgtmLabelPrefix, tc.labels, tc.needsLgtm)
+			fc := &fakegithub.FakeClient{
+				IssueComments:       make(map[int][]github.IssueComment),
+				IssueLabelsExisting: []string{"org/repo#5:" + tc.currentLabel},
+				Reviews:             map[int][]github.Review{5: tc.reviews},
+				PullRequestChanges:  map[int][]github.PullRequestChange{5: changes},
+			}
+			if tc.notification != nil {
+				fc.IssueComments[5] = []github.IssueComment{*tc.notification}
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+				{
+					Repos:           []string{"org/repo"},
+					ApprovalsOnPush: tc.approvalsOnPush,
+				},
+			}
+			foc := &fakeOwnersClient{reviewers: []string{"collab1", "collab2"}, needsLgtm: 2}
+			e := &github.PullRequestEvent{
+				Action: github.PullRequestActionSynchronize,
+				PullRequest: github.PullRequest{
+					Number: 5,
+					Base:   github.PullRequestBranch{Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}},
+					Head:   github.PullRequestBranch{SHA: headSHA},
+				},
+			}
 
-			if currentLabel != tc.expectCurrentLabel {
-				t.Fatalf("currentLabel mismatch: got %v, want %v", currentLabel, tc.expectCurrentLabel)
+			if err := HandlePullRequestEvent(fc, e, cfg, foc, logrus.WithField("plugin", PluginName)); err != nil {
+				t.Fatalf("didn't expect error from pull request: %v", err)
+			}
+
+			var lgtmLabels []string
+			labels, _ := fc.GetIssueLabels("org", "repo", 5)
+			for _, label := range labels {
+				if strings.HasPrefix(label.Name, externalplugins.LgtmLabelPrefix) {
+					lgtmLabels = append(lgtmLabels, label.Name)
+				}
+			}
+			if strings.Join(lgtmLabels, ",") != tc.expectLabel {
+				t.Errorf("Different labels: Got \"%v\" expected \"%v\"", lgtmLabels, tc.expectLabel)
 			}
 
-			if nextLabel != tc.expectNextLabel {
-				t.Fatalf("nextLabel mismatch: got %v, want %v", nextLabel, tc.expectNextLabel)
+			var invalidated []string
+			if comments := fc.IssueComments[5]; len(tc.expectInvalidated) != 0 && len(comments) != 0 {
+				latest := comments[len(comments)-1]
+				if !strings.Contains(latest.Body, "invalidated by commit "+headSHA) {
+					t.Errorf("Expected the invalidating commit in the notification, got %q", latest.Body)
+				}
+				if reset := externalplugins.ApprovalsResetFromNotification(&latest); reset != nil {
+					invalidated = reset.Reviewers
+				}
+			}
+			if strings.Join(invalidated, ",") != strings.Join(tc.expectInvalidated, ",") {
+				t.Errorf("Different invalidated: Got \"%v\" expected \"%v\"", invalidated, tc.expectInvalidated)
+			}
+		})
+	}
+}
+
+func TestGetPatchID(t *testing.T) {
+	change := github.PullRequestChange{Filename: "a.go", Status: "modified", Additions: 1,
+		Patch: "@@ -1,3 +1,4 @@\n a\n+b\n c"}
+	rebased := change
+	rebased.Patch = "@@ -11,3 +11,4 @@\n a\n+b\n c"
+	changed := change
+	changed.Patch = "@@ -1,3 +1,4 @@\n a\n+d\n c"
+	renamed := change
+	renamed.Filename = "b.go"
+
+	if getPatchID([]github.PullRequestChange{change}) != getPatchID([]github.PullRequestChange{rebased}) {
+		t.Errorf("Expected the same patch ID for the rebased changes")
+	}
+	for _, other := range []github.PullRequestChange{changed, renamed} {
+		if getPatchID([]github.PullRequestChange{change}) == getPatchID([]github.PullRequestChange{other}) {
+			t.Errorf("Expected different patch IDs for %v", other)
+		}
+	}
+}
+
+func TestGetLgtmLabel(t *testing.T) {
+	var testcases = []struct {
+		name        string
+		approvals   int
+		needsLgtm   int
+		expectLabel string
+	}{
+		{
+			name:        "No approvals, needs 2 LGTM",
+			approvals:   0,
+			needsLgtm:   2,
+			expectLabel: "",
+		},
+		{
+			name:        "1 approval, needs 2 LGTM",
+			approvals:   1,
+			needsLgtm:   2,
+			expectLabel: lgtmOne,
+		},
+		{
+			name:        "2 approvals, needs 2 LGTM",
+			approvals:   2,
+			needsLgtm:   2,
+			expectLabel: lgtmTwo,
+		},
+		{
+			name:        "3 approvals, needs 2 LGTM",
+			approvals:   3,
+			needsLgtm:   2,
+			expectLabel: lgtmTwo,
+		},
+		{
+			name:        "2 approvals, needs 1 LGTM",
+			approvals:   2,
+			needsLgtm:   1,
+			expectLabel: lgtmOne,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			label := getLgtmLabel(externalplugins.LgtmLabelPrefix, tc.approvals, tc.needsLgtm)
+			if label != tc.expectLabel {
+				t.Fatalf("label mismatch: got %v, want %v", label, tc.expectLabel)
 			}
 		})
 	}
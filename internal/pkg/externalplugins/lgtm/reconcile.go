@@ -0,0 +1,275 @@
+package lgtm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/plugins"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+)
+
+const searchQueryPrefix = "archived:false is:pr is:open sort:created-asc"
+
+// refreshRe is the regex that matches refresh comments.
+var refreshRe = regexp.MustCompile(`(?mi)^/refresh\s*$`)
+
+var labelChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tichi_lgtm_reconciled_label_changes_total",
+	Help: "The number of the label changes made by the lgtm reconciler.",
+}, []string{"org", "repo", "dry_run"})
+
+func init() {
+	prometheus.MustRegister(labelChanges)
+}
+
+// See: https://developer.github.com/v4/object/pullrequest/.
+type pullRequest struct {
+	Number githubql.Int
+	Author struct {
+		Login githubql.String
+	}
+	BaseRef struct {
+		Name githubql.String
+	}
+}
+
+type searchQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Search struct {
+		PageInfo struct {
+			HasNextPage githubql.Boolean
+			EndCursor   githubql.String
+		}
+		Nodes []struct {
+			PullRequest pullRequest `graphql:"... on PullRequest"`
+		}
+	} `graphql:"search(type: ISSUE, first: 100, after: $searchCursor, query: $query)"`
+}
+
+// ownersInvalidator invalidates the cached owners of the PR.
+type ownersInvalidator interface {
+	Invalidate(org, repo string, number int)
+}
+
+// reportingClient records the changes made to the labels and the review notifications of the PR,
+// and only records them without making them in dry-run mode.
+type reportingClient struct {
+	githubClient
+	dryRun  bool
+	changes []string
+}
+
+func (c *reportingClient) AddLabel(org, repo string, number int, label string) error {
+	c.changes = append(c.changes, fmt.Sprintf("add the `%s` label", label))
+	labelChanges.WithLabelValues(org, repo, fmt.Sprint(c.dryRun)).Inc()
+	if c.dryRun {
+		return nil
+	}
+	return c.githubClient.AddLabel(org, repo, number, label)
+}
+
+func (c *reportingClient) RemoveLabel(org, repo string, number int, label string) error {
+	c.changes = append(c.changes, fmt.Sprintf("remove the `%s` label", label))
+	labelChanges.WithLabelValues(org, repo, fmt.Sprint(c.dryRun)).Inc()
+	if c.dryRun {
+		return nil
+	}
+	return c.githubClient.RemoveLabel(org, repo, number, label)
+}
+
+func (c *reportingClient) CreateComment(org, repo string, number int, comment string) error {
+	c.changes = append(c.changes, "update the review notification")
+	if c.dryRun {
+		return nil
+	}
+	return c.githubClient.CreateComment(org, repo, number, comment)
+}
+
+func (c *reportingClient) DeleteComment(org, repo string, id int) error {
+	if c.dryRun {
+		return nil
+	}
+	return c.githubClient.DeleteComment(org, repo, id)
+}
+
+// handleRefresh reconciles the labels of the PR for the /refresh command.
+func handleRefresh(gc githubClient, ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration,
+	ol ownersclient.OwnersLoader, dryRun bool, log *logrus.Entry) error {
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	// Refresh with the latest owners instead of the cached owners.
+	if invalidator, ok := ol.(ownersInvalidator); ok {
+		invalidator.Invalidate(org, repo, number)
+	}
+
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	opts := cfg.LgtmForBranch(org, repo, pr.Base.Ref)
+	changes, err := reconcile(gc, cfg, opts, org, repo, number, pr.User.Login, ol, dryRun, log)
+	if err != nil {
+		return err
+	}
+
+	var resp string
+	switch {
+	case len(changes) == 0:
+		resp = "The labels of this pull request are up to date."
+	case dryRun:
+		resp = "The following changes are needed for this pull request, but they are not made in dry-run mode:\n\n"
+		resp += "- " + strings.Join(changes, "\n- ")
+	default:
+		resp = "The following changes have been made to this pull request:\n\n"
+		resp += "- " + strings.Join(changes, "\n- ")
+	}
+	log.Infof("Reply /refresh request with comment: \"%s\"", resp)
+	return gc.CreateComment(org, repo, number,
+		tiexternalplugins.FormatResponseRaw(ice.Comment.Body, ice.Comment.HTMLURL, ice.Comment.User.Login, resp))
+}
+
+// HandleAll reconciles the LGTM labels and the merge labels of all the open PRs in the repos that enabled
+// this plugin with their reviews and current owners.
+func HandleAll(log *logrus.Entry, gc githubClient, config *plugins.Configuration,
+	externalConfig *tiexternalplugins.Configuration, ol ownersclient.OwnersLoader, dryRun bool) error {
+	log.Info("Reconciling all PRs.")
+	_, repos := config.EnabledReposForExternalPlugin(PluginName)
+	if len(repos) == 0 {
+		log.Warnf("No repos have been configured for the %s plugin", PluginName)
+		return nil
+	}
+
+	// Do _not_ parallelize this. It will trigger GitHub's abuse detection.
+	for _, repo := range repos {
+		slashSplit := strings.Split(repo, "/")
+		if n := len(slashSplit); n != 2 {
+			log.WithField("repo", repo).Warn("Found repo that was not in org/repo format, ignoring...")
+			continue
+		}
+		org := slashSplit[0]
+		repoName := slashSplit[1]
+		query := fmt.Sprintf("%s repo:\"%s\"", searchQueryPrefix, repo)
+
+		prs, err := search(context.Background(), log, gc, query)
+		if err != nil {
+			log.WithError(err).Error("Error was encountered when querying GitHub, " +
+				"but the remaining repositories will be processed anyway.")
+			continue
+		}
+
+		log.Infof("Considering %d PRs of %s.", len(prs), repo)
+		for _, pr := range prs {
+			num := int(pr.Number)
+			base := string(pr.BaseRef.Name)
+			l := log.WithFields(logrus.Fields{
+				"org":  org,
+				"repo": repoName,
+				"pr":   num,
+				"base": base,
+			})
+
+			opts := externalConfig.LgtmForBranch(org, repoName, base)
+			changes, err := reconcile(gc, externalConfig, opts, org, repoName, num, string(pr.Author.Login), ol, dryRun, l)
+			if err != nil {
+				l.WithError(err).Error("The PR reconciliation failed, but the remaining PRs will be processed anyway.")
+				continue
+			}
+			if len(changes) != 0 {
+				l.WithField("dry-run", dryRun).Infof("Reconciled the PR: %s.", strings.Join(changes, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// StartPeriodicReconcile reconciles all PRs every period.
+func StartPeriodicReconcile(log *logrus.Entry, gc githubClient, pa *plugins.ConfigAgent,
+	epa *tiexternalplugins.ConfigAgent, ol ownersclient.OwnersLoader, period time.Duration, dryRun bool) {
+	interrupts.TickLiteral(func() {
+		start := time.Now()
+		if err := HandleAll(log, gc, pa.Config(), epa.Config(), ol, dryRun); err != nil {
+			log.WithError(err).Error("Error during periodic reconciliation of all PRs.")
+		}
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Periodic reconciliation complete.")
+	}, period)
+}
+
+// reconcile reconciles the LGTM label, the merge label and the review notification of the PR with its reviews
+// and current owners, it returns the changes made to the PR.
+func reconcile(gc githubClient, config *tiexternalplugins.Configuration, opts *tiexternalplugins.TiCommunityLgtm,
+	org, repo string, number int, author string, ol ownersclient.OwnersLoader, dryRun bool,
+	log *logrus.Entry) ([]string, error) {
+	owners, err := ol.LoadOwners(opts.PullOwnersEndpoint, org, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owners info for %s/%s#%d: %v", org, repo, number, err)
+	}
+
+	rc := &reportingClient{githubClient: gc, dryRun: dryRun}
+	state, err := loadApprovalState(rc, opts, org, repo, number, author, owners, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := reconcileApprovals(rc, config, opts, org, repo, number, owners, state, log); err != nil {
+		return rc.changes, err
+	}
+
+	// The merge label is removed if the PR no longer has the approvals required by the current owners.
+	satisfied := opts.WeightedApprovals(owners, state.approvers) >= owners.NeedsLgtm &&
+		len(owners.UncoveredGroups(state.approvers)) == 0
+	for _, progress := range opts.ApprovalRulesProgress(owners, state.approvers) {
+		satisfied = satisfied && progress.Satisfied()
+	}
+	for _, label := range state.labels {
+		if label.Name == tiexternalplugins.CanMergeLabel && !satisfied {
+			log.Infof("Removing the %s label.", tiexternalplugins.CanMergeLabel)
+			if err := rc.RemoveLabel(org, repo, number, tiexternalplugins.CanMergeLabel); err != nil {
+				return rc.changes, err
+			}
+		}
+	}
+
+	return rc.changes, nil
+}
+
+func search(ctx context.Context, log *logrus.Entry, gc githubClient, q string) ([]pullRequest, error) {
+	var ret []pullRequest
+	vars := map[string]interface{}{
+		"query":        githubql.String(q),
+		"searchCursor": (*githubql.String)(nil),
+	}
+	var totalCost int
+	var remaining int
+	for {
+		sq := searchQuery{}
+		if err := gc.Query(ctx, &sq, vars); err != nil {
+			return nil, err
+		}
+		totalCost += int(sq.RateLimit.Cost)
+		remaining = int(sq.RateLimit.Remaining)
+		for _, n := range sq.Search.Nodes {
+			ret = append(ret, n.PullRequest)
+		}
+		if !sq.Search.PageInfo.HasNextPage {
+			break
+		}
+		vars["searchCursor"] = githubql.NewString(sq.Search.PageInfo.EndCursor)
+	}
+	log.Infof("Search for query \"%s\" cost %d point(s). %d remaining.", q, totalCost, remaining)
+	tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
+	return ret, nil
+}
@@ -0,0 +1,262 @@
+package lgtm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+type fakeSearchClient struct {
+	*fakegithub.FakeClient
+	prs []pullRequest
+}
+
+func (f *fakeSearchClient) Query(_ context.Context, q interface{}, _ map[string]interface{}) error {
+	query, ok := q.(*searchQuery)
+	if !ok {
+		return errors.New("invalid query format")
+	}
+	for _, pr := range f.prs {
+		query.Search.Nodes = append(query.Search.Nodes, struct {
+			PullRequest pullRequest `graphql:"... on PullRequest"`
+		}{pr})
+	}
+	return nil
+}
+
+func TestHandleAll(t *testing.T) {
+	approvedByCollab1 := github.Review{ID: 1, State: github.ReviewStateApproved, User: github.User{Login: "collab1"}}
+	approvedByCollab2 := github.Review{ID: 2, State: github.ReviewStateApproved, User: github.User{Login: "collab2"}}
+
+	testcases := []struct {
+		name          string
+		reviews       []github.Review
+		reviewers     []string
+		needsLgtm     int
+		currentLabels []string
+		dryRun        bool
+
+		expectLabels []string
+	}{
+		{
+			name:          "labels are up to date",
+			reviews:       []github.Review{approvedByCollab1},
+			reviewers:     []string{"collab1", "collab2"},
+			needsLgtm:     2,
+			currentLabels: []string{lgtmOne},
+			expectLabels:  []string{lgtmOne},
+		},
+		{
+			name:          "missed approval",
+			reviews:       []github.Review{approvedByCollab1, approvedByCollab2},
+			reviewers:     []string{"collab1", "collab2"},
+			needsLgtm:     2,
+			currentLabels: []string{lgtmOne},
+			expectLabels:  []string{lgtmTwo},
+		},
+		{
+			name:          "needed LGTM number lowered",
+			reviews:       []github.Review{approvedByCollab1, approvedByCollab2},
+			reviewers:     []string{"collab1", "collab2"},
+			needsLgtm:     1,
+			currentLabels: []string{lgtmTwo, externalplugins.CanMergeLabel},
+			expectLabels:  []string{lgtmOne, externalplugins.CanMergeLabel},
+		},
+		{
+			name:          "approved reviewer removed",
+			reviews:       []github.Review{approvedByCollab1, approvedByCollab2},
+			reviewers:     []string{"collab1"},
+			needsLgtm:     2,
+			currentLabels: []string{lgtmTwo, externalplugins.CanMergeLabel},
+			expectLabels:  []string{lgtmOne},
+		},
+		{
+			name:          "dry run",
+			reviews:       []github.Review{approvedByCollab1, approvedByCollab2},
+			reviewers:     []string{"collab1"},
+			needsLgtm:     2,
+			currentLabels: []string{lgtmTwo, externalplugins.CanMergeLabel},
+			dryRun:        true,
+			expectLabels:  []string{lgtmTwo, externalplugins.CanMergeLabel},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			var existingLabels []string
+			for _, label := range tc.currentLabels {
+				existingLabels = append(existingLabels, "org/repo#5:"+label)
+			}
+			pr := pullRequest{Number: githubql.Int(5)}
+			pr.BaseRef.Name = "master"
+			fc := &fakeSearchClient{
+				FakeClient: &fakegithub.FakeClient{
+					IssueComments:       make(map[int][]github.IssueComment),
+					IssueLabelsExisting: existingLabels,
+					Reviews:             map[int][]github.Review{5: tc.reviews},
+				},
+				prs: []pullRequest{pr},
+			}
+			cfg := &plugins.Configuration{
+				ExternalPlugins: map[string][]plugins.ExternalPlugin{"org/repo": {{Name: PluginName}}},
+			}
+			externalConfig := &externalplugins.Configuration{}
+			externalConfig.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+				{
+					Repos: []string{"org/repo"},
+				},
+			}
+			foc := &fakeOwnersClient{reviewers: tc.reviewers, needsLgtm: tc.needsLgtm}
+
+			err := HandleAll(logrus.WithField("plugin", PluginName), fc, cfg, externalConfig, foc, tc.dryRun)
+			if err != nil {
+				t.Fatalf("Unexpected error handling all prs: %v.", err)
+			}
+
+			labels, _ := fc.GetIssueLabels("org", "repo", 5)
+			var labelNames []string
+			for _, label := range labels {
+				labelNames = append(labelNames, label.Name)
+			}
+			sort.Strings(labelNames)
+			sort.Strings(tc.expectLabels)
+			if strings.Join(labelNames, ",") != strings.Join(tc.expectLabels, ",") {
+				t.Errorf("Different labels: Got \"%v\" expected \"%v\"", labelNames, tc.expectLabels)
+			}
+		})
+	}
+}
+
+func TestHandleRefreshComment(t *testing.T) {
+	approvedByCollab1 := github.Review{ID: 1, State: github.ReviewStateApproved, User: github.User{Login: "collab1"}}
+	ownersURL := fmt.Sprintf(ownersclient.OwnersURLFmt, "", "org", "repo", 5)
+	notification, err := getMessage([]string{"collab1"}, nil, nil, nil, "", "", "", ownersURL, "org", "repo")
+	if err != nil {
+		t.Fatalf("failed to get message: %v", err)
+	}
+
+	testcases := []struct {
+		name         string
+		body         string
+		currentLabel string
+		dryRun       bool
+		base         string
+		branches     map[string]externalplugins.TiCommunityLgtm
+
+		expectLabel   string
+		expectComment string
+	}{
+		{
+			name:         "not a refresh command",
+			body:         "/refreshed",
+			currentLabel: lgtmTwo,
+			expectLabel:  lgtmTwo,
+		},
+		{
+			name:          "labels are up to date",
+			body:          "/refresh",
+			currentLabel:  lgtmOne,
+			expectLabel:   lgtmOne,
+			expectComment: "The labels of this pull request are up to date.",
+		},
+		{
+			name:          "refresh labels",
+			body:          "/refresh",
+			currentLabel:  lgtmTwo,
+			expectLabel:   lgtmOne,
+			expectComment: "- remove the `status/LGT2` label\n- add the `status/LGT1` label",
+		},
+		{
+			name:          "refresh labels in dry-run mode",
+			body:          "/refresh",
+			currentLabel:  lgtmTwo,
+			dryRun:        true,
+			expectLabel:   lgtmTwo,
+			expectComment: "they are not made in dry-run mode",
+		},
+		{
+			name:         "refresh labels with the branch config",
+			body:         "/refresh",
+			currentLabel: lgtmTwo,
+			base:         "release-5.0",
+			branches: map[string]externalplugins.TiCommunityLgtm{
+				"release-*": {
+					RoleWeights: map[string]int{"reviewer": 2},
+				},
+			},
+			expectLabel:   lgtmTwo,
+			expectComment: "The following changes have been made to this pull request:\n\n" +
+				"- update the review notification\n",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{
+					5: {{ID: 1, User: github.User{Login: fakegithub.Bot}, Body: *notification}},
+				},
+				IssueLabelsExisting: []string{"org/repo#5:" + tc.currentLabel},
+				Reviews:             map[int][]github.Review{5: {approvedByCollab1}},
+				PullRequests: map[int]*github.PullRequest{
+					5: {Number: 5, Base: github.PullRequestBranch{Ref: tc.base}, User: github.User{Login: "author"}},
+				},
+			}
+			e := &github.IssueCommentEvent{
+				Action: github.IssueCommentActionCreated,
+				Issue: github.Issue{
+					Number:      5,
+					State:       "open",
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{Body: tc.body, User: github.User{Login: "author"}},
+				Repo:    github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+				{
+					Repos:    []string{"org/repo"},
+					Branches: tc.branches,
+				},
+			}
+			foc := &fakeOwnersClient{reviewers: []string{"collab1"}, needsLgtm: 2}
+
+			err := HandleIssueCommentEvent(fc, e, cfg, foc, tc.dryRun, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error from refresh: %v", err)
+			}
+
+			var lgtmLabels []string
+			labels, _ := fc.GetIssueLabels("org", "repo", 5)
+			for _, label := range labels {
+				if strings.HasPrefix(label.Name, externalplugins.LgtmLabelPrefix) {
+					lgtmLabels = append(lgtmLabels, label.Name)
+				}
+			}
+			if strings.Join(lgtmLabels, ",") != tc.expectLabel {
+				t.Errorf("Different labels: Got \"%v\" expected \"%v\"", lgtmLabels, tc.expectLabel)
+			}
+
+			comments := strings.Join(fc.IssueCommentsAdded, "\n")
+			if len(tc.expectComment) == 0 && len(fc.IssueCommentsAdded) != 0 {
+				t.Errorf("Unexpected comments %v", fc.IssueCommentsAdded)
+			}
+			if !strings.Contains(comments, tc.expectComment) {
+				t.Errorf("Expected comment %q, got %v", tc.expectComment, fc.IssueCommentsAdded)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package externalplugins
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/test-infra/prow/plugins"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// The severities of the lint problems.
+const (
+	// ErrorSeverity means the configuration will be rejected by the plugins.
+	ErrorSeverity = "error"
+	// WarningSeverity means the configuration is valid, but it may not work as expected.
+	WarningSeverity = "warning"
+)
+
+// yamlErrorLineRegex matches the line number of the YAML syntax error.
+var yamlErrorLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// Problem is a problem found in the configuration file by Lint.
+type Problem struct {
+	// Severity specifies the severity of the problem, it is either "error" or "warning".
+	Severity string `json:"severity"`
+	// Line specifies the line of the problem in the configuration file, it is 0 if the problem has no position.
+	Line int `json:"line,omitempty"`
+	// Column specifies the column of the problem in the configuration file.
+	Column int `json:"column,omitempty"`
+	// Path specifies the path of the field which has the problem, such as "ti-community-lgtm[0].repos[1]".
+	Path string `json:"path,omitempty"`
+	// Message specifies the description of the problem.
+	Message string `json:"message"`
+}
+
+// Lint checks the whole configuration file and returns all the problems found, ordered by their positions.
+// Besides the errors reported by Validate, it reports the unknown fields. If the prow plugins config is
+// not nil, it also warns about the repos which are configured but the plugin is not enabled for,
+// and the repos which enable the plugin but are not configured.
+func Lint(b []byte, pc *plugins.Configuration) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		problem := Problem{Severity: ErrorSeverity, Message: err.Error()}
+		if matches := yamlErrorLineRegex.FindStringSubmatch(err.Error()); matches != nil {
+			problem.Line, _ = strconv.Atoi(matches[1])
+		}
+		return []Problem{problem}
+	}
+	if len(root.Content) != 0 {
+		root = *root.Content[0]
+	}
+
+	var problems []Problem
+	for _, e := range unknownFieldErrors(&root, reflect.TypeOf(Configuration{}), nil) {
+		problems = append(problems, newProblem(&root, ErrorSeverity, e))
+	}
+
+	c := &Configuration{}
+	if err := sigsyaml.Unmarshal(b, c); err != nil {
+		return append(problems, Problem{Severity: ErrorSeverity, Message: err.Error()})
+	}
+	c.setDefaults()
+
+	for _, e := range c.validate() {
+		problems = append(problems, newProblem(&root, ErrorSeverity, e))
+	}
+	if pc != nil {
+		for _, e := range c.enabledRepoErrors(pc) {
+			problems = append(problems, newProblem(&root, WarningSeverity, e))
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// newProblem creates a problem for the error, the position is the node located by the path of the error.
+func newProblem(root *yaml.Node, severity string, e configError) Problem {
+	problem := Problem{
+		Severity: severity,
+		Path:     e.path.String(),
+		Message:  e.err.Error(),
+	}
+	if node := locate(root, e.path); node != nil {
+		problem.Line = node.Line
+		problem.Column = node.Column
+	}
+	return problem
+}
+
+// locate returns the node of the field located by path. For a field or a map key, the key node is returned.
+// If the field does not exist in the file, the node of its closest parent is returned.
+func locate(root *yaml.Node, path fieldPath) *yaml.Node {
+	node, located := root, root
+	for _, element := range path {
+		node = resolveAlias(node)
+		var next, position *yaml.Node
+		switch e := element.(type) {
+		case int:
+			if node.Kind == yaml.SequenceNode && e < len(node.Content) {
+				next, position = node.Content[e], node.Content[e]
+			}
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == e {
+						next, position = node.Content[i+1], node.Content[i]
+						break
+					}
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		node, located = next, position
+	}
+	return located
+}
+
+// resolveAlias returns the node which the alias node refers to.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// unknownFieldErrors returns the errors of the fields in the node which are not defined by the type,
+// for example, the typo "exclude_labels" of the cherrypicker field "excludeLabels".
+func unknownFieldErrors(node *yaml.Node, t reflect.Type, path fieldPath) []configError {
+	node = resolveAlias(node)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var errs []configError
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := jsonFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			field, ok := fields[key]
+			if !ok {
+				msg := fmt.Sprintf("unknown field %q", key)
+				if suggestion := suggestField(key, fields); len(suggestion) != 0 {
+					msg += fmt.Sprintf(", did you mean %q", suggestion)
+				}
+				errs = append(errs, configError{path: path.with(key), err: errors.New(msg)})
+				continue
+			}
+			errs = append(errs, unknownFieldErrors(value, field.Type, path.with(key))...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			errs = append(errs, unknownFieldErrors(item, t.Elem(), path.with(i))...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, unknownFieldErrors(node.Content[i+1], t.Elem(), path.with(node.Content[i].Value))...)
+		}
+	}
+	return errs
+}
+
+// jsonFields returns the fields of the struct type keyed by their JSON names.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || len(field.PkgPath) != 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// suggestField returns the known field which only differs from the key in case, "_" or "-".
+func suggestField(key string, fields map[string]reflect.StructField) string {
+	normalize := func(s string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(s))
+	}
+	for name := range fields {
+		if normalize(name) == normalize(key) {
+			return name
+		}
+	}
+	return ""
+}
+
+// enabledRepoErrors returns the errors of the repos which are configured for a plugin but the plugin is not
+// enabled for them in the prow plugins config, and the repos which enable the plugin but are not configured.
+func (c *Configuration) enabledRepoErrors(pc *plugins.Configuration) []configError {
+	var errs []configError
+	for _, plugin := range c.plugins() {
+		orgs, repos := pc.EnabledReposForExternalPlugin(plugin.name)
+		enabled := make([]string, 0, len(orgs)+len(repos))
+		enabled = append(enabled, orgs...)
+		enabled = append(enabled, repos...)
+		sort.Strings(enabled)
+
+		reposList := reposOf(plugin.configs)
+		for i, patterns := range reposList {
+			for j, pattern := range patterns {
+				if !patternEnabled(pattern, enabled) {
+					errs = append(errs, configError{
+						path: fieldPath{plugin.name, i, reposJSONName, j},
+						err: fmt.Errorf("%s: repo %s is configured, but the plugin is not enabled for it in the plugins config",
+							plugin.name, pattern),
+					})
+				}
+			}
+		}
+
+		for _, orgRepo := range enabled {
+			if !orgRepoConfigured(orgRepo, reposList) {
+				errs = append(errs, configError{
+					path: fieldPath{plugin.name},
+					err: fmt.Errorf("%s: the plugin is enabled for %s in the plugins config, but it is not configured",
+						plugin.name, orgRepo),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// patternEnabled returns true if any of the enabled orgs or repos is matched by the repos pattern.
+func patternEnabled(pattern string, enabled []string) bool {
+	patternOrg := strings.Split(pattern, "/")[0]
+	for _, orgRepo := range enabled {
+		org, repo := splitOrgRepo(orgRepo)
+		if len(repo) == 0 || !strings.Contains(pattern, "/") {
+			// The plugin is enabled for the whole org, or the pattern is an org pattern.
+			if matched, _ := path.Match(patternOrg, org); matched {
+				return true
+			}
+			continue
+		}
+		if matchRepo(pattern, org, repo) != noMatch {
+			return true
+		}
+	}
+	return false
+}
+
+// orgRepoConfigured returns true if the org or repo is matched by any of the repos patterns.
+func orgRepoConfigured(orgRepo string, reposList [][]string) bool {
+	org, repo := splitOrgRepo(orgRepo)
+	for _, patterns := range reposList {
+		for _, pattern := range patterns {
+			if len(repo) != 0 && matchRepo(pattern, org, repo) != noMatch {
+				return true
+			}
+			if len(repo) == 0 {
+				if matched, _ := path.Match(strings.Split(pattern, "/")[0], org); matched {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// splitOrgRepo splits the org/repo into org and repo, the repo is empty if it is only an org.
+func splitOrgRepo(orgRepo string) (string, string) {
+	parts := strings.SplitN(orgRepo, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,187 @@
+package externalplugins
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestLint(t *testing.T) {
+	const links = `tichi_web_url: https://prow-dev.tidb.io/tichi
+pr_process_link: https://book.prow.tidb.io/#/en/workflows/pr
+command_help_link: https://prow-dev.tidb.io/command-help
+`
+
+	testcases := []struct {
+		name          string
+		config        string
+		pluginsConfig *plugins.Configuration
+
+		expectedProblems []Problem
+	}{
+		{
+			name: "valid config",
+			config: links + `
+ti-community-cherrypicker:
+  - repos:
+      - ti-community-infra/test-dev
+    excludeLabels:
+      - status/can-merge
+`,
+			expectedProblems: nil,
+		},
+		{
+			name:   "syntax error",
+			config: links + "ti-community-lgtm:\n  - repos: [\n",
+			expectedProblems: []Problem{
+				{
+					Severity: ErrorSeverity,
+					Line:     5,
+					Message:  "yaml: line 5: did not find expected node content",
+				},
+			},
+		},
+		{
+			name: "unknown fields",
+			config: links + `
+ti-community-cherrypicker:
+  - repos:
+      - ti-community-infra/test-dev
+    exclude_labels:
+      - status/can-merge
+    not_exist: true
+`,
+			expectedProblems: []Problem{
+				{
+					Severity: ErrorSeverity,
+					Line:     8,
+					Column:   5,
+					Path:     "ti-community-cherrypicker[0].exclude_labels",
+					Message:  `unknown field "exclude_labels", did you mean "excludeLabels"`,
+				},
+				{
+					Severity: ErrorSeverity,
+					Line:     10,
+					Column:   5,
+					Path:     "ti-community-cherrypicker[0].not_exist",
+					Message:  `unknown field "not_exist"`,
+				},
+			},
+		},
+		{
+			name: "multiple errors",
+			config: `tichi_web_url: https://prow-dev.tidb.io/tichi
+pr_process_link: https://book.prow.tidb.io/#/en/workflows/pr
+command_help_link: https://prow-dev.tidb.io/command-help
+log_level: unknown
+
+ti-community-lgtm:
+  - repos:
+      - ti-community-infra/test-dev
+  - repos:
+      - ti-community-infra/test-dev
+    pull_owners_endpoint: not-a-url
+
+ti-community-blunderbuss:
+  - repos:
+      - ti-community-infra/test-dev
+    max_request_count: -1
+`,
+			expectedProblems: []Problem{
+				{
+					Severity: ErrorSeverity,
+					Line:     4,
+					Column:   1,
+					Path:     "log_level",
+					Message:  `not a valid logrus Level: "unknown"`,
+				},
+				{
+					Severity: ErrorSeverity,
+					Line:     10,
+					Column:   9,
+					Path:     "ti-community-lgtm[1].repos[0]",
+					Message:  "ti-community-lgtm: repo ti-community-infra/test-dev is configured more than once",
+				},
+				{
+					Severity: ErrorSeverity,
+					Line:     11,
+					Column:   5,
+					Path:     "ti-community-lgtm[1].pull_owners_endpoint",
+					Message:  `parse "not-a-url": invalid URI for request`,
+				},
+				{
+					Severity: ErrorSeverity,
+					Line:     16,
+					Column:   5,
+					Path:     "ti-community-blunderbuss[0].max_request_count",
+					Message:  "max reviewer count must not less than 0",
+				},
+			},
+		},
+		{
+			name: "repos not enabled or not configured",
+			config: links + `
+ti-community-lgtm:
+  - repos:
+      - ti-community-infra/test-dev
+      - ti-community-infra/test-live
+`,
+			pluginsConfig: &plugins.Configuration{
+				ExternalPlugins: map[string][]plugins.ExternalPlugin{
+					"ti-community-infra/test-dev":  {{Name: "ti-community-lgtm"}},
+					"ti-community-infra/tichi":     {{Name: "ti-community-lgtm"}},
+					"ti-community-infra/test-live": {{Name: "ti-community-merge"}},
+				},
+			},
+			expectedProblems: []Problem{
+				{
+					Severity: WarningSeverity,
+					Line:     1,
+					Column:   1,
+					Path:     "ti-community-merge",
+					Message: "ti-community-merge: the plugin is enabled for ti-community-infra/test-live " +
+						"in the plugins config, but it is not configured",
+				},
+				{
+					Severity: WarningSeverity,
+					Line:     5,
+					Column:   1,
+					Path:     "ti-community-lgtm",
+					Message: "ti-community-lgtm: the plugin is enabled for ti-community-infra/tichi " +
+						"in the plugins config, but it is not configured",
+				},
+				{
+					Severity: WarningSeverity,
+					Line:     8,
+					Column:   9,
+					Path:     "ti-community-lgtm[0].repos[1]",
+					Message: "ti-community-lgtm: repo ti-community-infra/test-live is configured, " +
+						"but the plugin is not enabled for it in the plugins config",
+				},
+			},
+		},
+		{
+			name: "org enabled",
+			config: links + `
+ti-community-lgtm:
+  - repos:
+      - ti-community-infra/test-dev
+`,
+			pluginsConfig: &plugins.Configuration{
+				ExternalPlugins: map[string][]plugins.ExternalPlugin{
+					"ti-community-infra": {{Name: "ti-community-lgtm"}},
+				},
+			},
+			expectedProblems: nil,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			problems := Lint([]byte(tc.config), tc.pluginsConfig)
+			assert.DeepEqual(t, problems, tc.expectedProblems)
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/commentpruner"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pluginhelp"
@@ -94,6 +95,28 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, gc githubClient, ol ownersclient.OwnersLoader) {
+	host.RegisterIssueCommentEventHandler(PluginName,
+		func(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			// This should be used once per webhook event.
+			cp := commentpruner.NewEventClient(gc, log.WithField("client", "commentpruner"),
+				ice.Repo.Owner.Login, ice.Repo.Name, ice.Issue.Number)
+			return HandleIssueCommentEvent(gc, ice, cfg, ol, cp, log)
+		})
+	host.RegisterReviewCommentEventHandler(PluginName,
+		func(rce *github.ReviewCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			// This should be used once per webhook event.
+			cp := commentpruner.NewEventClient(gc, log.WithField("client", "commentpruner"),
+				rce.Repo.Owner.Login, rce.Repo.Name, rce.PullRequest.Number)
+			return HandlePullReviewCommentEvent(gc, rce, cfg, ol, cp, log)
+		})
+	host.RegisterPullRequestEventHandler(PluginName,
+		func(pe *github.PullRequestEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePullRequestEvent(gc, pe, cfg, log)
+		})
+}
+
 type githubClient interface {
 	AddLabel(owner, repo string, number int, label string) error
 	CreateComment(owner, repo string, number int, comment string) error
@@ -104,6 +127,7 @@ type githubClient interface {
 	DeleteComment(org, repo string, ID int) error
 	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
 	BotUserChecker() (func(candidate string) bool, error)
+	ListReviews(org, repo string, number int) ([]github.Review, error)
 }
 
 // reviewCtx contains information about each review event.
@@ -197,7 +221,7 @@ func HandlePullRequestEvent(gc githubClient, pe *github.PullRequestEvent,
 	repo := pe.PullRequest.Base.Repo.Name
 	number := pe.PullRequest.Number
 
-	opts := cfg.MergeFor(org, repo)
+	opts := cfg.MergeForBranch(org, repo, pe.PullRequest.Base.Ref)
 
 	// If we don't have the 'status/can-merge' label, we don't need to check anything.
 	labels, err := gc.GetIssueLabels(org, repo, number)
@@ -326,6 +350,39 @@ func handle(wantMerge bool, config *tiexternalplugins.Configuration, rc reviewCt
 			})
 		}
 	} else if !hasCanMerge && wantMerge {
+		var lgtmOpts *tiexternalplugins.TiCommunityLgtm
+		if isSatisfy {
+			lgtmOpts = lgtmConfigFor(config, gc, org, repoName, number, log)
+		}
+		// Every area of the pull request requires an approval from its reviewers if the owners specify the areas,
+		// and the approvals must satisfy the approval rules of the lgtm plugin.
+		if isSatisfy && (len(owners.ReviewerGroups) != 0 || len(lgtmOpts.ApprovalRules) != 0) {
+			approvers, err := listApprovers(gc, lgtmOpts, org, repoName, number, issueAuthor)
+			if err != nil {
+				return err
+			}
+			if missingAreas := owners.UncoveredGroups(approvers); len(missingAreas) != 0 {
+				resp := fmt.Sprintf("`/merge` in this pull request requires an approval from the reviewers of `%s`.",
+					strings.Join(missingAreas, "`, `"))
+				log.Infof("Reply /merge request with comment: \"%s\"", resp)
+				return gc.CreateComment(org, repoName, number, tiexternalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+			}
+
+			var unsatisfiedRules []string
+			for _, progress := range lgtmOpts.ApprovalRulesProgress(owners, approvers) {
+				if !progress.Satisfied() {
+					unsatisfiedRules = append(unsatisfiedRules,
+						fmt.Sprintf("%s (%d/%d)", progress.Name, progress.Approvals, progress.Required))
+				}
+			}
+			if len(unsatisfiedRules) != 0 {
+				resp := fmt.Sprintf("`/merge` in this pull request requires the approvals to satisfy the rules `%s`.",
+					strings.Join(unsatisfiedRules, "`, `"))
+				log.Infof("Reply /merge request with comment: \"%s\"", resp)
+				return gc.CreateComment(org, repoName, number, tiexternalplugins.FormatResponseRaw(body, htmlURL, author, resp))
+			}
+		}
+
 		if isSatisfy {
 			// Store the tree hash.
 			if opts.StoreTreeHash {
@@ -362,6 +419,42 @@ func handle(wantMerge bool, config *tiexternalplugins.Configuration, rc reviewCt
 	return nil
 }
 
+// lgtmConfigFor returns the lgtm config for the base branch of the PR, the repository level config is used
+// if the PR cannot be got.
+func lgtmConfigFor(config *tiexternalplugins.Configuration, gc githubClient, org, repo string, number int,
+	log *logrus.Entry) *tiexternalplugins.TiCommunityLgtm {
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get pull request, using the repository level lgtm config.")
+		return config.LgtmFor(org, repo)
+	}
+	return config.LgtmForBranch(org, repo, pr.Base.Ref)
+}
+
+// listApprovers returns the approvers of the PR, the approvals invalidated by the new commits
+// of the lgtm plugin are not included, and the lgtm commands count as reviews if they are allowed.
+func listApprovers(gc githubClient, lgtmOpts *tiexternalplugins.TiCommunityLgtm, org, repo string, number int,
+	author string) (sets.String, error) {
+	reviews, err := gc.ListReviews(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	botUserChecker, err := gc.BotUserChecker()
+	if err != nil {
+		return nil, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	notification := tiexternalplugins.LatestReviewNotification(comments, botUserChecker)
+	reset := tiexternalplugins.ApprovalsResetFromNotification(notification)
+	if lgtmOpts.AllowLgtmCommands {
+		reviews = append(reviews, tiexternalplugins.ReviewsFromLgtmCommands(comments, author)...)
+	}
+	return tiexternalplugins.ApproversFromReviews(reset.ValidReviews(reviews)), nil
+}
+
 // isLGTMSatisfy returns pull request current label number.
 func isLGTMSatisfy(prefix string, labels []github.Label, needsLgtm int) bool {
 	currentLgtmNumber := 0
@@ -22,15 +22,17 @@ var (
 )
 
 type fakeOwnersClient struct {
-	committers []string
-	needsLgtm  int
+	committers     []string
+	needsLgtm      int
+	reviewerGroups []ownersclient.ReviewerGroup
 }
 
 func (f *fakeOwnersClient) LoadOwners(_ string,
 	_, _ string, _ int) (*ownersclient.Owners, error) {
 	return &ownersclient.Owners{
-		Committers: f.committers,
-		NeedsLgtm:  f.needsLgtm,
+		Committers:     f.committers,
+		NeedsLgtm:      f.needsLgtm,
+		ReviewerGroups: f.reviewerGroups,
 	}, nil
 }
 
@@ -811,6 +813,219 @@ func TestHandlePullRequest(t *testing.T) {
 	}
 }
 
+func TestMergeApprovalCoverage(t *testing.T) {
+	review := func(login string, state github.ReviewState) github.Review {
+		return github.Review{User: github.User{Login: login}, State: state}
+	}
+	missingAllAreas := "`/merge` in this pull request requires an approval from the reviewers of " +
+		"`sig/planner`, `sig/execution`."
+
+	now := time.Now()
+	approvalsReset := &externalplugins.ApprovalsReset{Commit: "abc", Time: now, Reviewers: []string{"collab2"}}
+	resetNotification := github.IssueComment{
+		User: github.User{Login: fakegithub.Bot},
+		Body: approvalsReset.String() + "\n<!--" + externalplugins.ReviewNotificationIdentifier + "-->",
+	}
+
+	var testcases = []struct {
+		name         string
+		reviews      []github.Review
+		notification *github.IssueComment
+
+		shouldMerge   bool
+		expectComment string
+	}{
+		{
+			name:          "no approvals",
+			expectComment: missingAllAreas,
+		},
+		{
+			name: "one area approved",
+			reviews: []github.Review{
+				review("collab1", github.ReviewStateApproved),
+				review("collab2", github.ReviewStateApproved),
+				review("collab2", github.ReviewStateChangesRequested),
+			},
+			expectComment: "`/merge` in this pull request requires an approval from the reviewers of `sig/execution`.",
+		},
+		{
+			name: "approval dismissed",
+			reviews: []github.Review{
+				review("collab1", github.ReviewStateDismissed),
+				review("collab2", github.ReviewStateCommented),
+			},
+			expectComment: missingAllAreas,
+		},
+		{
+			name: "all areas approved",
+			reviews: []github.Review{
+				review("collab1", github.ReviewStateApproved),
+				review("collab2", github.ReviewStateApproved),
+			},
+			shouldMerge: true,
+		},
+		{
+			name: "approval invalidated by new commits",
+			reviews: []github.Review{
+				{User: github.User{Login: "collab1"}, State: github.ReviewStateApproved, SubmittedAt: now.Add(time.Minute)},
+				{User: github.User{Login: "collab2"}, State: github.ReviewStateApproved, SubmittedAt: now.Add(-time.Minute)},
+			},
+			notification:  &resetNotification,
+			expectComment: "`/merge` in this pull request requires an approval from the reviewers of `sig/execution`.",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegithub.FakeClient{
+				IssueComments:    make(map[int][]github.IssueComment),
+				Reviews:          map[int][]github.Review{5: tc.reviews},
+				IssueLabelsAdded: []string{"org/repo#5:" + lgtmTwo},
+			}
+			if tc.notification != nil {
+				fc.IssueComments[5] = []github.IssueComment{*tc.notification}
+			}
+			rc := reviewCtx{
+				author:      "collab1",
+				issueAuthor: "author",
+				repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				number:      5,
+				body:        "/merge",
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1"},
+				needsLgtm:  2,
+				reviewerGroups: []ownersclient.ReviewerGroup{
+					{Name: "sig/planner", Reviewers: []string{"collab1"}},
+					{Name: "sig/execution", Reviewers: []string{"collab2"}},
+				},
+			}
+
+			err := handle(true, &externalplugins.Configuration{}, rc, fc, foc, &fakePruner{},
+				logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error from merge: %v", err)
+			}
+
+			merged := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == "org/repo#5:"+externalplugins.CanMergeLabel {
+					merged = true
+				}
+			}
+			if merged != tc.shouldMerge {
+				t.Errorf("Different merge: Got \"%v\" expected \"%v\"", merged, tc.shouldMerge)
+			}
+			if len(tc.expectComment) != 0 && !strings.Contains(strings.Join(fc.IssueCommentsAdded, "\n"), tc.expectComment) {
+				t.Errorf("Expected comment %q, got %v", tc.expectComment, fc.IssueCommentsAdded)
+			}
+		})
+	}
+}
+
+func TestMergeApprovalRules(t *testing.T) {
+	review := func(login string, state github.ReviewState) github.Review {
+		return github.Review{User: github.User{Login: login}, State: state}
+	}
+
+	var testcases = []struct {
+		name    string
+		reviews []github.Review
+		branch  string
+
+		shouldMerge   bool
+		expectComment string
+	}{
+		{
+			name: "rule unsatisfied",
+			reviews: []github.Review{
+				review("collab1", github.ReviewStateApproved),
+				review("collab2", github.ReviewStateApproved),
+			},
+			branch:        "master",
+			expectComment: "`/merge` in this pull request requires the approvals to satisfy the rules `committers (1/2)`.",
+		},
+		{
+			name: "rule satisfied",
+			reviews: []github.Review{
+				review("collab1", github.ReviewStateApproved),
+				review("collab3", github.ReviewStateApproved),
+			},
+			branch:      "master",
+			shouldMerge: true,
+		},
+		{
+			name: "rule overridden by the branch",
+			reviews: []github.Review{
+				review("collab1", github.ReviewStateApproved),
+				review("collab2", github.ReviewStateApproved),
+			},
+			branch:      "release-5.0",
+			shouldMerge: true,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegithub.FakeClient{
+				IssueComments:    make(map[int][]github.IssueComment),
+				Reviews:          map[int][]github.Review{5: tc.reviews},
+				IssueLabelsAdded: []string{"org/repo#5:" + lgtmTwo},
+				PullRequests: map[int]*github.PullRequest{
+					5: {Number: 5, Base: github.PullRequestBranch{Ref: tc.branch}},
+				},
+			}
+			rc := reviewCtx{
+				author:      "collab1",
+				issueAuthor: "author",
+				repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+				number:      5,
+				body:        "/merge",
+			}
+			foc := &fakeOwnersClient{
+				committers: []string{"collab1", "collab3"},
+				needsLgtm:  2,
+			}
+			cfg := &externalplugins.Configuration{}
+			cfg.TiCommunityLgtm = []externalplugins.TiCommunityLgtm{
+				{
+					Repos: []string{"org/repo"},
+					ApprovalRules: []externalplugins.LgtmApprovalRule{
+						{Name: "committers", Role: ownersclient.RoleCommitter, MinApprovals: 2},
+					},
+					Branches: map[string]externalplugins.TiCommunityLgtm{
+						"release-*": {
+							ApprovalRules: []externalplugins.LgtmApprovalRule{
+								{Name: "committers", Role: ownersclient.RoleCommitter, MinApprovals: 1},
+							},
+						},
+					},
+				},
+			}
+
+			err := handle(true, cfg, rc, fc, foc, &fakePruner{}, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("didn't expect error from merge: %v", err)
+			}
+
+			merged := false
+			for _, label := range fc.IssueLabelsAdded {
+				if label == "org/repo#5:"+externalplugins.CanMergeLabel {
+					merged = true
+				}
+			}
+			if merged != tc.shouldMerge {
+				t.Errorf("Different merge: Got \"%v\" expected \"%v\"", merged, tc.shouldMerge)
+			}
+			if len(tc.expectComment) != 0 && !strings.Contains(strings.Join(fc.IssueCommentsAdded, "\n"), tc.expectComment) {
+				t.Errorf("Expected comment %q, got %v", tc.expectComment, fc.IssueCommentsAdded)
+			}
+		})
+	}
+}
+
 func TestAddTreeHashComment(t *testing.T) {
 	c := struct {
 		name          string
@@ -0,0 +1,121 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// rateLimitRemainingHeader is the header of the GitHub API responses which contains the remaining
+	// requests of the rate limit.
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	// rateLimitResourceHeader is the header of the GitHub API responses which contains the rate limit
+	// resource that the request counts against, e.g. core, search or graphql.
+	rateLimitResourceHeader = "X-RateLimit-Resource"
+	// graphQLResource is the rate limit resource of the GitHub GraphQL API.
+	graphQLResource = "graphql"
+)
+
+var (
+	eventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_plugin_events_received_total",
+		Help: "The number of webhook events received by the plugins.",
+	}, []string{"plugin", "event_type", "action"})
+	eventsHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_plugin_events_handled_total",
+		Help: "The number of webhook events handled by the plugins successfully.",
+	}, []string{"plugin", "event_type", "action"})
+	eventsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_plugin_events_failed_total",
+		Help: "The number of webhook events that the plugins failed to handle, including the rejected ones.",
+	}, []string{"plugin", "event_type", "action"})
+	eventsRetried = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_plugin_events_retried_total",
+		Help: "The number of retries of the persisted webhook events.",
+	}, []string{"plugin", "event_type", "action"})
+	eventsDead = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_plugin_events_dead_total",
+		Help: "The number of the persisted webhook events moved to the dead events.",
+	}, []string{"plugin", "event_type", "action"})
+	eventsTimedOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_plugin_events_timed_out_total",
+		Help: "The number of webhook events whose handlers run longer than the handler timeout.",
+	}, []string{"plugin", "event_type", "action"})
+	handleDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tichi_plugin_handle_duration_seconds",
+		Help:    "The duration of the plugins handling webhook events.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 15),
+	}, []string{"plugin", "event_type", "action"})
+
+	githubRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_github_requests_total",
+		Help: "The number of requests sent to the GitHub API.",
+	}, []string{"resource", "method", "status"})
+	githubRateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tichi_github_rate_limit_remaining",
+		Help: "The remaining requests or points of the GitHub API rate limit.",
+	}, []string{"resource"})
+	githubGraphQLCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tichi_github_graphql_cost_total",
+		Help: "The points of the GitHub GraphQL API rate limit cost by the plugins.",
+	}, []string{"plugin"})
+)
+
+func init() {
+	prometheus.MustRegister(eventsReceived, eventsHandled, eventsFailed, eventsRetried, eventsDead, eventsTimedOut,
+		handleDuration, githubRequests, githubRateLimitRemaining, githubGraphQLCost)
+}
+
+// eventAction returns the action of the webhook event payload, it is empty if the event has no action.
+func eventAction(payload []byte) string {
+	var event struct {
+		Action string `json:"action"`
+	}
+	_ = json.Unmarshal(payload, &event)
+	return event.Action
+}
+
+// ObserveGraphQLRateLimit records the cost and the remaining points of the GitHub GraphQL API rate limit
+// returned by a query of the plugin.
+func ObserveGraphQLRateLimit(plugin string, cost, remaining int) {
+	githubGraphQLCost.WithLabelValues(plugin).Add(float64(cost))
+	githubRateLimitRemaining.WithLabelValues(graphQLResource).Set(float64(remaining))
+}
+
+// githubMetricsTransport records the requests sent to the GitHub API and the remaining rate limit.
+type githubMetricsTransport struct {
+	upstream http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper. Only the responses with the rate limit headers are recorded,
+// so that the requests sent to other hosts with the same transport are ignored.
+func (t *githubMetricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.upstream.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+
+	remaining := resp.Header.Get(rateLimitRemainingHeader)
+	if len(remaining) == 0 {
+		return resp, nil
+	}
+	resource := resp.Header.Get(rateLimitResourceHeader)
+	githubRequests.WithLabelValues(resource, r.Method, strconv.Itoa(resp.StatusCode)).Inc()
+	if n, err := strconv.Atoi(remaining); err == nil {
+		githubRateLimitRemaining.WithLabelValues(resource).Set(float64(n))
+	}
+	return resp, nil
+}
+
+// InstrumentGitHubRequests records the GitHub API requests sent by the GitHub clients created
+// after it is called.
+// NOTICE: The GitHub client of prow always uses http.DefaultTransport, so it is wrapped here.
+func InstrumentGitHubRequests() {
+	if _, ok := http.DefaultTransport.(*githubMetricsTransport); ok {
+		return
+	}
+	http.DefaultTransport = &githubMetricsTransport{upstream: http.DefaultTransport}
+}
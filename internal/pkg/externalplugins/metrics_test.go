@@ -0,0 +1,96 @@
+package externalplugins
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandlerMetrics(t *testing.T) {
+	host := newTestHost(t, HostOptions{Workers: 1, QueueSize: 2, HandlerTimeout: time.Second})
+	host.RegisterPullRequestEventHandler("metrics-success",
+		func(*github.PullRequestEvent, *Configuration, *logrus.Entry) error {
+			return nil
+		})
+	host.RegisterPullRequestEventHandler("metrics-failure",
+		func(*github.PullRequestEvent, *Configuration, *logrus.Entry) error {
+			return errors.New("failed")
+		})
+
+	if err := host.Dispatch(PullRequestEvent, "guid", []byte(`{"action": "opened"}`)); err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	host.Drain()
+
+	var testcases = []struct {
+		name   string
+		plugin string
+
+		expectedHandled float64
+		expectedFailed  float64
+	}{
+		{
+			name:            "Handled successfully",
+			plugin:          "metrics-success",
+			expectedHandled: 1,
+		},
+		{
+			name:           "Failed to handle",
+			plugin:         "metrics-failure",
+			expectedFailed: 1,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			labels := []string{tc.plugin, PullRequestEvent, "opened"}
+			if received := testutil.ToFloat64(eventsReceived.WithLabelValues(labels...)); received != 1 {
+				t.Errorf("Different received: Got \"%v\" expected \"%v\"", received, 1)
+			}
+			handled := testutil.ToFloat64(eventsHandled.WithLabelValues(labels...))
+			if handled != tc.expectedHandled {
+				t.Errorf("Different handled: Got \"%v\" expected \"%v\"", handled, tc.expectedHandled)
+			}
+			failed := testutil.ToFloat64(eventsFailed.WithLabelValues(labels...))
+			if failed != tc.expectedFailed {
+				t.Errorf("Different failed: Got \"%v\" expected \"%v\"", failed, tc.expectedFailed)
+			}
+		})
+	}
+}
+
+func TestGitHubMetricsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			w.Header().Set(rateLimitRemainingHeader, "4321")
+			w.Header().Set(rateLimitResourceHeader, "metrics-test")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &githubMetricsTransport{upstream: http.DefaultTransport}}
+	for _, path := range []string{"/api", "/api", "/patch"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	requests := testutil.ToFloat64(githubRequests.WithLabelValues("metrics-test", http.MethodGet, "200"))
+	if requests != 2 {
+		t.Errorf("Different requests: Got \"%v\" expected \"%v\"", requests, 2)
+	}
+	remaining := testutil.ToFloat64(githubRateLimitRemaining.WithLabelValues("metrics-test"))
+	if remaining != 4321 {
+		t.Errorf("Different remaining: Got \"%v\" expected \"%v\"", remaining, 4321)
+	}
+}
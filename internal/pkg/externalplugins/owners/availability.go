@@ -0,0 +1,138 @@
+package owners
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+	"sigs.k8s.io/yaml"
+)
+
+// availabilityDateLayout specifies the layout of the dates in the availability file and the away commands.
+const availabilityDateLayout = "2006-01-02"
+
+var (
+	// awayRe is the regex that matches the away commands, such as: /away until 2021-10-01.
+	awayRe = regexp.MustCompile(`(?mi)^/away(?:\s+until\s+(\d{4}-\d{2}-\d{2}))?\s*$`)
+	// backRe is the regex that matches the back commands.
+	backRe = regexp.MustCompile(`(?mi)^/back\s*$`)
+)
+
+// AvailabilityFile is a YAML or JSON file describing the periods when the users are unavailable.
+type AvailabilityFile struct {
+	Unavailable []UnavailablePeriod `json:"unavailable,omitempty"`
+}
+
+// UnavailablePeriod is a period when the user is unavailable. The dates are inclusive and in the form
+// of 2006-01-02, the period has no start or no end if the date is empty.
+type UnavailablePeriod struct {
+	Login  string `json:"login"`
+	From   string `json:"from,omitempty"`
+	Until  string `json:"until,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// covers returns whether the period covers the day of the time.
+func (p *UnavailablePeriod) covers(now time.Time) (bool, error) {
+	today := now.UTC().Format(availabilityDateLayout)
+	// The dates in the layout are compared as strings after they are validated.
+	for _, date := range []string{p.From, p.Until} {
+		if _, err := time.Parse(availabilityDateLayout, date); len(date) != 0 && err != nil {
+			return false, fmt.Errorf("invalid date %q of the unavailable period of %s", date, p.Login)
+		}
+	}
+	return (len(p.From) == 0 || p.From <= today) && (len(p.Until) == 0 || today <= p.Until), nil
+}
+
+// getUnavailableUsers returns the users who are unavailable now according to the availability file and
+// the away commands in the availability issue. The users are available if the availability cannot be loaded.
+func (s *Server) getUnavailableUsers(availability tiexternalplugins.TiCommunityOwnerAvailability,
+	now time.Time) sets.String {
+	unavailable := sets.NewString()
+	if len(availability.Repo) == 0 {
+		return unavailable
+	}
+	parts := strings.SplitN(availability.Repo, "/", 2)
+	org, repo := parts[0], parts[1]
+	log := s.Log.WithField("org", org).WithField("repo", repo)
+
+	if len(availability.File) != 0 {
+		periods, err := s.loadUnavailablePeriods(org, repo, availability.Branch, availability.File)
+		if err != nil {
+			log.WithError(err).Error("Failed to load the availability file.")
+		}
+		for i := range periods {
+			covered, err := periods[i].covers(now)
+			if err != nil {
+				log.WithError(err).Warn("Ignore the invalid unavailable period.")
+				continue
+			}
+			if covered {
+				unavailable.Insert(github.NormLogin(periods[i].Login))
+			}
+		}
+	}
+
+	if availability.Issue != 0 {
+		comments, err := s.loadAvailabilityComments(org, repo, availability.Issue)
+		if err != nil {
+			log.WithError(err).Error("Failed to load the comments of the availability issue.")
+		}
+		unavailable.Insert(awayUsers(comments, now)...)
+	}
+	return unavailable
+}
+
+// loadUnavailablePeriods returns the unavailable periods in the availability file.
+func (s *Server) loadUnavailablePeriods(org, repo, branch, path string) ([]UnavailablePeriod, error) {
+	content, err := s.loadFile(org, repo, branch, path)
+	if err != nil {
+		return nil, err
+	}
+	var availabilityFile AvailabilityFile
+	if err := yaml.Unmarshal(content, &availabilityFile); err != nil {
+		return nil, fmt.Errorf("failed to parse the availability file %s: %w", path, err)
+	}
+	return availabilityFile.Unavailable, nil
+}
+
+// loadAvailabilityComments returns the comments of the availability issue.
+func (s *Server) loadAvailabilityComments(org, repo string, number int) ([]github.IssueComment, error) {
+	value, err := s.Cache.get(availabilitySource, cacheKey(org, repo, strconv.Itoa(number)),
+		func() (interface{}, error) {
+			return s.Gc.ListIssueComments(org, repo, number)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]github.IssueComment), nil
+}
+
+// awayUsers returns the users who are away now according to their latest away or back commands in the comments.
+func awayUsers(comments []github.IssueComment, now time.Time) []string {
+	today := now.UTC().Format(availabilityDateLayout)
+	away := make(map[string]bool)
+	for _, comment := range comments {
+		login := github.NormLogin(comment.User.Login)
+		if backRe.MatchString(comment.Body) {
+			away[login] = false
+		}
+		if m := awayRe.FindStringSubmatch(comment.Body); m != nil {
+			// The user is away until the end of the day, or until they are back if the day is not specified.
+			away[login] = len(m[1]) == 0 || today <= m[1]
+		}
+	}
+
+	var users []string
+	for login, isAway := range away {
+		if isAway {
+			users = append(users, login)
+		}
+	}
+	return users
+}
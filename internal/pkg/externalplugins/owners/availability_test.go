@@ -0,0 +1,125 @@
+package owners
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestListOwnersUnavailable(t *testing.T) {
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	pullNumber := 1
+	availabilityIssue := 10
+
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1).UTC().Format(availabilityDateLayout)
+	tomorrow := now.AddDate(0, 0, 1).UTC().Format(availabilityDateLayout)
+
+	availabilityFile := []byte("unavailable:\n" +
+		"  - login: collab1\n    from: " + yesterday + "\n    until: " + tomorrow + "\n    reason: vacation\n" +
+		"  - login: collab2\n    until: " + yesterday + "\n" +
+		"  - login: collab3\n    from: " + tomorrow + "\n" +
+		"  - login: collab4\n    from: someday\n" +
+		"  - login: non-reviewer\n")
+	var collaborators []RepositoryCollaboratorConnection
+	reviewers := []string{"collab1", "collab2", "collab3", "collab4", "collab5"}
+	for _, login := range reviewers {
+		collaborator := RepositoryCollaboratorConnection{Permission: writePermission}
+		collaborator.Node.Login = githubql.String(login)
+		collaborators = append(collaborators, collaborator)
+	}
+	comment := func(login, body string) github.IssueComment {
+		return github.IssueComment{User: github.User{Login: login}, Body: body}
+	}
+
+	testcases := []struct {
+		name         string
+		availability tiexternalplugins.TiCommunityOwnerAvailability
+		comments     []github.IssueComment
+
+		expectUnavailable []string
+	}{
+		{
+			name: "availability not configured",
+		},
+		{
+			name: "availability file",
+			availability: tiexternalplugins.TiCommunityOwnerAvailability{
+				Repo: "ti-community-infra/community",
+				File: "availability.yaml",
+			},
+			expectUnavailable: []string{"collab1"},
+		},
+		{
+			name: "away commands",
+			availability: tiexternalplugins.TiCommunityOwnerAvailability{
+				Repo:  "ti-community-infra/community",
+				Issue: availabilityIssue,
+			},
+			comments: []github.IssueComment{
+				comment("collab2", "/away"),
+				comment("collab3", "/away until "+tomorrow),
+				comment("collab4", "/away until "+yesterday),
+				comment("collab5", "I will be on vacation.\n/away"),
+				comment("collab5", "/back"),
+				comment("collab2", "Thanks!"),
+			},
+			expectUnavailable: []string{"collab2", "collab3"},
+		},
+		{
+			name: "availability file and away commands",
+			availability: tiexternalplugins.TiCommunityOwnerAvailability{
+				Repo:  "ti-community-infra/community",
+				File:  "availability.yaml",
+				Issue: availabilityIssue,
+			},
+			comments: []github.IssueComment{
+				comment("collab1", "/back"),
+				comment("collab5", "/away"),
+			},
+			expectUnavailable: []string{"collab1", "collab5"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			config := &tiexternalplugins.Configuration{
+				TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{{
+					Repos:               []string{org + "/" + repoName},
+					UseGitHubPermission: true,
+					Availability:        tc.availability,
+				}},
+			}
+
+			fc := &fakegithub{
+				PullRequests: map[int]*github.PullRequest{
+					pullNumber: {Base: github.PullRequestBranch{Ref: "master"}, Number: pullNumber},
+				},
+				Collaborators: collaborators,
+				Files:         map[string]map[string][]byte{"": {"availability.yaml": availabilityFile}},
+				IssueComments: map[int][]github.IssueComment{availabilityIssue: tc.comments},
+			}
+			ownersServer := Server{Gc: fc, Log: logrus.WithField("server", "testing")}
+
+			res, err := ownersServer.ListOwners(org, repoName, pullNumber, config)
+			if err != nil {
+				t.Fatalf("list owners failed: %v", err)
+			}
+			if len(res.Data.Unavailable) != len(tc.expectUnavailable) ||
+				len(tc.expectUnavailable) != 0 && !reflect.DeepEqual(res.Data.Unavailable, tc.expectUnavailable) {
+				t.Errorf("Different unavailable: Got \"%v\" expected \"%v\"", res.Data.Unavailable, tc.expectUnavailable)
+			}
+			// The unavailable reviewers are still reviewers, so that their approvals count.
+			if !reflect.DeepEqual(res.Data.Reviewers, reviewers) {
+				t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", res.Data.Reviewers, reviewers)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package owners
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"golang.org/x/sync/singleflight"
+)
+
+// The sources of the owners cached by the server.
+const (
+	// sigSource caches the sig info by the URL of the sig endpoint.
+	sigSource = "sig"
+	// membersSource caches the members of all the sigs by the URL of the members endpoint.
+	membersSource = "members"
+	// teamSource caches the members of a trusted team by the org and the team name.
+	teamSource = "team"
+	// collaboratorsSource caches the collaborators and their permissions by the org and the repo.
+	collaboratorsSource = "collaborators"
+	// filesSource caches the OWNERS and CODEOWNERS files by the org, the repo, the branch and the path.
+	filesSource = "files"
+	// availabilitySource caches the comments of the availability issue by the org, the repo and the number.
+	availabilitySource = "availability"
+)
+
+const (
+	defaultSigCacheTTL           = 5 * time.Minute
+	defaultMembersCacheTTL       = 5 * time.Minute
+	defaultTeamCacheTTL          = 10 * time.Minute
+	defaultCollaboratorsCacheTTL = 10 * time.Minute
+	defaultFilesCacheTTL         = 5 * time.Minute
+	defaultAvailabilityCacheTTL  = time.Minute
+	// cacheRefreshInterval specifies the interval of refreshing the entries before they expire.
+	cacheRefreshInterval = time.Minute
+)
+
+// InvalidateCachePath invalidates the cached owners of the source specified by the source query parameter,
+// or all the sources if it is not specified. The key query parameter further limits it to one entry.
+const InvalidateCachePath = "/" + PluginName + "/cache/invalidate"
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tichi_owners_cache_requests_total",
+	Help: "The number of the requests to the owners cache by the source and the result, which is hit or miss.",
+}, []string{"source", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+// CacheOptions specifies the TTLs of the sources cached by the owners server, it implements
+// the flagutil.OptionGroup. The source is not cached if its TTL is 0.
+type CacheOptions struct {
+	SigTTL           time.Duration
+	MembersTTL       time.Duration
+	TeamTTL          time.Duration
+	CollaboratorsTTL time.Duration
+	FilesTTL         time.Duration
+	AvailabilityTTL  time.Duration
+}
+
+// AddFlags adds the flags of the owners cache to the flag set.
+func (o *CacheOptions) AddFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&o.SigTTL, "sig-cache-ttl", defaultSigCacheTTL,
+		"Time to cache the info of a sig from the sig endpoint, the cache is disabled if it is 0.")
+	fs.DurationVar(&o.MembersTTL, "members-cache-ttl", defaultMembersCacheTTL,
+		"Time to cache the members of all the sigs from the members endpoint, the cache is disabled if it is 0.")
+	fs.DurationVar(&o.TeamTTL, "team-cache-ttl", defaultTeamCacheTTL,
+		"Time to cache the members of a trusted team, the cache is disabled if it is 0.")
+	fs.DurationVar(&o.CollaboratorsTTL, "collaborators-cache-ttl", defaultCollaboratorsCacheTTL,
+		"Time to cache the collaborators of a repo, the cache is disabled if it is 0.")
+	fs.DurationVar(&o.FilesTTL, "files-cache-ttl", defaultFilesCacheTTL,
+		"Time to cache the OWNERS and CODEOWNERS files of a branch, the cache is disabled if it is 0.")
+	fs.DurationVar(&o.AvailabilityTTL, "availability-cache-ttl", defaultAvailabilityCacheTTL,
+		"Time to cache the away commands in the availability issue, the cache is disabled if it is 0.")
+}
+
+// Validate validates the options of the owners cache.
+func (o *CacheOptions) Validate(bool) error {
+	for name, ttl := range map[string]time.Duration{
+		sigSource:           o.SigTTL,
+		membersSource:       o.MembersTTL,
+		teamSource:          o.TeamTTL,
+		collaboratorsSource: o.CollaboratorsTTL,
+		filesSource:         o.FilesTTL,
+		availabilitySource:  o.AvailabilityTTL,
+	} {
+		if ttl < 0 {
+			return fmt.Errorf("%s cache TTL must not less than 0, got %v", name, ttl)
+		}
+	}
+	return nil
+}
+
+// cacheEntry is a value loaded from a source.
+type cacheEntry struct {
+	value    interface{}
+	loadedAt time.Time
+	// load loads the value again when the entry is refreshed.
+	load func() (interface{}, error)
+	// read means the entry has been read since it was loaded, only such entries are refreshed.
+	read bool
+}
+
+// sourceCache caches the values loaded from a source. The concurrent loads of the same key are
+// deduplicated, and the values must not be modified by the callers.
+type sourceCache struct {
+	name  string
+	ttl   time.Duration
+	group singleflight.Group
+
+	mut     sync.Mutex
+	entries map[string]*cacheEntry
+	// generation counts the invalidations, the values loaded before an invalidation are not cached.
+	generation uint64
+}
+
+// get returns the cached value of the key, or loads it if it is not cached or expired.
+func (c *sourceCache) get(key string, now time.Time, load func() (interface{}, error)) (interface{}, error) {
+	c.mut.Lock()
+	entry, ok := c.entries[key]
+	if ok && now.Sub(entry.loadedAt) < c.ttl {
+		entry.read = true
+		c.mut.Unlock()
+		cacheRequests.WithLabelValues(c.name, "hit").Inc()
+		return entry.value, nil
+	}
+	generation := c.generation
+	c.mut.Unlock()
+
+	cacheRequests.WithLabelValues(c.name, "miss").Inc()
+	value, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, err := load()
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, value, now, load, generation, true)
+		return value, nil
+	})
+	return value, err
+}
+
+// store caches the value, unless the cache has been invalidated since the value was requested.
+func (c *sourceCache) store(key string, value interface{}, now time.Time, load func() (interface{}, error),
+	generation uint64, read bool) {
+	if c.ttl == 0 {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.generation != generation {
+		return
+	}
+	c.entries[key] = &cacheEntry{value: value, loadedAt: now, load: load, read: read}
+}
+
+// refresh reloads the entries read since they were loaded before they expire, and removes the
+// other expired entries. The entries failed to reload are kept until they expire.
+func (c *sourceCache) refresh(now time.Time, log *logrus.Entry) {
+	type refreshing struct {
+		key  string
+		load func() (interface{}, error)
+	}
+	var refreshes []refreshing
+
+	c.mut.Lock()
+	generation := c.generation
+	for key, entry := range c.entries {
+		age := now.Sub(entry.loadedAt)
+		if entry.read && age+cacheRefreshInterval >= c.ttl {
+			refreshes = append(refreshes, refreshing{key: key, load: entry.load})
+		} else if age >= c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	c.mut.Unlock()
+
+	for _, r := range refreshes {
+		load := r.load
+		_, err, _ := c.group.Do(r.key, func() (interface{}, error) {
+			value, err := load()
+			if err != nil {
+				return nil, err
+			}
+			c.store(r.key, value, now, load, generation, false)
+			return value, nil
+		})
+		if err != nil {
+			log.WithError(err).Warnf("Failed to refresh the cached %s %s.", c.name, r.key)
+		}
+	}
+}
+
+// invalidate removes the cached value of the key, or all the values if the key is empty.
+func (c *sourceCache) invalidate(key string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.generation++
+	if len(key) == 0 {
+		c.entries = make(map[string]*cacheEntry)
+		return
+	}
+	delete(c.entries, key)
+}
+
+// Cache caches the owners loaded by the owners server from the sig endpoint, the members endpoint,
+// the trusted teams and the collaborators of the repos, so that a burst of owners requests does not
+// hammer the community API or burn the GitHub API rate limit.
+type Cache struct {
+	log     *logrus.Entry
+	now     func() time.Time
+	sources map[string]*sourceCache
+}
+
+// NewCache creates the owners cache with the options.
+func NewCache(options CacheOptions, log *logrus.Entry) *Cache {
+	c := &Cache{log: log, now: time.Now, sources: make(map[string]*sourceCache)}
+	for name, ttl := range map[string]time.Duration{
+		sigSource:           options.SigTTL,
+		membersSource:       options.MembersTTL,
+		teamSource:          options.TeamTTL,
+		collaboratorsSource: options.CollaboratorsTTL,
+		filesSource:         options.FilesTTL,
+		availabilitySource:  options.AvailabilityTTL,
+	} {
+		c.sources[name] = &sourceCache{name: name, ttl: ttl, entries: make(map[string]*cacheEntry)}
+	}
+	return c
+}
+
+// Start refreshes the cached owners in the background, and flushes them when the external plugins
+// config changes, e.g. the sig endpoint or the trusted teams are changed, until the context is done.
+func (c *Cache) Start(ctx context.Context, configAgent *tiexternalplugins.ConfigAgent) {
+	configUpdates := make(chan tiexternalplugins.Delta)
+	configAgent.Subscribe(ctx, configUpdates)
+
+	go func() {
+		ticker := time.NewTicker(cacheRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-configUpdates:
+				c.log.Info("Flushing the owners cache because the config changed.")
+				c.Invalidate("", "")
+			case <-ticker.C:
+				c.refresh()
+			}
+		}
+	}()
+}
+
+// get returns the value of the key from the source, it is loaded if it is not cached.
+// It is loaded every time if the cache is nil.
+func (c *Cache) get(source, key string, load func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return load()
+	}
+	return c.sources[source].get(key, c.now(), load)
+}
+
+func (c *Cache) refresh() {
+	now := c.now()
+	for _, source := range c.sources {
+		source.refresh(now, c.log)
+	}
+}
+
+// Invalidate removes the cached value of the key from the source. All the values of the source are removed
+// if the key is empty, and all the sources are flushed if the source is empty.
+func (c *Cache) Invalidate(source, key string) error {
+	if len(source) == 0 {
+		for _, s := range c.sources {
+			s.invalidate("")
+		}
+		return nil
+	}
+
+	s, ok := c.sources[source]
+	if !ok {
+		return fmt.Errorf("unknown source %q, the source must be one of %v", source, c.sourceNames())
+	}
+	s.invalidate(key)
+	return nil
+}
+
+func (c *Cache) sourceNames() []string {
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterRoutes registers the endpoint which invalidates the cached owners to the router.
+func (c *Cache) RegisterRoutes(router gin.IRoutes) {
+	router.POST(InvalidateCachePath, func(ctx *gin.Context) {
+		source, key := ctx.Query("source"), ctx.Query("key")
+		if len(source) == 0 && len(key) != 0 {
+			ctx.String(http.StatusBadRequest, "400 Bad Request: the source must be specified with the key")
+			return
+		}
+		if err := c.Invalidate(source, key); err != nil {
+			ctx.String(http.StatusBadRequest, "400 Bad Request: "+err.Error())
+			return
+		}
+
+		c.log.WithField("source", source).WithField("key", key).Info("Invalidated the owners cache.")
+		ctx.String(http.StatusOK, "Invalidated the owners cache.")
+	})
+}
+
+// cacheKey joins the parts of a cache key.
+func cacheKey(parts ...string) string {
+	return strings.Join(parts, "/")
+}
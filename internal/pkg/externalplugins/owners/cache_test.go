@@ -0,0 +1,359 @@
+package owners
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+// countingLoader returns the number of the loads as the value.
+type countingLoader struct {
+	loads int32
+	err   error
+}
+
+func (l *countingLoader) load() (interface{}, error) {
+	loads := atomic.AddInt32(&l.loads, 1)
+	if l.err != nil {
+		return nil, l.err
+	}
+	return int(loads), nil
+}
+
+func newTestCache(ttl time.Duration) *Cache {
+	return NewCache(CacheOptions{SigTTL: ttl, MembersTTL: ttl, TeamTTL: ttl, CollaboratorsTTL: ttl, FilesTTL: ttl,
+		AvailabilityTTL: ttl}, logrus.WithField("client", "cache"))
+}
+
+func TestCache(t *testing.T) {
+	testcases := []struct {
+		name string
+		ttl  time.Duration
+		// prepare runs after the value is cached at the start time.
+		prepare func(c *Cache, loader *countingLoader)
+		after   time.Duration
+
+		expectValue int
+		expectLoads int32
+	}{
+		{
+			name:        "fresh value",
+			ttl:         5 * time.Minute,
+			after:       time.Minute,
+			expectValue: 1,
+			expectLoads: 1,
+		},
+		{
+			name:        "expired value",
+			ttl:         5 * time.Minute,
+			after:       5 * time.Minute,
+			expectValue: 2,
+			expectLoads: 2,
+		},
+		{
+			name:        "cache disabled",
+			ttl:         0,
+			after:       time.Second,
+			expectValue: 3,
+			expectLoads: 3,
+		},
+		{
+			name: "value invalidated",
+			ttl:  5 * time.Minute,
+			prepare: func(c *Cache, _ *countingLoader) {
+				_ = c.Invalidate(sigSource, "key")
+			},
+			after:       time.Minute,
+			expectValue: 2,
+			expectLoads: 2,
+		},
+		{
+			name: "other value invalidated",
+			ttl:  5 * time.Minute,
+			prepare: func(c *Cache, _ *countingLoader) {
+				_ = c.Invalidate(sigSource, "other")
+				_ = c.Invalidate(membersSource, "")
+			},
+			after:       time.Minute,
+			expectValue: 1,
+			expectLoads: 1,
+		},
+		{
+			name: "all values flushed",
+			ttl:  5 * time.Minute,
+			prepare: func(c *Cache, _ *countingLoader) {
+				_ = c.Invalidate("", "")
+			},
+			after:       time.Minute,
+			expectValue: 2,
+			expectLoads: 2,
+		},
+		{
+			name: "read value refreshed before it expires",
+			ttl:  5 * time.Minute,
+			prepare: func(c *Cache, loader *countingLoader) {
+				now := c.now()
+				c.now = func() time.Time { return now.Add(4*time.Minute + 30*time.Second) }
+				c.refresh()
+				c.now = func() time.Time { return now }
+			},
+			after:       6 * time.Minute,
+			expectValue: 2,
+			expectLoads: 2,
+		},
+		{
+			name: "value kept when the refresh failed",
+			ttl:  5 * time.Minute,
+			prepare: func(c *Cache, loader *countingLoader) {
+				loader.err = errors.New("connection refused")
+				now := c.now()
+				c.now = func() time.Time { return now.Add(4*time.Minute + 30*time.Second) }
+				c.refresh()
+				c.now = func() time.Time { return now }
+				loader.err = nil
+			},
+			after:       4 * time.Minute,
+			expectValue: 1,
+			expectLoads: 2,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			loader := &countingLoader{}
+			c := newTestCache(tc.ttl)
+			c.now = func() time.Time { return now }
+
+			if _, err := c.get(sigSource, "key", loader.load); err != nil {
+				t.Fatalf("get value failed: %v", err)
+			}
+			// Read the value, so that it is refreshed in the background.
+			if _, err := c.get(sigSource, "key", loader.load); err != nil {
+				t.Fatalf("get value failed: %v", err)
+			}
+			if tc.prepare != nil {
+				tc.prepare(c, loader)
+			}
+			now = now.Add(tc.after)
+
+			value, err := c.get(sigSource, "key", loader.load)
+			if err != nil {
+				t.Fatalf("get value failed: %v", err)
+			}
+			if value.(int) != tc.expectValue {
+				t.Errorf("Different value: Got \"%v\" expected \"%v\"", value, tc.expectValue)
+			}
+			if loads := atomic.LoadInt32(&loader.loads); loads != tc.expectLoads {
+				t.Errorf("Different loads: Got \"%v\" expected \"%v\"", loads, tc.expectLoads)
+			}
+		})
+	}
+}
+
+func TestCacheEvictsUnreadValues(t *testing.T) {
+	now := time.Now()
+	loader := &countingLoader{}
+	c := newTestCache(5 * time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, err := c.get(sigSource, "key", loader.load); err != nil {
+		t.Fatalf("get value failed: %v", err)
+	}
+	c.sources[sigSource].entries["key"].read = false
+
+	now = now.Add(5 * time.Minute)
+	c.refresh()
+	if len(c.sources[sigSource].entries) != 0 {
+		t.Errorf("Different entries: Got \"%v\" expected \"%v\"", c.sources[sigSource].entries, "no entries")
+	}
+	if loads := atomic.LoadInt32(&loader.loads); loads != 1 {
+		t.Errorf("Different loads: Got \"%v\" expected \"%v\"", loads, 1)
+	}
+}
+
+func TestCacheDeduplicatesLoads(t *testing.T) {
+	c := newTestCache(5 * time.Minute)
+
+	var loads int32
+	release := make(chan struct{})
+	load := func() (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.get(collaboratorsSource, cacheKey("org", "repo"), load); err != nil {
+				t.Errorf("unexpected error: '%v'", err)
+			}
+		}()
+	}
+	// Wait for the first load to start, the others wait for it.
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads := atomic.LoadInt32(&loads); loads != 1 {
+		t.Errorf("Different loads: Got \"%v\" expected \"%v\"", loads, 1)
+	}
+}
+
+func TestCacheFlushedOnConfigChange(t *testing.T) {
+	epa := &tiexternalplugins.ConfigAgent{}
+	epa.Set(&tiexternalplugins.Configuration{})
+	c := newTestCache(5 * time.Minute)
+	loader := &countingLoader{}
+	if _, err := c.get(teamSource, cacheKey("org", "team"), loader.load); err != nil {
+		t.Fatalf("get value failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx, epa)
+	epa.Set(&tiexternalplugins.Configuration{LogLevel: "debug"})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		value, err := c.get(teamSource, cacheKey("org", "team"), loader.load)
+		if err != nil {
+			t.Fatalf("get value failed: %v", err)
+		}
+		if value.(int) > 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the cache was not flushed after the config changed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListOwnersCached(t *testing.T) {
+	var sigRequests int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&sigRequests, 1)
+		body, err := json.Marshal(SigResponse{Data: SigInfo{
+			Name:       "testing",
+			Membership: SigMembership{Reviewers: []MemberInfo{{GithubName: "reviewer"}}},
+			NeedsLgtm:  2,
+		}})
+		if err != nil {
+			t.Errorf("marshal sig failed: %v", err)
+		}
+		_, _ = res.Write(body)
+	}))
+	defer testServer.Close()
+
+	config := &tiexternalplugins.Configuration{}
+	config.TiCommunityOwners = []tiexternalplugins.TiCommunityOwners{
+		{
+			Repos:       []string{"ti-community-infra/test-dev"},
+			SigEndpoint: testServer.URL,
+		},
+	}
+	fc := &fakegithub{PullRequests: map[int]*github.PullRequest{
+		1: {Number: 1, Labels: []github.Label{{Name: "sig/testing"}}},
+	}}
+	ownersServer := Server{
+		Client: testServer.Client(),
+		Gc:     fc,
+		Log:    logrus.WithField("server", "testing"),
+		Cache:  newTestCache(5 * time.Minute),
+	}
+
+	for i := 0; i < 3; i++ {
+		res, err := ownersServer.ListOwners("ti-community-infra", "test-dev", 1, config)
+		if err != nil {
+			t.Fatalf("list owners failed: %v", err)
+		}
+		if len(res.Data.Reviewers) != 1 || res.Data.Reviewers[0] != "reviewer" {
+			t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", res.Data.Reviewers, []string{"reviewer"})
+		}
+	}
+	if requests := atomic.LoadInt32(&sigRequests); requests != 1 {
+		t.Errorf("Different sig requests: Got \"%v\" expected \"%v\"", requests, 1)
+	}
+}
+
+func TestInvalidateCacheEndpoint(t *testing.T) {
+	testcases := []struct {
+		name  string
+		query string
+
+		expectStatus  int
+		expectFlushed bool
+	}{
+		{
+			name:          "flush all",
+			expectStatus:  http.StatusOK,
+			expectFlushed: true,
+		},
+		{
+			name:          "invalidate the key",
+			query:         "?source=sig&key=https://sig",
+			expectStatus:  http.StatusOK,
+			expectFlushed: true,
+		},
+		{
+			name:         "invalidate other key",
+			query:        "?source=sig&key=https://other",
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "unknown source",
+			query:        "?source=unknown",
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "key without source",
+			query:        "?key=https://sig",
+			expectStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCache(5 * time.Minute)
+			loader := &countingLoader{}
+			if _, err := c.get(sigSource, "https://sig", loader.load); err != nil {
+				t.Fatalf("get value failed: %v", err)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			c.RegisterRoutes(router)
+			req := httptest.NewRequest(http.MethodPost, InvalidateCachePath+tc.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectStatus {
+				t.Errorf("Different status: Got \"%v\" expected \"%v\"", rr.Code, tc.expectStatus)
+			}
+			_, cached := c.sources[sigSource].entries["https://sig"]
+			if cached == tc.expectFlushed {
+				t.Errorf("Different flushed: Got \"%v\" expected \"%v\"", !cached, tc.expectFlushed)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package owners
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
+)
+
+var explainRe = regexp.MustCompile(`(?mi)^/owners\s+explain(?:\s+@?([\w-]+))?\s*$`)
+
+// HelpProvider constructs the PluginHelp for this plugin.
+func HelpProvider(_ *tiexternalplugins.ConfigAgent) externalplugins.ExternalPluginHelpProvider {
+	return func(_ []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+		pluginHelp := &pluginhelp.PluginHelp{
+			Description: "The ti-community-owners plugin serves the owners of the PRs to the other plugins, " +
+				"and explains why a user is or is not a reviewer or a committer of a PR.",
+			Events: []string{tiexternalplugins.IssueCommentEvent},
+		}
+
+		pluginHelp.AddCommand(pluginhelp.Command{
+			Usage: "/owners explain [@user]",
+			Description: "Explains the roles of the user in the PR and where they come from, " +
+				"the user is the commenter if it is omitted.",
+			Featured:  false,
+			WhoCanUse: "Anyone.",
+			Examples:  []string{"/owners explain", "/owners explain @Rustin-Liu"},
+		})
+
+		return pluginHelp, nil
+	}
+}
+
+// RegisterHandlers registers the handlers of the comment commands to the plugin host.
+func (s *Server) RegisterHandlers(host *tiexternalplugins.PluginHost) {
+	host.RegisterIssueCommentEventHandler(PluginName, s.HandleIssueCommentEvent)
+}
+
+// HandleIssueCommentEvent handles a GitHub issue comment event and explains the owners of the PR if requested.
+func (s *Server) HandleIssueCommentEvent(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration,
+	log *logrus.Entry) error {
+	// Only consider open PRs and new comments.
+	if ice.Action != github.IssueCommentActionCreated || !ice.Issue.IsPullRequest() || ice.Issue.State == "closed" {
+		return nil
+	}
+
+	matches := explainRe.FindStringSubmatch(ice.Comment.Body)
+	if len(matches) == 0 {
+		return nil
+	}
+	user := matches[1]
+	if len(user) == 0 {
+		user = ice.Comment.User.Login
+	}
+
+	org := ice.Repo.Owner.Login
+	repo := ice.Repo.Name
+	number := ice.Issue.Number
+	explanation, err := s.ExplainOwners(org, repo, number, user, cfg)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Explaining the owners of the PR to %s.", ice.Comment.User.Login)
+	resp := tiexternalplugins.FormatResponseRaw(ice.Comment.Body, ice.Comment.HTMLURL, ice.Comment.User.Login,
+		formatExplanation(explanation))
+	return s.Gc.CreateComment(org, repo, number, resp)
+}
+
+// formatExplanation formats the explanation of the owners as the markdown of a comment.
+// The logins are quoted so that the explained user is not notified.
+func formatExplanation(explanation *OwnersExplanation) string {
+	var b strings.Builder
+	for _, user := range explanation.Users {
+		fmt.Fprintf(&b, "`%s` is %s of this pull request.\n", user.Login, formatRoles(user))
+		for _, grant := range user.Grants {
+			fmt.Fprintf(&b, "- The %s role is granted by %s.\n", grant.Role, formatGrant(grant))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(formatSigSource(explanation))
+	fmt.Fprintf(&b, "\nThis pull request needs %d LGTM, %s.\n", explanation.NeedsLgtm,
+		formatNeedsLgtmSource(explanation))
+	return b.String()
+}
+
+// formatRoles formats the roles of the user, such as "a committer and a reviewer".
+func formatRoles(user UserExplanation) string {
+	var roles []string
+	if user.Leader {
+		roles = append(roles, "a leader")
+	}
+	if user.Committer {
+		roles = append(roles, "a "+committerRole)
+	}
+	if user.Reviewer {
+		roles = append(roles, "a "+reviewerRole)
+	}
+
+	switch len(roles) {
+	case 0:
+		return "neither a committer nor a reviewer"
+	case 1:
+		return roles[0]
+	default:
+		return strings.Join(roles[:len(roles)-1], ", ") + " and " + roles[len(roles)-1]
+	}
+}
+
+// formatGrant formats the source which grants the role.
+func formatGrant(grant RoleGrant) string {
+	switch grant.Source {
+	case GrantedBySig:
+		if len(grant.Sig) == 0 {
+			return fmt.Sprintf("being a %s of a sig", grant.Level)
+		}
+		return fmt.Sprintf("being a %s of the sig `%s`", grant.Level, grant.Sig)
+	case GrantedByTrustedTeam:
+		return fmt.Sprintf("being a member of the trusted team `%s` of the %s config", grant.Team, grant.ConfigLevel)
+	case GrantedByGitHubPermission:
+		return fmt.Sprintf("having the `%s` permission to the repository", grant.Permission)
+	case GrantedByOwnersFile:
+		if len(grant.Pattern) == 0 {
+			return fmt.Sprintf("the `%s` file", grant.File)
+		}
+		if len(grant.Team) == 0 {
+			return fmt.Sprintf("the rule `%s` of the `%s` file", grant.Pattern, grant.File)
+		}
+		return fmt.Sprintf("the rule `%s` of the `%s` file through the team `%s`", grant.Pattern, grant.File, grant.Team)
+	default:
+		return grant.Source
+	}
+}
+
+// formatSigSource formats how the sigs of the PR are chosen.
+func formatSigSource(explanation *OwnersExplanation) string {
+	sigs := make([]string, 0, len(explanation.Sigs))
+	for _, sig := range explanation.Sigs {
+		sigs = append(sigs, "`"+sig+"`")
+	}
+
+	switch explanation.SigSource {
+	case SigsFromLabels:
+		return fmt.Sprintf("The owners are the members of the sigs %s, which come from the sig labels.\n",
+			strings.Join(sigs, ", "))
+	case SigsFromDefaultSigName:
+		return fmt.Sprintf("The owners are the members of the default sig %s, because there are no sig labels.\n",
+			strings.Join(sigs, ", "))
+	case SigsFromAllSigs:
+		return "The owners are the members of all sigs, because there are no sig labels and no default sig.\n"
+	case SigsNotUsed:
+		return "The owners are the collaborators of the repository according to their GitHub permissions.\n"
+	case SigsFromOwnersFiles:
+		return "The owners come from the OWNERS or CODEOWNERS files of the changed files.\n"
+	default:
+		return ""
+	}
+}
+
+// formatNeedsLgtmSource formats where the required lgtm number comes from.
+func formatNeedsLgtmSource(explanation *OwnersExplanation) string {
+	switch explanation.NeedsLgtmSource {
+	case NeedsLgtmFromLabel:
+		return fmt.Sprintf("which comes from the label `%s`", explanation.NeedsLgtmDetail)
+	case NeedsLgtmFromBranchConfig:
+		return fmt.Sprintf("which comes from the config of the branch `%s`", explanation.NeedsLgtmDetail)
+	case NeedsLgtmFromRepoConfig:
+		return "which comes from the repository config"
+	case NeedsLgtmFromSig:
+		return fmt.Sprintf("which comes from the sig `%s`", explanation.NeedsLgtmDetail)
+	default:
+		return "which is the default"
+	}
+}
@@ -0,0 +1,164 @@
+package owners
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestHandleIssueCommentEvent(t *testing.T) {
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	pullNumber := 1
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sigs/sig1", func(res http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(res).Encode(SigResponse{Data: SigInfo{
+			Name: "sig1",
+			Membership: SigMembership{
+				TechLeaders: []MemberInfo{{GithubName: "leader1"}},
+				Reviewers:   []MemberInfo{{GithubName: "reviewer1"}},
+			},
+			NeedsLgtm: 2,
+		}})
+	})
+	mux.HandleFunc("/members/", func(res http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(res).Encode(MembersResponse{})
+	})
+	testServer := httptest.NewServer(mux)
+	defer testServer.Close()
+
+	testcases := []struct {
+		name      string
+		body      string
+		action    github.IssueCommentEventAction
+		state     string
+		commenter string
+
+		expectComment string
+	}{
+		{
+			name:      "explain the commenter",
+			body:      "/owners explain",
+			action:    github.IssueCommentActionCreated,
+			state:     "open",
+			commenter: "leader1",
+			expectComment: "`leader1` is a leader, a committer and a reviewer of this pull request.\n" +
+				"- The committer role is granted by being a leader of the sig `sig1`.\n" +
+				"- The reviewer role is granted by being a leader of the sig `sig1`.\n" +
+				"\n" +
+				"The owners are the members of the sigs `sig1`, which come from the sig labels.\n" +
+				"\n" +
+				"This pull request needs 2 LGTM, which comes from the sig `sig1`.\n",
+		},
+		{
+			name:      "explain the mentioned user",
+			body:      "/owners explain @reviewer1",
+			action:    github.IssueCommentActionCreated,
+			state:     "open",
+			commenter: "someone",
+			expectComment: "`reviewer1` is a reviewer of this pull request.\n" +
+				"- The reviewer role is granted by being a reviewer of the sig `sig1`.\n",
+		},
+		{
+			name:          "explain the user without roles",
+			body:          "/OWNERS EXPLAIN someone",
+			action:        github.IssueCommentActionCreated,
+			state:         "open",
+			commenter:     "leader1",
+			expectComment: "`someone` is neither a committer nor a reviewer of this pull request.\n",
+		},
+		{
+			name:      "other command",
+			body:      "/owners",
+			action:    github.IssueCommentActionCreated,
+			state:     "open",
+			commenter: "leader1",
+		},
+		{
+			name:      "edited comment",
+			body:      "/owners explain",
+			action:    github.IssueCommentActionEdited,
+			state:     "open",
+			commenter: "leader1",
+		},
+		{
+			name:      "closed PR",
+			body:      "/owners explain",
+			action:    github.IssueCommentActionCreated,
+			state:     "closed",
+			commenter: "leader1",
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			config := &tiexternalplugins.Configuration{
+				TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{
+					{
+						Repos:       []string{org + "/" + repoName},
+						SigEndpoint: testServer.URL,
+					},
+				},
+			}
+
+			fc := &fakegithub{
+				PullRequests: map[int]*github.PullRequest{
+					pullNumber: {
+						Base:   github.PullRequestBranch{Ref: "master"},
+						Number: pullNumber,
+						Labels: []github.Label{{Name: "sig/sig1"}},
+					},
+				},
+			}
+			ownersServer := Server{
+				Client: testServer.Client(),
+				Gc:     fc,
+				Log:    logrus.WithField("server", "testing"),
+			}
+
+			ice := &github.IssueCommentEvent{
+				Action: tc.action,
+				Issue: github.Issue{
+					Number:      pullNumber,
+					State:       tc.state,
+					PullRequest: &struct{}{},
+				},
+				Comment: github.IssueComment{
+					Body: tc.body,
+					User: github.User{Login: tc.commenter},
+				},
+				Repo: github.Repo{
+					Owner: github.User{Login: org},
+					Name:  repoName,
+				},
+			}
+
+			err := ownersServer.HandleIssueCommentEvent(ice, config, logrus.WithField("plugin", PluginName))
+			if err != nil {
+				t.Fatalf("handle issue comment event failed: %v", err)
+			}
+
+			comments := fc.CreatedComments[pullNumber]
+			if len(tc.expectComment) == 0 {
+				if len(comments) != 0 {
+					t.Errorf("Unexpected comments: %v", comments)
+				}
+				return
+			}
+			if len(comments) != 1 {
+				t.Fatalf("Different comments count: Got %d expected 1", len(comments))
+			}
+			if !strings.Contains(comments[0], tc.expectComment) {
+				t.Errorf("Different comment: Got \"%s\" expected to contain \"%s\"", comments[0], tc.expectComment)
+			}
+		})
+	}
+}
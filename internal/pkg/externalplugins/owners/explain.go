@@ -0,0 +1,143 @@
+package owners
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// How the sigs of a PR are chosen.
+const (
+	// SigsFromLabels means the sigs are chosen by the sig labels of the PR.
+	SigsFromLabels = "sig_labels"
+	// SigsFromDefaultSigName means the PR has no sig labels, and the default sig name is used.
+	SigsFromDefaultSigName = "default_sig_name"
+	// SigsFromAllSigs means the PR has no sig labels and no default sig name, the members of all sigs are used.
+	SigsFromAllSigs = "all_sigs"
+	// SigsNotUsed means the owners are the collaborators of the repo according to their GitHub permissions.
+	SigsNotUsed = "github_permission"
+	// SigsFromOwnersFiles means the owners come from the OWNERS or CODEOWNERS files, the sigs are not used.
+	SigsFromOwnersFiles = "owners_files"
+)
+
+// Where the required lgtm number of a PR comes from.
+const (
+	NeedsLgtmFromLabel        = "require_lgtm_label"
+	NeedsLgtmFromBranchConfig = "branch_config"
+	NeedsLgtmFromRepoConfig   = "repo_config"
+	NeedsLgtmFromSig          = "sig"
+	NeedsLgtmFromDefault      = "default"
+)
+
+// The sources which grant the roles to the users.
+const (
+	GrantedBySig              = "sig"
+	GrantedByTrustedTeam      = "trusted_team"
+	GrantedByGitHubPermission = "github_permission"
+	GrantedByOwnersFile       = "owners_file"
+)
+
+// The roles granted to the users.
+const (
+	committerRole = "committer"
+	reviewerRole  = "reviewer"
+)
+
+// The levels of the config which the trusted teams come from.
+const (
+	repoConfigLevel   = "repo"
+	branchConfigLevel = "branch"
+)
+
+// explainer collects where the owners and the required lgtm number come from while they are listed.
+type explainer struct {
+	sigSource       string
+	sigs            []string
+	needsLgtmSource string
+	needsLgtmDetail string
+	grants          map[string][]RoleGrant
+}
+
+func newExplainer() *explainer {
+	return &explainer{grants: make(map[string][]RoleGrant)}
+}
+
+// grant records that the source grants the role to the user, the same grant is recorded once.
+func (e *explainer) grant(login string, role string, source RoleGrant) {
+	source.Role = role
+	for _, granted := range e.grants[login] {
+		if granted == source {
+			return
+		}
+	}
+	e.grants[login] = append(e.grants[login], source)
+}
+
+// merge records the grants of the other explainer.
+func (e *explainer) merge(other *explainer) {
+	for login, grants := range other.grants {
+		for _, grant := range grants {
+			e.grant(login, grant.Role, grant)
+		}
+	}
+}
+
+// grantBoth records that the source grants both the committer and the reviewer roles to the user.
+func (e *explainer) grantBoth(login string, source RoleGrant) {
+	e.grant(login, committerRole, source)
+	e.grant(login, reviewerRole, source)
+}
+
+// needsLgtmFrom records where the required lgtm number comes from.
+func (e *explainer) needsLgtmFrom(source string, detail string) {
+	e.needsLgtmSource = source
+	e.needsLgtmDetail = detail
+}
+
+// explain returns the explanation of the owners. Only the user is explained if it is not empty,
+// otherwise all the users granted any role are explained.
+func (e *explainer) explain(owners ownersclient.Owners, user string) *OwnersExplanation {
+	leaders := sets.NewString(owners.Leaders...)
+	committers := sets.NewString(owners.Committers...)
+	reviewers := sets.NewString(owners.Reviewers...)
+
+	var logins []string
+	if len(user) != 0 {
+		login := strings.TrimPrefix(user, "@")
+		// GitHub logins are case insensitive.
+		for granted := range e.grants {
+			if strings.EqualFold(granted, login) {
+				login = granted
+				break
+			}
+		}
+		logins = []string{login}
+	} else {
+		for login := range e.grants {
+			logins = append(logins, login)
+		}
+		sort.Strings(logins)
+	}
+
+	users := make([]UserExplanation, 0, len(logins))
+	for _, login := range logins {
+		users = append(users, UserExplanation{
+			Login:     login,
+			Leader:    leaders.Has(login),
+			Committer: committers.Has(login),
+			Reviewer:  reviewers.Has(login),
+			Grants:    e.grants[login],
+		})
+	}
+
+	return &OwnersExplanation{
+		SigSource:       e.sigSource,
+		Sigs:            e.sigs,
+		NeedsLgtm:       owners.NeedsLgtm,
+		NeedsLgtmSource: e.needsLgtmSource,
+		NeedsLgtmDetail: e.needsLgtmDetail,
+		Users:           users,
+	}
+}
@@ -0,0 +1,234 @@
+package owners
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestExplainOwners(t *testing.T) {
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	pullNumber := 1
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sigs/sig1", func(res http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(res).Encode(SigResponse{Data: SigInfo{
+			Name: "sig1",
+			Membership: SigMembership{
+				TechLeaders: []MemberInfo{{GithubName: "leader1"}},
+				Reviewers:   []MemberInfo{{GithubName: "reviewer1"}},
+			},
+			NeedsLgtm: 3,
+		}})
+	})
+	mux.HandleFunc("/members/", func(res http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(res).Encode(MembersResponse{Data: MembersInfo{
+			Members: []MemberInfo{
+				{GithubName: "committer1", Level: committerLevel},
+				{GithubName: "reviewer1", Level: reviewerLevel},
+				{GithubName: "contributor1", Level: activeContributorLevel},
+			},
+		}})
+	})
+	testServer := httptest.NewServer(mux)
+	defer testServer.Close()
+
+	testcases := []struct {
+		name   string
+		labels []github.Label
+		owners tiexternalplugins.TiCommunityOwners
+		user   string
+
+		expectExplanation OwnersExplanation
+	}{
+		{
+			name:   "sig leader",
+			labels: []github.Label{{Name: "sig/sig1"}},
+			user:   "leader1",
+			expectExplanation: OwnersExplanation{
+				SigSource:       SigsFromLabels,
+				Sigs:            []string{"sig1"},
+				NeedsLgtm:       3,
+				NeedsLgtmSource: NeedsLgtmFromSig,
+				NeedsLgtmDetail: "sig1",
+				Users: []UserExplanation{
+					{
+						Login:     "leader1",
+						Leader:    true,
+						Committer: true,
+						Reviewer:  true,
+						Grants: []RoleGrant{
+							{Role: committerRole, Source: GrantedBySig, Sig: "sig1", Level: leaderLevel},
+							{Role: reviewerRole, Source: GrantedBySig, Sig: "sig1", Level: leaderLevel},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "default sig reviewer with require lgtm label",
+			labels: []github.Label{{Name: "require/LGT1"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				DefaultSigName:         "sig1",
+				RequireLgtmLabelPrefix: "require/LGT",
+				DefaultRequireLgtm:     2,
+			},
+			user: "@reviewer1",
+			expectExplanation: OwnersExplanation{
+				SigSource:       SigsFromDefaultSigName,
+				Sigs:            []string{"sig1"},
+				NeedsLgtm:       1,
+				NeedsLgtmSource: NeedsLgtmFromLabel,
+				NeedsLgtmDetail: "require/LGT1",
+				Users: []UserExplanation{
+					{
+						Login:    "reviewer1",
+						Reviewer: true,
+						Grants: []RoleGrant{
+							{Role: reviewerRole, Source: GrantedBySig, Sig: "sig1", Level: reviewerLevel},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "branch trusted team member",
+			labels: []github.Label{{Name: "sig/sig1"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				DefaultRequireLgtm: 1,
+				TrustTeams:         []string{"Admins"},
+				Branches: map[string]tiexternalplugins.TiCommunityOwnerBranchConfig{
+					"master": {DefaultRequireLgtm: 2, TrustTeams: []string{"Leads"}},
+				},
+			},
+			user: "Sig-Leader1",
+			expectExplanation: OwnersExplanation{
+				SigSource:       SigsFromLabels,
+				Sigs:            []string{"sig1"},
+				NeedsLgtm:       2,
+				NeedsLgtmSource: NeedsLgtmFromBranchConfig,
+				NeedsLgtmDetail: "master",
+				Users: []UserExplanation{
+					{
+						Login:     "sig-leader1",
+						Committer: true,
+						Reviewer:  true,
+						Grants: []RoleGrant{
+							{Role: committerRole, Source: GrantedByTrustedTeam, Team: "Leads", ConfigLevel: branchConfigLevel},
+							{Role: reviewerRole, Source: GrantedByTrustedTeam, Team: "Leads", ConfigLevel: branchConfigLevel},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "all sigs members",
+			owners: tiexternalplugins.TiCommunityOwners{
+				DefaultRequireLgtm: 1,
+			},
+			expectExplanation: OwnersExplanation{
+				SigSource:       SigsFromAllSigs,
+				NeedsLgtm:       1,
+				NeedsLgtmSource: NeedsLgtmFromRepoConfig,
+				Users: []UserExplanation{
+					{
+						Login:     "committer1",
+						Committer: true,
+						Reviewer:  true,
+						Grants: []RoleGrant{
+							{Role: committerRole, Source: GrantedBySig, Level: committerLevel},
+							{Role: reviewerRole, Source: GrantedBySig, Level: committerLevel},
+						},
+					},
+					{
+						Login:    "reviewer1",
+						Reviewer: true,
+						Grants: []RoleGrant{
+							{Role: reviewerRole, Source: GrantedBySig, Level: reviewerLevel},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "collaborator",
+			owners: tiexternalplugins.TiCommunityOwners{
+				UseGitHubPermission: true,
+			},
+			user: "collab1",
+			expectExplanation: OwnersExplanation{
+				SigSource:       SigsNotUsed,
+				NeedsLgtm:       defaultRequireLgtmNum,
+				NeedsLgtmSource: NeedsLgtmFromDefault,
+				Users: []UserExplanation{
+					{
+						Login:    "collab1",
+						Reviewer: true,
+						Grants: []RoleGrant{
+							{Role: reviewerRole, Source: GrantedByGitHubPermission, Permission: triagePermission},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "user without roles",
+			labels: []github.Label{{Name: "sig/sig1"}},
+			user:   "someone",
+			expectExplanation: OwnersExplanation{
+				SigSource:       SigsFromLabels,
+				Sigs:            []string{"sig1"},
+				NeedsLgtm:       3,
+				NeedsLgtmSource: NeedsLgtmFromSig,
+				NeedsLgtmDetail: "sig1",
+				Users:           []UserExplanation{{Login: "someone"}},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			owners := tc.owners
+			owners.Repos = []string{org + "/" + repoName}
+			owners.SigEndpoint = testServer.URL
+			config := &tiexternalplugins.Configuration{
+				TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{owners},
+			}
+
+			fc := &fakegithub{
+				PullRequests: map[int]*github.PullRequest{
+					pullNumber: {
+						Base:   github.PullRequestBranch{Ref: "master"},
+						Number: pullNumber,
+						Labels: tc.labels,
+					},
+				},
+				Collaborators: []RepositoryCollaboratorConnection{
+					{Permission: triagePermission, Node: struct{ Login githubql.String }{Login: "collab1"}},
+				},
+			}
+			ownersServer := Server{
+				Client: testServer.Client(),
+				Gc:     fc,
+				Log:    logrus.WithField("server", "testing"),
+			}
+
+			explanation, err := ownersServer.ExplainOwners(org, repoName, pullNumber, tc.user, config)
+			if err != nil {
+				t.Fatalf("explain owners failed: %v", err)
+			}
+			if !reflect.DeepEqual(*explanation, tc.expectExplanation) {
+				t.Errorf("Different explanation: Got \"%+v\" expected \"%+v\"", *explanation, tc.expectExplanation)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package owners
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// MembershipBackend loads the sigs and their members which the owners come from.
+type MembershipBackend interface {
+	// Sig returns the info of the sig.
+	Sig(name string) (*SigInfo, error)
+	// Members returns the members of all the sigs with their levels.
+	Members() ([]MemberInfo, error)
+}
+
+// SigsFile is a YAML or JSON file describing the sigs, the sigs are in the format of the sig endpoint.
+type SigsFile struct {
+	Sigs []SigInfo `json:"sigs,omitempty"`
+}
+
+// membershipFor returns the membership backend configured for the repos of the org.
+func (s *Server) membershipFor(org string, opts *tiexternalplugins.TiCommunityOwners) MembershipBackend {
+	switch opts.SigBackend {
+	case tiexternalplugins.SigBackendFile:
+		return &fileMembership{s: s, path: opts.SigFile, repo: opts.SigFileRepo, branch: opts.SigFileBranch}
+	case tiexternalplugins.SigBackendGitHubTeams:
+		return &teamsMembership{s: s, org: org, teams: opts.SigTeams}
+	default:
+		return &httpMembership{s: s, endpoint: opts.SigEndpoint}
+	}
+}
+
+// httpMembership loads the sigs from the sig endpoint of the community service.
+type httpMembership struct {
+	s        *Server
+	endpoint string
+}
+
+func (m *httpMembership) Sig(name string) (*SigInfo, error) {
+	return m.s.loadSig(m.endpoint+fmt.Sprintf(SigEndpointFmt, name), name)
+}
+
+func (m *httpMembership) Members() ([]MemberInfo, error) {
+	return m.s.loadMembers(m.endpoint + MembersEndpoint)
+}
+
+// fileMembership loads the sigs from a local sig file, or a sig file in a repo.
+type fileMembership struct {
+	s      *Server
+	path   string
+	repo   string
+	branch string
+}
+
+// load returns the sigs in the sig file.
+func (m *fileMembership) load() ([]SigInfo, error) {
+	var content []byte
+	var err error
+	if len(m.repo) == 0 {
+		content, err = ioutil.ReadFile(m.path)
+	} else {
+		parts := strings.SplitN(m.repo, "/", 2)
+		content, err = m.s.loadFile(parts[0], parts[1], m.branch, m.path)
+		if err == nil && content == nil {
+			err = fmt.Errorf("the sig file %s does not exist in %s", m.path, m.repo)
+		}
+	}
+	if err != nil {
+		m.s.Log.WithField("path", m.path).WithField("repo", m.repo).WithError(err).Error("Failed to load sig file.")
+		return nil, err
+	}
+
+	var sigsFile SigsFile
+	if err := yaml.Unmarshal(content, &sigsFile); err != nil {
+		return nil, fmt.Errorf("failed to parse the sig file %s: %w", m.path, err)
+	}
+	return sigsFile.Sigs, nil
+}
+
+func (m *fileMembership) Sig(name string) (*SigInfo, error) {
+	sigs, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sigs {
+		if sigs[i].Name == name {
+			return &sigs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("could not get the sig: %s", name)
+}
+
+func (m *fileMembership) Members() ([]MemberInfo, error) {
+	sigs, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	var members []MemberInfo
+	for i := range sigs {
+		members = append(members, membersOfSig(&sigs[i])...)
+	}
+	return members, nil
+}
+
+// teamsMembership loads the sigs from the GitHub teams of the org mapped to the sigs.
+type teamsMembership struct {
+	s     *Server
+	org   string
+	teams map[string]tiexternalplugins.TiCommunityOwnerSigTeams
+}
+
+func (m *teamsMembership) Sig(name string) (*SigInfo, error) {
+	teams, ok := m.teams[name]
+	if !ok {
+		return nil, fmt.Errorf("could not get the sig: %s", name)
+	}
+
+	committers := sets.NewString()
+	for _, team := range teams.CommitterTeams {
+		committers.Insert(m.s.getTrustTeamMembers(m.org, team)...)
+	}
+	reviewers := sets.NewString()
+	for _, team := range teams.ReviewerTeams {
+		reviewers.Insert(m.s.getTrustTeamMembers(m.org, team)...)
+	}
+
+	sig := &SigInfo{Name: name, NeedsLgtm: teams.NeedsLgtm}
+	for _, committer := range committers.List() {
+		sig.Membership.Committers = append(sig.Membership.Committers,
+			MemberInfo{GithubName: committer, Level: committerLevel})
+	}
+	// The committers of the sig are not listed as its reviewers again.
+	for _, reviewer := range reviewers.Difference(committers).List() {
+		sig.Membership.Reviewers = append(sig.Membership.Reviewers,
+			MemberInfo{GithubName: reviewer, Level: reviewerLevel})
+	}
+	return sig, nil
+}
+
+func (m *teamsMembership) Members() ([]MemberInfo, error) {
+	names := make([]string, 0, len(m.teams))
+	for name := range m.teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var members []MemberInfo
+	for _, name := range names {
+		sig, err := m.Sig(name)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, membersOfSig(sig)...)
+	}
+	return members, nil
+}
+
+// membersOfSig returns the members of the sig with the levels of their roles.
+func membersOfSig(sig *SigInfo) []MemberInfo {
+	var members []MemberInfo
+	levels := []struct {
+		members []MemberInfo
+		level   string
+	}{
+		{sig.Membership.TechLeaders, leaderLevel},
+		{sig.Membership.CoLeaders, coLeaderLevel},
+		{sig.Membership.Committers, committerLevel},
+		{sig.Membership.Reviewers, reviewerLevel},
+	}
+	for _, l := range levels {
+		for _, member := range l.members {
+			members = append(members, MemberInfo{GithubName: member.GithubName, Level: l.level})
+		}
+	}
+	return members
+}
@@ -0,0 +1,176 @@
+package owners
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestMembershipBackends(t *testing.T) {
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	pullNumber := 1
+
+	sigsFile := []byte(`sigs:
+  - name: planner
+    needsLGTM: 3
+    membership:
+      techLeaders:
+        - githubName: leader1
+      committers:
+        - githubName: committer1
+      reviewers:
+        - githubName: reviewer1
+  - name: execution
+    membership:
+      coLeaders:
+        - githubName: co-leader2
+`)
+	sigsJSONFile := []byte(`{"sigs": [{"name": "planner", "needsLGTM": 1, ` +
+		`"membership": {"committers": [{"githubName": "committer1"}]}}]}`)
+
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "sigs.yaml")
+	if err := ioutil.WriteFile(localPath, sigsFile, 0600); err != nil {
+		t.Fatalf("write sig file failed: %v", err)
+	}
+	localJSONPath := filepath.Join(dir, "sigs.json")
+	if err := ioutil.WriteFile(localJSONPath, sigsJSONFile, 0600); err != nil {
+		t.Fatalf("write sig file failed: %v", err)
+	}
+
+	testcases := []struct {
+		name   string
+		labels []github.Label
+		owners tiexternalplugins.TiCommunityOwners
+
+		expectCommitters []string
+		expectReviewers  []string
+		expectNeedsLgtm  int
+		expectErr        bool
+	}{
+		{
+			name:   "local YAML file",
+			labels: []github.Label{{Name: "sig/planner"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend: tiexternalplugins.SigBackendFile,
+				SigFile:    localPath,
+			},
+			expectCommitters: []string{"committer1", "leader1"},
+			expectReviewers:  []string{"committer1", "leader1", "reviewer1"},
+			expectNeedsLgtm:  3,
+		},
+		{
+			name:   "local JSON file",
+			labels: []github.Label{{Name: "sig/planner"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend: tiexternalplugins.SigBackendFile,
+				SigFile:    localJSONPath,
+			},
+			expectCommitters: []string{"committer1"},
+			expectReviewers:  []string{"committer1"},
+			expectNeedsLgtm:  1,
+		},
+		{
+			name: "all sigs in the file of a repo",
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend:    tiexternalplugins.SigBackendFile,
+				SigFile:       "sigs.yaml",
+				SigFileRepo:   "ti-community-infra/community",
+				SigFileBranch: "main",
+			},
+			expectCommitters: []string{"co-leader2", "committer1", "leader1"},
+			expectReviewers:  []string{"co-leader2", "committer1", "leader1", "reviewer1"},
+			expectNeedsLgtm:  defaultRequireLgtmNum,
+		},
+		{
+			name:   "sig not in the file",
+			labels: []github.Label{{Name: "sig/storage"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend: tiexternalplugins.SigBackendFile,
+				SigFile:    localPath,
+			},
+			expectErr: true,
+		},
+		{
+			name:   "file not in the repo",
+			labels: []github.Label{{Name: "sig/planner"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend:  tiexternalplugins.SigBackendFile,
+				SigFile:     "missing.yaml",
+				SigFileRepo: "ti-community-infra/community",
+			},
+			expectErr: true,
+		},
+		{
+			name:   "GitHub teams",
+			labels: []github.Label{{Name: "sig/planner"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend: tiexternalplugins.SigBackendGitHubTeams,
+				SigTeams: map[string]tiexternalplugins.TiCommunityOwnerSigTeams{
+					"planner": {CommitterTeams: []string{"Leads"}, ReviewerTeams: []string{"Releasers", "Leads"}, NeedsLgtm: 1},
+				},
+			},
+			expectCommitters: []string{"sig-leader1", "sig-leader2"},
+			expectReviewers:  []string{"admin1", "releaser1", "releaser2", "sig-leader1", "sig-leader2"},
+			expectNeedsLgtm:  1,
+		},
+		{
+			name: "all sigs of GitHub teams",
+			owners: tiexternalplugins.TiCommunityOwners{
+				SigBackend: tiexternalplugins.SigBackendGitHubTeams,
+				SigTeams: map[string]tiexternalplugins.TiCommunityOwnerSigTeams{
+					"planner":   {CommitterTeams: []string{"Leads"}},
+					"execution": {ReviewerTeams: []string{"Admins"}},
+				},
+			},
+			expectCommitters: []string{"sig-leader1", "sig-leader2"},
+			expectReviewers:  []string{"admin1", "admin2", "sig-leader1", "sig-leader2"},
+			expectNeedsLgtm:  defaultRequireLgtmNum,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			owners := tc.owners
+			owners.Repos = []string{org + "/" + repoName}
+			config := &tiexternalplugins.Configuration{
+				TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{owners},
+			}
+
+			fc := &fakegithub{
+				PullRequests: map[int]*github.PullRequest{
+					pullNumber: {Base: github.PullRequestBranch{Ref: "master"}, Number: pullNumber, Labels: tc.labels},
+				},
+				Files: map[string]map[string][]byte{"main": {"sigs.yaml": sigsFile}},
+			}
+			ownersServer := Server{Gc: fc, Log: logrus.WithField("server", "testing")}
+
+			res, err := ownersServer.ListOwners(org, repoName, pullNumber, config)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got owners %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("list owners failed: %v", err)
+			}
+			if !reflect.DeepEqual(res.Data.Committers, tc.expectCommitters) {
+				t.Errorf("Different committers: Got \"%v\" expected \"%v\"", res.Data.Committers, tc.expectCommitters)
+			}
+			if !reflect.DeepEqual(res.Data.Reviewers, tc.expectReviewers) {
+				t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", res.Data.Reviewers, tc.expectReviewers)
+			}
+			if res.Data.NeedsLgtm != tc.expectNeedsLgtm {
+				t.Errorf("Different LGTM: Got \"%v\" expected \"%v\"", res.Data.NeedsLgtm, tc.expectNeedsLgtm)
+			}
+		})
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
@@ -52,12 +55,28 @@ const (
 const (
 	// listOwnersSuccessMessage returns on success.
 	listOwnersSuccessMessage = "List all owners success."
+	// explainOwnersSuccessMessage returns on success.
+	explainOwnersSuccessMessage = "Explain owners success."
 	// defaultRequireLgtmNum specifies default lgtm number.
 	defaultRequireLgtmNum = 2
 )
 
+var requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "tichi_owners_request_duration_seconds",
+	Help:    "The duration of the requests to the owners API.",
+	Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+}, []string{"status"})
+
+func init() {
+	prometheus.MustRegister(requestDuration)
+}
+
 type githubClient interface {
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	GetFile(org, repo, filepath, commit string) ([]byte, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	CreateComment(owner, repo string, number int, comment string) error
 	ListTeams(org string) ([]github.Team, error)
 	ListTeamMembers(org string, id int, role string) ([]github.TeamMember, error)
 	Query(context.Context, interface{}, map[string]interface{}) error
@@ -114,6 +133,7 @@ func listCollaborators(ctx context.Context, log *logrus.Entry, ghc githubClient,
 		vars["collaboratorsCursor"] = githubql.NewString(cq.Repository.Collaborators.PageInfo.EndCursor)
 	}
 	log.Infof("List collaborators of repo \"%s/%s\" cost %d point(s). %d remaining.", owner, name, totalCost, remaining)
+	tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
 	return collaborators, nil
 }
 
@@ -125,61 +145,146 @@ type Server struct {
 	Gc             githubClient
 	ConfigAgent    *tiexternalplugins.ConfigAgent
 	Log            *logrus.Entry
+	// Cache caches the owners loaded from the sources, they are loaded for every request if it is nil.
+	Cache *Cache
 }
 
-func (s *Server) listOwnersByAllSigs(opts *tiexternalplugins.TiCommunityOwners,
-	trustTeamMembers []string, requireLgtm int) (*ownersclient.OwnersResponse, error) {
-	var committers []string
-	var reviewers []string
+// loadSig returns the info of the sig from the sig endpoint.
+func (s *Server) loadSig(url string, sigName string) (*SigInfo, error) {
+	value, err := s.Cache.get(sigSource, url, func() (interface{}, error) {
+		res, err := s.Client.Get(url)
+		if err != nil {
+			s.Log.WithField("url", url).WithError(err).Error("Failed to get sigName info.")
+			return nil, err
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
 
-	// Members URL.
-	url := opts.SigEndpoint + MembersEndpoint
+		if res.StatusCode != 200 {
+			s.Log.WithField("url", url).WithField("status", res.StatusCode).Error("Failed to get sigName info.")
+			return nil, fmt.Errorf("could not get the sig: %s", sigName)
+		}
 
-	res, err := s.Client.Get(url)
+		// Unmarshal sigName members from body.
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		var sigRes SigResponse
+		if err := json.Unmarshal(body, &sigRes); err != nil {
+			s.Log.WithField("body", body).WithError(err).Error("Failed to unmarshal body.")
+			return nil, err
+		}
+		return &sigRes.Data, nil
+	})
 	if err != nil {
-		s.Log.WithField("url", url).WithError(err).Error("Failed to get members.")
 		return nil, err
 	}
-	defer func() {
-		_ = res.Body.Close()
-	}()
+	return value.(*SigInfo), nil
+}
+
+// loadMembers returns the members of all the sigs from the members endpoint.
+func (s *Server) loadMembers(url string) ([]MemberInfo, error) {
+	value, err := s.Cache.get(membersSource, url, func() (interface{}, error) {
+		res, err := s.Client.Get(url)
+		if err != nil {
+			s.Log.WithField("url", url).WithError(err).Error("Failed to get members.")
+			return nil, err
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+
+		if res.StatusCode != 200 {
+			s.Log.WithField("url", url).WithField("status", res.StatusCode).Error("Failed to get members.")
+			return nil, errors.New("could not get the members")
+		}
+
+		// Unmarshal members from body.
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
 
-	if res.StatusCode != 200 {
-		s.Log.WithField("url", url).WithError(err).Error("Failed to get members.")
-		return nil, errors.New("could not get the members")
+		var membersRes MembersResponse
+		if err := json.Unmarshal(body, &membersRes); err != nil {
+			s.Log.WithField("body", body).WithError(err).Error("Failed to unmarshal body.")
+			return nil, err
+		}
+		return membersRes.Data.Members, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return value.([]MemberInfo), nil
+}
 
-	// Unmarshal members from body.
-	body, err := ioutil.ReadAll(res.Body)
+// loadCollaborators returns the collaborators of the repo and their permissions.
+func (s *Server) loadCollaborators(org string, repo string) (map[string]string, error) {
+	value, err := s.Cache.get(collaboratorsSource, cacheKey(org, repo), func() (interface{}, error) {
+		return listCollaborators(context.Background(), s.Log, s.Gc, org, repo)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return value.(map[string]string), nil
+}
+
+// getTrustTeamMembers returns the members of the team, such as a trust team or a sig team, it returns no members
+// if they cannot be listed.
+func (s *Server) getTrustTeamMembers(org, trustTeam string) []string {
+	if len(trustTeam) == 0 {
+		return []string{}
+	}
+
+	value, err := s.Cache.get(teamSource, cacheKey(org, trustTeam), func() (interface{}, error) {
+		return listTrustTeamMembers(s.Gc, org, trustTeam)
+	})
+	if err != nil {
+		s.Log.WithError(err).Errorf("Failed to list members of the trust team %s in org %s.", trustTeam, org)
+		return []string{}
+	}
+	return value.([]string)
+}
 
-	var membersRes MembersResponse
-	if err := json.Unmarshal(body, &membersRes); err != nil {
-		s.Log.WithField("body", body).WithError(err).Error("Failed to unmarshal body.")
+func (s *Server) listOwnersByAllSigs(membership MembershipBackend,
+	trustTeamMembers []string, requireLgtm int, exp *explainer) (*ownersclient.OwnersResponse, error) {
+	var leaders []string
+	var committers []string
+	var reviewers []string
+
+	members, err := membership.Members()
+	if err != nil {
 		return nil, err
 	}
 
-	members := membersRes.Data.Members
 	for _, member := range members {
+		grant := RoleGrant{Source: GrantedBySig, Level: member.Level}
+		if member.Level == leaderLevel || member.Level == coLeaderLevel {
+			leaders = append(leaders, member.GithubName)
+		}
 		// Except for activeContributor and reviewer, which are both committers.
 		if member.Level != activeContributorLevel && member.Level != reviewerLevel {
 			committers = append(committers, member.GithubName)
+			exp.grant(member.GithubName, committerRole, grant)
 		}
 		// Except for activeContributor, which are both reviewers.
 		if member.Level != activeContributorLevel {
 			reviewers = append(reviewers, member.GithubName)
+			exp.grant(member.GithubName, reviewerRole, grant)
 		}
 	}
 
 	// If require lgtm no setting, use default require lgtm.
 	if requireLgtm == 0 {
 		requireLgtm = defaultRequireLgtmNum
+		exp.needsLgtmFrom(NeedsLgtmFromDefault, "")
 	}
 
 	return &ownersclient.OwnersResponse{
 		Data: ownersclient.Owners{
+			Leaders:    sets.NewString(leaders...).List(),
 			Committers: sets.NewString(committers...).Insert(trustTeamMembers...).List(),
 			Reviewers:  sets.NewString(reviewers...).Insert(trustTeamMembers...).List(),
 			NeedsLgtm:  requireLgtm,
@@ -189,83 +294,79 @@ func (s *Server) listOwnersByAllSigs(opts *tiexternalplugins.TiCommunityOwners,
 }
 
 func (s *Server) listOwnersBySigs(sigNames []string,
-	opts *tiexternalplugins.TiCommunityOwners, trustTeamMembers []string,
-	requireLgtm int) (*ownersclient.OwnersResponse, error) {
+	membership MembershipBackend, trustTeamMembers []string,
+	requireLgtm int, exp *explainer) (*ownersclient.OwnersResponse, error) {
+	var leaders []string
 	var committers []string
 	var reviewers []string
 	var maxNeedsLgtm int
+	var maxNeedsLgtmSig string
+	groups := make(map[string]sets.String)
 
 	for _, sigName := range sigNames {
-		url := opts.SigEndpoint + fmt.Sprintf(SigEndpointFmt, sigName)
 		// Get sigName info.
-		res, err := s.Client.Get(url)
+		sig, err := membership.Sig(sigName)
 		if err != nil {
-			s.Log.WithField("url", url).WithError(err).Error("Failed to get sigName info.")
 			return nil, err
 		}
 
-		if res.StatusCode != 200 {
-			s.Log.WithField("url", url).WithError(err).Error("Failed to get sigName info.")
-			return nil, fmt.Errorf("could not get the sig: %s", sigName)
-		}
-
-		// Unmarshal sigName members from body.
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, err
-		}
-		var sigRes SigResponse
-		if err := json.Unmarshal(body, &sigRes); err != nil {
-			s.Log.WithField("body", body).WithError(err).Error("Failed to unmarshal body.")
-			return nil, err
-		}
-
-		sig := sigRes.Data
-
+		sigReviewersStart := len(reviewers)
 		for _, leader := range sig.Membership.TechLeaders {
+			leaders = append(leaders, leader.GithubName)
 			committers = append(committers, leader.GithubName)
 			reviewers = append(reviewers, leader.GithubName)
+			exp.grantBoth(leader.GithubName, RoleGrant{Source: GrantedBySig, Sig: sigName, Level: leaderLevel})
 		}
 
 		for _, coLeader := range sig.Membership.CoLeaders {
+			leaders = append(leaders, coLeader.GithubName)
 			committers = append(committers, coLeader.GithubName)
 			reviewers = append(reviewers, coLeader.GithubName)
+			exp.grantBoth(coLeader.GithubName, RoleGrant{Source: GrantedBySig, Sig: sigName, Level: coLeaderLevel})
 		}
 
 		for _, committer := range sig.Membership.Committers {
 			committers = append(committers, committer.GithubName)
 			reviewers = append(reviewers, committer.GithubName)
+			exp.grantBoth(committer.GithubName, RoleGrant{Source: GrantedBySig, Sig: sigName, Level: committerLevel})
 		}
 
 		for _, reviewer := range sig.Membership.Reviewers {
 			reviewers = append(reviewers, reviewer.GithubName)
+			exp.grant(reviewer.GithubName, reviewerRole,
+				RoleGrant{Source: GrantedBySig, Sig: sigName, Level: reviewerLevel})
 		}
 
+		// The reviewers of the sig are the ones appended since the previous sig.
+		groups[tiexternalplugins.SigPrefix+sigName] = sets.NewString(reviewers[sigReviewersStart:]...)
+
 		if sig.NeedsLgtm > maxNeedsLgtm {
 			maxNeedsLgtm = sig.NeedsLgtm
+			maxNeedsLgtmSig = sigName
 		}
-
-		_ = res.Body.Close()
 	}
 
 	// If the number of lgtm is not specified, the maximum of sigName's needsLgtm is used.
 	if requireLgtm == 0 {
 		requireLgtm = maxNeedsLgtm
+		exp.needsLgtmFrom(NeedsLgtmFromSig, maxNeedsLgtmSig)
 	}
 
 	return &ownersclient.OwnersResponse{
 		Data: ownersclient.Owners{
-			Committers: sets.NewString(committers...).Insert(trustTeamMembers...).List(),
-			Reviewers:  sets.NewString(reviewers...).Insert(trustTeamMembers...).List(),
-			NeedsLgtm:  requireLgtm,
+			Leaders:        sets.NewString(leaders...).List(),
+			Committers:     sets.NewString(committers...).Insert(trustTeamMembers...).List(),
+			Reviewers:      sets.NewString(reviewers...).Insert(trustTeamMembers...).List(),
+			NeedsLgtm:      requireLgtm,
+			ReviewerGroups: reviewerGroups(groups, trustTeamMembers),
 		},
 		Message: listOwnersSuccessMessage,
 	}, nil
 }
 
 func (s *Server) listOwnersByGitHubPermission(org string, repo string,
-	trustTeamMembers []string, requireLgtm int) (*ownersclient.OwnersResponse, error) {
-	collaborators, err := listCollaborators(context.Background(), s.Log, s.Gc, org, repo)
+	trustTeamMembers []string, requireLgtm int, exp *explainer) (*ownersclient.OwnersResponse, error) {
+	collaborators, err := s.loadCollaborators(org, repo)
 	if err != nil {
 		s.Log.WithField("org", org).WithField("repo", repo).WithError(err).Error("Failed to list collaborators.")
 		return nil, err
@@ -274,13 +375,16 @@ func (s *Server) listOwnersByGitHubPermission(org string, repo string,
 	var reviewersLogin []string
 	var committersLogin []string
 	for login, permission := range collaborators {
+		grant := RoleGrant{Source: GrantedByGitHubPermission, Permission: permission}
 		if permission == triagePermission {
 			reviewersLogin = append(reviewersLogin, login)
+			exp.grant(login, reviewerRole, grant)
 		}
 
 		if permission == writePermission || permission == maintainPermission || permission == adminPermission {
 			reviewersLogin = append(reviewersLogin, login)
 			committersLogin = append(committersLogin, login)
+			exp.grantBoth(login, grant)
 		}
 	}
 	reviewers := sets.NewString(reviewersLogin...).Insert(trustTeamMembers...).List()
@@ -288,6 +392,7 @@ func (s *Server) listOwnersByGitHubPermission(org string, repo string,
 
 	if requireLgtm == 0 {
 		requireLgtm = defaultRequireLgtmNum
+		exp.needsLgtmFrom(NeedsLgtmFromDefault, "")
 	}
 
 	return &ownersclient.OwnersResponse{
@@ -303,12 +408,57 @@ func (s *Server) listOwnersByGitHubPermission(org string, repo string,
 // ListOwners returns owners of tidb community PR.
 func (s *Server) ListOwners(org string, repo string, number int,
 	config *tiexternalplugins.Configuration) (*ownersclient.OwnersResponse, error) {
+	ownersRes, _, err := s.listOwners(org, repo, number, config)
+	return ownersRes, err
+}
+
+// ExplainOwners explains where the owners of tidb community PR and the required lgtm number come from.
+// Only the user is explained if it is not empty.
+func (s *Server) ExplainOwners(org string, repo string, number int, user string,
+	config *tiexternalplugins.Configuration) (*OwnersExplanation, error) {
+	ownersRes, exp, err := s.listOwners(org, repo, number, config)
+	if err != nil {
+		return nil, err
+	}
+	return exp.explain(ownersRes.Data, user), nil
+}
+
+// listOwners returns owners of tidb community PR, and the explainer which collects where they come from.
+// The reviewer groups are only returned if the approval coverage is required.
+func (s *Server) listOwners(org string, repo string, number int,
+	config *tiexternalplugins.Configuration) (*ownersclient.OwnersResponse, *explainer, error) {
 	// Get pull request.
 	pull, err := s.Gc.GetPullRequest(org, repo, number)
 	if err != nil {
 		s.Log.WithField("pullNumber", number).WithError(err).Error("Failed to get pull request.")
-		return nil, err
+		return nil, nil, err
+	}
+
+	ownersRes, exp, err := s.listAllOwners(org, repo, pull, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := config.OwnersFor(org, repo)
+	// The branch configuration will override the repository configuration if it is set.
+	requireApprovalCoverage := opts.RequireApprovalCoverage
+	if branchConfig, ok := opts.Branches[pull.Base.Ref]; ok && branchConfig.RequireApprovalCoverage != nil {
+		requireApprovalCoverage = *branchConfig.RequireApprovalCoverage
 	}
+	if !requireApprovalCoverage {
+		ownersRes.Data.ReviewerGroups = nil
+	}
+	// The unavailable reviewers are still reviewers, so that their approvals count.
+	unavailable := s.getUnavailableUsers(opts.Availability, time.Now())
+	ownersRes.Data.Unavailable = unavailable.Intersection(sets.NewString(ownersRes.Data.Reviewers...)).List()
+	return ownersRes, exp, nil
+}
+
+// listAllOwners returns owners of tidb community PR with the reviewer groups of its areas.
+func (s *Server) listAllOwners(org string, repo string, pull *github.PullRequest,
+	config *tiexternalplugins.Configuration) (*ownersclient.OwnersResponse, *explainer, error) {
+	exp := newExplainer()
+	number := pull.Number
 
 	// Get the configuration.
 	opts := config.OwnersFor(org, repo)
@@ -321,25 +471,29 @@ func (s *Server) ListOwners(org string, repo string, number int,
 	requireLgtm, err := getRequireLgtmByLabel(pull.Labels, opts.RequireLgtmLabelPrefix)
 	if err != nil {
 		s.Log.WithField("pullNumber", number).WithError(err).Error("Failed to parse require lgtm.")
-		return nil, err
+		return nil, nil, err
 	}
 
 	// When we cannot find the require label from the PR, try to use the default require lgtm.
-	if requireLgtm == 0 {
-		if hasBranchConfig && branchConfig.DefaultRequireLgtm != 0 {
-			requireLgtm = branchConfig.DefaultRequireLgtm
-		} else {
-			requireLgtm = opts.DefaultRequireLgtm
-		}
+	if requireLgtm != 0 {
+		exp.needsLgtmFrom(NeedsLgtmFromLabel, opts.RequireLgtmLabelPrefix+strconv.Itoa(requireLgtm))
+	} else if hasBranchConfig && branchConfig.DefaultRequireLgtm != 0 {
+		requireLgtm = branchConfig.DefaultRequireLgtm
+		exp.needsLgtmFrom(NeedsLgtmFromBranchConfig, branchName)
+	} else {
+		requireLgtm = opts.DefaultRequireLgtm
+		exp.needsLgtmFrom(NeedsLgtmFromRepoConfig, "")
 	}
 
 	// Notice: If the branch of the PR has extra trust team config, it will override the repository config.
 	var trustTeams []string
+	trustTeamsLevel := repoConfigLevel
 
 	// Notice: If the configuration of the trust team gives an empty slice (not nil slice), the plugin
 	// will consider that the branch does not trust any team.
 	if hasBranchConfig && branchConfig.TrustTeams != nil {
 		trustTeams = branchConfig.TrustTeams
+		trustTeamsLevel = branchConfigLevel
 	} else {
 		trustTeams = opts.TrustTeams
 	}
@@ -348,8 +502,25 @@ func (s *Server) ListOwners(org string, repo string, number int,
 	trustTeamMembers := sets.String{}
 
 	for _, trustTeam := range trustTeams {
-		members := getTrustTeamMembers(s.Log, s.Gc, org, trustTeam)
+		members := s.getTrustTeamMembers(org, trustTeam)
 		trustTeamMembers.Insert(members...)
+		for _, member := range members {
+			exp.grantBoth(member, RoleGrant{Source: GrantedByTrustedTeam, Team: trustTeam, ConfigLevel: trustTeamsLevel})
+		}
+	}
+
+	// The owners files are used if they are configured, unless no committers are found in them.
+	ownersFileMode := opts.OwnersFileMode
+	if hasBranchConfig && len(branchConfig.OwnersFileMode) != 0 {
+		ownersFileMode = branchConfig.OwnersFileMode
+	}
+	if len(ownersFileMode) != 0 {
+		ownersRes, err := s.listOwnersByOwnersFiles(org, repo, pull, ownersFileMode, trustTeamMembers.List(),
+			requireLgtm, exp)
+		if err != nil || ownersRes != nil {
+			exp.sigSource = SigsFromOwnersFiles
+			return ownersRes, exp, err
+		}
 	}
 
 	useGitHubPermission := false
@@ -361,23 +532,101 @@ func (s *Server) ListOwners(org string, repo string, number int,
 	}
 	// If you use GitHub permissions, you can handle it directly.
 	if useGitHubPermission {
-		return s.listOwnersByGitHubPermission(org, repo, trustTeamMembers.List(), requireLgtm)
+		exp.sigSource = SigsNotUsed
+		ownersRes, err := s.listOwnersByGitHubPermission(org, repo, trustTeamMembers.List(), requireLgtm, exp)
+		return ownersRes, exp, err
 	}
 
 	// Find sig names by labels.
 	sigNames := getSigNamesByLabels(pull.Labels)
+	exp.sigSource = SigsFromLabels
 
 	// Use default sig name if cannot find.
 	if len(sigNames) == 0 && len(opts.DefaultSigName) != 0 {
 		sigNames = append(sigNames, opts.DefaultSigName)
+		exp.sigSource = SigsFromDefaultSigName
 	}
 	// When we cannot find a sig label for PR and there is no default sig name,
 	// the members of all sig will be reviewers and committers.
 	if len(sigNames) == 0 {
-		return s.listOwnersByAllSigs(opts, trustTeamMembers.List(), requireLgtm)
+		exp.sigSource = SigsFromAllSigs
+		ownersRes, err := s.listOwnersByAllSigs(s.membershipFor(org, opts), trustTeamMembers.List(), requireLgtm, exp)
+		return ownersRes, exp, err
 	}
 
-	return s.listOwnersBySigs(sigNames, opts, trustTeamMembers.List(), requireLgtm)
+	exp.sigs = sigNames
+	ownersRes, err := s.listOwnersBySigs(sigNames, s.membershipFor(org, opts), trustTeamMembers.List(), requireLgtm, exp)
+	return ownersRes, exp, err
+}
+
+// RegisterRoutes registers the owners API of the server to the router.
+func (s *Server) RegisterRoutes(router gin.IRoutes) {
+	router.GET("/"+PluginName+"/repos/:org/:repo/pulls/:number/owners", func(c *gin.Context) {
+		start := time.Now()
+		defer func() {
+			requestDuration.WithLabelValues(strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+		}()
+
+		owner := c.Param("org")
+		repo := c.Param("repo")
+		number := c.Param("number")
+
+		pullNumber, err := strconv.Atoi(number)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			s.Log.WithError(err).Error("Failed convert pull number.")
+			return
+		}
+
+		// Get config everytime.
+		config := s.ConfigAgent.Config()
+		ownersData, err := s.ListOwners(owner, repo, pullNumber, config)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			s.Log.WithError(err).Error("Failed list owners.")
+			return
+		}
+
+		c.JSON(http.StatusOK, ownersData)
+	})
+
+	// The explain API explains the owners of all the users, or the user specified by the user query parameter.
+	router.GET("/"+PluginName+"/repos/:org/:repo/pulls/:number/owners/explain", func(c *gin.Context) {
+		owner := c.Param("org")
+		repo := c.Param("repo")
+		number := c.Param("number")
+
+		pullNumber, err := strconv.Atoi(number)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			s.Log.WithError(err).Error("Failed convert pull number.")
+			return
+		}
+
+		config := s.ConfigAgent.Config()
+		explanation, err := s.ExplainOwners(owner, repo, pullNumber, c.Query("user"), config)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			s.Log.WithError(err).Error("Failed explain owners.")
+			return
+		}
+
+		c.JSON(http.StatusOK, ExplainResponse{Data: *explanation, Message: explainOwnersSuccessMessage})
+	})
+}
+
+// reviewerGroups returns the reviewer groups of the areas sorted by name, the members of the trusted teams
+// are reviewers of every area.
+func reviewerGroups(groups map[string]sets.String, trustTeamMembers []string) []ownersclient.ReviewerGroup {
+	areas := sets.StringKeySet(groups).List()
+	reviewerGroups := make([]ownersclient.ReviewerGroup, 0, len(areas))
+	for _, area := range areas {
+		reviewerGroups = append(reviewerGroups, ownersclient.ReviewerGroup{
+			Name:      area,
+			Reviewers: sets.NewString(trustTeamMembers...).Union(groups[area]).List(),
+		})
+	}
+	return reviewerGroups
 }
 
 // getSigNamesByLabels returns the names of sig when the label prefix matches.
@@ -414,25 +663,26 @@ func getRequireLgtmByLabel(labels []github.Label, labelPrefix string) (int, erro
 	return noRequireLgtm, nil
 }
 
-// getTrustTeamMembers returns the members of trust team.
-func getTrustTeamMembers(log *logrus.Entry, gc githubClient, org, trustTeam string) []string {
-	if len(trustTeam) > 0 {
-		if teams, err := gc.ListTeams(org); err == nil {
-			for _, teamInOrg := range teams {
-				if strings.Compare(teamInOrg.Name, trustTeam) == 0 {
-					if members, err := gc.ListTeamMembers(org, teamInOrg.ID, github.RoleAll); err == nil {
-						var membersLogin []string
-						for _, member := range members {
-							membersLogin = append(membersLogin, member.Login)
-						}
-						return membersLogin
-					}
-					log.WithError(err).Errorf("Failed to list members in %s:%s.", org, teamInOrg.Name)
-				}
+// listTrustTeamMembers returns the login of the members of the trust team, which is the name or the slug
+// of the team, it returns no members if the team does not exist.
+func listTrustTeamMembers(gc githubClient, org, trustTeam string) ([]string, error) {
+	teams, err := gc.ListTeams(org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams in org %s: %w", org, err)
+	}
+
+	membersLogin := []string{}
+	for _, teamInOrg := range teams {
+		if teamInOrg.Name == trustTeam || teamInOrg.Slug == trustTeam {
+			members, err := gc.ListTeamMembers(org, teamInOrg.ID, github.RoleAll)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list members in %s:%s: %w", org, teamInOrg.Name, err)
+			}
+			for _, member := range members {
+				membersLogin = append(membersLogin, member.Login)
 			}
-		} else {
-			log.WithError(err).Errorf("Failed to list teams in org %s.", org)
+			return membersLogin, nil
 		}
 	}
-	return []string{}
+	return membersLogin, nil
 }
@@ -15,6 +15,7 @@ import (
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
 	"gotest.tools/assert"
 	"k8s.io/test-infra/prow/github"
 )
@@ -22,6 +23,14 @@ import (
 type fakegithub struct {
 	PullRequests  map[int]*github.PullRequest
 	Collaborators []RepositoryCollaboratorConnection
+	// Changes specifies the changed files of the PRs.
+	Changes map[int][]github.PullRequestChange
+	// Files specifies the files of the repo by the branch and the path.
+	Files map[string]map[string][]byte
+	// IssueComments specifies the comments of the issues.
+	IssueComments map[int][]github.IssueComment
+	// CreatedComments records the comments created on the issues.
+	CreatedComments map[int][]string
 }
 
 // GetPullRequest returns details about the PR.
@@ -33,6 +42,34 @@ func (f *fakegithub) GetPullRequest(owner, repo string, number int) (*github.Pul
 	return val, nil
 }
 
+// GetPullRequestChanges returns the changed files of the PR.
+func (f *fakegithub) GetPullRequestChanges(_, _ string, number int) ([]github.PullRequestChange, error) {
+	return f.Changes[number], nil
+}
+
+// GetFile returns the content of the file at the branch.
+func (f *fakegithub) GetFile(org, repo, filepath, commit string) ([]byte, error) {
+	content, ok := f.Files[commit][filepath]
+	if !ok {
+		return nil, &github.FileNotFound{}
+	}
+	return content, nil
+}
+
+// ListIssueComments returns the comments of the issue.
+func (f *fakegithub) ListIssueComments(_, _ string, number int) ([]github.IssueComment, error) {
+	return f.IssueComments[number], nil
+}
+
+// CreateComment records the comment created on the issue.
+func (f *fakegithub) CreateComment(_, _ string, number int, comment string) error {
+	if f.CreatedComments == nil {
+		f.CreatedComments = make(map[int][]string)
+	}
+	f.CreatedComments[number] = append(f.CreatedComments[number], comment)
+	return nil
+}
+
 func (f *fakegithub) Query(_ context.Context, q interface{}, _ map[string]interface{}) error {
 	query, ok := q.(*collaboratorsQuery)
 	if !ok {
@@ -257,6 +294,7 @@ func TestListOwners(t *testing.T) {
 		useGitHubPermission    bool
 		branchesConfig         map[string]tiexternalplugins.TiCommunityOwnerBranchConfig
 
+		expectLeaders    []string
 		expectCommitters []string
 		expectReviewers  []string
 		expectNeedsLgtm  int
@@ -269,6 +307,7 @@ func TestListOwners(t *testing.T) {
 					Name: "sig/sig1",
 				},
 			},
+			expectLeaders: []string{"coLeader1", "coLeader2", "leader1", "leader2"},
 			expectCommitters: []string{
 				"leader1", "leader2", "coLeader1", "coLeader2",
 				"committer1", "committer2",
@@ -721,6 +760,10 @@ func TestListOwners(t *testing.T) {
 				t.Errorf("unexpected error: '%v'", err)
 			}
 
+			if tc.expectLeaders != nil && !reflect.DeepEqual(res.Data.Leaders, tc.expectLeaders) {
+				t.Errorf("Different leaders: Got \"%v\" expected \"%v\"", res.Data.Leaders, tc.expectLeaders)
+			}
+
 			sort.Strings(res.Data.Committers)
 			sort.Strings(tc.expectCommitters)
 
@@ -1002,3 +1045,164 @@ func TestGetRequireLgtmByLabel(t *testing.T) {
 		})
 	}
 }
+
+func TestListOwnersReviewerGroups(t *testing.T) {
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	pullNumber := 1
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sigs/sig1", func(res http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(res).Encode(SigResponse{Data: SigInfo{
+			Name: "sig1",
+			Membership: SigMembership{
+				TechLeaders: []MemberInfo{{GithubName: "leader1"}},
+				Reviewers:   []MemberInfo{{GithubName: "reviewer1"}},
+			},
+		}})
+	})
+	mux.HandleFunc("/sigs/sig2", func(res http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(res).Encode(SigResponse{Data: SigInfo{
+			Name: "sig2",
+			Membership: SigMembership{
+				Committers: []MemberInfo{{GithubName: "committer2"}},
+			},
+		}})
+	})
+	testServer := httptest.NewServer(mux)
+	defer testServer.Close()
+
+	files := map[string][]byte{
+		"OWNERS":               []byte("approvers:\n  - root-approver\n"),
+		"executor/OWNERS":      []byte("reviewers:\n  - exec-reviewer\n"),
+		"executor/join/OWNERS": []byte("options:\n  no_parent_owners: false\n"),
+		"docs/OWNERS": []byte("options:\n  no_parent_owners: true\n" +
+			"approvers:\n  - doc-approver\n"),
+		".github/CODEOWNERS": []byte("*  @default-owner\n" +
+			"*.md  @doc-owner\n" +
+			"/build/  docs@example.com\n"),
+	}
+	requireCoverage, notRequireCoverage := true, false
+	changes := []github.PullRequestChange{
+		{Filename: "executor/join/join.go"},
+		{Filename: "docs/README.md"},
+		{Filename: "main.go"},
+		{Filename: "build/Makefile"},
+	}
+
+	testcases := []struct {
+		name   string
+		labels []github.Label
+		owners tiexternalplugins.TiCommunityOwners
+
+		expectGroups []ownersclient.ReviewerGroup
+	}{
+		{
+			name:   "sig labels",
+			labels: []github.Label{{Name: "sig/sig1"}, {Name: "sig/sig2"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				TrustTeams:              []string{"Leads"},
+				RequireApprovalCoverage: true,
+			},
+			expectGroups: []ownersclient.ReviewerGroup{
+				{Name: "sig/sig1", Reviewers: []string{"leader1", "reviewer1", "sig-leader1", "sig-leader2"}},
+				{Name: "sig/sig2", Reviewers: []string{"committer2", "sig-leader1", "sig-leader2"}},
+			},
+		},
+		{
+			name:   "approval coverage not required",
+			labels: []github.Label{{Name: "sig/sig1"}, {Name: "sig/sig2"}},
+		},
+		{
+			name:   "approval coverage required by the branch",
+			labels: []github.Label{{Name: "sig/sig1"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				Branches: map[string]tiexternalplugins.TiCommunityOwnerBranchConfig{
+					"master": {RequireApprovalCoverage: &requireCoverage},
+				},
+			},
+			expectGroups: []ownersclient.ReviewerGroup{
+				{Name: "sig/sig1", Reviewers: []string{"leader1", "reviewer1"}},
+			},
+		},
+		{
+			name:   "approval coverage not required by the branch",
+			labels: []github.Label{{Name: "sig/sig1"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				RequireApprovalCoverage: true,
+				Branches: map[string]tiexternalplugins.TiCommunityOwnerBranchConfig{
+					"master": {RequireApprovalCoverage: &notRequireCoverage},
+				},
+			},
+		},
+		{
+			name:   "approval coverage inherited by the branch",
+			labels: []github.Label{{Name: "sig/sig1"}},
+			owners: tiexternalplugins.TiCommunityOwners{
+				RequireApprovalCoverage: true,
+				Branches: map[string]tiexternalplugins.TiCommunityOwnerBranchConfig{
+					"master": {DefaultRequireLgtm: 2},
+				},
+			},
+			expectGroups: []ownersclient.ReviewerGroup{
+				{Name: "sig/sig1", Reviewers: []string{"leader1", "reviewer1"}},
+			},
+		},
+		{
+			name: "owners files",
+			owners: tiexternalplugins.TiCommunityOwners{
+				OwnersFileMode:          tiexternalplugins.OwnersFileModeOwners,
+				RequireApprovalCoverage: true,
+			},
+			expectGroups: []ownersclient.ReviewerGroup{
+				{Name: "OWNERS", Reviewers: []string{"root-approver"}},
+				{Name: "docs/OWNERS", Reviewers: []string{"doc-approver"}},
+				{Name: "executor/OWNERS", Reviewers: []string{"exec-reviewer", "root-approver"}},
+			},
+		},
+		{
+			name: "codeowners",
+			owners: tiexternalplugins.TiCommunityOwners{
+				OwnersFileMode:          tiexternalplugins.OwnersFileModeCodeowners,
+				RequireApprovalCoverage: true,
+			},
+			expectGroups: []ownersclient.ReviewerGroup{
+				{Name: "*", Reviewers: []string{"default-owner"}},
+				{Name: "*.md", Reviewers: []string{"doc-owner"}},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			owners := tc.owners
+			owners.Repos = []string{org + "/" + repoName}
+			owners.SigEndpoint = testServer.URL
+			config := &tiexternalplugins.Configuration{
+				TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{owners},
+			}
+
+			fc := &fakegithub{
+				PullRequests: map[int]*github.PullRequest{
+					pullNumber: {Base: github.PullRequestBranch{Ref: "master"}, Number: pullNumber, Labels: tc.labels},
+				},
+				Changes: map[int][]github.PullRequestChange{pullNumber: changes},
+				Files:   map[string]map[string][]byte{"master": files},
+			}
+			ownersServer := Server{
+				Client: testServer.Client(),
+				Gc:     fc,
+				Log:    logrus.WithField("server", "testing"),
+			}
+
+			res, err := ownersServer.ListOwners(org, repoName, pullNumber, config)
+			if err != nil {
+				t.Fatalf("list owners failed: %v", err)
+			}
+			if !reflect.DeepEqual(res.Data.ReviewerGroups, tc.expectGroups) {
+				t.Errorf("Different reviewer groups: Got \"%v\" expected \"%v\"", res.Data.ReviewerGroups, tc.expectGroups)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package owners
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ownersFileName specifies the name of the OWNERS files.
+	ownersFileName = "OWNERS"
+	// ownersAliasesFileName specifies the name of the file in the root directory which defines the aliases
+	// of the users used in the OWNERS files.
+	ownersAliasesFileName = "OWNERS_ALIASES"
+)
+
+// codeownersPaths specifies the paths of the CODEOWNERS file in the order GitHub looks for it.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// ownersFile is an OWNERS file, the approvers of the files in its directory are committers.
+type ownersFile struct {
+	Approvers []string `json:"approvers,omitempty"`
+	Reviewers []string `json:"reviewers,omitempty"`
+	Options   struct {
+		// NoParentOwners means the OWNERS files in the parent directories are ignored.
+		NoParentOwners bool `json:"no_parent_owners,omitempty"`
+	} `json:"options,omitempty"`
+}
+
+// ownersAliases is an OWNERS_ALIASES file.
+type ownersAliases struct {
+	Aliases map[string][]string `json:"aliases,omitempty"`
+}
+
+// codeownersRule is a line of the CODEOWNERS file.
+type codeownersRule struct {
+	pattern string
+	regex   *regexp.Regexp
+	owners  []string
+}
+
+// ownersCollector collects the owners of the changed files, and groups the reviewers by the areas of the files.
+type ownersCollector struct {
+	committers sets.String
+	reviewers  sets.String
+	groups     map[string]sets.String
+	exp        *explainer
+}
+
+func newOwnersCollector(exp *explainer) *ownersCollector {
+	return &ownersCollector{
+		committers: sets.NewString(),
+		reviewers:  sets.NewString(),
+		groups:     make(map[string]sets.String),
+		exp:        exp,
+	}
+}
+
+// group adds the reviewers to the reviewer group of the area.
+func (c *ownersCollector) group(area string, reviewers ...string) {
+	if _, ok := c.groups[area]; !ok {
+		c.groups[area] = sets.NewString()
+	}
+	c.groups[area].Insert(reviewers...)
+}
+
+// loadFile returns the content of the file at the branch of the repo, it returns nil if the file does not exist.
+func (s *Server) loadFile(org, repo, branch, filePath string) ([]byte, error) {
+	value, err := s.Cache.get(filesSource, cacheKey(org, repo, branch, filePath), func() (interface{}, error) {
+		content, err := s.Gc.GetFile(org, repo, filePath, branch)
+		if err != nil {
+			if _, ok := err.(*github.FileNotFound); ok {
+				return []byte(nil), nil
+			}
+			return nil, err
+		}
+		return content, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+// changedFiles returns the files changed by the PR, including the original paths of the renamed files.
+func (s *Server) changedFiles(org, repo string, number int) ([]string, error) {
+	changes, err := s.Gc.GetPullRequestChanges(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	files := sets.NewString()
+	for _, change := range changes {
+		files.Insert(change.Filename)
+		if len(change.PreviousFilename) != 0 {
+			files.Insert(change.PreviousFilename)
+		}
+	}
+	return files.List(), nil
+}
+
+// listOwnersByOwnersFiles returns the owners of the files changed by the PR according to the OWNERS
+// or CODEOWNERS files at the base branch. It returns nil if no committers are found in the files.
+func (s *Server) listOwnersByOwnersFiles(org string, repo string, pull *github.PullRequest, mode string,
+	trustTeamMembers []string, requireLgtm int, exp *explainer) (*ownersclient.OwnersResponse, error) {
+	files, err := s.changedFiles(org, repo, pull.Number)
+	if err != nil {
+		s.Log.WithField("pullNumber", pull.Number).WithError(err).Error("Failed to list changed files.")
+		return nil, err
+	}
+
+	// The grants are recorded only if the owners files are used.
+	collector := newOwnersCollector(newExplainer())
+	switch mode {
+	case tiexternalplugins.OwnersFileModeOwners:
+		err = s.collectOwnersFilesOwners(org, repo, pull.Base.Ref, files, collector)
+	case tiexternalplugins.OwnersFileModeCodeowners:
+		err = s.collectCodeownersOwners(org, repo, pull.Base.Ref, files, collector)
+	default:
+		err = fmt.Errorf("unknown owners file mode %q", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if collector.committers.Len() == 0 {
+		s.Log.WithField("pullNumber", pull.Number).WithField("mode", mode).
+			Info("No committers found in the owners files, fall back to the other owners.")
+		return nil, nil
+	}
+	exp.merge(collector.exp)
+
+	if requireLgtm == 0 {
+		requireLgtm = defaultRequireLgtmNum
+		exp.needsLgtmFrom(NeedsLgtmFromDefault, "")
+	}
+
+	return &ownersclient.OwnersResponse{
+		Data: ownersclient.Owners{
+			Committers:     collector.committers.Insert(trustTeamMembers...).List(),
+			Reviewers:      collector.reviewers.Insert(trustTeamMembers...).List(),
+			NeedsLgtm:      requireLgtm,
+			ReviewerGroups: reviewerGroups(collector.groups, trustTeamMembers),
+		},
+		Message: listOwnersSuccessMessage,
+	}, nil
+}
+
+// collectOwnersFilesOwners collects the approvers and the reviewers in the OWNERS files of the directories
+// of the files and their parent directories, until an OWNERS file sets no_parent_owners. The files are grouped
+// by the nearest OWNERS file which has owners.
+func (s *Server) collectOwnersFilesOwners(org, repo, branch string, files []string, c *ownersCollector) error {
+	aliases := ownersAliases{}
+	content, err := s.loadFile(org, repo, branch, ownersAliasesFileName)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(content, &aliases); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", ownersAliasesFileName, err)
+	}
+	expand := func(names []string) []string {
+		var logins []string
+		for _, name := range names {
+			if members, ok := aliases.Aliases[name]; ok {
+				logins = append(logins, members...)
+			} else {
+				logins = append(logins, name)
+			}
+		}
+		return logins
+	}
+
+	parsed := make(map[string]*ownersFile)
+	for _, file := range files {
+		var area string
+		areaReviewers := sets.NewString()
+		for dir := path.Dir(file); ; dir = path.Dir(dir) {
+			ownersPath := path.Join(dir, ownersFileName)
+			owners, ok := parsed[ownersPath]
+			if !ok {
+				content, err := s.loadFile(org, repo, branch, ownersPath)
+				if err != nil {
+					return err
+				}
+				owners = &ownersFile{}
+				if err := yaml.Unmarshal(content, owners); err != nil {
+					return fmt.Errorf("failed to parse %s: %w", ownersPath, err)
+				}
+				parsed[ownersPath] = owners
+
+				grant := RoleGrant{Source: GrantedByOwnersFile, File: ownersPath}
+				for _, approver := range expand(owners.Approvers) {
+					c.committers.Insert(approver)
+					c.reviewers.Insert(approver)
+					c.exp.grantBoth(approver, grant)
+				}
+				for _, reviewer := range expand(owners.Reviewers) {
+					c.reviewers.Insert(reviewer)
+					c.exp.grant(reviewer, reviewerRole, grant)
+				}
+			}
+
+			owned := append(expand(owners.Approvers), expand(owners.Reviewers)...)
+			if len(area) == 0 && len(owned) != 0 {
+				area = ownersPath
+			}
+			areaReviewers.Insert(owned...)
+
+			if owners.Options.NoParentOwners || dir == "." {
+				break
+			}
+		}
+		if len(area) != 0 {
+			c.group(area, areaReviewers.UnsortedList()...)
+		}
+	}
+	return nil
+}
+
+// collectCodeownersOwners collects the owners of the files in the CODEOWNERS file, the last matching rule
+// of a file takes precedence. The members of the teams are the owners, and the emails are ignored. The files
+// are grouped by the pattern of the matching rule.
+func (s *Server) collectCodeownersOwners(org, repo, branch string, files []string, c *ownersCollector) error {
+	var codeownersPath string
+	var content []byte
+	for _, p := range codeownersPaths {
+		c, err := s.loadFile(org, repo, branch, p)
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			codeownersPath, content = p, c
+			break
+		}
+	}
+	if content == nil {
+		return nil
+	}
+
+	rules, err := parseCodeowners(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", codeownersPath, err)
+	}
+
+	for _, file := range files {
+		var matched *codeownersRule
+		for i := range rules {
+			if rules[i].regex.MatchString(file) {
+				matched = &rules[i]
+			}
+		}
+		if matched == nil {
+			continue
+		}
+
+		for _, owner := range matched.owners {
+			grant := RoleGrant{Source: GrantedByOwnersFile, File: codeownersPath, Pattern: matched.pattern}
+			logins := []string{owner}
+			if parts := strings.SplitN(owner, "/", 2); len(parts) == 2 {
+				if !strings.EqualFold(parts[0], org) {
+					continue
+				}
+				grant.Team = parts[1]
+				logins = s.getTrustTeamMembers(org, parts[1])
+			}
+			for _, login := range logins {
+				c.committers.Insert(login)
+				c.reviewers.Insert(login)
+				c.exp.grantBoth(login, grant)
+				c.group(matched.pattern, login)
+			}
+		}
+	}
+	return nil
+}
+
+// parseCodeowners parses the rules of the CODEOWNERS file. The owners are the logins of the users,
+// or the org and the slug of the teams, without the "@" prefix.
+func parseCodeowners(content []byte) ([]codeownersRule, error) {
+	var rules []codeownersRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		rule := codeownersRule{pattern: fields[0], regex: codeownersPatternRegex(fields[0])}
+		for _, owner := range fields[1:] {
+			// The owners without the "@" prefix are emails.
+			if strings.HasPrefix(owner, "@") {
+				rule.owners = append(rule.owners, strings.TrimPrefix(owner, "@"))
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// codeownersPatternRegex converts the gitignore style pattern of the CODEOWNERS file into a regex
+// matching the paths of the files.
+func codeownersPatternRegex(pattern string) *regexp.Regexp {
+	// The pattern is relative to the root if it has a slash at the beginning or in the middle.
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	switch {
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "*") && !strings.HasSuffix(pattern, "**"):
+		// A trailing asterisk does not match the files in the subdirectories.
+		b.WriteString("$")
+	default:
+		// A pattern matching a directory matches all the files in it.
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.MustCompile(b.String())
+}
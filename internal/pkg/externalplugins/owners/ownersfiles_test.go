@@ -0,0 +1,220 @@
+package owners
+
+import (
+	"reflect"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	tiexternalplugins "github.com/ti-community-infra/tichi/internal/pkg/externalplugins"
+	"k8s.io/test-infra/prow/github"
+)
+
+func TestListOwnersByOwnersFiles(t *testing.T) {
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	pullNumber := 1
+
+	files := map[string][]byte{
+		"OWNERS_ALIASES": []byte("aliases:\n  sig-exec:\n    - alias1\n"),
+		"OWNERS":         []byte("approvers:\n  - root-approver\n"),
+		"executor/OWNERS": []byte("approvers:\n  - sig-exec\n" +
+			"reviewers:\n  - exec-reviewer\n"),
+		"docs/OWNERS": []byte("options:\n  no_parent_owners: true\n" +
+			"approvers:\n  - doc-approver\n"),
+		".github/CODEOWNERS": []byte("# Default owners.\n" +
+			"*  @default-owner\n" +
+			"/executor/  @ti-community-infra/Leads @exec-owner # The executor owners.\n" +
+			"*.md  docs@example.com @doc-owner\n"),
+	}
+
+	testcases := []struct {
+		name    string
+		owners  tiexternalplugins.TiCommunityOwners
+		files   map[string][]byte
+		changes []github.PullRequestChange
+
+		expectCommitters []string
+		expectReviewers  []string
+		expectSigSource  string
+	}{
+		{
+			name:             "owners files of the parent directories",
+			owners:           tiexternalplugins.TiCommunityOwners{OwnersFileMode: tiexternalplugins.OwnersFileModeOwners},
+			files:            files,
+			changes:          []github.PullRequestChange{{Filename: "executor/join/join.go"}},
+			expectCommitters: []string{"alias1", "root-approver"},
+			expectReviewers:  []string{"alias1", "exec-reviewer", "root-approver"},
+			expectSigSource:  SigsFromOwnersFiles,
+		},
+		{
+			name:             "no parent owners",
+			owners:           tiexternalplugins.TiCommunityOwners{OwnersFileMode: tiexternalplugins.OwnersFileModeOwners},
+			files:            files,
+			changes:          []github.PullRequestChange{{Filename: "docs/README.md"}},
+			expectCommitters: []string{"doc-approver"},
+			expectReviewers:  []string{"doc-approver"},
+			expectSigSource:  SigsFromOwnersFiles,
+		},
+		{
+			name: "owners of all the changed files with trusted teams",
+			owners: tiexternalplugins.TiCommunityOwners{
+				OwnersFileMode: tiexternalplugins.OwnersFileModeOwners,
+				TrustTeams:     []string{"Leads"},
+			},
+			files: files,
+			changes: []github.PullRequestChange{
+				{Filename: "executor/join/join.go"},
+				{Filename: "docs/design.md", PreviousFilename: "design.md"},
+			},
+			expectCommitters: []string{"alias1", "doc-approver", "root-approver", "sig-leader1", "sig-leader2"},
+			expectReviewers: []string{"alias1", "doc-approver", "exec-reviewer", "root-approver",
+				"sig-leader1", "sig-leader2"},
+			expectSigSource: SigsFromOwnersFiles,
+		},
+		{
+			name:             "codeowners of team",
+			owners:           tiexternalplugins.TiCommunityOwners{OwnersFileMode: tiexternalplugins.OwnersFileModeCodeowners},
+			files:            files,
+			changes:          []github.PullRequestChange{{Filename: "executor/join/join.go"}},
+			expectCommitters: []string{"exec-owner", "sig-leader1", "sig-leader2"},
+			expectReviewers:  []string{"exec-owner", "sig-leader1", "sig-leader2"},
+			expectSigSource:  SigsFromOwnersFiles,
+		},
+		{
+			name: "last matching codeowners rule",
+			owners: tiexternalplugins.TiCommunityOwners{
+				OwnersFileMode: tiexternalplugins.OwnersFileModeOwners,
+				Branches: map[string]tiexternalplugins.TiCommunityOwnerBranchConfig{
+					"master": {OwnersFileMode: tiexternalplugins.OwnersFileModeCodeowners},
+				},
+			},
+			files:            files,
+			changes:          []github.PullRequestChange{{Filename: "executor/README.md"}, {Filename: "main.go"}},
+			expectCommitters: []string{"default-owner", "doc-owner"},
+			expectReviewers:  []string{"default-owner", "doc-owner"},
+			expectSigSource:  SigsFromOwnersFiles,
+		},
+		{
+			name: "no owners files",
+			owners: tiexternalplugins.TiCommunityOwners{
+				OwnersFileMode:      tiexternalplugins.OwnersFileModeCodeowners,
+				UseGitHubPermission: true,
+			},
+			changes:          []github.PullRequestChange{{Filename: "main.go"}},
+			expectCommitters: []string{"collab1"},
+			expectReviewers:  []string{"collab1"},
+			expectSigSource:  SigsNotUsed,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			owners := tc.owners
+			owners.Repos = []string{org + "/" + repoName}
+			config := &tiexternalplugins.Configuration{
+				TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{owners},
+			}
+
+			fc := &fakegithub{
+				PullRequests: map[int]*github.PullRequest{
+					pullNumber: {Base: github.PullRequestBranch{Ref: "master"}, Number: pullNumber},
+				},
+				Collaborators: []RepositoryCollaboratorConnection{
+					{Permission: writePermission, Node: struct{ Login githubql.String }{Login: "collab1"}},
+				},
+				Changes: map[int][]github.PullRequestChange{pullNumber: tc.changes},
+				Files:   map[string]map[string][]byte{"master": tc.files},
+			}
+			ownersServer := Server{
+				Gc:    fc,
+				Log:   logrus.WithField("server", "testing"),
+				Cache: newTestCache(0),
+			}
+
+			res, exp, err := ownersServer.listOwners(org, repoName, pullNumber, config)
+			if err != nil {
+				t.Fatalf("list owners failed: %v", err)
+			}
+			if !reflect.DeepEqual(res.Data.Committers, tc.expectCommitters) {
+				t.Errorf("Different committers: Got \"%v\" expected \"%v\"", res.Data.Committers, tc.expectCommitters)
+			}
+			if !reflect.DeepEqual(res.Data.Reviewers, tc.expectReviewers) {
+				t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", res.Data.Reviewers, tc.expectReviewers)
+			}
+			if res.Data.NeedsLgtm != defaultRequireLgtmNum {
+				t.Errorf("Different LGTM: Got \"%v\" expected \"%v\"", res.Data.NeedsLgtm, defaultRequireLgtmNum)
+			}
+			if exp.sigSource != tc.expectSigSource {
+				t.Errorf("Different sig source: Got \"%v\" expected \"%v\"", exp.sigSource, tc.expectSigSource)
+			}
+		})
+	}
+}
+
+func TestExplainOwnersFiles(t *testing.T) {
+	fc := &fakegithub{
+		PullRequests: map[int]*github.PullRequest{
+			1: {Base: github.PullRequestBranch{Ref: "master"}, Number: 1},
+		},
+		Changes: map[int][]github.PullRequestChange{1: {{Filename: "executor/join.go"}, {Filename: "main.go"}}},
+		Files: map[string]map[string][]byte{"master": {
+			"OWNERS":          []byte("approvers:\n  - approver1\n"),
+			"executor/OWNERS": []byte("reviewers:\n  - approver1\n"),
+		}},
+	}
+	config := &tiexternalplugins.Configuration{
+		TiCommunityOwners: []tiexternalplugins.TiCommunityOwners{{
+			Repos:          []string{"ti-community-infra/test-dev"},
+			OwnersFileMode: tiexternalplugins.OwnersFileModeOwners,
+		}},
+	}
+	ownersServer := Server{Gc: fc, Log: logrus.WithField("server", "testing")}
+
+	explanation, err := ownersServer.ExplainOwners("ti-community-infra", "test-dev", 1, "approver1", config)
+	if err != nil {
+		t.Fatalf("explain owners failed: %v", err)
+	}
+	expectGrants := []RoleGrant{
+		{Role: reviewerRole, Source: GrantedByOwnersFile, File: "executor/OWNERS"},
+		{Role: committerRole, Source: GrantedByOwnersFile, File: "OWNERS"},
+		{Role: reviewerRole, Source: GrantedByOwnersFile, File: "OWNERS"},
+	}
+	if !reflect.DeepEqual(explanation.Users[0].Grants, expectGrants) {
+		t.Errorf("Different grants: Got \"%v\" expected \"%v\"", explanation.Users[0].Grants, expectGrants)
+	}
+}
+
+func TestCodeownersPatternRegex(t *testing.T) {
+	testcases := []struct {
+		pattern string
+		path    string
+
+		expectMatch bool
+	}{
+		{pattern: "*", path: "executor/join/join.go", expectMatch: true},
+		{pattern: "*.js", path: "web/src/app.js", expectMatch: true},
+		{pattern: "*.js", path: "web/src/app.jsx", expectMatch: false},
+		{pattern: "/build/logs/", path: "build/logs/a/b.log", expectMatch: true},
+		{pattern: "/build/logs/", path: "src/build/logs/b.log", expectMatch: false},
+		{pattern: "apps/", path: "src/apps/main.go", expectMatch: true},
+		{pattern: "docs/*", path: "docs/getting-started.md", expectMatch: true},
+		{pattern: "docs/*", path: "docs/build-app/troubleshooting.md", expectMatch: false},
+		{pattern: "**/logs", path: "build/logs/a.log", expectMatch: true},
+		{pattern: "/docs/**/*.md", path: "docs/a/b/c.md", expectMatch: true},
+		{pattern: "/docs/**/*.md", path: "docs/c.md", expectMatch: true},
+		{pattern: "executor", path: "executor/join.go", expectMatch: true},
+		{pattern: "executor", path: "executors/join.go", expectMatch: false},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			match := codeownersPatternRegex(tc.pattern).MatchString(tc.path)
+			if match != tc.expectMatch {
+				t.Errorf("Different match: Got \"%v\" expected \"%v\"", match, tc.expectMatch)
+			}
+		})
+	}
+}
@@ -42,3 +42,57 @@ type MembersInfo struct {
 	Members []MemberInfo `json:"members,omitempty"`
 	Total   int          `json:"total,omitempty"`
 }
+
+// ExplainResponse specifies the response to the request to explain the owners.
+type ExplainResponse struct {
+	Data    OwnersExplanation `json:"data,omitempty"`
+	Message string            `json:"message,omitempty"`
+}
+
+// OwnersExplanation explains where the owners of a PR and the required lgtm number come from.
+type OwnersExplanation struct {
+	// SigSource specifies how the sigs are chosen, see the SigsFrom constants.
+	SigSource string `json:"sigSource"`
+	// Sigs specifies the names of the sigs whose members are the owners.
+	Sigs []string `json:"sigs,omitempty"`
+	// NeedsLgtm specifies the required lgtm number.
+	NeedsLgtm int `json:"needsLGTM"`
+	// NeedsLgtmSource specifies where the required lgtm number comes from, see the NeedsLgtmFrom constants.
+	NeedsLgtmSource string `json:"needsLGTMSource"`
+	// NeedsLgtmDetail specifies the require lgtm label or the sig which the required lgtm number comes from.
+	NeedsLgtmDetail string `json:"needsLGTMDetail,omitempty"`
+	// Users explains the roles of the users.
+	Users []UserExplanation `json:"users"`
+}
+
+// UserExplanation explains the roles of a user.
+type UserExplanation struct {
+	Login     string `json:"login"`
+	Leader    bool   `json:"leader"`
+	Committer bool   `json:"committer"`
+	Reviewer  bool   `json:"reviewer"`
+	// Grants specifies the sources which grant the roles to the user.
+	Grants []RoleGrant `json:"grants,omitempty"`
+}
+
+// RoleGrant specifies a source which grants a role to a user.
+type RoleGrant struct {
+	// Role specifies the granted role, which is committer or reviewer.
+	Role string `json:"role"`
+	// Source specifies the kind of the source, see the GrantedBy constants.
+	Source string `json:"source"`
+	// Sig specifies the name of the sig, it is empty when the members of all sigs are used.
+	Sig string `json:"sig,omitempty"`
+	// Level specifies the level of the member in the sig.
+	Level string `json:"level,omitempty"`
+	// Team specifies the name of the trusted team.
+	Team string `json:"team,omitempty"`
+	// ConfigLevel specifies whether the trusted team is configured for the repo or the branch.
+	ConfigLevel string `json:"configLevel,omitempty"`
+	// Permission specifies the permission of the collaborator to the repo.
+	Permission string `json:"permission,omitempty"`
+	// File specifies the OWNERS or CODEOWNERS file.
+	File string `json:"file,omitempty"`
+	// Pattern specifies the pattern of the CODEOWNERS rule.
+	Pattern string `json:"pattern,omitempty"`
+}
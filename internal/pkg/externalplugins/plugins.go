@@ -1,34 +1,115 @@
 package externalplugins
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// ConfigStatusPath specifies the path of the config status endpoint.
+	ConfigStatusPath = "/config-status"
+)
+
 var (
 	// pullDuration is a duration for pull config form file.
+	// The file watcher is preferred, polling is used as a fallback.
 	pullDuration = 1 * time.Minute
 )
 
+// Delta represents the before and after states of a Configuration change detected by the ConfigAgent.
+//
+// NOTICE: Before and After are shallow copies, they share slices and maps with the configurations
+// held by the agent, so subscribers must not modify them.
+type Delta struct {
+	Before, After Configuration
+}
+
+// DeltaChan is a channel to receive config delta events when config changes.
+type DeltaChan = chan<- Delta
+
+// ConfigStatus describes the state of the configuration currently held by the ConfigAgent.
+type ConfigStatus struct {
+	// Hash specifies the sha256 hash of the configuration file currently applied.
+	Hash string `json:"hash,omitempty"`
+	// LastLoadTime specifies the time when the current configuration was applied.
+	LastLoadTime time.Time `json:"last_load_time,omitempty"`
+	// LastError specifies the error of the last failed load, it is cleared after a successful load.
+	LastError string `json:"last_error,omitempty"`
+	// LastErrorTime specifies the time of the last failed load.
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// subscription delivers deltas to a subscriber in order. When the subscriber falls behind,
+// the pending delta is merged with the newer one, so it always receives the latest config.
+type subscription struct {
+	sub     DeltaChan
+	pending chan Delta
+}
+
+// deliver sends the pending deltas to the subscriber until the context is done, it never blocks on
+// a subscriber that stops reading after the context is done.
+func (s *subscription) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delta := <-s.pending:
+			select {
+			case s.sub <- delta:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 // ConfigAgent contains the agent mutex and the agent configuration.
 type ConfigAgent struct {
 	mut           sync.Mutex
 	configuration *Configuration
+	subscriptions []*subscription
+	status        ConfigStatus
+
+	// reloadMut serializes reading, comparing and applying the config file.
+	reloadMut sync.Mutex
+	// failedHash specifies the hash of the config content that failed to load last time.
+	failedHash string
 }
 
 // Load attempts to load config from the path. It returns an error if either
 // the file can't be read or the configuration is invalid.
-// If checkUnknownPlugins is true, unrecognized plugin names will make config
-// loading fail.
+// When loading fails, the last known good configuration is kept.
 func (pa *ConfigAgent) Load(path string) error {
+	pa.reloadMut.Lock()
+	defer pa.reloadMut.Unlock()
+
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
+		pa.recordError(err)
 		return err
 	}
+
+	if err := pa.load(b); err != nil {
+		pa.failedHash = hashOf(b)
+		pa.recordError(err)
+		return err
+	}
+	return nil
+}
+
+// load parses, validates and applies the configuration content.
+func (pa *ConfigAgent) load(b []byte) error {
 	np := &Configuration{}
 	if err := yaml.Unmarshal(b, np); err != nil {
 		return err
@@ -49,35 +130,185 @@ func (pa *ConfigAgent) Load(path string) error {
 	// Output line number and call function information.
 	logrus.SetReportCaller(true)
 
-	pa.Set(np)
+	pa.failedHash = ""
+	pa.set(np, hashOf(b))
 	return nil
 }
 
 // Set attempts to set the plugins config.
 func (pa *ConfigAgent) Set(pc *Configuration) {
+	pa.set(pc, "")
+}
+
+// set replaces the configuration and notifies all subscribers.
+func (pa *ConfigAgent) set(pc *Configuration, hash string) {
 	pa.mut.Lock()
 	defer pa.mut.Unlock()
+
+	var before Configuration
+	if pa.configuration != nil {
+		before = *pa.configuration
+	}
+
 	pa.configuration = pc
+	pa.status = ConfigStatus{
+		Hash:         hash,
+		LastLoadTime: time.Now(),
+	}
+
+	for _, s := range pa.subscriptions {
+		delta := Delta{Before: before, After: *pc}
+		// Merge with the delta which has not been received yet, only set writes into pending,
+		// so the send below never blocks.
+		select {
+		case stale := <-s.pending:
+			delta.Before = stale.Before
+		default:
+		}
+		s.pending <- delta
+	}
+}
+
+// recordError records the error of a failed load.
+func (pa *ConfigAgent) recordError(err error) {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	now := time.Now()
+	pa.status.LastError = err.Error()
+	pa.status.LastErrorTime = &now
+}
+
+// clearError clears the error of the last failed load.
+func (pa *ConfigAgent) clearError() {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	pa.status.LastError = ""
+	pa.status.LastErrorTime = nil
+}
+
+// Subscribe registers the channel for messages on config reload until the context is done.
+// The caller can expect the previous and current config to be sent
+// down the subscribed channel in order when a new configuration is loaded.
+// If the caller falls behind, it only receives the latest change.
+// The channel is unsubscribed when the context is done, so the caller can stop reading it.
+func (pa *ConfigAgent) Subscribe(ctx context.Context, sub DeltaChan) {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	s := &subscription{sub: sub, pending: make(chan Delta, 1)}
+	pa.subscriptions = append(pa.subscriptions, s)
+	go func() {
+		s.deliver(ctx)
+		pa.unsubscribe(s)
+	}()
 }
 
-// Start starts polling path for plugin config. If the first attempt fails,
-// then start returns the error. Future errors will halt updates but not stop.
-// If checkUnknownPlugins is true, unrecognized plugin names will make config
-// loading fail.
-func (pa *ConfigAgent) Start(path string, checkUnknownPlugins bool) error {
+// unsubscribe stops notifying the subscription.
+func (pa *ConfigAgent) unsubscribe(s *subscription) {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	for i := range pa.subscriptions {
+		if pa.subscriptions[i] == s {
+			pa.subscriptions = append(pa.subscriptions[:i], pa.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Start loads the config from path and then watches the path for changes until ctx is done.
+// If the first attempt fails, then start returns the error.
+// Future errors will keep the last known good config and be reported by Status.
+func (pa *ConfigAgent) Start(ctx context.Context, path string) error {
 	if err := pa.Load(path); err != nil {
 		return err
 	}
-	// nolint:staticcheck
-	ticker := time.Tick(pullDuration)
-	go func() {
-		for range ticker {
-			if err := pa.Load(path); err != nil {
-				logrus.WithField("path", path).WithError(err).Error("Error loading plugin config.")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to create config watcher, fall back to polling.")
+	} else if err := watcher.Add(filepath.Dir(path)); err != nil {
+		logrus.WithField("path", path).WithError(err).Warn("Failed to watch config, fall back to polling.")
+		_ = watcher.Close()
+		watcher = nil
+	}
+
+	go pa.watch(ctx, path, watcher, pullDuration)
+	return nil
+}
+
+// watch reloads the config when the file watcher reports a change or the poll ticker fires.
+// Kubernetes updates a mounted ConfigMap by swapping the "..data" symlink, so the whole
+// directory is watched and the content hash decides whether the config really changed.
+func (pa *ConfigAgent) watch(ctx context.Context, path string, watcher *fsnotify.Watcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if watcher != nil {
+		defer func() {
+			_ = watcher.Close()
+		}()
+		events = watcher.Events
+		errs = watcher.Errors
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			pa.reload(path)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
 			}
+			logrus.WithField("path", path).WithError(err).Warn("Error watching plugin config.")
+		case <-ticker.C:
+			pa.reload(path)
 		}
-	}()
-	return nil
+	}
+}
+
+// reload loads the config from path if its content has changed since the last attempt.
+func (pa *ConfigAgent) reload(path string) {
+	pa.reloadMut.Lock()
+	defer pa.reloadMut.Unlock()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		pa.recordError(err)
+		logrus.WithField("path", path).WithError(err).Error("Error reading plugin config.")
+		return
+	}
+
+	hash := hashOf(b)
+	// The file has been restored to the config currently applied, so the last error is outdated.
+	if pa.Status().Hash == hash {
+		pa.failedHash = ""
+		pa.clearError()
+		return
+	}
+	// The same content has been reported, there is no need to parse it again.
+	if pa.failedHash == hash {
+		return
+	}
+
+	if err := pa.load(b); err != nil {
+		pa.failedHash = hash
+		pa.recordError(err)
+		logrus.WithField("path", path).WithError(err).
+			Error("Error loading plugin config, keeping the last known good config.")
+		return
+	}
+	logrus.WithField("path", path).WithField("hash", hash).Info("Plugin config reloaded.")
 }
 
 // Config returns the agent current Configuration.
@@ -86,3 +317,26 @@ func (pa *ConfigAgent) Config() *Configuration {
 	defer pa.mut.Unlock()
 	return pa.configuration
 }
+
+// Status returns the status of the agent current Configuration.
+func (pa *ConfigAgent) Status() ConfigStatus {
+	pa.mut.Lock()
+	defer pa.mut.Unlock()
+	return pa.status
+}
+
+// StatusHandler returns a handler that serves the status of the configuration as JSON.
+func (pa *ConfigAgent) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pa.Status()); err != nil {
+			logrus.WithError(err).Error("Failed to encode config status.")
+		}
+	}
+}
+
+// hashOf returns the sha256 hash of the config content.
+func hashOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -1,8 +1,13 @@
 package externalplugins
 
 import (
+	"context"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -46,14 +51,20 @@ func TestStartLoadConfig(t *testing.T) {
 	tmp := "../../../test/testdata/config_tmp.yaml"
 	_ = os.Remove(tmp)
 	// Change pull config duration.
+	originalPullDuration := pullDuration
 	pullDuration = 1 * time.Second
+	defer func() {
+		pullDuration = originalPullDuration
+	}()
 
 	// Test and update config.
 	testConfigPath := "../../../test/testdata/config_test.yaml"
 	updateConfigPath := "../../../test/testdata/config_update.yaml"
 
 	// Start pull config.
-	err := pa.Start(testConfigPath, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := pa.Start(ctx, testConfigPath)
 	if err != nil {
 		t.Errorf("unexpected error: '%v'", err)
 	}
@@ -126,8 +137,274 @@ func TestStartLoadFailed(t *testing.T) {
 	_ = os.Remove(failedPath)
 
 	// Start pull config.
-	err := pa.Start(failedPath, false)
+	err := pa.Start(context.Background(), failedPath)
 	if err == nil {
 		t.Errorf("expected error, but it is nil")
 	}
 }
+
+func TestReloadKeepsLastKnownGoodConfig(t *testing.T) {
+	pa := ConfigAgent{}
+
+	configPath := filepath.Join(t.TempDir(), "external_plugins_config.yaml")
+	validConfig, err := ioutil.ReadFile("../../../test/testdata/config_test.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	updatedConfig, err := ioutil.ReadFile("../../../test/testdata/config_update.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	if err := ioutil.WriteFile(configPath, validConfig, 0600); err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+
+	if err := pa.Load(configPath); err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	goodHash := pa.Status().Hash
+	if goodHash == "" {
+		t.Errorf("expected config hash, but it is empty")
+	}
+
+	deltas := make(chan Delta, 1)
+	pa.Subscribe(context.Background(), deltas)
+
+	// Push an invalid config.
+	if err := ioutil.WriteFile(configPath, []byte("tichi_web_url: \"not a url\""), 0600); err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	pa.reload(configPath)
+
+	status := pa.Status()
+	if status.Hash != goodHash {
+		t.Errorf("Different hash: Got \"%s\" expected \"%s\"", status.Hash, goodHash)
+	}
+	if status.LastError == "" || status.LastErrorTime == nil {
+		t.Errorf("expected last error, but it is empty")
+	}
+	if pa.Config().TiCommunityLgtm[0].PullOwnersEndpoint != "https://test" {
+		t.Errorf("Different PullOwnersEndpoint: Got \"%v\" expected \"%v\"",
+			pa.Config().TiCommunityLgtm[0].PullOwnersEndpoint, "https://test")
+	}
+
+	// The invalid config that already failed should not be loaded again.
+	pa.reload(configPath)
+	if !pa.Status().LastErrorTime.Equal(*status.LastErrorTime) {
+		t.Errorf("expected the failed config to be skipped, but it was loaded again")
+	}
+
+	// Push a valid config.
+	if err := ioutil.WriteFile(configPath, updatedConfig, 0600); err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	pa.reload(configPath)
+
+	status = pa.Status()
+	if status.Hash == goodHash {
+		t.Errorf("expected config hash changed, but it is the same")
+	}
+	if status.LastError != "" {
+		t.Errorf("unexpected last error: '%s'", status.LastError)
+	}
+
+	select {
+	case delta := <-deltas:
+		if delta.Before.TiCommunityLgtm[0].PullOwnersEndpoint != "https://test" {
+			t.Errorf("Different before PullOwnersEndpoint: Got \"%v\" expected \"%v\"",
+				delta.Before.TiCommunityLgtm[0].PullOwnersEndpoint, "https://test")
+		}
+		if delta.After.TiCommunityLgtm[0].PullOwnersEndpoint != "https://test-updated" {
+			t.Errorf("Different after PullOwnersEndpoint: Got \"%v\" expected \"%v\"",
+				delta.After.TiCommunityLgtm[0].PullOwnersEndpoint, "https://test-updated")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected config delta, but got nothing")
+	}
+}
+
+func TestWatchConfigChange(t *testing.T) {
+	validConfig, err := ioutil.ReadFile("../../../test/testdata/config_test.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	updatedConfig, err := ioutil.ReadFile("../../../test/testdata/config_update.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+
+	// writeConfigMap writes the config in the way kubelet updates a mounted ConfigMap:
+	// the content is written into a new timestamped directory, then the "..data" symlink is swapped.
+	writeConfigMap := func(dir, version string, content []byte) error {
+		dataDir := filepath.Join(dir, version)
+		if err := os.Mkdir(dataDir, 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dataDir, "external_plugins_config.yaml"), content, 0600); err != nil {
+			return err
+		}
+		tmpLink := filepath.Join(dir, "..data_tmp")
+		if err := os.Symlink(version, tmpLink); err != nil {
+			return err
+		}
+		return os.Rename(tmpLink, filepath.Join(dir, "..data"))
+	}
+
+	testcases := []struct {
+		name   string
+		setup  func(dir string) (string, error)
+		update func(dir string) error
+	}{
+		{
+			name: "regular file",
+			setup: func(dir string) (string, error) {
+				configPath := filepath.Join(dir, "external_plugins_config.yaml")
+				return configPath, ioutil.WriteFile(configPath, validConfig, 0600)
+			},
+			update: func(dir string) error {
+				return ioutil.WriteFile(filepath.Join(dir, "external_plugins_config.yaml"), updatedConfig, 0600)
+			},
+		},
+		{
+			name: "ConfigMap symlink swap",
+			setup: func(dir string) (string, error) {
+				if err := writeConfigMap(dir, "..2021_06_01_00_00_00.1", validConfig); err != nil {
+					return "", err
+				}
+				configPath := filepath.Join(dir, "external_plugins_config.yaml")
+				return configPath, os.Symlink(filepath.Join("..data", "external_plugins_config.yaml"), configPath)
+			},
+			update: func(dir string) error {
+				return writeConfigMap(dir, "..2021_06_01_00_00_00.2", updatedConfig)
+			},
+		},
+	}
+
+	// Make sure the change is picked up by the file watcher rather than polling.
+	originalPullDuration := pullDuration
+	pullDuration = 1 * time.Hour
+	defer func() {
+		pullDuration = originalPullDuration
+	}()
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath, err := tc.setup(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: '%v'", err)
+			}
+
+			pa := ConfigAgent{}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := pa.Start(ctx, configPath); err != nil {
+				t.Fatalf("unexpected error: '%v'", err)
+			}
+
+			deltas := make(chan Delta, 1)
+			pa.Subscribe(ctx, deltas)
+
+			if err := tc.update(dir); err != nil {
+				t.Fatalf("unexpected error: '%v'", err)
+			}
+
+			select {
+			case delta := <-deltas:
+				if delta.After.TiCommunityLgtm[0].PullOwnersEndpoint != "https://test-updated" {
+					t.Errorf("Different after PullOwnersEndpoint: Got \"%v\" expected \"%v\"",
+						delta.After.TiCommunityLgtm[0].PullOwnersEndpoint, "https://test-updated")
+				}
+			case <-time.After(10 * time.Second):
+				t.Errorf("expected config delta, but got nothing")
+			}
+		})
+	}
+}
+
+func TestSubscribeMergesStaleDeltas(t *testing.T) {
+	pa := ConfigAgent{}
+	pa.Set(&Configuration{LogLevel: "v1"})
+
+	// An unbuffered channel that is not read yet, so the deltas pile up.
+	deltas := make(chan Delta)
+	pa.Subscribe(context.Background(), deltas)
+
+	pa.Set(&Configuration{LogLevel: "v2"})
+	pa.Set(&Configuration{LogLevel: "v3"})
+	pa.Set(&Configuration{LogLevel: "v4"})
+
+	var received []Delta
+	for len(received) == 0 || received[len(received)-1].After.LogLevel != "v4" {
+		select {
+		case delta := <-deltas:
+			received = append(received, delta)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected config delta, but got nothing")
+		}
+	}
+
+	// Deltas must be received in order and chained without gaps.
+	if received[0].Before.LogLevel != "v1" {
+		t.Errorf("Different before LogLevel: Got \"%s\" expected \"%s\"", received[0].Before.LogLevel, "v1")
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i].Before.LogLevel != received[i-1].After.LogLevel {
+			t.Errorf("Delta %d is not chained: before \"%s\", previous after \"%s\"",
+				i, received[i].Before.LogLevel, received[i-1].After.LogLevel)
+		}
+	}
+}
+
+func TestSubscribeUnsubscribesWhenDone(t *testing.T) {
+	pa := ConfigAgent{}
+	pa.Set(&Configuration{LogLevel: "v1"})
+
+	// A subscriber that never reads the channel.
+	ctx, cancel := context.WithCancel(context.Background())
+	pa.Subscribe(ctx, make(chan Delta))
+	pa.Set(&Configuration{LogLevel: "v2"})
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pa.mut.Lock()
+		count := len(pa.subscriptions)
+		pa.mut.Unlock()
+		if count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the subscription to be removed, but %d subscriptions are left", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Setting the config must not block after the subscriber is gone.
+	pa.Set(&Configuration{LogLevel: "v3"})
+}
+
+func TestStatusHandler(t *testing.T) {
+	pa := ConfigAgent{}
+	pa.set(&Configuration{}, "hash")
+	pa.recordError(os.ErrNotExist)
+
+	recorder := httptest.NewRecorder()
+	pa.StatusHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, ConfigStatusPath, nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Different status code: Got \"%d\" expected \"%d\"", recorder.Code, http.StatusOK)
+	}
+
+	var status ConfigStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+	if status.Hash != "hash" {
+		t.Errorf("Different hash: Got \"%s\" expected \"%s\"", status.Hash, "hash")
+	}
+	if status.LastError != os.ErrNotExist.Error() {
+		t.Errorf("Different last error: Got \"%s\" expected \"%s\"", status.LastError, os.ErrNotExist.Error())
+	}
+}
@@ -0,0 +1,426 @@
+package externalplugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The priorities of the repos or branches pattern matching, the more specific the pattern is,
+// the higher the priority is.
+const (
+	noMatch = iota
+	// orgPatternMatch means that the org matches a glob pattern, such as "ti-community-*".
+	orgPatternMatch
+	// orgMatch means that the org matches exactly, such as "ti-community-infra".
+	orgMatch
+	// repoPatternMatch means that the repo matches a glob pattern, such as "ti-community-infra/test-*".
+	repoPatternMatch
+	// repoMatch means that the repo matches exactly, such as "ti-community-infra/test-dev".
+	repoMatch
+)
+
+// The priorities of the branch pattern matching.
+const (
+	branchPatternMatch = iota + 1
+	branchMatch
+)
+
+const (
+	// reposFieldName specifies the name of the field which specifies the repos of the plugin config.
+	reposFieldName = "Repos"
+	// reposJSONName specifies the JSON name of the repos field.
+	reposJSONName = "repos"
+	// branchesFieldName specifies the name of the field which specifies the branch level overrides.
+	branchesFieldName = "Branches"
+	// branchesJSONName specifies the JSON name of the branches field.
+	branchesJSONName = "branches"
+	// setFieldsFieldName specifies the name of the field which records the fields set explicitly.
+	setFieldsFieldName = "setFields"
+)
+
+// fieldSet records the JSON names of the fields set explicitly in the configuration file, the names
+// are in lower case since the JSON names are matched case-insensitively.
+type fieldSet map[string]bool
+
+// unmarshalFieldSet unmarshals the JSON object into v and returns the fields set explicitly in it.
+func unmarshalFieldSet(b []byte, v interface{}) (fieldSet, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	fields := make(fieldSet, len(raw))
+	for name := range raw {
+		fields[strings.ToLower(name)] = true
+	}
+	return fields, nil
+}
+
+// explicitFields returns the fields set explicitly of the plugin config, it is nil if the config
+// is not unmarshalled from a configuration file, e.g. it is built in code.
+func explicitFields(config reflect.Value) fieldSet {
+	setFields := config.FieldByName(setFieldsFieldName)
+	if !setFields.IsValid() || setFields.IsNil() {
+		return nil
+	}
+	fields := make(fieldSet, setFields.Len())
+	for _, name := range setFields.MapKeys() {
+		fields[name.String()] = true
+	}
+	return fields
+}
+
+// defaulter is implemented by the plugin configs which need to set default values.
+type defaulter interface {
+	setDefaults()
+}
+
+// matchRepo returns the priority of the pattern matching the org/repo.
+// A pattern is either of the form org/repo or just org, and both parts can be glob patterns.
+func matchRepo(pattern, org, repo string) int {
+	isGlob := strings.ContainsAny(pattern, "*?[")
+
+	if !strings.Contains(pattern, "/") {
+		if pattern == org {
+			return orgMatch
+		}
+		if isGlob {
+			if matched, _ := path.Match(pattern, org); matched {
+				return orgPatternMatch
+			}
+		}
+		return noMatch
+	}
+
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+	if pattern == fullName {
+		return repoMatch
+	}
+	if isGlob {
+		if matched, _ := path.Match(pattern, fullName); matched {
+			return repoPatternMatch
+		}
+	}
+	return noMatch
+}
+
+// matchRepos returns the highest priority of the patterns matching the org/repo.
+func matchRepos(patterns []string, org, repo string) int {
+	priority := noMatch
+	for _, pattern := range patterns {
+		if p := matchRepo(pattern, org, repo); p > priority {
+			priority = p
+		}
+	}
+	return priority
+}
+
+// matchBranch returns the priority of the pattern matching the branch.
+func matchBranch(pattern, branch string) int {
+	if pattern == branch {
+		return branchMatch
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return branchPatternMatch
+		}
+	}
+	return noMatch
+}
+
+// matchedValue is a config value matched with its priority.
+type matchedValue struct {
+	value    reflect.Value
+	priority int
+}
+
+// sortMatched sorts the matched values from the least specific to the most specific,
+// the values with the same priority keep the order in the configuration file.
+func sortMatched(matched []matchedValue) {
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].priority < matched[j].priority
+	})
+}
+
+// resolve merges the plugin configs in configs that match the org/repo and branch into result.
+//
+// The configs is a slice of the plugin config structs, and result is a pointer to the same struct.
+// The org level configs are merged first, then the repo level configs, and finally the branch level
+// configs, the fields set in the more specific config override the less specific one field by field,
+// so a more specific config can set a bool back to false or clear a list. The non-zero fields are
+// regarded as set if the config is not unmarshalled from a configuration file. The Repos of the
+// result is the Repos of the most specific config, and the generic branch overrides are not kept
+// in the result. If the plugin config needs default values, they are set after merging.
+func resolve(configs interface{}, org, repo, branch string, result interface{}) {
+	configsValue := reflect.ValueOf(configs)
+	resultValue := reflect.ValueOf(result).Elem()
+
+	var matched []matchedValue
+	for i := 0; i < configsValue.Len(); i++ {
+		config := configsValue.Index(i)
+		repos := config.FieldByName(reposFieldName).Interface().([]string)
+		if priority := matchRepos(repos, org, repo); priority != noMatch {
+			matched = append(matched, matchedValue{value: config, priority: priority})
+		}
+	}
+	sortMatched(matched)
+
+	for _, m := range matched {
+		mergeFields(resultValue, m.value)
+		resultValue.FieldByName(reposFieldName).Set(m.value.FieldByName(reposFieldName))
+	}
+
+	if len(branch) != 0 {
+		var matchedBranches []matchedValue
+		for _, m := range matched {
+			branches := branchOverrides(m.value)
+			if !branches.IsValid() {
+				continue
+			}
+			// Sort the branch patterns to make the merging order stable.
+			for _, key := range sortedKeys(branches) {
+				if priority := matchBranch(key.String(), branch); priority != noMatch {
+					matchedBranches = append(matchedBranches,
+						matchedValue{value: branches.MapIndex(key), priority: priority})
+				}
+			}
+		}
+		sortMatched(matchedBranches)
+
+		for _, m := range matchedBranches {
+			mergeFields(resultValue, m.value)
+		}
+	}
+
+	if d, ok := result.(defaulter); ok && len(matched) != 0 {
+		d.setDefaults()
+	}
+}
+
+// branchOverrides returns the branch level overrides of the plugin config if it supports.
+// Only the Branches map whose value type is the plugin config itself is the generic branch overrides.
+func branchOverrides(config reflect.Value) reflect.Value {
+	branches := config.FieldByName(branchesFieldName)
+	if !branches.IsValid() || branches.Kind() != reflect.Map || branches.Type().Elem() != config.Type() {
+		return reflect.Value{}
+	}
+	return branches
+}
+
+// branchPatterns returns the sorted branch patterns of the generic branch overrides of all plugin
+// configs in configs which match the repo.
+func branchPatterns(configs interface{}, org, repo string) []string {
+	configsValue := reflect.ValueOf(configs)
+	patterns := sets.NewString()
+	for i := 0; i < configsValue.Len(); i++ {
+		config := configsValue.Index(i)
+		repos := config.FieldByName(reposFieldName).Interface().([]string)
+		if matchRepos(repos, org, repo) == noMatch {
+			continue
+		}
+		branches := branchOverrides(config)
+		if !branches.IsValid() {
+			continue
+		}
+		for _, key := range branches.MapKeys() {
+			patterns.Insert(key.String())
+		}
+	}
+	return patterns.List()
+}
+
+// sortedKeys returns the keys of the map with string keys in order.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+// mergeFields sets the fields set explicitly in src into dst, or the non-zero fields of src if
+// it does not record the fields set explicitly.
+func mergeFields(dst, src reflect.Value) {
+	setFields := explicitFields(src)
+	for i := 0; i < src.NumField(); i++ {
+		structField := src.Type().Field(i)
+		name := structField.Name
+		if len(structField.PkgPath) != 0 || name == reposFieldName {
+			continue
+		}
+		if name == branchesFieldName && branchOverrides(src).IsValid() {
+			continue
+		}
+		field := src.Field(i)
+		if setFields != nil {
+			jsonName := strings.Split(structField.Tag.Get("json"), ",")[0]
+			if !setFields[strings.ToLower(jsonName)] {
+				continue
+			}
+		} else if field.IsZero() {
+			continue
+		}
+		dst.Field(i).Set(field)
+	}
+}
+
+// reposOf returns the Repos of each plugin config in configs.
+func reposOf(configs interface{}) [][]string {
+	configsValue := reflect.ValueOf(configs)
+	reposList := make([][]string, 0, configsValue.Len())
+	for i := 0; i < configsValue.Len(); i++ {
+		reposList = append(reposList, configsValue.Index(i).FieldByName(reposFieldName).Interface().([]string))
+	}
+	return reposList
+}
+
+// validateRepos will return an error if the repos patterns of a plugin are invalid, duplicated or ambiguous.
+func validateRepos(pluginName string, reposList [][]string) error {
+	if errs := repoErrors(pluginName, reposList); len(errs) != 0 {
+		return errs[0].err
+	}
+	return nil
+}
+
+// repoErrors returns the errors of the repos patterns of a plugin, the patterns are invalid, duplicated
+// or ambiguous. Two patterns are ambiguous when they have the same priority and can match the same repo,
+// so which one wins depends on the order of the configuration file.
+func repoErrors(pluginName string, reposList [][]string) []configError {
+	type pattern struct {
+		value string
+		path  fieldPath
+		index int
+	}
+	var patterns []pattern
+	var errs []configError
+
+	for i, repos := range reposList {
+		for j, repo := range repos {
+			repoPath := fieldPath{pluginName, i, reposJSONName, j}
+			if strings.Count(repo, "/") > 1 || strings.HasPrefix(repo, "/") || strings.HasSuffix(repo, "/") {
+				errs = append(errs, configError{
+					path: repoPath,
+					err:  fmt.Errorf("%s: found repo %s that was not in org/repo or org format", pluginName, repo),
+				})
+				continue
+			}
+			if _, err := path.Match(repo, ""); err != nil {
+				errs = append(errs, configError{
+					path: repoPath,
+					err:  fmt.Errorf("%s: invalid repo pattern %s: %v", pluginName, repo, err),
+				})
+				continue
+			}
+			patterns = append(patterns, pattern{value: repo, path: repoPath, index: i})
+		}
+	}
+
+	for i := range patterns {
+		for j := i + 1; j < len(patterns); j++ {
+			a, b := patterns[i], patterns[j]
+			if a.value == b.value {
+				errs = append(errs, configError{
+					path: b.path,
+					err:  fmt.Errorf("%s: repo %s is configured more than once", pluginName, a.value),
+				})
+				continue
+			}
+			if a.index == b.index || strings.Contains(a.value, "/") != strings.Contains(b.value, "/") {
+				continue
+			}
+			if isAmbiguousPattern(a.value, b.value) {
+				errs = append(errs, configError{
+					path: b.path,
+					err:  fmt.Errorf("%s: repo patterns %s and %s are ambiguous", pluginName, a.value, b.value),
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+// isAmbiguousPattern returns true if both patterns are glob patterns of the same level and
+// there is a name matched by both of them.
+func isAmbiguousPattern(a, b string) bool {
+	if !strings.ContainsAny(a, "*?[") || !strings.ContainsAny(b, "*?[") {
+		return false
+	}
+
+	aParts := strings.Split(a, "/")
+	bParts := strings.Split(b, "/")
+	if len(aParts) != len(bParts) {
+		return false
+	}
+	for i := range aParts {
+		if !patternsOverlap(globTokens(aParts[i]), globTokens(bParts[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// globTokens splits the glob pattern into tokens, each token is "*", "?" or a single character.
+// NOTICE: The character class is treated as "?", so the overlapping check may report false positives
+// for it, but never misses an ambiguous pattern.
+func globTokens(pattern string) []string {
+	var tokens []string
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			tokens = append(tokens, pattern[i:i+1])
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				end = len(pattern) - i - 1
+			}
+			i += end
+			tokens = append(tokens, "?")
+		default:
+			tokens = append(tokens, pattern[i:i+1])
+		}
+	}
+	return tokens
+}
+
+// patternsOverlap returns true if there is a string matched by both of the tokenized patterns.
+func patternsOverlap(a, b []string) bool {
+	visited := make(map[[2]int]bool)
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		key := [2]int{i, j}
+		if result, ok := visited[key]; ok {
+			return result
+		}
+		// Mark the state as failed before exploring it to avoid cycles.
+		visited[key] = false
+
+		var result bool
+		switch {
+		case i == len(a) && j == len(b):
+			result = true
+		case i < len(a) && a[i] == "*":
+			// The star matches nothing or consumes the next token of the other pattern.
+			result = overlap(i+1, j) || (j < len(b) && overlap(i, j+1))
+		case j < len(b) && b[j] == "*":
+			result = overlap(i, j+1) || (i < len(a) && overlap(i+1, j))
+		case i < len(a) && j < len(b):
+			result = (a[i] == "?" || b[j] == "?" || a[i] == b[j]) && overlap(i+1, j+1)
+		}
+
+		visited[key] = result
+		return result
+	}
+	return overlap(0, 0)
+}
@@ -0,0 +1,395 @@
+package externalplugins
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gotest.tools/assert"
+	"k8s.io/test-infra/prow/labels"
+	"sigs.k8s.io/yaml"
+)
+
+func TestResolve(t *testing.T) {
+	testcases := []struct {
+		name         string
+		blunderbuss  []TiCommunityBlunderbuss
+		org          string
+		repo         string
+		branch       string
+		expectConfig *TiCommunityBlunderbuss
+	}{
+		{
+			name: "org config only",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:              []string{"ti-community-infra"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					MaxReviewerCount:   2,
+				},
+			},
+			org:  "ti-community-infra",
+			repo: "test-dev",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-infra"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				MaxReviewerCount:         2,
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+			},
+		},
+		{
+			name: "repo config overrides org config field by field",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:            []string{"ti-community-infra/test-dev"},
+					MaxReviewerCount: 3,
+				},
+				{
+					Repos:              []string{"ti-community-infra"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					MaxReviewerCount:   2,
+					RequireSigLabel:    true,
+				},
+			},
+			org:  "ti-community-infra",
+			repo: "test-dev",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				MaxReviewerCount:         3,
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+				RequireSigLabel:          true,
+			},
+		},
+		{
+			name: "repo glob config overrides org config",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:              []string{"ti-community-infra"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					MaxReviewerCount:   2,
+				},
+				{
+					Repos:            []string{"ti-community-infra/test-*"},
+					MaxReviewerCount: 4,
+				},
+			},
+			org:  "ti-community-infra",
+			repo: "test-dev",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-infra/test-*"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				MaxReviewerCount:         4,
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+			},
+		},
+		{
+			name: "exact repo config overrides repo glob config",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:            []string{"ti-community-infra/test-dev"},
+					MaxReviewerCount: 5,
+				},
+				{
+					Repos:              []string{"ti-community-infra/test-*"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					MaxReviewerCount:   4,
+				},
+			},
+			org:  "ti-community-infra",
+			repo: "test-dev",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				MaxReviewerCount:         5,
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+			},
+		},
+		{
+			name: "org glob config",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:              []string{"ti-community-*"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				},
+			},
+			org:  "ti-community-infra",
+			repo: "test-dev",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-*"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+			},
+		},
+		{
+			name: "branch config overrides repo config",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:              []string{"ti-community-infra/test-dev"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					MaxReviewerCount:   2,
+					Branches: map[string]TiCommunityBlunderbuss{
+						"release-*": {
+							MaxReviewerCount: 1,
+							RequireSigLabel:  true,
+						},
+						"release-5.0": {
+							MaxReviewerCount: 3,
+						},
+					},
+				},
+			},
+			org:    "ti-community-infra",
+			repo:   "test-dev",
+			branch: "release-5.0",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-infra/test-dev"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				MaxReviewerCount:         3,
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+				RequireSigLabel:          true,
+			},
+		},
+		{
+			name: "branch config does not match",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:              []string{"ti-community-infra"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+					MaxReviewerCount:   2,
+					Branches: map[string]TiCommunityBlunderbuss{
+						"release-*": {
+							MaxReviewerCount: 1,
+						},
+					},
+				},
+			},
+			org:    "ti-community-infra",
+			repo:   "test-dev",
+			branch: "master",
+			expectConfig: &TiCommunityBlunderbuss{
+				Repos:                    []string{"ti-community-infra"},
+				PullOwnersEndpoint:       "https://bots.tidb.io/ti-community-bot",
+				MaxReviewerCount:         2,
+				GracePeriodDuration:      defaultGracePeriodDuration,
+				Strategy:                 BlunderbussStrategyFileHistory,
+				MaxHistoryPaths:          defaultMaxHistoryPaths,
+				HistoryHalfLife:          defaultHistoryHalfLife,
+				StaleReviewExcludeLabels: []string{labels.Hold, labels.WorkInProgress},
+			},
+		},
+		{
+			name: "can not find",
+			blunderbuss: []TiCommunityBlunderbuss{
+				{
+					Repos:              []string{"ti-community-infra/test-*"},
+					PullOwnersEndpoint: "https://bots.tidb.io/ti-community-bot",
+				},
+			},
+			org:          "ti-community-infra",
+			repo:         "tichi",
+			expectConfig: &TiCommunityBlunderbuss{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			config := Configuration{TiCommunityBlunderbuss: tc.blunderbuss}
+
+			blunderbuss := config.BlunderbussForBranch(tc.org, tc.repo, tc.branch)
+
+			assert.DeepEqual(t, blunderbuss, tc.expectConfig, cmpopts.IgnoreUnexported(TiCommunityBlunderbuss{}))
+		})
+	}
+}
+
+func TestResolveExplicitZeroValues(t *testing.T) {
+	configYaml := `
+ti-community-owners:
+  - repos:
+      - ti-community-infra
+    use_github_permission: true
+    trusted_teams:
+      - admins
+  - repos:
+      - ti-community-infra/test-dev
+    use_github_permission: false
+    trusted_teams: []
+ti-community-blunderbuss:
+  - repos:
+      - ti-community-infra
+    require_sig_label: true
+    exclude_reviewers:
+      - bot
+    max_request_count: 2
+    branches:
+      release-*:
+        require_sig_label: false
+        exclude_reviewers: []
+`
+	config := Configuration{}
+	if err := yaml.Unmarshal([]byte(configYaml), &config); err != nil {
+		t.Fatalf("unexpected error: '%v'", err)
+	}
+
+	testcases := []struct {
+		name   string
+		repo   string
+		branch string
+
+		expectUseGitHubPermission bool
+		expectTrustTeams          []string
+		expectRequireSigLabel     bool
+		expectExcludeReviewers    []string
+	}{
+		{
+			name:                      "org config",
+			repo:                      "test-live",
+			branch:                    "master",
+			expectUseGitHubPermission: true,
+			expectTrustTeams:          []string{"admins"},
+			expectRequireSigLabel:     true,
+			expectExcludeReviewers:    []string{"bot"},
+		},
+		{
+			name:                   "repo config sets false and an empty list",
+			repo:                   "test-dev",
+			branch:                 "master",
+			expectTrustTeams:       []string{},
+			expectRequireSigLabel:  true,
+			expectExcludeReviewers: []string{"bot"},
+		},
+		{
+			name:                      "branch config sets false and an empty list",
+			repo:                      "test-live",
+			branch:                    "release-5.0",
+			expectUseGitHubPermission: true,
+			expectTrustTeams:          []string{"admins"},
+			expectExcludeReviewers:    []string{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			owners := config.OwnersFor("ti-community-infra", tc.repo)
+			if owners.UseGitHubPermission != tc.expectUseGitHubPermission {
+				t.Errorf("Different use GitHub permission: Got \"%v\" expected \"%v\"",
+					owners.UseGitHubPermission, tc.expectUseGitHubPermission)
+			}
+			assert.DeepEqual(t, owners.TrustTeams, tc.expectTrustTeams)
+
+			blunderbuss := config.BlunderbussForBranch("ti-community-infra", tc.repo, tc.branch)
+			if blunderbuss.RequireSigLabel != tc.expectRequireSigLabel {
+				t.Errorf("Different require sig label: Got \"%v\" expected \"%v\"",
+					blunderbuss.RequireSigLabel, tc.expectRequireSigLabel)
+			}
+			assert.DeepEqual(t, blunderbuss.ExcludeReviewers, tc.expectExcludeReviewers)
+			if blunderbuss.MaxReviewerCount != 2 {
+				t.Errorf("Different max reviewer count: Got \"%v\" expected \"%v\"", blunderbuss.MaxReviewerCount, 2)
+			}
+		})
+	}
+}
+
+func TestResolveDoesNotModifyConfig(t *testing.T) {
+	config := Configuration{TiCommunityTars: []TiCommunityTars{
+		{
+			Repos:   []string{"ti-community-infra/test-dev"},
+			Message: "updated",
+		},
+	}}
+
+	tars := config.TarsFor("ti-community-infra", "test-dev")
+	tars.Message = "changed"
+
+	if config.TiCommunityTars[0].Message != "updated" {
+		t.Errorf("Different message: Got \"%s\" expected \"%s\"", config.TiCommunityTars[0].Message, "updated")
+	}
+	if len(config.TiCommunityTars[0].OnlyWhenLabel) != 0 {
+		t.Errorf("The default value should not be set into the configuration.")
+	}
+}
+
+func TestValidateRepos(t *testing.T) {
+	testcases := []struct {
+		name      string
+		reposList [][]string
+		expected  error
+	}{
+		{
+			name: "valid repos",
+			reposList: [][]string{
+				{"ti-community-infra", "ti-community-infra/test-*"},
+				{"ti-community-infra/test-dev", "pingcap/tidb"},
+			},
+		},
+		{
+			name:      "invalid repo format",
+			reposList: [][]string{{"ti-community-infra/test-dev/master"}},
+			expected: fmt.Errorf("ti-community-lgtm: found repo ti-community-infra/test-dev/master " +
+				"that was not in org/repo or org format"),
+		},
+		{
+			name:      "invalid repo pattern",
+			reposList: [][]string{{"ti-community-infra/test-["}},
+			expected:  fmt.Errorf("ti-community-lgtm: invalid repo pattern ti-community-infra/test-[: syntax error in pattern"),
+		},
+		{
+			name:      "duplicated repos",
+			reposList: [][]string{{"ti-community-infra/test-dev"}, {"ti-community-infra/test-dev"}},
+			expected:  fmt.Errorf("ti-community-lgtm: repo ti-community-infra/test-dev is configured more than once"),
+		},
+		{
+			name:      "ambiguous repo patterns",
+			reposList: [][]string{{"ti-community-infra/test-*"}, {"ti-community-infra/*-dev"}},
+			expected: fmt.Errorf("ti-community-lgtm: repo patterns ti-community-infra/test-* " +
+				"and ti-community-infra/*-dev are ambiguous"),
+		},
+		{
+			name:      "nested repo patterns",
+			reposList: [][]string{{"ti-community-infra/*"}, {"ti-community-infra/test-*"}},
+			expected: fmt.Errorf("ti-community-lgtm: repo patterns ti-community-infra/* " +
+				"and ti-community-infra/test-* are ambiguous"),
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRepos("ti-community-lgtm", tc.reposList)
+
+			if tc.expected == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tc.expected != nil && (err == nil || err.Error() != tc.expected.Error()) {
+				t.Errorf("Different error: Got \"%v\" expected \"%v\"", err, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package externalplugins
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+)
+
+// ReviewNotificationIdentifier defines the identifier for the review notifications of the lgtm plugin.
+const ReviewNotificationIdentifier = "Review Notification Identifier"
+
+var (
+	// reviewNotificationRegex is the regex that matches the review notifications.
+	reviewNotificationRegex = regexp.MustCompile("<!--" + ReviewNotificationIdentifier + "-->$")
+	// approvalsResetRegex is the regex that matches the approvals reset recorded in the review notifications.
+	approvalsResetRegex = regexp.MustCompile(`<!--Approvals Reset: (\S+) (\S+)(?: (\S+))?-->`)
+	// lgtmRe is the regex that matches the /lgtm comment commands.
+	lgtmRe = regexp.MustCompile(`(?mi)^/lgtm\s*$`)
+	// lgtmCancelRe is the regex that matches the /lgtm cancel comment commands.
+	lgtmCancelRe = regexp.MustCompile(`(?mi)^/lgtm cancel\s*$`)
+)
+
+// ApprovalsReset records that the approvals submitted before the time are invalidated by the commit.
+type ApprovalsReset struct {
+	Commit string
+	Time   time.Time
+	// Reviewers specifies the reviewers whose approvals are invalidated.
+	Reviewers []string
+}
+
+// String returns the approvals reset in the form recorded in the review notifications.
+func (r *ApprovalsReset) String() string {
+	record := fmt.Sprintf("<!--Approvals Reset: %s %s", r.Commit, r.Time.UTC().Format(time.RFC3339))
+	if len(r.Reviewers) != 0 {
+		record += " " + strings.Join(r.Reviewers, ",")
+	}
+	return record + "-->"
+}
+
+// ValidReviews returns the reviews submitted after the approvals reset, all the reviews are valid if there
+// is no approvals reset.
+func (r *ApprovalsReset) ValidReviews(reviews []github.Review) []github.Review {
+	if r == nil {
+		return reviews
+	}
+	var valid []github.Review
+	for _, review := range reviews {
+		if review.SubmittedAt.After(r.Time) {
+			valid = append(valid, review)
+		}
+	}
+	return valid
+}
+
+// ApprovalsResetFromNotification returns the approvals reset recorded in the review notification,
+// it returns nil if there is no approvals reset.
+func ApprovalsResetFromNotification(notification *github.IssueComment) *ApprovalsReset {
+	if notification == nil {
+		return nil
+	}
+	m := approvalsResetRegex.FindStringSubmatch(notification.Body)
+	if m == nil {
+		return nil
+	}
+	resetTime, err := time.Parse(time.RFC3339, m[2])
+	if err != nil {
+		return nil
+	}
+	reset := &ApprovalsReset{Commit: m[1], Time: resetTime}
+	if len(m[3]) != 0 {
+		reset.Reviewers = strings.Split(m[3], ",")
+	}
+	return reset
+}
+
+// LatestReviewNotification returns the latest review notification created by the bot in the comments,
+// it returns nil if there is no review notification.
+func LatestReviewNotification(comments []github.IssueComment, isBot func(string) bool) *github.IssueComment {
+	for i := len(comments) - 1; i >= 0; i-- {
+		if IsReviewNotification(&comments[i], isBot) {
+			return &comments[i]
+		}
+	}
+	return nil
+}
+
+// IsReviewNotification returns whether the comment is a review notification created by the bot.
+func IsReviewNotification(comment *github.IssueComment, isBot func(string) bool) bool {
+	return isBot(comment.User.Login) && reviewNotificationRegex.MatchString(comment.Body)
+}
+
+// ApproversFromReviews returns the users whose latest review of the PR approves it. The comment reviews do not
+// change the state of their authors, and a dismissed review withdraws the approval of its author.
+func ApproversFromReviews(reviews []github.Review) sets.String {
+	sorted := append([]github.Review(nil), reviews...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SubmittedAt.Before(sorted[j].SubmittedAt)
+	})
+
+	approved := make(map[string]bool)
+	for _, review := range sorted {
+		// The review webhook returns state as lowercase, while the review API returns state as uppercase.
+		switch github.ReviewState(strings.ToUpper(string(review.State))) {
+		case github.ReviewStateApproved:
+			approved[review.User.Login] = true
+		case github.ReviewStateChangesRequested, github.ReviewStateDismissed:
+			approved[review.User.Login] = false
+		}
+	}
+
+	approvers := sets.NewString()
+	for login, ok := range approved {
+		if ok {
+			approvers.Insert(login)
+		}
+	}
+	return approvers
+}
+
+// ReviewFromLgtmCommand returns the review which the /lgtm or /lgtm cancel command in the comment stands for,
+// the /lgtm command approves the PR and the /lgtm cancel command withdraws the approval like a dismissed review.
+// It returns nil if the comment is not a lgtm command.
+func ReviewFromLgtmCommand(comment github.IssueComment) *github.Review {
+	var state github.ReviewState
+	switch {
+	case lgtmCancelRe.MatchString(comment.Body):
+		state = github.ReviewStateDismissed
+	case lgtmRe.MatchString(comment.Body):
+		state = github.ReviewStateApproved
+	default:
+		return nil
+	}
+	return &github.Review{
+		User:        comment.User,
+		Body:        comment.Body,
+		State:       state,
+		HTMLURL:     comment.HTMLURL,
+		SubmittedAt: comment.CreatedAt,
+	}
+}
+
+// ReviewsFromLgtmCommands returns the reviews which the lgtm commands in the comments stand for,
+// the commands of the PR author are ignored since the author cannot approve their own PR.
+func ReviewsFromLgtmCommands(comments []github.IssueComment, author string) []github.Review {
+	var reviews []github.Review
+	for _, comment := range comments {
+		if comment.User.Login == author {
+			continue
+		}
+		if review := ReviewFromLgtmCommand(comment); review != nil {
+			reviews = append(reviews, *review)
+		}
+	}
+	return reviews
+}
+
+// ApprovalProgress is the progress of the approvals of the PR against a requirement.
+type ApprovalProgress struct {
+	// Name specifies the name of the requirement.
+	Name      string
+	Approvals int
+	Required  int
+}
+
+// Satisfied returns whether the approvals satisfy the requirement.
+func (p ApprovalProgress) Satisfied() bool {
+	return p.Approvals >= p.Required
+}
+
+// rolesByPriority specifies the roles of the owners from the highest to the lowest.
+var rolesByPriority = []string{ownersclient.RoleLeader, ownersclient.RoleCommitter, ownersclient.RoleReviewer}
+
+// WeightedApprovals returns the number of the approvals from the approvers weighted by their roles.
+func (l *TiCommunityLgtm) WeightedApprovals(owners *ownersclient.Owners, approvers sets.String) int {
+	approvals := 0
+	for _, approver := range approvers.List() {
+		weight := 1
+		for _, role := range rolesByPriority {
+			if w, ok := l.RoleWeights[role]; ok && owners.HasRole(approver, role) {
+				weight = w
+				break
+			}
+		}
+		approvals += weight
+	}
+	return approvals
+}
+
+// ApprovalRulesProgress returns the progress of the approvals from the approvers against the approval rules,
+// the approvals are not weighted by the roles.
+func (l *TiCommunityLgtm) ApprovalRulesProgress(owners *ownersclient.Owners,
+	approvers sets.String) []ApprovalProgress {
+	progress := make([]ApprovalProgress, 0, len(l.ApprovalRules))
+	for _, rule := range l.ApprovalRules {
+		approvals := 0
+		for _, approver := range approvers.List() {
+			if owners.HasRole(approver, rule.Role) {
+				approvals++
+			}
+		}
+		progress = append(progress, ApprovalProgress{Name: rule.Name, Approvals: approvals, Required: rule.MinApprovals})
+	}
+	return progress
+}
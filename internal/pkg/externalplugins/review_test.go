@@ -0,0 +1,279 @@
+package externalplugins
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/github"
+
+	"github.com/ti-community-infra/tichi/internal/pkg/ownersclient"
+)
+
+func TestApproversFromReviews(t *testing.T) {
+	now := time.Now()
+	review := func(login string, state github.ReviewState, minutes int) github.Review {
+		return github.Review{
+			User:        github.User{Login: login},
+			State:       state,
+			SubmittedAt: now.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+
+	testcases := []struct {
+		name    string
+		reviews []github.Review
+
+		expectApprovers []string
+	}{
+		{
+			name:            "no reviews",
+			expectApprovers: []string{},
+		},
+		{
+			name: "latest review",
+			reviews: []github.Review{
+				review("hi-rustin", github.ReviewStateApproved, 1),
+				review("mini256", github.ReviewStateApproved, 2),
+				review("mini256", github.ReviewStateChangesRequested, 3),
+			},
+			expectApprovers: []string{"hi-rustin"},
+		},
+		{
+			name: "reviews out of order",
+			reviews: []github.Review{
+				review("mini256", github.ReviewStateApproved, 3),
+				review("mini256", github.ReviewStateChangesRequested, 2),
+			},
+			expectApprovers: []string{"mini256"},
+		},
+		{
+			name: "comment reviews",
+			reviews: []github.Review{
+				review("hi-rustin", github.ReviewStateApproved, 1),
+				review("hi-rustin", github.ReviewStateCommented, 2),
+				review("mini256", "commented", 3),
+			},
+			expectApprovers: []string{"hi-rustin"},
+		},
+		{
+			name: "dismissed reviews",
+			reviews: []github.Review{
+				review("hi-rustin", github.ReviewStateDismissed, 1),
+				review("mini256", github.ReviewStateDismissed, 2),
+				review("mini256", "approved", 3),
+			},
+			expectApprovers: []string{"mini256"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			approvers := ApproversFromReviews(tc.reviews).List()
+			if !reflect.DeepEqual(approvers, tc.expectApprovers) {
+				t.Errorf("Different approvers: Got \"%v\" expected \"%v\"", approvers, tc.expectApprovers)
+			}
+		})
+	}
+}
+
+func TestApprovalsResetFromNotification(t *testing.T) {
+	resetTime := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name         string
+		notification *github.IssueComment
+
+		expectReset *ApprovalsReset
+	}{
+		{
+			name: "no notification",
+		},
+		{
+			name:         "no approvals reset",
+			notification: &github.IssueComment{Body: "<!--" + ReviewNotificationIdentifier + "-->"},
+		},
+		{
+			name: "approvals reset",
+			notification: &github.IssueComment{
+				Body: (&ApprovalsReset{Commit: "abc", Time: resetTime, Reviewers: []string{"a", "b"}}).String(),
+			},
+			expectReset: &ApprovalsReset{Commit: "abc", Time: resetTime, Reviewers: []string{"a", "b"}},
+		},
+		{
+			name:         "approvals reset without reviewers",
+			notification: &github.IssueComment{Body: (&ApprovalsReset{Commit: "abc", Time: resetTime}).String()},
+			expectReset:  &ApprovalsReset{Commit: "abc", Time: resetTime},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			reset := ApprovalsResetFromNotification(tc.notification)
+			if !reflect.DeepEqual(reset, tc.expectReset) {
+				t.Errorf("Different approvals reset: Got \"%v\" expected \"%v\"", reset, tc.expectReset)
+			}
+		})
+	}
+}
+
+func TestWeightedApprovals(t *testing.T) {
+	owners := &ownersclient.Owners{
+		Leaders:    []string{"leader1"},
+		Committers: []string{"committer1"},
+		Reviewers:  []string{"reviewer1", "reviewer2"},
+	}
+
+	testcases := []struct {
+		name        string
+		roleWeights map[string]int
+		approvers   []string
+
+		expectApprovals int
+	}{
+		{
+			name:            "no role weights",
+			approvers:       []string{"leader1", "committer1", "reviewer1"},
+			expectApprovals: 3,
+		},
+		{
+			name:            "weighted leader",
+			roleWeights:     map[string]int{ownersclient.RoleLeader: 2},
+			approvers:       []string{"leader1", "reviewer1"},
+			expectApprovals: 3,
+		},
+		{
+			name:            "leader weighted as committer",
+			roleWeights:     map[string]int{ownersclient.RoleCommitter: 2},
+			approvers:       []string{"leader1", "committer1", "reviewer1"},
+			expectApprovals: 5,
+		},
+		{
+			name: "highest role weight",
+			roleWeights: map[string]int{
+				ownersclient.RoleLeader:    3,
+				ownersclient.RoleCommitter: 2,
+			},
+			approvers:       []string{"leader1", "reviewer2"},
+			expectApprovals: 4,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &TiCommunityLgtm{RoleWeights: tc.roleWeights}
+			approvals := opts.WeightedApprovals(owners, sets.NewString(tc.approvers...))
+			if approvals != tc.expectApprovals {
+				t.Errorf("Different approvals: Got \"%v\" expected \"%v\"", approvals, tc.expectApprovals)
+			}
+		})
+	}
+}
+
+func TestApprovalRulesProgress(t *testing.T) {
+	owners := &ownersclient.Owners{
+		Leaders:    []string{"leader1"},
+		Committers: []string{"committer1"},
+		Reviewers:  []string{"reviewer1"},
+	}
+	rules := []LgtmApprovalRule{
+		{Name: "leaders", Role: ownersclient.RoleLeader, MinApprovals: 1},
+		{Name: "committers", Role: ownersclient.RoleCommitter, MinApprovals: 2},
+	}
+
+	testcases := []struct {
+		name      string
+		approvers []string
+
+		expectProgress []ApprovalProgress
+	}{
+		{
+			name:      "no approvals",
+			approvers: []string{},
+			expectProgress: []ApprovalProgress{
+				{Name: "leaders", Approvals: 0, Required: 1},
+				{Name: "committers", Approvals: 0, Required: 2},
+			},
+		},
+		{
+			name:      "leader counts as committer",
+			approvers: []string{"leader1", "committer1", "reviewer1"},
+			expectProgress: []ApprovalProgress{
+				{Name: "leaders", Approvals: 1, Required: 1},
+				{Name: "committers", Approvals: 2, Required: 2},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			opts := &TiCommunityLgtm{ApprovalRules: rules}
+			progress := opts.ApprovalRulesProgress(owners, sets.NewString(tc.approvers...))
+			if !reflect.DeepEqual(progress, tc.expectProgress) {
+				t.Errorf("Different progress: Got \"%v\" expected \"%v\"", progress, tc.expectProgress)
+			}
+		})
+	}
+}
+
+func TestReviewsFromLgtmCommands(t *testing.T) {
+	now := time.Now()
+	comment := func(login, body string, minutes int) github.IssueComment {
+		return github.IssueComment{
+			User:      github.User{Login: login},
+			Body:      body,
+			CreatedAt: now.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+
+	testcases := []struct {
+		name     string
+		comments []github.IssueComment
+
+		expectApprovers []string
+	}{
+		{
+			name:            "no commands",
+			comments:        []github.IssueComment{comment("hi-rustin", "LGTM", 1)},
+			expectApprovers: []string{},
+		},
+		{
+			name: "lgtm commands",
+			comments: []github.IssueComment{
+				comment("hi-rustin", "/lgtm", 1),
+				comment("mini256", "Looks good.\n/LGTM", 2),
+			},
+			expectApprovers: []string{"hi-rustin", "mini256"},
+		},
+		{
+			name: "lgtm cancel commands",
+			comments: []github.IssueComment{
+				comment("hi-rustin", "/lgtm", 1),
+				comment("hi-rustin", "/lgtm cancel", 2),
+				comment("mini256", "/lgtm cancel", 3),
+				comment("mini256", "/lgtm", 4),
+			},
+			expectApprovers: []string{"mini256"},
+		},
+		{
+			name:            "commands of the author",
+			comments:        []github.IssueComment{comment("author", "/lgtm", 1)},
+			expectApprovers: []string{},
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			approvers := ApproversFromReviews(ReviewsFromLgtmCommands(tc.comments, "author"))
+			if !reflect.DeepEqual(approvers.List(), tc.expectApprovers) {
+				t.Errorf("Different approvers: Got \"%v\" expected \"%v\"", approvers.List(), tc.expectApprovers)
+			}
+		})
+	}
+}
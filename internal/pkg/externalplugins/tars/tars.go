@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/interrupts"
 	"k8s.io/test-infra/prow/pluginhelp"
 	"k8s.io/test-infra/prow/pluginhelp/externalplugins"
 	"k8s.io/test-infra/prow/plugins"
@@ -31,6 +36,15 @@ const searchQueryPrefix = "archived:false is:pr is:open sort:created-asc"
 
 var sleep = time.Sleep
 
+var prUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tichi_tars_pr_updates_total",
+	Help: "The number of PRs updated with their base branch by tars.",
+}, []string{"org", "repo"})
+
+func init() {
+	prometheus.MustRegister(prUpdates)
+}
+
 type githubClient interface {
 	CreateComment(org, repo string, number int, comment string) error
 	BotUserChecker() (func(candidate string) bool, error)
@@ -141,6 +155,18 @@ func HelpProvider(epa *tiexternalplugins.ConfigAgent) externalplugins.ExternalPl
 	}
 }
 
+// RegisterHandlers registers the handlers of the plugin for the events it handles to the host.
+func RegisterHandlers(host *tiexternalplugins.PluginHost, ghc githubClient) {
+	host.RegisterIssueCommentEventHandler(PluginName,
+		func(ice *github.IssueCommentEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandleIssueCommentEvent(log, ghc, ice, cfg)
+		})
+	host.RegisterPushEventHandler(PluginName,
+		func(pe *github.PushEvent, cfg *tiexternalplugins.Configuration, log *logrus.Entry) error {
+			return HandlePushEvent(log, ghc, pe, cfg)
+		})
+}
+
 // HandleIssueCommentEvent handles a GitHub issue comment event and update the PR
 // if the issue is a PR based on whether the PR out-of-date.
 func HandleIssueCommentEvent(log *logrus.Entry, ghc githubClient, ice *github.IssueCommentEvent,
@@ -168,7 +194,7 @@ func handlePullRequest(log *logrus.Entry, ghc githubClient,
 	repo := pr.Base.Repo.Name
 	number := pr.Number
 	updated := false
-	tars := cfg.TarsFor(org, repo)
+	tars := cfg.TarsForBranch(org, repo, pr.Base.Ref)
 
 	hasTriggerLabel := false
 	for _, label := range pr.Labels {
@@ -229,7 +255,7 @@ func HandlePushEvent(log *logrus.Entry, ghc githubClient, pe *github.PushEvent,
 	org := pe.Repo.Owner.Login
 	repo := pe.Repo.Name
 	branch := getRefBranch(pe.Ref)
-	tars := cfg.TarsFor(org, repo)
+	tars := cfg.TarsForBranch(org, repo, branch)
 	log.Infof("Checking %s/%s/%s PRs.", org, repo, branch)
 
 	var buf bytes.Buffer
@@ -303,11 +329,16 @@ func HandleAll(log *logrus.Entry, ghc githubClient, config *plugins.Configuratio
 		}
 		org := slashSplit[0]
 		repoName := slashSplit[1]
-		tars := externalConfig.TarsFor(org, repoName)
-		fmt.Fprintf(&reposQuery, " label:\"%s\" repo:\"%s\"", tars.OnlyWhenLabel, repo)
-		for _, label := range tars.ExcludeLabels {
-			fmt.Fprintf(&reposQuery, " -label:\"%s\"", label)
+		// The labels can be overridden by the branch level configs, then the labels of each PR are
+		// checked against the config of its base branch instead.
+		if len(externalConfig.TarsBranchesFor(org, repoName)) == 0 {
+			tars := externalConfig.TarsFor(org, repoName)
+			fmt.Fprintf(&reposQuery, " label:\"%s\"", tars.OnlyWhenLabel)
+			for _, label := range tars.ExcludeLabels {
+				fmt.Fprintf(&reposQuery, " -label:\"%s\"", label)
+			}
 		}
+		fmt.Fprintf(&reposQuery, " repo:\"%s\"", repo)
 		query := reposQuery.String()
 
 		prs, err := search(context.Background(), log, ghc, query)
@@ -359,12 +390,57 @@ func HandleAll(log *logrus.Entry, ghc githubClient, config *plugins.Configuratio
 	return nil
 }
 
+// StartPeriodicUpdate checks all PRs every period, and checks them again as soon as the tars configuration
+// changes instead of waiting for the next period.
+func StartPeriodicUpdate(log *logrus.Entry, ghc githubClient, pa *plugins.ConfigAgent,
+	epa *tiexternalplugins.ConfigAgent, period time.Duration) {
+	// Avoid the periodic update and the update triggered by config changes running at the same time.
+	var handleAllMut sync.Mutex
+	handleAll := func() {
+		handleAllMut.Lock()
+		defer handleAllMut.Unlock()
+		start := time.Now()
+		if err := HandleAll(log, ghc, pa.Config(), epa.Config()); err != nil {
+			log.WithError(err).Error("Error during periodic update of all PRs.")
+		}
+		log.WithField("duration", fmt.Sprintf("%v", time.Since(start))).Info("Periodic update complete.")
+	}
+
+	interrupts.TickLiteral(handleAll, period)
+
+	deltas := make(chan tiexternalplugins.Delta)
+	interrupts.Run(func(ctx context.Context) {
+		epa.Subscribe(ctx, deltas)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case delta := <-deltas:
+				if !reflect.DeepEqual(delta.Before.TiCommunityTars, delta.After.TiCommunityTars) {
+					log.Info("The tars configuration changed, rescheduling the update of all PRs.")
+					handleAll()
+				}
+			}
+		}
+	})
+}
+
 func handle(log *logrus.Entry, ghc githubClient, pr *pullRequest, cfg *tiexternalplugins.Configuration) (bool, error) {
 	org := string(pr.Repository.Owner.Login)
 	repo := string(pr.Repository.Name)
 	number := int(pr.Number)
 	updated := false
-	tars := cfg.TarsFor(org, repo)
+	tars := cfg.TarsForBranch(org, repo, string(pr.BaseRef.Name))
+
+	var labels []string
+	for _, label := range pr.Labels.Nodes {
+		labels = append(labels, string(label.Name))
+	}
+	if !triggeredByLabels(labels, tars) {
+		log.Infof("Ignore PR %s/%s#%d without trigger label %s or with exclude labels.",
+			org, repo, number, tars.OnlyWhenLabel)
+		return false, nil
+	}
 
 	// Must have last commit.
 	if len(pr.Commits.Nodes) == 0 || len(pr.Commits.Nodes) != 1 {
@@ -389,6 +465,12 @@ func handle(log *logrus.Entry, ghc githubClient, pr *pullRequest, cfg *tiexterna
 	return true, takeAction(log, ghc, org, repo, number, string(pr.Author.Login), tars.Message)
 }
 
+// triggeredByLabels checks if the labels contain the trigger label and none of the exclude labels.
+func triggeredByLabels(labels []string, tars *tiexternalplugins.TiCommunityTars) bool {
+	labelSet := sets.NewString(labels...)
+	return labelSet.Has(tars.OnlyWhenLabel) && !labelSet.HasAny(tars.ExcludeLabels...)
+}
+
 func search(ctx context.Context, log *logrus.Entry, ghc githubClient, q string) ([]pullRequest, error) {
 	var ret []pullRequest
 	vars := map[string]interface{}{
@@ -413,6 +495,7 @@ func search(ctx context.Context, log *logrus.Entry, ghc githubClient, q string)
 		vars["searchCursor"] = githubql.NewString(sq.Search.PageInfo.EndCursor)
 	}
 	log.Infof("Search for query \"%s\" cost %d point(s). %d remaining.", q, totalCost, remaining)
+	tiexternalplugins.ObserveGraphQLRateLimit(PluginName, totalCost, remaining)
 	return ret, nil
 }
 
@@ -437,6 +520,7 @@ func takeAction(log *logrus.Entry, ghc githubClient, org, repo string, num int,
 	if err != nil {
 		return err
 	}
+	prUpdates.WithLabelValues(org, repo).Inc()
 	if needsReply {
 		// Delay the reply because we may trigger the test in the reply.
 		// See: https://github.com/ti-community-infra/tichi/issues/181.
@@ -482,6 +482,8 @@ func TestHandleAll(t *testing.T) {
 	currentBaseSHA := "0bd3ed50c88cd53a09316bf7a298f900e9371652"
 	outOfDateSHA := "0bd3ed50c88cd53a0931609dsa9d-0a9d0-as9d0"
 	triggerLabel := "trigger-update"
+	branchTriggerLabel := "branch-trigger-update"
+	excludeLabel := "exclude"
 
 	baseCommit := github.RepositoryCommit{
 		SHA: currentBaseSHA,
@@ -521,6 +523,8 @@ func TestHandleAll(t *testing.T) {
 		prCommits  []github.RepositoryCommit
 		outOfDate  bool
 		message    string
+		base       string
+		branches   map[string]externalplugins.TiCommunityTars
 
 		expectComment  bool
 		expectDeletion bool
@@ -589,6 +593,69 @@ func TestHandleAll(t *testing.T) {
 			expectComment:  true,
 			expectUpdate:   true,
 		},
+		{
+			name: "out of date with branch trigger label",
+			pr:   getPullRequest("org", "repo", 5),
+			labels: []github.Label{
+				{
+					Name: branchTriggerLabel,
+				},
+			},
+			baseCommit: baseCommit,
+			prCommits:  outOfDatePrCommits(),
+			outOfDate:  true,
+			message:    "updated",
+			base:       "release-5.0",
+			branches: map[string]externalplugins.TiCommunityTars{
+				"release-*": {
+					OnlyWhenLabel: branchTriggerLabel,
+				},
+			},
+			expectDeletion: true,
+			expectComment:  true,
+			expectUpdate:   true,
+		},
+		{
+			name: "out of date without branch trigger label",
+			pr:   getPullRequest("org", "repo", 5),
+			labels: []github.Label{
+				{
+					Name: triggerLabel,
+				},
+			},
+			baseCommit: baseCommit,
+			prCommits:  outOfDatePrCommits(),
+			outOfDate:  true,
+			message:    "updated",
+			base:       "release-5.0",
+			branches: map[string]externalplugins.TiCommunityTars{
+				"release-*": {
+					OnlyWhenLabel: branchTriggerLabel,
+				},
+			},
+		},
+		{
+			name: "out of date with branch exclude label",
+			pr:   getPullRequest("org", "repo", 5),
+			labels: []github.Label{
+				{
+					Name: triggerLabel,
+				},
+				{
+					Name: excludeLabel,
+				},
+			},
+			baseCommit: baseCommit,
+			prCommits:  outOfDatePrCommits(),
+			outOfDate:  true,
+			message:    "updated",
+			base:       "release-5.0",
+			branches: map[string]externalplugins.TiCommunityTars{
+				"release-*": {
+					ExcludeLabels: []string{excludeLabel},
+				},
+			},
+		},
 	}
 
 	oldSleep := sleep
@@ -601,6 +668,7 @@ func TestHandleAll(t *testing.T) {
 			// For now we only add one pr.
 			var prs []pullRequest
 			if tc.pr != nil {
+				tc.pr.Base.Ref = tc.base
 				prs = generatePullRequests("org", "repo", tc.pr, tc.prCommits, tc.labels)
 			}
 			fc := newFakeGithubClient(prs, tc.pr, tc.baseCommit, tc.prCommits, tc.outOfDate)
@@ -613,6 +681,7 @@ func TestHandleAll(t *testing.T) {
 					Repos:         []string{"org/repo"},
 					Message:       tc.message,
 					OnlyWhenLabel: triggerLabel,
+					Branches:      tc.branches,
 				},
 			}
 			if err := HandleAll(logrus.WithField("plugin", PluginName), fc, cfg, externalConfig); err != nil {
@@ -0,0 +1,267 @@
+package ownersclient
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+)
+
+const (
+	// pullRequestEvent is the type of the pull request events, which may change the owners.
+	pullRequestEvent = "pull_request"
+
+	defaultTimeout      = 10 * time.Second
+	defaultRetries      = 3
+	defaultRetryBackoff = time.Second
+	defaultCacheTTL     = 5 * time.Minute
+	defaultMaxStale     = time.Hour
+)
+
+// Options specifies the options of the owners client, it implements the flagutil.OptionGroup.
+type Options struct {
+	// Timeout specifies the timeout of each request to the owners endpoint.
+	Timeout time.Duration
+	// Retries specifies the number of retries on the network errors and 5xx responses.
+	Retries int
+	// RetryBackoff specifies the delay before the first retry, it is doubled for each of the later retries.
+	RetryBackoff time.Duration
+	// CacheTTL specifies how long the owners are cached, the cache is disabled if it is 0.
+	CacheTTL time.Duration
+	// MaxStale specifies how long the expired owners are served while they are revalidated in the background,
+	// e.g. when the owners endpoint is down.
+	MaxStale time.Duration
+}
+
+// AddFlags adds the flags of the owners client to the flag set.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&o.Timeout, "owners-timeout", defaultTimeout, "Timeout of each request to the owners endpoint.")
+	fs.IntVar(&o.Retries, "owners-retries", defaultRetries,
+		"Number of retries of the owners requests on the network errors and 5xx responses.")
+	fs.DurationVar(&o.RetryBackoff, "owners-retry-backoff", defaultRetryBackoff,
+		"Delay before retrying an owners request, it is doubled for each of the later retries.")
+	fs.DurationVar(&o.CacheTTL, "owners-cache-ttl", defaultCacheTTL,
+		"Time to cache the owners of a PR, the cache is disabled if it is 0.")
+	fs.DurationVar(&o.MaxStale, "owners-max-stale", defaultMaxStale,
+		"Time to serve the expired owners of a PR while they are revalidated in the background.")
+}
+
+// Validate validates the options of the owners client.
+func (o *Options) Validate(bool) error {
+	if o.Timeout <= 0 {
+		return fmt.Errorf("owners timeout must be greater than 0, got %v", o.Timeout)
+	}
+	if o.Retries < 0 {
+		return fmt.Errorf("owners retries must not less than 0, got %d", o.Retries)
+	}
+	if o.RetryBackoff <= 0 {
+		return fmt.Errorf("owners retry backoff must be greater than 0, got %v", o.RetryBackoff)
+	}
+	if o.CacheTTL < 0 {
+		return fmt.Errorf("owners cache TTL must not less than 0, got %v", o.CacheTTL)
+	}
+	if o.MaxStale < 0 {
+		return fmt.Errorf("owners max stale must not less than 0, got %v", o.MaxStale)
+	}
+	return nil
+}
+
+// NewClient creates a caching owners client which requests the owners endpoint with the transport.
+func (o *Options) NewClient(transport http.RoundTripper, log *logrus.Entry) *CachedOwnersClient {
+	return NewCachedOwnersClient(&OwnersClient{
+		Client:       &http.Client{Transport: transport, Timeout: o.Timeout},
+		Retries:      o.Retries,
+		RetryBackoff: o.RetryBackoff,
+	}, o.CacheTTL, o.MaxStale, log)
+}
+
+// pullKey identifies a PR.
+type pullKey struct {
+	org    string
+	repo   string
+	number int
+}
+
+// cacheKey identifies the owners of a PR loaded from an owners endpoint.
+type cacheKey struct {
+	pullKey
+	ownersURL string
+}
+
+type cacheEntry struct {
+	owners   Owners
+	loadedAt time.Time
+	// revalidating means the owners are being loaded in the background.
+	revalidating bool
+}
+
+// CachedOwnersClient caches the owners of the PRs loaded by the loader. The expired owners are served
+// while they are revalidated in the background, until they are too stale. The owners of a PR are
+// invalidated when its labels, base branch or commits change.
+type CachedOwnersClient struct {
+	loader   OwnersLoader
+	ttl      time.Duration
+	maxStale time.Duration
+	log      *logrus.Entry
+	now      func() time.Time
+
+	mut     sync.Mutex
+	entries map[cacheKey]*cacheEntry
+	// generation counts the invalidations, the owners loaded before an invalidation are not cached.
+	generation uint64
+	lastPrune  time.Time
+	// revalidations tracks the background revalidations.
+	revalidations sync.WaitGroup
+}
+
+// NewCachedOwnersClient creates a caching owners client, the cache is disabled if the TTL is 0.
+func NewCachedOwnersClient(loader OwnersLoader, ttl, maxStale time.Duration,
+	log *logrus.Entry) *CachedOwnersClient {
+	return &CachedOwnersClient{
+		loader:   loader,
+		ttl:      ttl,
+		maxStale: maxStale,
+		log:      log,
+		now:      time.Now,
+		entries:  make(map[cacheKey]*cacheEntry),
+	}
+}
+
+// LoadOwners returns the cached owners of the PR if they are fresh. The expired owners are returned
+// and revalidated in the background, unless they are older than the max stale time.
+func (c *CachedOwnersClient) LoadOwners(ownersURL string, org, repoName string, number int) (*Owners, error) {
+	if c.ttl == 0 {
+		return c.loader.LoadOwners(ownersURL, org, repoName, number)
+	}
+
+	key := cacheKey{pullKey: pullKey{org: org, repo: repoName, number: number}, ownersURL: ownersURL}
+	c.mut.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	if ok {
+		age := c.now().Sub(entry.loadedAt)
+		if age < c.ttl {
+			c.mut.Unlock()
+			return copyOwners(entry.owners), nil
+		}
+		if age < c.ttl+c.maxStale {
+			if !entry.revalidating {
+				entry.revalidating = true
+				c.revalidations.Add(1)
+				go c.revalidate(key, entry, generation)
+			}
+			c.mut.Unlock()
+			return copyOwners(entry.owners), nil
+		}
+	}
+	c.mut.Unlock()
+
+	owners, err := c.loader.LoadOwners(ownersURL, org, repoName, number)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, owners, generation)
+	return copyOwners(*owners), nil
+}
+
+// revalidate loads the owners in the background, the expired owners are kept if it fails.
+func (c *CachedOwnersClient) revalidate(key cacheKey, entry *cacheEntry, generation uint64) {
+	defer c.revalidations.Done()
+
+	owners, err := c.loader.LoadOwners(key.ownersURL, key.org, key.repo, key.number)
+	if err != nil {
+		c.mut.Lock()
+		entry.revalidating = false
+		c.mut.Unlock()
+		c.log.WithError(err).Warnf("Error revalidating the owners of %s/%s#%d, serving the stale owners.",
+			key.org, key.repo, key.number)
+		return
+	}
+	c.store(key, owners, generation)
+}
+
+// store caches the owners, unless any PR has been invalidated since they were requested. The owners
+// too stale to be served are removed at most once per TTL.
+func (c *CachedOwnersClient) store(key cacheKey, owners *Owners, generation uint64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	now := c.now()
+	if now.Sub(c.lastPrune) >= c.ttl {
+		for k, entry := range c.entries {
+			if now.Sub(entry.loadedAt) >= c.ttl+c.maxStale {
+				delete(c.entries, k)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if c.generation != generation {
+		return
+	}
+	c.entries[key] = &cacheEntry{owners: *copyOwners(*owners), loadedAt: now}
+}
+
+// Invalidate removes the cached owners of the PR.
+func (c *CachedOwnersClient) Invalidate(org, repo string, number int) {
+	pull := pullKey{org: org, repo: repo, number: number}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.generation++
+	for key := range c.entries {
+		if key.pullKey == pull {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// ObserveEvent invalidates the cached owners of the PR whose labels, base branch or commits are changed
+// by the webhook event.
+func (c *CachedOwnersClient) ObserveEvent(eventType string, payload []byte) {
+	if eventType != pullRequestEvent {
+		return
+	}
+
+	var pe struct {
+		Action  github.PullRequestEventAction `json:"action"`
+		Number  int                           `json:"number"`
+		Repo    github.Repo                   `json:"repository"`
+		Changes struct {
+			Base *json.RawMessage `json:"base"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(payload, &pe); err != nil {
+		return
+	}
+
+	switch pe.Action {
+	case github.PullRequestActionLabeled, github.PullRequestActionUnlabeled, github.PullRequestActionSynchronize:
+	case github.PullRequestActionEdited:
+		if pe.Changes.Base == nil {
+			return
+		}
+	default:
+		return
+	}
+	c.Invalidate(pe.Repo.Owner.Login, pe.Repo.Name, pe.Number)
+}
+
+// copyOwners returns a copy of the owners, so that the cached owners are not modified by the callers.
+func copyOwners(owners Owners) *Owners {
+	var groups []ReviewerGroup
+	for _, group := range owners.ReviewerGroups {
+		groups = append(groups, ReviewerGroup{Name: group.Name, Reviewers: append([]string(nil), group.Reviewers...)})
+	}
+	return &Owners{
+		Leaders:        append([]string(nil), owners.Leaders...),
+		Committers:     append([]string(nil), owners.Committers...),
+		Reviewers:      append([]string(nil), owners.Reviewers...),
+		NeedsLgtm:      owners.NeedsLgtm,
+		ReviewerGroups: groups,
+		Unavailable:    append([]string(nil), owners.Unavailable...),
+	}
+}
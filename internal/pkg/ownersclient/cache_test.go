@@ -0,0 +1,185 @@
+package ownersclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeOwnersLoader returns owners whose NeedsLgtm is the number of the loads.
+type fakeOwnersLoader struct {
+	mut   sync.Mutex
+	loads int
+	err   error
+}
+
+func (f *fakeOwnersLoader) LoadOwners(string, string, string, int) (*Owners, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.loads++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Owners{Reviewers: []string{"reviewer"}, NeedsLgtm: f.loads}, nil
+}
+
+func (f *fakeOwnersLoader) setErr(err error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.err = err
+}
+
+func TestCachedOwnersClient(t *testing.T) {
+	const ownersURL = "https://owners"
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	number := 1
+	labeledEvent := []byte(`{"action": "labeled", "number": 1,
+		"repository": {"name": "test-dev", "owner": {"login": "ti-community-infra"}}}`)
+
+	testcases := []struct {
+		name string
+		// prepare runs after the owners are cached at the start time.
+		prepare func(c *CachedOwnersClient, loader *fakeOwnersLoader)
+		after   time.Duration
+
+		expectNeedsLgtm int
+		expectError     bool
+		// expectCachedNeedsLgtm is the owners returned by the next load after the revalidation.
+		expectCachedNeedsLgtm int
+	}{
+		{
+			name:                  "fresh owners",
+			after:                 time.Minute,
+			expectNeedsLgtm:       1,
+			expectCachedNeedsLgtm: 1,
+		},
+		{
+			name:                  "expired owners revalidated",
+			after:                 10 * time.Minute,
+			expectNeedsLgtm:       1,
+			expectCachedNeedsLgtm: 2,
+		},
+		{
+			name: "expired owners served while the endpoint is down",
+			prepare: func(_ *CachedOwnersClient, loader *fakeOwnersLoader) {
+				loader.setErr(errors.New("connection refused"))
+			},
+			after:                 10 * time.Minute,
+			expectNeedsLgtm:       1,
+			expectCachedNeedsLgtm: 1,
+		},
+		{
+			name: "too stale owners",
+			prepare: func(_ *CachedOwnersClient, loader *fakeOwnersLoader) {
+				loader.setErr(errors.New("connection refused"))
+			},
+			after:       2 * time.Hour,
+			expectError: true,
+		},
+		{
+			name: "owners invalidated by the labeled event",
+			prepare: func(c *CachedOwnersClient, _ *fakeOwnersLoader) {
+				c.ObserveEvent("pull_request", labeledEvent)
+			},
+			after:                 time.Minute,
+			expectNeedsLgtm:       2,
+			expectCachedNeedsLgtm: 2,
+		},
+		{
+			name: "owners invalidated by the new commits",
+			prepare: func(c *CachedOwnersClient, _ *fakeOwnersLoader) {
+				c.ObserveEvent("pull_request", []byte(`{"action": "synchronize", "number": 1,
+					"repository": {"name": "test-dev", "owner": {"login": "ti-community-infra"}}}`))
+			},
+			after:                 time.Minute,
+			expectNeedsLgtm:       2,
+			expectCachedNeedsLgtm: 2,
+		},
+		{
+			name: "owners not invalidated by other events",
+			prepare: func(c *CachedOwnersClient, _ *fakeOwnersLoader) {
+				c.ObserveEvent("issues", labeledEvent)
+				c.ObserveEvent("pull_request", []byte(`{"action": "edited", "number": 1,
+					"repository": {"name": "test-dev", "owner": {"login": "ti-community-infra"}}}`))
+			},
+			after:                 time.Minute,
+			expectNeedsLgtm:       1,
+			expectCachedNeedsLgtm: 1,
+		},
+		{
+			name: "owners invalidated by the base branch change",
+			prepare: func(c *CachedOwnersClient, _ *fakeOwnersLoader) {
+				c.ObserveEvent("pull_request", []byte(`{"action": "edited", "number": 1,
+					"changes": {"base": {"ref": {"from": "master"}}},
+					"repository": {"name": "test-dev", "owner": {"login": "ti-community-infra"}}}`))
+			},
+			after:                 time.Minute,
+			expectNeedsLgtm:       2,
+			expectCachedNeedsLgtm: 2,
+		},
+	}
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			loader := &fakeOwnersLoader{}
+			c := NewCachedOwnersClient(loader, 5*time.Minute, time.Hour, logrus.WithField("client", "owners"))
+			c.now = func() time.Time { return now }
+
+			if _, err := c.LoadOwners(ownersURL, org, repoName, number); err != nil {
+				t.Fatalf("load owners failed: %v", err)
+			}
+			if tc.prepare != nil {
+				tc.prepare(c, loader)
+			}
+			now = now.Add(tc.after)
+
+			owners, err := c.LoadOwners(ownersURL, org, repoName, number)
+			if err != nil {
+				if !tc.expectError {
+					t.Errorf("unexpected error: '%v'", err)
+				}
+				return
+			}
+			if tc.expectError {
+				t.Fatalf("expected error, but it is nil")
+			}
+			if owners.NeedsLgtm != tc.expectNeedsLgtm {
+				t.Errorf("Different LGTM: Got \"%v\" expected \"%v\"", owners.NeedsLgtm, tc.expectNeedsLgtm)
+			}
+
+			c.revalidations.Wait()
+			owners, err = c.LoadOwners(ownersURL, org, repoName, number)
+			if err != nil {
+				t.Fatalf("load owners failed: %v", err)
+			}
+			c.revalidations.Wait()
+			if owners.NeedsLgtm != tc.expectCachedNeedsLgtm {
+				t.Errorf("Different cached LGTM: Got \"%v\" expected \"%v\"", owners.NeedsLgtm, tc.expectCachedNeedsLgtm)
+			}
+		})
+	}
+}
+
+func TestCachedOwnersNotModified(t *testing.T) {
+	c := NewCachedOwnersClient(&fakeOwnersLoader{}, time.Minute, time.Hour, logrus.WithField("client", "owners"))
+
+	owners, err := c.LoadOwners("https://owners", "ti-community-infra", "test-dev", 1)
+	if err != nil {
+		t.Fatalf("load owners failed: %v", err)
+	}
+	owners.Reviewers[0] = "modified"
+
+	owners, err = c.LoadOwners("https://owners", "ti-community-infra", "test-dev", 1)
+	if err != nil {
+		t.Fatalf("load owners failed: %v", err)
+	}
+	if owners.Reviewers[0] != "reviewer" {
+		t.Errorf("Different reviewers: Got \"%v\" expected \"%v\"", owners.Reviewers, []string{"reviewer"})
+	}
+}
@@ -2,15 +2,18 @@ package ownersclient
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const (
 	// OwnersURLFmt specifies a format for owners URL.
 	OwnersURLFmt = "%s/repos/%s/%s/pulls/%d/owners"
+	// maxErrorMessageLength specifies the max length of the response body included in the errors.
+	maxErrorMessageLength = 200
 )
 
 // OwnersLoader load PR's reviewers.
@@ -19,10 +22,31 @@ type OwnersLoader interface {
 		repoName string, number int) (*Owners, error)
 }
 
+// StatusError means the owners endpoint responded with a non-200 status.
+type StatusError struct {
+	// URL specifies the URL of the owners requested.
+	URL string
+	// StatusCode specifies the status code of the response.
+	StatusCode int
+	// Message specifies the message of the response, or the response body if it has no message.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("could not get owners from %s: status %d", e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("could not get owners from %s: status %d: %s", e.URL, e.StatusCode, e.Message)
+}
+
 // OwnersClient for load PR's reviewers.
 type OwnersClient struct {
 	// Client is a HTTP client to request reviewers.
 	Client *http.Client
+	// Retries specifies the number of retries on the network errors and 5xx responses.
+	Retries int
+	// RetryBackoff specifies the delay before the first retry, it is doubled for each of the later retries.
+	RetryBackoff time.Duration
 }
 
 // LoadOwners returns owners and needs
@@ -30,27 +54,49 @@ type OwnersClient struct {
 func (rc *OwnersClient) LoadOwners(ownersURL string,
 	org, repoName string, number int) (*Owners, error) {
 	url := fmt.Sprintf(OwnersURLFmt, ownersURL, org, repoName, number)
-	res, err := rc.Client.Get(url)
 
+	backoff := rc.RetryBackoff
+	for retries := 0; ; retries++ {
+		owners, retryable, err := rc.loadOwners(url)
+		if err == nil || retries >= rc.Retries || !retryable {
+			return owners, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// loadOwners requests the owners once, it also returns whether the error is retryable, which means
+// a network error or a 5xx response.
+func (rc *OwnersClient) loadOwners(url string) (*Owners, bool, error) {
+	res, err := rc.Client.Get(url)
 	if err != nil {
-		return nil, err
+		return nil, true, err
 	}
 	defer func() {
 		_ = res.Body.Close()
 	}()
 
-	if res.StatusCode != 200 {
-		return nil, errors.New("could not get a owners")
-	}
-
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, true, fmt.Errorf("could not read owners from %s: %w", url, err)
 	}
 
 	var ownersRes OwnersResponse
+	if res.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(body))
+		if err := json.Unmarshal(body, &ownersRes); err == nil && len(ownersRes.Message) != 0 {
+			message = ownersRes.Message
+		}
+		if len(message) > maxErrorMessageLength {
+			message = message[:maxErrorMessageLength] + "..."
+		}
+		return nil, res.StatusCode >= http.StatusInternalServerError,
+			&StatusError{URL: url, StatusCode: res.StatusCode, Message: message}
+	}
+
 	if err := json.Unmarshal(body, &ownersRes); err != nil {
-		return nil, err
+		return nil, false, fmt.Errorf("could not parse owners from %s: %w", url, err)
 	}
-	return &ownersRes.Data, nil
+	return &ownersRes.Data, false, nil
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 const testOwnersURLFmt = "/repos/%s/%s/pulls/%d/owners"
@@ -136,16 +137,31 @@ func TestLoadOwnersFailed(t *testing.T) {
 		name        string
 		ownersURL   string
 		invalidData bool
+		statusCode  int
+		body        string
 		expectError string
 	}{
 		{
 			name:        "get data form url failed(use mock URL)",
-			expectError: "could not get a owners",
+			statusCode:  http.StatusInternalServerError,
+			expectError: "could not get owners from %s: status 500",
+		},
+		{
+			name:        "get data with message failed(use mock URL)",
+			statusCode:  http.StatusNotFound,
+			body:        `{"message": "Pull request not found."}`,
+			expectError: "could not get owners from %s: status 404: Pull request not found.",
+		},
+		{
+			name:        "get data with body failed(use mock URL)",
+			statusCode:  http.StatusBadGateway,
+			body:        "Bad gateway\n",
+			expectError: "could not get owners from %s: status 502: Bad gateway",
 		},
 		{
 			name:        "parse data failed(use mock URL)",
 			invalidData: true,
-			expectError: "unexpected end of JSON input",
+			expectError: "could not parse owners from %s: unexpected end of JSON input",
 		},
 	}
 	org := "ti-community-infra"
@@ -178,20 +194,88 @@ func TestLoadOwnersFailed(t *testing.T) {
 					}
 				} else {
 					// Just http filed.
-					res.WriteHeader(http.StatusInternalServerError)
+					res.WriteHeader(tc.statusCode)
+					_, _ = res.Write([]byte(tc.body))
 				}
 			})
 
 			client := OwnersClient{Client: testServer.Client()}
 
 			_, err := client.LoadOwners(tc.ownersURL, org, repoName, number)
+			expectError := fmt.Sprintf(tc.expectError, tc.ownersURL+pattern)
 			if err == nil {
-				t.Errorf("expected error '%v', but it is nil", tc.expectError)
-			} else if err.Error() != tc.expectError {
-				t.Errorf("expected error '%v', but it is '%v'", tc.expectError, err)
+				t.Errorf("expected error '%v', but it is nil", expectError)
+			} else if err.Error() != expectError {
+				t.Errorf("expected error '%v', but it is '%v'", expectError, err)
 			}
 
 			testServer.Close()
 		})
 	}
 }
+
+func TestLoadOwnersRetry(t *testing.T) {
+	testcases := []struct {
+		name        string
+		statusCodes []int
+		retries     int
+
+		expectRequests int
+		expectError    bool
+	}{
+		{
+			name:           "retry on 5xx until success",
+			statusCodes:    []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK},
+			retries:        3,
+			expectRequests: 3,
+		},
+		{
+			name:           "run out of retries",
+			statusCodes:    []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			retries:        1,
+			expectRequests: 2,
+			expectError:    true,
+		},
+		{
+			name:           "no retry on 4xx",
+			statusCodes:    []int{http.StatusNotFound, http.StatusOK},
+			retries:        3,
+			expectRequests: 1,
+			expectError:    true,
+		},
+	}
+	org := "ti-community-infra"
+	repoName := "test-dev"
+	number := 1
+
+	for _, testcase := range testcases {
+		tc := testcase
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			mux := http.NewServeMux()
+			mux.HandleFunc(fmt.Sprintf(testOwnersURLFmt, org, repoName, number),
+				func(res http.ResponseWriter, req *http.Request) {
+					statusCode := tc.statusCodes[requests]
+					requests++
+					res.WriteHeader(statusCode)
+					if statusCode == http.StatusOK {
+						_ = json.NewEncoder(res).Encode(OwnersResponse{Data: Owners{NeedsLgtm: 2}})
+					}
+				})
+			testServer := httptest.NewServer(mux)
+			defer testServer.Close()
+
+			client := OwnersClient{Client: testServer.Client(), Retries: tc.retries, RetryBackoff: time.Millisecond}
+			owners, err := client.LoadOwners(testServer.URL, org, repoName, number)
+			if (err != nil) != tc.expectError {
+				t.Errorf("unexpected error: '%v'", err)
+			}
+			if err == nil && owners.NeedsLgtm != 2 {
+				t.Errorf("Different LGTM: Got \"%v\" expected \"%v\"", owners.NeedsLgtm, 2)
+			}
+			if requests != tc.expectRequests {
+				t.Errorf("Different requests: Got \"%v\" expected \"%v\"", requests, tc.expectRequests)
+			}
+		})
+	}
+}
@@ -1,14 +1,63 @@
 package ownersclient
 
+import "k8s.io/apimachinery/pkg/util/sets"
+
 // OwnersResponse specifies the response to the request to get owners.
 type OwnersResponse struct {
 	Data    Owners `json:"data,omitempty"`
 	Message string `json:"message,omitempty"`
 }
 
+// The roles of the owners, a leader is also a committer and a committer is also a reviewer.
+const (
+	RoleLeader    = "leader"
+	RoleCommitter = "committer"
+	RoleReviewer  = "reviewer"
+)
+
 // Owners contains owners and the number of lgtm required by PR.
 type Owners struct {
+	// Leaders specifies the tech leaders and co-leaders of the sigs, they are also committers.
+	Leaders    []string `json:"leaders,omitempty"`
 	Committers []string `json:"committers,omitempty"`
 	Reviewers  []string `json:"reviewers,omitempty"`
 	NeedsLgtm  int      `json:"needsLGTM,omitempty"`
+	// ReviewerGroups specifies the areas of the PR which each require an approval from their reviewers,
+	// it is empty if the approval coverage is not required.
+	ReviewerGroups []ReviewerGroup `json:"reviewerGroups,omitempty"`
+	// Unavailable specifies the reviewers who are unavailable now, they should not be requested for reviews
+	// but their approvals still count.
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// ReviewerGroup specifies the reviewers of an area of the PR, such as a sig or the changed files
+// owned by an OWNERS file.
+type ReviewerGroup struct {
+	Name      string   `json:"name"`
+	Reviewers []string `json:"reviewers,omitempty"`
+}
+
+// UncoveredGroups returns the names of the reviewer groups which are not approved by any of the approvers.
+func (o *Owners) UncoveredGroups(approvers sets.String) []string {
+	var uncovered []string
+	for _, group := range o.ReviewerGroups {
+		if !approvers.HasAny(group.Reviewers...) {
+			uncovered = append(uncovered, group.Name)
+		}
+	}
+	return uncovered
+}
+
+// HasRole returns whether the user has the role, the users of the higher roles also have the lower roles.
+func (o *Owners) HasRole(login string, role string) bool {
+	switch role {
+	case RoleLeader:
+		return sets.NewString(o.Leaders...).Has(login)
+	case RoleCommitter:
+		return sets.NewString(o.Leaders...).Insert(o.Committers...).Has(login)
+	case RoleReviewer:
+		return sets.NewString(o.Leaders...).Insert(o.Committers...).Insert(o.Reviewers...).Has(login)
+	default:
+		return false
+	}
 }